@@ -2,13 +2,25 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"image"
 	"log"
+	"math"
 	"net/http"
 
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/webrtc/v3"
 	"gitlab.lanestel.net/quangdung/go-pirtc/internal/pirtc"
 	readenv "gitlab.lanestel.net/quangdung/go-pirtc/internal/read_env"
 	"gitlab.lanestel.net/quangdung/go-pirtc/internal/unixsocket"
@@ -46,13 +58,59 @@ func main() {
 	// setting cleanup function
 	folderPaths := []string{env.VideoPath, env.ImagePath}
 
-	go utils.RunPeriodicFileCleanup(folderPaths, 24, disconnectChan)
+	// the previews of a recording go with it
+	removeFile := func(path string) error {
+		switch filepath.Ext(path) {
+		case ".webM", ".webm", ".mkv":
+			return pirtc.RemoveRecording(path)
+		}
+		return os.Remove(path)
+	}
+	go utils.RunPeriodicFileCleanup(folderPaths, 24, removeFile, disconnectChan)
 
 	// setting pirtc
 	prtc, err := pirtc.Init()
 	if err != nil {
 		panic(err)
 	}
+	if env.Overlay != "off" {
+		prtc.SetOverlay(newOverlayConfig(env))
+	}
+	prtc.SetGeometry(newGeometryConfig(env))
+	if err := prtc.LoadPrivacyMasks(env.PrivacyMaskPath); err != nil {
+		log.Printf("[privacy masks error]: %v\n", err)
+	}
+
+	if err := prtc.LoadPTZPresets(env.PtzPresetPath); err != nil {
+		log.Printf("[ptz error]: %v\n", err)
+	}
+	if err := prtc.LoadCameraControls(env.CameraControlsPath); err != nil {
+		log.Printf("[camera controls error]: %v\n", err)
+	}
+	if env.DayNight == "on" {
+		prtc.SetDayNight(newDayNightConfig(env))
+	}
+	prtc.SetAudioProcessing(newAudioProcessingConfig(env))
+	var passthrough frame.Format
+	switch env.VideoPassthrough {
+	case "h264":
+		passthrough = frame.FormatH264
+	case "mjpeg":
+		passthrough = frame.FormatMJPEG
+	case "off":
+	default:
+		log.Printf("[passthrough error]: invalid format %q\n", env.VideoPassthrough)
+	}
+	if passthrough != "" {
+		if err := prtc.SetVideoPassthrough(passthrough); err != nil {
+			log.Printf("[passthrough error]: %v\n", err)
+		}
+	}
+	if env.SoundDetection == "on" {
+		if err := prtc.SetSoundDetection(newSoundDetectionConfig(env)); err != nil {
+			log.Printf("[sound detection error]: %v\n", err)
+		}
+	}
 
 	ctx = context.WithValue(ctx, PrtcKey, prtc)
 
@@ -69,6 +127,8 @@ func main() {
 	callbacks := createCallBacks(ctx)
 	wsClient.EmitMessage("request-list-users", map[string]string{})
 	go wsClient.ListenAndServe(callbacks, disconnectChan)
+	go listenPeerEvents(ctx, disconnectChan)
+	go listenSceneEvents(ctx, disconnectChan)
 	
 	// connect to unix socket
 	var unixClient unixsocket.UnixSocketClient
@@ -76,12 +136,16 @@ func main() {
 
 	unixCallbacksMap := createUnixCallbacks(ctx)
 	go unixClient.ListenAndServe(unixCallbacksMap, disconnectChan)
+	go listenSoundEvents(ctx, unixCallbacksMap["SOUND"], disconnectChan)
 
 	for {
 		select {
 		case <-quitChan:
 			log.Println("Quitting....")
 			close(disconnectChan)
+			if err := prtc.Close(); err != nil {
+				log.Printf("[pirtc close error]: %v\n", err)
+			}
 			os.Exit(0)
 		default:
 
@@ -91,39 +155,548 @@ func main() {
 }
 
 
+// listenPeerEvents tells the backend when a viewer dropped without sending user-disconnect
+func listenPeerEvents(ctx context.Context, stopChan <-chan struct{}) {
+	env := ctx.Value(EnvKey).(*readenv.Env)
+	prtc := ctx.Value(PrtcKey).(*pirtc.PiRTC)
+	wsClient := ctx.Value(WsKey).(*ws.WS)
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case event := <-prtc.PeerEvents():
+			log.Printf("[Peer - %s]: %s %s\n", event.UUID, event.Type, event.Reason)
+			if event.Type != pirtc.PeerDropped {
+				continue
+			}
+			data := map[string]string{
+				"uuid":   event.UUID,
+				"from":   env.Uuid,
+				"reason": event.Reason,
+			}
+			if err := wsClient.EmitMessage("user-dropped", data); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// listenSceneEvents tells the backend when the scene turns to day or night
+func listenSceneEvents(ctx context.Context, stopChan <-chan struct{}) {
+	env := ctx.Value(EnvKey).(*readenv.Env)
+	prtc := ctx.Value(PrtcKey).(*pirtc.PiRTC)
+	wsClient := ctx.Value(WsKey).(*ws.WS)
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case event := <-prtc.DayNightEvents():
+			log.Printf("[Scene]: %s (luma %.0f)\n", event.Scene, event.Luma)
+			data := map[string]interface{}{
+				"uuid":  env.Uuid,
+				"scene": event.Scene,
+				"luma":  event.Luma,
+			}
+			if err := wsClient.EmitMessage("scene-changed", data); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// minAudioLevel is sent for the silence, -Inf dBFS can't be written in JSON
+const minAudioLevel = -100
+
+// listenSoundEvents records while the sound is loud, like the PIR sensor, and
+// streams the level of the microphone to the viewers
+func listenSoundEvents(ctx context.Context, actions map[string]func(string), stopChan <-chan struct{}) {
+	env := ctx.Value(EnvKey).(*readenv.Env)
+	prtc := ctx.Value(PrtcKey).(*pirtc.PiRTC)
+	wsClient := ctx.Value(WsKey).(*ws.WS)
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case event := <-prtc.SoundEvents():
+			log.Printf("[Sound]: loud %v (%.0f dBFS)\n", event.Loud, event.Level.RMS)
+			data := map[string]interface{}{
+				"uuid": env.Uuid,
+				"loud": event.Loud,
+				"rms":  math.Max(event.Level.RMS, minAudioLevel),
+				"peak": math.Max(event.Level.Peak, minAudioLevel),
+			}
+			if err := wsClient.EmitMessage("sound-event", data); err != nil {
+				log.Println(err)
+			}
+			if event.Loud {
+				actions["ok"]("")
+			} else {
+				actions["ko"]("")
+			}
+		case level := <-prtc.AudioLevels():
+			data := map[string]interface{}{
+				"uuid": env.Uuid,
+				"rms":  math.Max(level.RMS, minAudioLevel),
+				"peak": math.Max(level.Peak, minAudioLevel),
+			}
+			if err := wsClient.EmitMessage("audio-level", data); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// newSoundDetectionConfig reads the thresholds and the durations of the sound
+// detection, a bad value is logged and skipped
+func newSoundDetectionConfig(env *readenv.Env) *pirtc.SoundDetectionConfig {
+	config := &pirtc.SoundDetectionConfig{}
+	for _, threshold := range []struct {
+		value string
+		dest  *float64
+	}{
+		{env.SoundThreshold, &config.Threshold},
+		{env.SoundPeakThreshold, &config.PeakThreshold},
+	} {
+		if threshold.value == "" {
+			continue
+		}
+		level, err := strconv.ParseFloat(threshold.value, 64)
+		if err != nil {
+			log.Printf("[sound detection error]: invalid threshold %q\n", threshold.value)
+			continue
+		}
+		*threshold.dest = level
+	}
+	for _, duration := range []struct {
+		value string
+		unit  time.Duration
+		dest  *time.Duration
+	}{
+		{env.SoundMinDuration, time.Millisecond, &config.MinDuration},
+		{env.SoundHold, time.Second, &config.HoldTime},
+	} {
+		if duration.value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(duration.value)
+		if err != nil {
+			log.Printf("[sound detection error]: invalid duration %q\n", duration.value)
+			continue
+		}
+		*duration.dest = time.Duration(n) * duration.unit
+	}
+	return config
+}
+
+// newDayNightConfig reads the thresholds and the profiles of the scenes, a bad
+// value is logged and skipped
+func newDayNightConfig(env *readenv.Env) *pirtc.DayNightConfig {
+	config := &pirtc.DayNightConfig{}
+	for _, threshold := range []struct {
+		value string
+		dest  *float64
+	}{
+		{env.NightThreshold, &config.NightThreshold},
+		{env.DayThreshold, &config.DayThreshold},
+	} {
+		if threshold.value == "" {
+			continue
+		}
+		luma, err := strconv.ParseFloat(threshold.value, 64)
+		if err != nil {
+			log.Printf("[day-night error]: invalid threshold %q\n", threshold.value)
+			continue
+		}
+		*threshold.dest = luma
+	}
+	if env.DayNightHold != "" {
+		hold, err := strconv.Atoi(env.DayNightHold)
+		if err != nil {
+			log.Printf("[day-night error]: invalid hold time %q\n", env.DayNightHold)
+		} else {
+			config.HoldTime = time.Duration(hold) * time.Second
+		}
+	}
+	var err error
+	if config.Day, err = pirtc.ParseSceneProfile(env.DayProfile); err != nil {
+		log.Printf("[day-night error]: day profile: %v\n", err)
+	}
+	if config.Night, err = pirtc.ParseSceneProfile(env.NightProfile); err != nil {
+		log.Printf("[day-night error]: night profile: %v\n", err)
+	}
+	return config
+}
+
+// newPreviewOptions sizes the previews of the recordings, a bad value is logged
+// and skipped. It returns nil when the previews are off.
+func newPreviewOptions(env *readenv.Env) *pirtc.PreviewOptions {
+	if env.RecordingPreview == "off" {
+		return nil
+	}
+	opts := &pirtc.PreviewOptions{}
+	for _, setting := range []struct {
+		value string
+		dest  *int
+	}{
+		{env.PreviewWidth, &opts.Width},
+		{env.PreviewFrames, &opts.Frames},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(setting.value)
+		if err != nil {
+			log.Printf("[recording-preview error]: invalid value %q\n", setting.value)
+			continue
+		}
+		*setting.dest = n
+	}
+	return opts
+}
+
+// uploadPreviews saves the poster frame and the animated preview of a recording
+// and uploads them with the name of the video, their metadata is returned
+func uploadPreviews(env *readenv.Env, dest string) []map[string]interface{} {
+	opts := newPreviewOptions(env)
+	if opts == nil {
+		return nil
+	}
+	shots, err := pirtc.RecordingPreviews(dest, *opts)
+	if err != nil {
+		log.Printf("[recording-preview error]: %v\n", err)
+	}
+	previews := make([]map[string]interface{}, 0, len(shots))
+	for _, shot := range shots {
+		kind := "poster"
+		if shot.Format == pirtc.ImageGIF {
+			kind = "preview"
+		}
+		fields := map[string]string{
+			"camera-uuid": env.Uuid,
+			"video":       filepath.Base(dest),
+			"kind":        kind,
+			"width":       strconv.Itoa(shot.Width),
+			"height":      strconv.Itoa(shot.Height),
+		}
+		if err := utils.UploadImageWithFields(env.ApiUri+"camera/upload-image/", shot.Path, env.ApiKey, fields); err != nil {
+			log.Printf("[upload-image error]: %v\n", err)
+			continue
+		}
+		previews = append(previews, map[string]interface{}{
+			"name":    filepath.Base(shot.Path),
+			"kind":    kind,
+			"format":  shot.Format,
+			"width":   shot.Width,
+			"height":  shot.Height,
+			"size":    shot.Size,
+			"takenAt": shot.TakenAt.UnixMilli(),
+		})
+	}
+	return previews
+}
+
+// newOverlayConfig burns the timestamp, the camera name and its location in the
+// video, a bad timezone or logo is logged and skipped
+func newOverlayConfig(env *readenv.Env) *pirtc.OverlayConfig {
+	config := &pirtc.OverlayConfig{
+		TimestampLayout: "2006-01-02 15:04:05",
+	}
+	if env.TimestampFormat != "" {
+		config.TimestampLayout = env.TimestampFormat
+	}
+	if env.Timezone != "" {
+		location, err := time.LoadLocation(env.Timezone)
+		if err != nil {
+			log.Printf("[overlay error]: %v\n", err)
+		}
+		config.Location = location
+	}
+	for _, line := range []string{env.Name, env.Location} {
+		if line != "" {
+			config.Lines = append(config.Lines, line)
+		}
+	}
+	if env.LogoPath != "" {
+		logo, err := utils.LoadImage(env.LogoPath)
+		if err != nil {
+			log.Printf("[overlay error]: %v\n", err)
+		}
+		config.Logo = logo
+	}
+	return config
+}
+
+// newGeometryConfig reads the rotation, flip, crop and zoom of the camera, a bad
+// value is logged and skipped. It returns nil when the frames are untouched.
+func newGeometryConfig(env *readenv.Env) *pirtc.GeometryConfig {
+	config := pirtc.GeometryConfig{}
+	if env.Rotation != "" {
+		rotation, err := strconv.Atoi(env.Rotation)
+		if err != nil || rotation%90 != 0 {
+			log.Printf("[geometry error]: invalid rotation %q\n", env.Rotation)
+		} else {
+			config.Rotation = video.Rotation(rotation)
+		}
+	}
+	switch env.Flip {
+	case "":
+	case "horizontal":
+		config.FlipHorizontal = true
+	case "vertical":
+		config.FlipVertical = true
+	case "both":
+		config.FlipHorizontal, config.FlipVertical = true, true
+	default:
+		log.Printf("[geometry error]: invalid flip %q\n", env.Flip)
+	}
+	if env.Crop != "" {
+		var x, y, w, h int
+		if _, err := fmt.Sscanf(env.Crop, "%d,%d,%d,%d", &x, &y, &w, &h); err != nil || w <= 0 || h <= 0 {
+			log.Printf("[geometry error]: invalid crop %q\n", env.Crop)
+		} else {
+			config.Crop = image.Rect(x, y, x+w, y+h)
+		}
+	}
+	if env.Zoom != "" {
+		zoom, err := strconv.ParseFloat(env.Zoom, 64)
+		if err != nil {
+			log.Printf("[geometry error]: invalid zoom %q\n", env.Zoom)
+		}
+		config.Zoom = zoom
+	}
+	if config == (pirtc.GeometryConfig{}) {
+		return nil
+	}
+	return &config
+}
+
+// newAudioProcessingConfig reads the processing of the microphone, a bad value
+// is logged and skipped. It returns nil when the sound is untouched.
+func newAudioProcessingConfig(env *readenv.Env) *pirtc.AudioProcessingConfig {
+	config := pirtc.AudioProcessingConfig{AutomaticGain: env.AudioGain == "on"}
+	for _, setting := range []struct {
+		value string
+		dest  *float64
+	}{
+		{env.AudioHighPass, &config.HighPass},
+		{env.AudioNoiseSuppression, &config.NoiseSuppression},
+		{env.AudioNoiseGate, &config.NoiseGate},
+		{env.AudioGainTarget, &config.GainTarget},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(setting.value, 64)
+		if err != nil {
+			log.Printf("[audio processing error]: invalid value %q\n", setting.value)
+			continue
+		}
+		*setting.dest = v
+	}
+	if config == (pirtc.AudioProcessingConfig{}) {
+		return nil
+	}
+	return &config
+}
+
+func emitVideoMode(wsClient *ws.WS, uuid string, to interface{}, mode pirtc.VideoMode) {
+	if wsClient == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"uuid":   uuid,
+		"to":     to,
+		"width":  mode.Width,
+		"height": mode.Height,
+		"fps":    mode.FrameRate,
+	}
+	if err := wsClient.EmitMessage("video-mode", data); err != nil {
+		log.Println(err)
+	}
+}
+
+func emitDayNight(wsClient *ws.WS, uuid string, to interface{}, config *pirtc.DayNightConfig, scene string) {
+	if wsClient == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"uuid":    uuid,
+		"to":      to,
+		"enabled": config != nil,
+		"scene":   scene,
+	}
+	if config != nil {
+		data["nightThreshold"] = config.NightThreshold
+		data["dayThreshold"] = config.DayThreshold
+		data["holdTime"] = config.HoldTime.Seconds()
+		data["day"] = config.Day
+		data["night"] = config.Night
+	}
+	if err := wsClient.EmitMessage("day-night", data); err != nil {
+		log.Println(err)
+	}
+}
+
+func emitSoundDetection(wsClient *ws.WS, uuid string, to interface{}, config *pirtc.SoundDetectionConfig) {
+	if wsClient == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"uuid":    uuid,
+		"to":      to,
+		"enabled": config != nil,
+	}
+	if config != nil {
+		data["threshold"] = config.Threshold
+		data["peakThreshold"] = config.PeakThreshold
+		data["minDuration"] = config.MinDuration.Milliseconds()
+		data["holdTime"] = config.HoldTime.Seconds()
+	}
+	if err := wsClient.EmitMessage("sound-detection", data); err != nil {
+		log.Println(err)
+	}
+}
+
+func emitCameraControls(wsClient *ws.WS, uuid string, to interface{}, controls []driver.Control) {
+	if wsClient == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"uuid":     uuid,
+		"to":       to,
+		"controls": controls,
+	}
+	if err := wsClient.EmitMessage("camera-controls", data); err != nil {
+		log.Println(err)
+	}
+}
+
+func emitPTZState(wsClient *ws.WS, uuid string, to interface{}, state pirtc.PTZState) {
+	if wsClient == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"uuid":    uuid,
+		"to":      to,
+		"region":  state.Region,
+		"target":  state.Target,
+		"presets": state.Presets,
+	}
+	if err := wsClient.EmitMessage("ptz-state", data); err != nil {
+		log.Println(err)
+	}
+}
+
+func emitPrivacyMasks(wsClient *ws.WS, uuid string, to interface{}, masks []pirtc.PrivacyMask) {
+	if wsClient == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"uuid":  uuid,
+		"to":    to,
+		"masks": masks,
+	}
+	if err := wsClient.EmitMessage("privacy-masks", data); err != nil {
+		log.Println(err)
+	}
+}
+
+func parseShotOptions(payload map[string]interface{}) pirtc.ShotOptions {
+	opts := pirtc.ShotOptions{WarmupFrames: 1}
+	if format, ok := payload["format"].(string); ok {
+		opts.Format = pirtc.ImageFormat(format)
+	}
+	if quality, ok := payload["quality"].(float64); ok {
+		opts.Quality = int(quality)
+	}
+	if width, ok := payload["width"].(float64); ok {
+		opts.Width = int(width)
+	}
+	if height, ok := payload["height"].(float64); ok {
+		opts.Height = int(height)
+	}
+	if timestamp, ok := payload["timestamp"].(bool); ok {
+		opts.Timestamp = timestamp
+	}
+	if count, ok := payload["count"].(float64); ok {
+		opts.Count = int(count)
+	}
+	if interval, ok := payload["interval"].(float64); ok {
+		opts.Interval = time.Duration(interval) * time.Millisecond
+	}
+	return opts
+}
+
 func createUnixCallbacks(ctx context.Context) map[string]map[string]func(string){
 	env := ctx.Value(EnvKey).(*readenv.Env)
 
 	prtc := ctx.Value(PrtcKey).(*pirtc.PiRTC)
 	var stopRecordChan chan struct{}
+	var recordDoneChan chan struct{}
 	var isRecording  bool = false
 	var dest string 
+	// the recording runs while one of its triggers, the PIR sensor or a loud
+	// sound, is on. The sound events come from another goroutine.
+	var recordMu sync.Mutex
+	triggers := make(map[string]bool)
+	startRecording := func(trigger string) {
+		recordMu.Lock()
+		defer recordMu.Unlock()
+		triggers[trigger] = true
+		if prtc!=nil && !isRecording {
+				stopRecordChan = make(chan struct{})
+				recordDoneChan = make(chan struct{})
+				dest = env.VideoPath + "/" + utils.GetCurrentTimeStr() + prtc.VideoExtension()
+				go func(dest string, stopChan, doneChan chan struct{}) {
+					prtc.Record(dest, stopChan)
+					close(doneChan)
+				}(dest, stopRecordChan, recordDoneChan)
+				isRecording = true
+
+		}
+	}
+	stopRecording := func(trigger string) {
+		recordMu.Lock()
+		defer recordMu.Unlock()
+		delete(triggers, trigger)
+		if prtc!=nil && isRecording && len(triggers) == 0 {
+			close(stopRecordChan)
+			<-recordDoneChan
+			log.Printf("Video saved in: %v \n", dest)
+			err := utils.UploadVideo(env.ApiUri+"camera/upload-video/", dest, env.Uuid, env.ApiKey)
+			if err != nil {
+				panic(err)
+			}
+			log.Printf("Video %s uploaded", dest)
+			// decoding the previews takes a while, the next recording mustn't wait
+			go uploadPreviews(env, dest)
+			dest = ""
+			isRecording = false
+
+		}
+	}
 	actionMap := map[string]map[string]func(string){
 		"PIR":{
 			"ok":func(param string){
 				log.Println("something moved")
-				if prtc!=nil && !isRecording {
-						stopRecordChan = make(chan struct{})
-						dest = env.VideoPath + "/" + utils.GetCurrentTimeStr() + ".webM"
-						go prtc.Record(dest, stopRecordChan)
-						isRecording = true
-
-				}
+				startRecording("PIR")
 			},
 			"ko":func(param string){
 				log.Println("unmoved")
-				if prtc!=nil && isRecording{
-					close(stopRecordChan)
-					log.Printf("Video saved in: %v \n", dest)
-					err := utils.UploadVideo(env.ApiUri+"camera/upload-video/", dest, env.Uuid, env.ApiKey)
-					if err != nil {
-						panic(err)
-					}
-					log.Printf("Video %s uploaded", dest)
-					dest = ""
-					isRecording = false
-
-				}
+				stopRecording("PIR")
+			},
+		},
+		"SOUND":{
+			"ok":func(param string){
+				log.Println("loud sound")
+				startRecording("SOUND")
+			},
+			"ko":func(param string){
+				log.Println("silence")
+				stopRecording("SOUND")
 			},
 		},
 	}
@@ -137,8 +710,26 @@ func createCallBacks(ctx context.Context) map[string]func(interface{}) {
 
 	callbacks := make(map[string]func(interface{}))
 
+	// the recording maps are also read by the stop-record goroutines
+	var recordMu sync.Mutex
 	videoPathMap:= make(map[string]string)
 	stopRecordChans:= make(map[string]chan struct{})
+	// recordDoneChans are closed once the recordings are complete
+	recordDoneChans := make(map[string]chan struct{})
+	// takeRecording removes the recording of a viewer from the maps
+	takeRecording := func(uuid string) (chan struct{}, chan struct{}, string, bool) {
+		recordMu.Lock()
+		defer recordMu.Unlock()
+		stopChan, exist := stopRecordChans[uuid]
+		if !exist {
+			return nil, nil, "", false
+		}
+		doneChan, dest := recordDoneChans[uuid], videoPathMap[uuid]
+		delete(stopRecordChans, uuid)
+		delete(recordDoneChans, uuid)
+		delete(videoPathMap, uuid)
+		return stopChan, doneChan, dest, true
+	}
 
 
 	callbacks["user-connect"] = func(data interface{}) {
@@ -147,7 +738,7 @@ func createCallBacks(ctx context.Context) map[string]func(interface{}) {
 		if err != nil {
 			log.Printf("[user-connect error]: %v\n", err)
 		}
-		log.Println(prtc.Connections)
+		log.Println(prtc.Peers())
 		// log.Println(data)
 		// pr
 	}
@@ -159,16 +750,15 @@ func createCallBacks(ctx context.Context) map[string]func(interface{}) {
 			log.Printf("[user-connect error]: %v\n", err)
 		}
 		log.Printf("User %s disconnected",uuid)
-		if stopChan, exist := stopRecordChans[uuid]; exist{
+		if stopChan, doneChan, dest, exist := takeRecording(uuid); exist{
 			close(stopChan)
-			delete(stopRecordChans,uuid)
-			dest := videoPathMap[uuid]
+			<-doneChan
 			log.Printf("Video saved in: %v \n", dest)
 			err := utils.UploadVideo(env.ApiUri+"camera/upload-video/", dest, env.Uuid, env.ApiKey)
 			if err != nil {
 				panic(err)
 			}
-			delete(videoPathMap, uuid)
+			go uploadPreviews(env, dest)
 		}
 
 
@@ -191,7 +781,18 @@ func createCallBacks(ctx context.Context) map[string]func(interface{}) {
 			offerSd := pirtc.CreateSessionDescription(payload["type"].(string), payload["sdp"].(string))
 			answerSd, err := prtc.Answer(payload["from"].(string), offerSd)
 			if err != nil {
-				panic(err)
+				log.Printf("[offer-sd error]: %v\n", err)
+				if wsClient != nil {
+					data := map[string]string{
+						"uuid":  env.Uuid,
+						"to":    payload["from"].(string),
+						"error": err.Error(),
+					}
+					if err := wsClient.EmitMessage("answer-error", data); err != nil {
+						log.Println(err)
+					}
+				}
+				return
 			}
 			log.Println(env.Uuid)
 
@@ -211,19 +812,97 @@ func createCallBacks(ctx context.Context) map[string]func(interface{}) {
 		}
 	}
 
+	// offers made by the camera (renegotiation, ICE restart) are answered with camera-answer-sd
+	prtc.OnOffer(func(uuid string, offerSd webrtc.SessionDescription) {
+		data := map[string]string{
+			"uuid": env.Uuid,
+			"to":   uuid,
+			"type": offerSd.Type.String(),
+			"sdp":  offerSd.SDP,
+		}
+		if err := wsClient.EmitMessage("camera-offer-sd", data); err != nil {
+			log.Println(err)
+		}
+	})
+
+	// viewers which cannot make an offer (embedded displays, SFUs) ask the camera for one
+	callbacks["request-offer"] = func(data interface{}) {
+		if prtc != nil {
+			from := data.(map[string]interface{})["from"].(string)
+			go func() {
+				if err := prtc.Offer(from); err != nil {
+					log.Printf("[request-offer error]: %v\n", err)
+				}
+			}()
+		}
+	}
+
+	callbacks["camera-answer-sd"] = func(data interface{}) {
+		if prtc != nil {
+			payload := data.(map[string]interface{})
+			answerSd := pirtc.CreateSessionDescription(payload["type"].(string), payload["sdp"].(string))
+			if err := prtc.ApplyAnswer(payload["from"].(string), answerSd); err != nil {
+				log.Printf("[camera-answer-sd error]: %v\n", err)
+			}
+		}
+	}
+
 	callbacks["ice-candidate"] = func(data interface{}) {}
 
+	callbacks["enable-audio"] = func(data interface{}) {
+		if prtc != nil {
+			go func() {
+				if err := prtc.EnableAudio(); err != nil {
+					log.Printf("[enable-audio error]: %v\n", err)
+				}
+			}()
+		}
+	}
+
+	callbacks["disable-audio"] = func(data interface{}) {
+		if prtc != nil {
+			go func() {
+				if err := prtc.DisableAudio(); err != nil {
+					log.Printf("[disable-audio error]: %v\n", err)
+				}
+			}()
+		}
+	}
+
+	// take-image accepts optional format, quality, width, height, timestamp, count and
+	// interval (ms) fields, the metadata of every shot is sent back to the requester
 	callbacks["take-image"] = func(data interface{}){
 		log.Println("Take Image Event")
 		if prtc!=nil{
+			payload, _ := data.(map[string]interface{})
 			dest := env.ImagePath+ "/" +utils.GetCurrentTimeStr()
-			if err := prtc.TakeShot(dest); err != nil {
-				panic(err)
-			}
 			go func(){
-				err := utils.UploadImage(env.ApiUri+"camera/upload-image/", dest+".jpeg", env.ApiKey)
+				shots, err := prtc.TakeShots(dest, parseShotOptions(payload))
 				if err != nil {
-					panic(err)
+					log.Printf("[take-image error]: %v\n", err)
+				}
+				for _, shot := range shots {
+					if err := utils.UploadImage(env.ApiUri+"camera/upload-image/", shot.Path, env.ApiKey); err != nil {
+						log.Printf("[upload-image error]: %v\n", err)
+						continue
+					}
+					from, ok := payload["from"].(string)
+					if !ok || wsClient == nil {
+						continue
+					}
+					data := map[string]interface{}{
+						"uuid":    env.Uuid,
+						"to":      from,
+						"name":    filepath.Base(shot.Path),
+						"format":  shot.Format,
+						"width":   shot.Width,
+						"height":  shot.Height,
+						"size":    shot.Size,
+						"takenAt": shot.TakenAt.UnixMilli(),
+					}
+					if err := wsClient.EmitMessage("image-captured", data); err != nil {
+						log.Println(err)
+					}
 				}
 			}()
 		}
@@ -232,6 +911,8 @@ func createCallBacks(ctx context.Context) map[string]func(interface{}) {
 	callbacks["start-record"] = func(data interface{}){
 		if prtc!=nil && wsClient !=nil {
 			from:= data.(map[string]interface{})["from"].(string)
+			recordMu.Lock()
+			defer recordMu.Unlock()
 			if _, exists:= stopRecordChans[from]; exists{
 				data:= map[string]string{
 					"uuid":from,
@@ -240,9 +921,14 @@ func createCallBacks(ctx context.Context) map[string]func(interface{}) {
 			}else{
 				stopChan:= make(chan struct{})
 				stopRecordChans[from]=stopChan
-				dest := env.VideoPath + "/" + utils.GetCurrentTimeStr() + ".webM"
+				dest := env.VideoPath + "/" + utils.GetCurrentTimeStr() + prtc.VideoExtension()
 				videoPathMap[from]= dest
-				go prtc.Record(dest, stopChan)
+				doneChan := make(chan struct{})
+				recordDoneChans[from] = doneChan
+				go func() {
+					prtc.Record(dest, stopChan)
+					close(doneChan)
+				}()
 			}
 		}
 	}
@@ -250,26 +936,340 @@ func createCallBacks(ctx context.Context) map[string]func(interface{}) {
 	callbacks["stop-record"] = func(data interface{}){
 		from:= data.(map[string]interface{})["from"].(string)
 		go func(){
-			if _,exists := stopRecordChans[from];exists{
-				close(stopRecordChans[from])
-				delete(stopRecordChans, from)
+			if stopChan, doneChan, dest, exists := takeRecording(from); exists{
+				close(stopChan)
+				<-doneChan
 	
-				dest := videoPathMap[from]
 				log.Printf("Video saved in: %v \n", dest)
 				err := utils.UploadVideo(env.ApiUri+"camera/upload-video/", dest, env.Uuid, env.ApiKey)
 				if err != nil {
 					panic(err)
 				}
-				delete(videoPathMap, from)
-				data:=map[string]string{
+				previews := uploadPreviews(env, dest)
+				data:=map[string]interface{}{
 					"to":from,
 					"from":env.Uuid,
+					"previews":previews,
 				}
 				wsClient.EmitMessage("video-recorded",data)
 			}
 		}()
 	}
 
+	// masks are sent as {"masks": [{"points": [[x, y], ...], "style": "fill"|"pixelate", "blockSize": n}]}
+	// with coordinates relative to the frame size, the current masks are sent back to the requester
+	callbacks["set-privacy-masks"] = func(data interface{}){
+		if prtc == nil {
+			return
+		}
+		payload := data.(map[string]interface{})
+		var masks []pirtc.PrivacyMask
+		raw, err := json.Marshal(payload["masks"])
+		if err == nil {
+			err = json.Unmarshal(raw, &masks)
+		}
+		if err == nil {
+			err = prtc.SetPrivacyMasks(masks)
+		}
+		if err != nil {
+			log.Printf("[set-privacy-masks error]: %v\n", err)
+		}
+		emitPrivacyMasks(wsClient, env.Uuid, payload["from"], prtc.PrivacyMasks())
+	}
+
+	callbacks["get-privacy-masks"] = func(data interface{}){
+		if prtc != nil {
+			emitPrivacyMasks(wsClient, env.Uuid, data.(map[string]interface{})["from"], prtc.PrivacyMasks())
+		}
+	}
+
+	// commands are sent as {"action": "move"|"save"|"recall"|"delete"|"get", "region": {x, y, width, height},
+	// "pan", "tilt", "zoom", "preset", "duration"}, the view and the presets are sent back to the requester
+	callbacks["ptz"] = func(data interface{}){
+		if prtc == nil {
+			return
+		}
+		payload := data.(map[string]interface{})
+		var command pirtc.PTZCommand
+		raw, err := json.Marshal(payload)
+		if err == nil {
+			err = json.Unmarshal(raw, &command)
+		}
+		if err == nil {
+			_, err = prtc.HandlePTZCommand(command)
+		}
+		if err != nil {
+			log.Printf("[ptz error]: %v\n", err)
+		}
+		emitPTZState(wsClient, env.Uuid, payload["from"], prtc.PTZState())
+	}
+
+	// a single time-lapse runs on the camera, it is shared by every viewer
+	var stopTimelapseChan chan struct{}
+	callbacks["start-timelapse"] = func(data interface{}){
+		if prtc == nil {
+			return
+		}
+		if stopTimelapseChan != nil {
+			log.Println("Time-lapse already running")
+			return
+		}
+		payload, _ := data.(map[string]interface{})
+		opts := pirtc.TimelapseOptions{Dir: env.VideoPath + "/timelapse"}
+		if format, ok := payload["format"].(string); ok {
+			opts.Format = pirtc.TimelapseFormat(format)
+		}
+		if interval, ok := payload["interval"].(float64); ok {
+			opts.Interval = time.Duration(interval) * time.Second
+		}
+		if fps, ok := payload["fps"].(float64); ok {
+			opts.PlaybackFPS = int(fps)
+		}
+		if quality, ok := payload["quality"].(float64); ok {
+			opts.Quality = int(quality)
+		}
+		if width, ok := payload["width"].(float64); ok {
+			opts.Width = int(width)
+		}
+		if height, ok := payload["height"].(float64); ok {
+			opts.Height = int(height)
+		}
+		if timestamp, ok := payload["timestamp"].(bool); ok {
+			opts.Timestamp = timestamp
+		}
+
+		stopChan := make(chan struct{})
+		segments, err := prtc.RecordTimelapse(opts, stopChan)
+		if err != nil {
+			log.Printf("[start-timelapse error]: %v\n", err)
+			return
+		}
+		stopTimelapseChan = stopChan
+		go func(){
+			for dest := range segments {
+				if err := utils.UploadVideo(env.ApiUri+"camera/upload-video/", dest, env.Uuid, env.ApiKey); err != nil {
+					log.Printf("[upload-video error]: %v\n", err)
+					continue
+				}
+				log.Printf("Time-lapse %s uploaded", dest)
+			}
+		}()
+	}
+
+	callbacks["stop-timelapse"] = func(data interface{}){
+		if stopTimelapseChan != nil {
+			close(stopTimelapseChan)
+			stopTimelapseChan = nil
+		}
+	}
+
+	// the mode is sent as {"width": w, "height": h, "fps": f}, the viewers stay connected and the
+	// resulting mode is sent back to the requester
+	callbacks["set-video-mode"] = func(data interface{}){
+		if prtc == nil {
+			return
+		}
+		payload := data.(map[string]interface{})
+		mode := prtc.VideoMode()
+		if width, ok := payload["width"].(float64); ok {
+			mode.Width = int(width)
+		}
+		if height, ok := payload["height"].(float64); ok {
+			mode.Height = int(height)
+		}
+		if fps, ok := payload["fps"].(float64); ok {
+			mode.FrameRate = float32(fps)
+		}
+		// the camera is reopened off the websocket loop
+		go func(){
+			if err := prtc.SetVideoMode(mode); err != nil {
+				log.Printf("[set-video-mode error]: %v\n", err)
+			}
+			emitVideoMode(wsClient, env.Uuid, payload["from"], prtc.VideoMode())
+		}()
+	}
+
+	// viewers report the bandwidth they receive as {"bitrate": bit/s}, the video mode follows the
+	// slowest one
+	callbacks["bandwidth-estimate"] = func(data interface{}){
+		if prtc == nil {
+			return
+		}
+		payload := data.(map[string]interface{})
+		bitRate, ok := payload["bitrate"].(float64)
+		if !ok {
+			return
+		}
+		from, _ := payload["from"].(string)
+		go func(){
+			if err := prtc.AdaptToBandwidth(from, int(bitRate)); err != nil {
+				log.Printf("[bandwidth-estimate error]: %v\n", err)
+			}
+		}()
+	}
+
+	// the profiles are sent as {"enabled": bool, "nightThreshold": luma, "dayThreshold": luma,
+	// "holdTime": seconds, "day": {"fps", "bitrate", "grayscale", "denoise"}, "night": {...}}, the
+	// missing fields are kept and the result is saved and sent back to the requester
+	callbacks["set-day-night"] = func(data interface{}){
+		if prtc == nil {
+			return
+		}
+		payload := data.(map[string]interface{})
+		config, _ := prtc.DayNight()
+		if config == nil {
+			config = newDayNightConfig(env)
+		}
+		enabled := env.DayNight == "on"
+		if value, ok := payload["enabled"].(bool); ok {
+			enabled = value
+		}
+		if value, ok := payload["nightThreshold"].(float64); ok {
+			config.NightThreshold = value
+		}
+		if value, ok := payload["dayThreshold"].(float64); ok {
+			config.DayThreshold = value
+		}
+		if value, ok := payload["holdTime"].(float64); ok {
+			config.HoldTime = time.Duration(value * float64(time.Second))
+		}
+		for key, profile := range map[string]*pirtc.SceneProfile{"day": &config.Day, "night": &config.Night} {
+			raw, ok := payload[key]
+			if !ok {
+				continue
+			}
+			var update pirtc.SceneProfile
+			encoded, err := json.Marshal(raw)
+			if err == nil {
+				err = json.Unmarshal(encoded, &update)
+			}
+			if err == nil {
+				err = update.Validate()
+			}
+			if err != nil {
+				log.Printf("[set-day-night error]: invalid %s profile: %v\n", key, err)
+				continue
+			}
+			*profile = update
+		}
+
+		if enabled {
+			prtc.SetDayNight(config)
+			env.DayNight = "on"
+		} else {
+			prtc.SetDayNight(nil)
+			env.DayNight = "off"
+		}
+		env.NightThreshold = strconv.FormatFloat(config.NightThreshold, 'g', -1, 64)
+		env.DayThreshold = strconv.FormatFloat(config.DayThreshold, 'g', -1, 64)
+		env.DayNightHold = strconv.Itoa(int(config.HoldTime / time.Second))
+		env.DayProfile = config.Day.String()
+		env.NightProfile = config.Night.String()
+		if err := env.Save(); err != nil {
+			log.Printf("[set-day-night error]: %v\n", err)
+		}
+		current, scene := prtc.DayNight()
+		emitDayNight(wsClient, env.Uuid, payload["from"], current, scene)
+	}
+
+	// the detection is sent as {"enabled": true, "threshold": -30, "peakThreshold": -6,
+	// "minDuration": ms, "holdTime": s}, the levels are in dBFS. It is saved and sent back.
+	callbacks["set-sound-detection"] = func(data interface{}){
+		if prtc == nil {
+			return
+		}
+		payload := data.(map[string]interface{})
+		config := prtc.SoundDetection()
+		if config == nil {
+			config = newSoundDetectionConfig(env)
+		}
+		enabled := env.SoundDetection == "on"
+		if value, ok := payload["enabled"].(bool); ok {
+			enabled = value
+		}
+		if value, ok := payload["threshold"].(float64); ok {
+			config.Threshold = value
+		}
+		if value, ok := payload["peakThreshold"].(float64); ok {
+			config.PeakThreshold = value
+		}
+		if value, ok := payload["minDuration"].(float64); ok {
+			config.MinDuration = time.Duration(value * float64(time.Millisecond))
+		}
+		if value, ok := payload["holdTime"].(float64); ok {
+			config.HoldTime = time.Duration(value * float64(time.Second))
+		}
+
+		var err error
+		if enabled {
+			err = prtc.SetSoundDetection(config)
+			env.SoundDetection = "on"
+		} else {
+			err = prtc.SetSoundDetection(nil)
+			env.SoundDetection = "off"
+		}
+		if err != nil {
+			log.Printf("[set-sound-detection error]: %v\n", err)
+			env.SoundDetection = "off"
+		}
+		env.SoundThreshold = strconv.FormatFloat(config.Threshold, 'g', -1, 64)
+		env.SoundPeakThreshold = strconv.FormatFloat(config.PeakThreshold, 'g', -1, 64)
+		env.SoundMinDuration = strconv.Itoa(int(config.MinDuration / time.Millisecond))
+		env.SoundHold = strconv.Itoa(int(config.HoldTime / time.Second))
+		if err := env.Save(); err != nil {
+			log.Printf("[set-sound-detection error]: %v\n", err)
+		}
+		emitSoundDetection(wsClient, env.Uuid, payload["from"], prtc.SoundDetection())
+	}
+
+	callbacks["get-sound-detection"] = func(data interface{}){
+		if prtc != nil {
+			emitSoundDetection(wsClient, env.Uuid, data.(map[string]interface{})["from"], prtc.SoundDetection())
+		}
+	}
+
+	// the controls are sent as {"controls": {"brightness": 10, "exposure_auto": 1, ...}}, they are
+	// saved and the controls of the camera are sent back to the requester
+	callbacks["set-camera-controls"] = func(data interface{}){
+		if prtc == nil {
+			return
+		}
+		payload := data.(map[string]interface{})
+		values, _ := payload["controls"].(map[string]interface{})
+		controls := make(map[driver.ControlID]int32)
+		for id, value := range values {
+			if number, ok := value.(float64); ok {
+				controls[driver.ControlID(id)] = int32(number)
+			}
+		}
+		if err := prtc.SetCameraControls(controls); err != nil {
+			log.Printf("[set-camera-controls error]: %v\n", err)
+		}
+		current, err := prtc.CameraControls()
+		if err != nil {
+			log.Printf("[set-camera-controls error]: %v\n", err)
+		}
+		emitCameraControls(wsClient, env.Uuid, payload["from"], current)
+	}
+
+	callbacks["get-camera-controls"] = func(data interface{}){
+		if prtc == nil {
+			return
+		}
+		controls, err := prtc.CameraControls()
+		if err != nil {
+			log.Printf("[get-camera-controls error]: %v\n", err)
+		}
+		emitCameraControls(wsClient, env.Uuid, data.(map[string]interface{})["from"], controls)
+	}
+
+	callbacks["get-day-night"] = func(data interface{}){
+		if prtc != nil {
+			config, scene := prtc.DayNight()
+			emitDayNight(wsClient, env.Uuid, data.(map[string]interface{})["from"], config, scene)
+		}
+	}
+
 	return callbacks
 }
 
@@ -2,16 +2,20 @@ module gitlab.lanestel.net/quangdung/go-pirtc
 
 go 1.21.4
 
-replace github.com/pion/mediadevices v0.0.0 => ./internal/mediadevices/
+replace github.com/pion/mediadevices => ./internal/mediadevices/
 
 require (
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.1
+	github.com/pion/rtp v1.8.6
+	github.com/pion/webrtc/v4 v4.0.0-beta.19
+	golang.org/x/image v0.15.0
 )
 
 require (
 	github.com/blackjack/webcam v0.5.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gen2brain/malgo v0.11.21 // indirect
 	github.com/pion/datachannel v1.5.6 // indirect
 	github.com/pion/dtls/v2 v2.2.10 // indirect
 	github.com/pion/ice/v2 v2.3.24 // indirect
@@ -22,7 +26,6 @@ require (
 	github.com/pion/mdns/v2 v2.0.7 // indirect
 	github.com/pion/randutil v0.1.0 // indirect
 	github.com/pion/rtcp v1.2.14 // indirect
-	github.com/pion/rtp v1.8.6 // indirect
 	github.com/pion/sctp v1.8.16 // indirect
 	github.com/pion/sdp/v3 v3.0.9 // indirect
 	github.com/pion/srtp/v2 v2.0.18 // indirect
@@ -33,12 +36,11 @@ require (
 	github.com/pion/transport/v3 v3.0.2 // indirect
 	github.com/pion/turn/v2 v2.1.3 // indirect
 	github.com/pion/turn/v3 v3.0.3 // indirect
-	github.com/pion/webrtc/v4 v4.0.0-beta.19 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/testify v1.9.0 // indirect
 	golang.org/x/crypto v0.23.0 // indirect
-	golang.org/x/image v0.15.0 // indirect
 	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
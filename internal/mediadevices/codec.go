@@ -17,6 +17,8 @@ import (
 type CodecSelector struct {
 	videoEncoders []codec.VideoEncoderBuilder
 	audioEncoders []codec.AudioEncoderBuilder
+	// passthroughEncoders are tried before videoEncoders, see WithPassthrough
+	passthroughEncoders []*passthroughEncoderBuilder
 }
 
 // CodecSelectorOption is a type for specifying CodecSelector options
@@ -49,7 +51,20 @@ func NewCodecSelector(opts ...CodecSelectorOption) *CodecSelector {
 
 // Populate lets the webrtc engine be aware of supported codecs that are contained in CodecSelector
 func (selector *CodecSelector) Populate(setting *webrtc.MediaEngine) {
+	// the passed through codecs come first so that the peers prefer them
+	registered := make(map[string]bool)
+	for _, encoder := range selector.passthroughEncoders {
+		if encoder.RTPCodec().Payloader == nil {
+			continue
+		}
+		setting.RegisterCodec(encoder.RTPCodec().RTPCodecParameters, webrtc.RTPCodecTypeVideo)
+		registered[strings.ToLower(encoder.RTPCodec().MimeType)] = true
+	}
+
 	for _, encoder := range selector.videoEncoders {
+		if registered[strings.ToLower(encoder.RTPCodec().MimeType)] {
+			continue
+		}
 		setting.RegisterCodec(encoder.RTPCodec().RTPCodecParameters, webrtc.RTPCodecTypeVideo)
 	}
 
@@ -65,20 +80,42 @@ func (selector *CodecSelector) selectVideoCodecByNames(reader video.Reader, inpu
 	var errReasons []string
 	var err error
 
+	// The passed through codecs are preferred to the codecs encoding the frames again, whatever
+	// their order in codecNames
+	passthroughEncoders := make([]codec.VideoEncoderBuilder, len(selector.passthroughEncoders))
+	for i, encoder := range selector.passthroughEncoders {
+		passthroughEncoders[i] = encoder
+	}
+	rawReader, rawProp, rawErr := decodedInput(reader, inputProp)
+	groups := []struct {
+		encoders  []codec.VideoEncoderBuilder
+		reader    video.Reader
+		inputProp prop.Media
+		err       error
+	}{
+		{passthroughEncoders, reader, inputProp, nil},
+		{selector.videoEncoders, rawReader, rawProp, rawErr},
+	}
+
 outer:
-	for _, wantCodec := range codecNames {
-		wantCodecLower := strings.ToLower(wantCodec)
-		for _, encoder := range selector.videoEncoders {
-			// MimeType is formated as "video/<codecName>"
-			if strings.HasSuffix(strings.ToLower(encoder.RTPCodec().MimeType), wantCodecLower) {
-				encodedReader, err = encoder.BuildVideoEncoder(reader, inputProp)
-				if err == nil {
-					selectedEncoder = encoder
-					break outer
+	for _, group := range groups {
+		for _, wantCodec := range codecNames {
+			wantCodecLower := strings.ToLower(wantCodec)
+			for _, encoder := range group.encoders {
+				// MimeType is formated as "video/<codecName>"
+				if strings.HasSuffix(strings.ToLower(encoder.RTPCodec().MimeType), wantCodecLower) {
+					err = group.err
+					if err == nil {
+						encodedReader, err = encoder.BuildVideoEncoder(group.reader, group.inputProp)
+					}
+					if err == nil {
+						selectedEncoder = encoder
+						break outer
+					}
 				}
-			}
 
-			errReasons = append(errReasons, fmt.Sprintf("%s: %s", encoder.RTPCodec().MimeType, err))
+				errReasons = append(errReasons, fmt.Sprintf("%s: %s", encoder.RTPCodec().MimeType, err))
+			}
 		}
 	}
 
@@ -112,7 +149,8 @@ outer:
 		for _, encoder := range selector.audioEncoders {
 			// MimeType is formated as "audio/<codecName>"
 			if strings.HasSuffix(strings.ToLower(encoder.RTPCodec().MimeType), wantCodecLower) {
-				encodedReader, err = encoder.BuildAudioEncoder(reader, inputProp)
+				encoderReader, encoderProp := resampleFor(encoder, reader, inputProp)
+				encodedReader, err = encoder.BuildAudioEncoder(encoderReader, encoderProp)
 				if err == nil {
 					selectedEncoder = encoder
 					break outer
@@ -130,6 +168,31 @@ outer:
 	return encodedReader, selectedEncoder.RTPCodec(), nil
 }
 
+// resampleFor converts the audio to a sample rate supported by encoder, the
+// closest one above the rate of the input or the highest one
+func resampleFor(encoder codec.AudioEncoderBuilder, reader audio.Reader, inputProp prop.Media) (audio.Reader, prop.Media) {
+	limiter, ok := encoder.(codec.SampleRateLimiter)
+	if !ok || inputProp.SampleRate <= 0 {
+		return reader, inputProp
+	}
+
+	sampleRate := 0
+	for _, rate := range limiter.SupportedSampleRates() {
+		if rate == inputProp.SampleRate {
+			return reader, inputProp
+		}
+		if (sampleRate < inputProp.SampleRate && rate > sampleRate) ||
+			(rate > inputProp.SampleRate && rate < sampleRate) {
+			sampleRate = rate
+		}
+	}
+	if sampleRate == 0 {
+		return reader, inputProp
+	}
+	inputProp.SampleRate = sampleRate
+	return audio.NewResampler(sampleRate)(reader), inputProp
+}
+
 func (selector *CodecSelector) selectAudioCodec(reader audio.Reader, inputProp prop.Media, codecs ...webrtc.RTPCodecParameters) (codec.ReadCloser, *codec.RTPCodec, error) {
 	var codecNames []string
 
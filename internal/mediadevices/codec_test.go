@@ -0,0 +1,65 @@
+package mediadevices
+
+import (
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+type mockAudioEncoderBuilder struct {
+	sampleRates []int
+	prop        prop.Media
+	reader      audio.Reader
+}
+
+func (builder *mockAudioEncoderBuilder) RTPCodec() *codec.RTPCodec {
+	return codec.NewRTPOpusCodec(48000)
+}
+
+func (builder *mockAudioEncoderBuilder) BuildAudioEncoder(r audio.Reader, p prop.Media) (codec.ReadCloser, error) {
+	builder.prop, builder.reader = p, r
+	return nil, nil
+}
+
+func (builder *mockAudioEncoderBuilder) SupportedSampleRates() []int {
+	return builder.sampleRates
+}
+
+func TestSelectAudioCodecResampling(t *testing.T) {
+	testCases := map[string]struct {
+		sampleRates []int
+		input       int
+		expected    int
+	}{
+		"USBMicrophone": {[]int{8000, 12000, 16000, 24000, 48000}, 44100, 48000},
+		"Supported":     {[]int{8000, 12000, 16000, 24000, 48000}, 16000, 16000},
+		"Closest":       {[]int{48000, 8000, 24000}, 11025, 24000},
+		"Highest":       {[]int{8000, 48000}, 96000, 48000},
+		"Unlimited":     {nil, 44100, 44100},
+	}
+	for name, testCase := range testCases {
+		builder := &mockAudioEncoderBuilder{sampleRates: testCase.sampleRates}
+		selector := NewCodecSelector(WithAudioEncoders(builder))
+		input := audio.ReaderFunc(func() (wave.Audio, func(), error) {
+			ci := wave.ChunkInfo{Len: testCase.input / 100, Channels: 1, SamplingRate: testCase.input}
+			return wave.NewInt16Interleaved(ci), func() {}, nil
+		})
+		inputProp := prop.Media{Audio: prop.Audio{SampleRate: testCase.input}}
+		if _, _, err := selector.selectAudioCodecByNames(input, inputProp, "opus"); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if builder.prop.SampleRate != testCase.expected {
+			t.Errorf("%s: expected %d, got %d", name, testCase.expected, builder.prop.SampleRate)
+		}
+		chunk, _, err := builder.reader.Read()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if rate := chunk.ChunkInfo().SamplingRate; rate != testCase.expected {
+			t.Errorf("%s: expected chunks at %d, got %d", name, testCase.expected, rate)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package mediadevices
+
+import (
+	"github.com/pion/mediadevices/pkg/driver"
+)
+
+// Controls returns the controls of the driver of the track, like the brightness or the exposure of
+// a camera
+func (track *VideoTrack) Controls() ([]driver.Control, error) {
+	d, ok := track.currentSource().(driver.Driver)
+	if !ok {
+		return nil, driver.ErrControlsUnsupported
+	}
+	return driver.Controls(d)
+}
+
+// SetControl sets a control of the driver of the track, the frames already read aren't affected
+func (track *VideoTrack) SetControl(id driver.ControlID, value int32) error {
+	d, ok := track.currentSource().(driver.Driver)
+	if !ok {
+		return driver.ErrControlsUnsupported
+	}
+	return driver.SetControl(d, id, value)
+}
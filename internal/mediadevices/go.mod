@@ -3,6 +3,7 @@ module github.com/pion/mediadevices
 go 1.19
 
 require (
+	github.com/at-wat/ebml-go v0.17.1
 	github.com/blackjack/webcam v0.5.0
 	github.com/gen2brain/malgo v0.11.21
 	github.com/google/uuid v1.6.0
@@ -14,6 +15,7 @@ require (
 	github.com/pion/webrtc/v3 v3.2.29
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/image v0.15.0
+	golang.org/x/text v0.14.0
 )
 
 require (
@@ -117,7 +117,7 @@ func queryDriverProperties(filter driver.FilterFn) map[driver.Driver][]prop.Medi
 
 // select implements SelectSettings algorithm.
 // Reference: https://w3c.github.io/mediacapture-main/#dfn-selectsettings
-func selectBestDriver(filter driver.FilterFn, constraints MediaTrackConstraints) (driver.Driver, MediaTrackConstraints, error) {
+func selectBestDriver(filter driver.FilterFn, constraints MediaTrackConstraints, selector *CodecSelector) (driver.Driver, MediaTrackConstraints, error) {
 	var bestDriver driver.Driver
 	var bestProp prop.Media
 	var foundPropertiesLog []string
@@ -129,6 +129,9 @@ func selectBestDriver(filter driver.FilterFn, constraints MediaTrackConstraints)
 		priority := float64(d.Info().Priority)
 		for _, p := range props {
 			foundPropertiesLog = append(foundPropertiesLog, p.String())
+			if !selector.canRead(p) {
+				continue
+			}
 			fitnessDist, ok := constraints.MediaConstraints.FitnessDistance(p)
 			if !ok {
 				continue
@@ -163,7 +166,7 @@ func selectBestDriver(filter driver.FilterFn, constraints MediaTrackConstraints)
 func selectAudio(constraints MediaTrackConstraints, selector *CodecSelector) (Track, error) {
 	typeFilter := driver.FilterAudioRecorder()
 
-	d, c, err := selectBestDriver(typeFilter, constraints)
+	d, c, err := selectBestDriver(typeFilter, constraints, selector)
 	if err != nil {
 		return nil, err
 	}
@@ -175,7 +178,7 @@ func selectVideo(constraints MediaTrackConstraints, selector *CodecSelector) (Tr
 	notScreenFilter := driver.FilterNot(driver.FilterDeviceType(driver.Screen))
 	filter := driver.FilterAnd(typeFilter, notScreenFilter)
 
-	d, c, err := selectBestDriver(filter, constraints)
+	d, c, err := selectBestDriver(filter, constraints, selector)
 	if err != nil {
 		return nil, err
 	}
@@ -188,7 +191,7 @@ func selectScreen(constraints MediaTrackConstraints, selector *CodecSelector) (T
 	screenFilter := driver.FilterDeviceType(driver.Screen)
 	filter := driver.FilterAnd(typeFilter, screenFilter)
 
-	d, c, err := selectBestDriver(filter, constraints)
+	d, c, err := selectBestDriver(filter, constraints, selector)
 	if err != nil {
 		return nil, err
 	}
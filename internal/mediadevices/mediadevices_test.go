@@ -153,7 +153,7 @@ func TestSelectBestDriverConstraintsResultIsSetProperly(t *testing.T) {
 				},
 			}
 
-			bestDriver, bestConstraints, err := selectBestDriver(filterFn, wantConstraints)
+			bestDriver, bestConstraints, err := selectBestDriver(filterFn, wantConstraints, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -233,7 +233,7 @@ func TestSelectBestDriverConstraintsNoFit(t *testing.T) {
 				},
 			}
 
-			_, _, err := selectBestDriver(filterFn, wantConstraints)
+			_, _, err := selectBestDriver(filterFn, wantConstraints, nil)
 			if err == nil {
 				t.Fatal("expect to not find a driver that fits the constraints")
 			}
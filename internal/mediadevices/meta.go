@@ -1,6 +1,7 @@
 package mediadevices
 
 import (
+	"github.com/pion/mediadevices/pkg/frame"
 	"github.com/pion/mediadevices/pkg/io/audio"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
@@ -15,7 +16,11 @@ func detectCurrentVideoProp(broadcaster *video.Broadcaster) (prop.Media, error)
 	// in any case.
 	metaReader := broadcaster.NewReader(false)
 	metaReader = video.DetectChanges(0, 0, func(p prop.Media) { currentProp = p })(metaReader)
-	_, _, err := metaReader.Read()
+	img, _, err := metaReader.Read()
+	if encoded, ok := img.(*frame.Encoded); ok {
+		// the encoders of the raw frames can't read the frames compressed by the device as they are
+		currentProp.FrameFormat = encoded.Format
+	}
 
 	return currentProp, err
 }
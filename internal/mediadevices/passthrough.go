@@ -0,0 +1,229 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v3"
+)
+
+var (
+	errNoPayloader      = errors.New("codec can't be packetized to RTP")
+	errCompressedFrames = errors.New("frames can't be decoded, they are compressed")
+)
+
+// passthroughCodecs are the codecs of the formats the drivers can read compressed. MJPEG has no
+// RTP payloader in pion, it's only passed through to the encoded readers.
+var passthroughCodecs = map[frame.Format]func() *codec.RTPCodec{
+	frame.FormatH264: func() *codec.RTPCodec {
+		return codec.NewRTPH264Codec(90000)
+	},
+	frame.FormatMJPEG: func() *codec.RTPCodec {
+		return &codec.RTPCodec{
+			RTPCodecParameters: webrtc.RTPCodecParameters{
+				RTPCodecCapability: webrtc.RTPCodecCapability{
+					MimeType:  "video/MJPEG",
+					ClockRate: 90000,
+				},
+			},
+		}
+	},
+}
+
+// WithPassthrough lets the tracks read the frames compressed by their device in formats, for
+// example the H.264 of a USB camera, and send or record them without decoding and encoding them
+// again. The devices are then opened in these formats when the constraints allow it, the transforms
+// of the tracks don't apply to the compressed frames. The codecs passed through are preferred to the
+// video encoders of the selector, which encode the frames in the other formats.
+func WithPassthrough(formats ...frame.Format) CodecSelectorOption {
+	return func(t *CodecSelector) {
+		t.passthroughEncoders = nil
+		for _, f := range formats {
+			newCodec, ok := passthroughCodecs[f]
+			if !ok {
+				logger.Warnf("%s can't be passed through", f)
+				continue
+			}
+			t.passthroughEncoders = append(t.passthroughEncoders, &passthroughEncoderBuilder{
+				format: f,
+				codec:  newCodec(),
+			})
+		}
+	}
+}
+
+// passesThrough tells if the frames of format f are passed through by the selector
+func (selector *CodecSelector) passesThrough(f frame.Format) bool {
+	if selector == nil {
+		return false
+	}
+	for _, encoder := range selector.passthroughEncoders {
+		if encoder.format == f {
+			return true
+		}
+	}
+	return false
+}
+
+// canRead tells if the tracks of the selector can read the frames of p, the compressed formats
+// which can't be decoded are only read when they are passed through
+func (selector *CodecSelector) canRead(p prop.Media) bool {
+	if _, err := frame.NewDecoder(p.FrameFormat); err == nil || p.FrameFormat == "" {
+		return true
+	}
+	if _, err := frame.NewPassthroughDecoder(p.FrameFormat); err != nil {
+		// unknown to the frame package, the driver may read it anyway
+		return true
+	}
+	return selector.passesThrough(p.FrameFormat)
+}
+
+// recordVideo opens the reader of recorder with p, compressed when the selector passes the frames
+// of p through and the driver can read them so
+func recordVideo(recorder driver.VideoRecorder, p prop.Media, selector *CodecSelector) (video.Reader, error) {
+	if encodedRecorder, ok := recorder.(driver.EncodedVideoRecorder); ok && selector.passesThrough(p.FrameFormat) {
+		return encodedRecorder.EncodedVideoRecord(p)
+	}
+	return recorder.VideoRecord(p)
+}
+
+// decodedInput returns the input of the encoders of the raw frames. The frames compressed by the
+// device are decoded when their format has a decoder, like MJPEG, the H.264 frames can't be encoded
+// again.
+func decodedInput(reader video.Reader, inputProp prop.Media) (video.Reader, prop.Media, error) {
+	if inputProp.FrameFormat == "" {
+		return reader, inputProp, nil
+	}
+	if _, err := frame.NewDecoder(inputProp.FrameFormat); err != nil {
+		return nil, prop.Media{}, fmt.Errorf("%w in %s", errCompressedFrames, inputProp.FrameFormat)
+	}
+
+	inputProp.FrameFormat = ""
+	return video.ReaderFunc(func() (image.Image, func(), error) {
+		for {
+			img, release, err := reader.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			encoded, ok := img.(*frame.Encoded)
+			if !ok {
+				return img, release, nil
+			}
+			decoded := encoded.Decoded()
+			if decoded == nil {
+				// a corrupted frame, or a frame of a track reconfigured to a format which can't
+				// be decoded, the encoder is rebuilt then
+				release()
+				continue
+			}
+			return decoded, release, nil
+		}
+	}), inputProp, nil
+}
+
+// passthroughEncoderBuilder builds the encoders of the frames compressed by the devices in format
+type passthroughEncoderBuilder struct {
+	format frame.Format
+	codec  *codec.RTPCodec
+}
+
+func (b *passthroughEncoderBuilder) RTPCodec() *codec.RTPCodec {
+	return b.codec
+}
+
+// BuildVideoEncoder fails when the frames of r aren't compressed in the format of the builder,
+// the next encoders of the selector are tried then
+func (b *passthroughEncoderBuilder) BuildVideoEncoder(r video.Reader, p prop.Media) (codec.ReadCloser, error) {
+	img, release, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	encoded, ok := img.(*frame.Encoded)
+	if !ok || encoded.Format != b.format {
+		release()
+		return nil, fmt.Errorf("frames aren't compressed in %s", b.format)
+	}
+	return &passthroughEncoder{reader: r, format: b.format, next: encoded, release: release}, nil
+}
+
+// passthroughEncoder returns the compressed frames of its reader. The key frames are requested
+// from the device when it supports it.
+type passthroughEncoder struct {
+	reader video.Reader
+	format frame.Format
+	// source returns the driver read by the track, it's set by the track after the build
+	source func() Source
+
+	mu sync.Mutex
+	// next is the frame read by the build, returned by the first Read
+	next    *frame.Encoded
+	release func()
+	closed  bool
+}
+
+func (e *passthroughEncoder) Read() ([]byte, func(), error) {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil, func() {}, errTrackClosed
+	}
+	next, release := e.next, e.release
+	e.next, e.release = nil, nil
+	e.mu.Unlock()
+
+	if next != nil {
+		return next.Data, release, nil
+	}
+	for {
+		img, release, err := e.reader.Read()
+		if err != nil {
+			return nil, func() {}, err
+		}
+		encoded, ok := img.(*frame.Encoded)
+		if !ok || encoded.Format != e.format {
+			// the track was reconfigured to another format, the next read of the track fails
+			// and the encoder is rebuilt
+			release()
+			continue
+		}
+		if len(encoded.Data) == 0 {
+			release()
+			continue
+		}
+		return encoded.Data, release, nil
+	}
+}
+
+func (e *passthroughEncoder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.release != nil {
+		e.release()
+		e.next, e.release = nil, nil
+	}
+	e.closed = true
+	return nil
+}
+
+func (e *passthroughEncoder) Controller() codec.EncoderController {
+	return e
+}
+
+// ForceKeyFrame asks the device for a key frame, the devices which can't be asked send their key
+// frames at their own interval
+func (e *passthroughEncoder) ForceKeyFrame() error {
+	if e.source == nil {
+		return nil
+	}
+	if requester, ok := e.source().(driver.KeyFrameRequester); ok {
+		return requester.RequestKeyFrame()
+	}
+	return nil
+}
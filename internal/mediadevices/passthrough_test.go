@@ -0,0 +1,278 @@
+package mediadevices
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// fakeEncodingDriver is a camera compressing its frames in H.264 or MJPEG
+type fakeEncodingDriver struct {
+	fakeReconfigurableDriver
+	keyFrameRequests int32
+}
+
+func (d *fakeEncodingDriver) Properties() []prop.Media {
+	return []prop.Media{
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatH264, FrameRate: 30}},
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatMJPEG, FrameRate: 30}},
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420, FrameRate: 30}},
+	}
+}
+
+func (d *fakeEncodingDriver) EncodedVideoRecord(p prop.Media) (video.Reader, error) {
+	raw, err := d.VideoRecord(p)
+	if err != nil {
+		return nil, err
+	}
+	return video.ReaderFunc(func() (image.Image, func(), error) {
+		if _, _, err := raw.Read(); err != nil {
+			return nil, func() {}, err
+		}
+		return &frame.Encoded{
+			Format: p.FrameFormat,
+			Data:   []byte{0, 0, 0, 1, 0x65, 0x88},
+			Rect:   image.Rect(0, 0, p.Width, p.Height),
+		}, func() {}, nil
+	}), nil
+}
+
+func (d *fakeEncodingDriver) RequestKeyFrame() error {
+	atomic.AddInt32(&d.keyFrameRequests, 1)
+	return nil
+}
+
+func TestSelectBestPropPassthrough(t *testing.T) {
+	d := &fakeEncodingDriver{}
+	testCases := map[string]struct {
+		selector *CodecSelector
+		format   frame.Format
+		expected frame.Format
+	}{
+		"Decoded":     {nil, "", frame.FormatMJPEG},
+		"NotDecoded":  {nil, frame.FormatH264, ""},
+		"PassThrough": {NewCodecSelector(WithPassthrough(frame.FormatH264)), frame.FormatH264, frame.FormatH264},
+	}
+	for name, testCase := range testCases {
+		var constraints MediaTrackConstraints
+		if testCase.format != "" {
+			constraints.FrameFormat = prop.FrameFormatExact(testCase.format)
+		} else {
+			constraints.FrameFormat = prop.FrameFormatOneOf{frame.FormatH264, frame.FormatMJPEG}
+		}
+		selected, err := selectBestProp(d.Properties(), constraints, testCase.selector)
+		if testCase.expected == "" {
+			if err != errNotFound {
+				t.Errorf("%s: expected %v, got %v", name, errNotFound, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if selected.FrameFormat != testCase.expected {
+			t.Errorf("%s: expected %s, got %s", name, testCase.expected, selected.FrameFormat)
+		}
+	}
+}
+
+func TestVideoTrackPassthrough(t *testing.T) {
+	d := &fakeEncodingDriver{}
+	d.Open()
+	selector := NewCodecSelector(
+		WithVideoEncoders(&fakeSizeEncoderBuilder{}),
+		WithPassthrough(frame.FormatH264, frame.FormatMJPEG),
+	)
+	constraints := MediaTrackConstraints{
+		selectedMedia: prop.Media{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatH264}},
+	}
+	track, err := newTrackFromDriver(d, constraints, selector)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer track.Close()
+
+	encoded, err := track.NewEncodedReader("h264")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer encoded.Close()
+	buffer, _, err := encoded.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(buffer.Data) != 6 || buffer.Data[4] != 0x65 {
+		t.Errorf("Expected the access unit of the driver, got %v", buffer.Data)
+	}
+	if err := encoded.Controller().(codec.KeyFrameController).ForceKeyFrame(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&d.keyFrameRequests); n != 1 {
+		t.Errorf("Expected the key frame to be requested from the driver, got %d requests", n)
+	}
+
+	rtpReader, err := track.NewRTPReader("h264", 1, 1200)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer rtpReader.Close()
+	packets, _, err := rtpReader.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(packets) != 1 || len(packets[0].Payload) != 2 {
+		t.Errorf("Expected the NAL unit in a packet, got %v", packets)
+	}
+
+	// passing the frames through is preferred to encoding them again
+	preferred, selectedCodec, err := track.(*VideoTrack).newEncodedReader("vp8", "h264")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	preferred.Close()
+	if selectedCodec.MimeType != "video/H264" {
+		t.Errorf("Expected video/H264, got %s", selectedCodec.MimeType)
+	}
+
+	// the H.264 frames can't be decoded to be encoded again
+	if _, err := track.NewRTPReader("vp8", 1, 1200); err == nil {
+		t.Error("Expected the VP8 encoder to refuse the H.264 frames")
+	}
+
+	// the frames compressed in H.264 aren't MJPEG
+	if _, err := track.NewEncodedReader("mjpeg"); err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestVideoTrackPassthroughNoPayloader(t *testing.T) {
+	d := &fakeEncodingDriver{}
+	d.Open()
+	constraints := MediaTrackConstraints{
+		selectedMedia: prop.Media{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatMJPEG}},
+	}
+	track, err := newTrackFromDriver(d, constraints, NewCodecSelector(WithPassthrough(frame.FormatMJPEG)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer track.Close()
+
+	if _, err := track.NewRTPReader("mjpeg", 1, 1200); !errors.Is(err, errNoPayloader) {
+		t.Errorf("Expected %v, got %v", errNoPayloader, err)
+	}
+	encoded, err := track.NewEncodedReader("mjpeg")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	encoded.Close()
+}
+
+func TestDecodedInput(t *testing.T) {
+	var jpegData bytes.Buffer
+	if err := jpeg.Encode(&jpegData, image.NewGray(image.Rect(0, 0, 16, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	mjpeg := video.ReaderFunc(func() (image.Image, func(), error) {
+		return &frame.Encoded{Format: frame.FormatMJPEG, Data: jpegData.Bytes(), KeyFrame: true, Rect: image.Rect(0, 0, 16, 8)}, func() {}, nil
+	})
+
+	reader, inputProp, err := decodedInput(mjpeg, prop.Media{Video: prop.Video{FrameFormat: frame.FormatMJPEG}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if inputProp.FrameFormat != "" {
+		t.Errorf("Expected the frames to be raw, got %s", inputProp.FrameFormat)
+	}
+	img, _, err := reader.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := img.(*frame.Encoded); ok || img.Bounds().Dx() != 16 {
+		t.Errorf("Expected a decoded 16 pixels wide frame, got %T %v", img, img.Bounds())
+	}
+
+	if _, _, err := decodedInput(mjpeg, prop.Media{Video: prop.Video{FrameFormat: frame.FormatH264}}); !errors.Is(err, errCompressedFrames) {
+		t.Errorf("Expected %v, got %v", errCompressedFrames, err)
+	}
+}
+
+// fakeH264SizeEncoderBuilder encodes the raw frames of the tracks to H.264
+type fakeH264SizeEncoderBuilder struct {
+	fakeSizeEncoderBuilder
+}
+
+func (b *fakeH264SizeEncoderBuilder) RTPCodec() *codec.RTPCodec {
+	return codec.NewRTPH264Codec(90000)
+}
+
+func TestVideoTrackReconfigurePassthroughToRaw(t *testing.T) {
+	d := &fakeEncodingDriver{}
+	d.Open()
+	selector := NewCodecSelector(
+		WithVideoEncoders(&fakeH264SizeEncoderBuilder{}),
+		WithPassthrough(frame.FormatH264),
+	)
+	constraints := MediaTrackConstraints{
+		selectedMedia: prop.Media{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatH264}},
+	}
+	track, err := newTrackFromDriver(d, constraints, selector)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer track.Close()
+	track.OnEnded(func(err error) {
+		if err == io.EOF {
+			return
+		}
+		t.Errorf("Expected the track to survive the reconfiguration, got %v", err)
+	})
+
+	encoded, err := track.NewEncodedReader("h264")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer encoded.Close()
+
+	// the encoder keeps reading while the track is reconfigured, one of its reads gets the first
+	// raw frame
+	done := make(chan error, 1)
+	go func() {
+		for {
+			buffer, _, err := encoded.Read()
+			if err != nil {
+				done <- err
+				return
+			}
+			if string(buffer.Data) == "640" {
+				done <- nil
+				return
+			}
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	err = track.(*VideoTrack).Reconfigure(func(c *MediaTrackConstraints) {
+		c.FrameFormat = prop.FrameFormatExact(frame.FormatI420)
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected the encoder to be rebuilt for the raw frames, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the raw frames to be encoded")
+	}
+}
@@ -100,6 +100,14 @@ type AudioEncoderBuilder interface {
 	BuildAudioEncoder(r audio.Reader, p prop.Media) (ReadCloser, error)
 }
 
+// SampleRateLimiter is implemented by the audio encoder builders accepting a
+// limited set of sample rates. The audio of another rate is resampled before
+// being encoded.
+type SampleRateLimiter interface {
+	// SupportedSampleRates returns the sample rates accepted by the encoder
+	SupportedSampleRates() []int
+}
+
 // VideoEncoderBuilder is the interface that wraps basic operations that are
 // necessary to build the video encoder.
 //
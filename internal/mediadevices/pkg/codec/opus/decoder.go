@@ -0,0 +1,79 @@
+package opus
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+/*
+#include <opus.h>
+*/
+import "C"
+
+// maxFrameSamples is the number of samples of the longest OPUS packet, 120ms
+// at 48kHz
+const maxFrameSamples = 5760
+
+var errDecoderClosed = errors.New("opus: decoder is closed")
+
+// Decoder decodes OPUS packets to samples at 48kHz
+type Decoder struct {
+	channels int
+
+	mu     sync.Mutex
+	engine *C.OpusDecoder
+}
+
+// NewDecoder creates a decoder of packets of channels channels
+func NewDecoder(channels int) (*Decoder, error) {
+	var cerror C.int
+	engine := C.opus_decoder_create(48000, C.int(channels), &cerror)
+	if cerror != C.OPUS_OK {
+		return nil, errors.New("failed to create decoder engine")
+	}
+	return &Decoder{channels: channels, engine: engine}, nil
+}
+
+// Decode decodes a packet, nil decodes a lost packet
+func (d *Decoder) Decode(packet []byte) (*wave.Int16Interleaved, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.engine == nil {
+		return nil, errDecoderClosed
+	}
+
+	pcm := make([]int16, maxFrameSamples*d.channels)
+	var data *C.uchar
+	if len(packet) > 0 {
+		data = (*C.uchar)(&packet[0])
+	}
+	n := C.opus_decode(
+		d.engine,
+		data,
+		C.opus_int32(len(packet)),
+		(*C.opus_int16)(&pcm[0]),
+		maxFrameSamples,
+		0,
+	)
+	if n < 0 {
+		return nil, errors.New("failed to decode")
+	}
+
+	chunk := wave.NewInt16Interleaved(wave.ChunkInfo{Len: int(n), Channels: d.channels, SamplingRate: 48000})
+	copy(chunk.Data, pcm)
+	return chunk, nil
+}
+
+// Close releases the decoder
+func (d *Decoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.engine == nil {
+		return nil
+	}
+	C.opus_decoder_destroy(d.engine)
+	d.engine = nil
+	return nil
+}
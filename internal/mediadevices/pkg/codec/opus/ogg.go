@@ -0,0 +1,171 @@
+package opus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+var (
+	errOggSignature  = errors.New("opus: not an Ogg stream")
+	errOggOpusHeader = errors.New("opus: not an Ogg OPUS stream")
+)
+
+// OggWriter writes OPUS packets to an Ogg stream, a .opus or a .ogg file
+type OggWriter struct {
+	w         *oggwriter.OggWriter
+	timestamp uint32
+}
+
+// NewOggWriter writes the headers of an Ogg OPUS stream to w, sampleRate is
+// the rate of the audio given to the encoder
+func NewOggWriter(w io.Writer, sampleRate, channels int) (*OggWriter, error) {
+	ogg, err := oggwriter.NewWith(w, uint32(sampleRate), uint16(channels))
+	if err != nil {
+		return nil, err
+	}
+	return &OggWriter{w: ogg, timestamp: 1}, nil
+}
+
+// WritePacket writes a packet lasting samples at 48kHz, like the Data and the
+// Samples of the buffers of an encoded reader
+func (w *OggWriter) WritePacket(packet []byte, samples uint32) error {
+	err := w.w.WriteRTP(&rtp.Packet{
+		Header:  rtp.Header{Timestamp: w.timestamp},
+		Payload: packet,
+	})
+	w.timestamp += samples
+	return err
+}
+
+// Close closes the stream given to NewOggWriter when it is an io.Closer
+func (w *OggWriter) Close() error {
+	return w.w.Close()
+}
+
+// OggHeader describes the OPUS stream of an Ogg stream
+type OggHeader struct {
+	Channels int
+	// PreSkip is the number of samples at 48kHz to drop from the start of the
+	// decoded stream
+	PreSkip int
+	// SampleRate is the rate of the audio given to the encoder, informative
+	SampleRate int
+}
+
+// OggReader reads the OPUS packets of the first logical stream of an Ogg
+// stream
+type OggReader struct {
+	r      *bufio.Reader
+	header OggHeader
+	// serial is the serial number of the first logical stream
+	serial  uint32
+	started bool
+	// packets are the complete packets of the last page, partial is the start
+	// of a packet continued on the next page
+	packets [][]byte
+	partial []byte
+}
+
+// NewOggReader reads the headers of an Ogg OPUS stream
+func NewOggReader(r io.Reader) (*OggReader, error) {
+	reader := &OggReader{r: bufio.NewReader(r)}
+	head, err := reader.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(head) < 19 || !bytes.HasPrefix(head, []byte("OpusHead")) {
+		return nil, errOggOpusHeader
+	}
+	reader.header = OggHeader{
+		Channels:   int(head[9]),
+		PreSkip:    int(binary.LittleEndian.Uint16(head[10:])),
+		SampleRate: int(binary.LittleEndian.Uint32(head[12:])),
+	}
+	if reader.header.Channels == 0 {
+		return nil, errOggOpusHeader
+	}
+	tags, err := reader.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(tags, []byte("OpusTags")) {
+		return nil, errOggOpusHeader
+	}
+	return reader, nil
+}
+
+// Header returns the header of the stream
+func (r *OggReader) Header() OggHeader {
+	return r.header
+}
+
+// ReadPacket reads the next packet, io.EOF at the end of the stream
+func (r *OggReader) ReadPacket() ([]byte, error) {
+	for len(r.packets) == 0 {
+		if err := r.readPage(); err != nil {
+			return nil, err
+		}
+	}
+	packet := r.packets[0]
+	r.packets = r.packets[1:]
+	return packet, nil
+}
+
+// readPage splits the next page of the stream into packets
+func (r *OggReader) readPage() error {
+	var header [27]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return truncated(err)
+	}
+	if string(header[:4]) != "OggS" {
+		return errOggSignature
+	}
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(r.r, segments); err != nil {
+		return truncated(err)
+	}
+	size := 0
+	for _, s := range segments {
+		size += int(s)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return truncated(err)
+	}
+
+	const beginningOfStream = 2
+	serial := binary.LittleEndian.Uint32(header[14:])
+	if header[5]&beginningOfStream != 0 && !r.started {
+		r.serial, r.started = serial, true
+	}
+	if serial != r.serial {
+		// the pages of the other logical streams are skipped
+		return nil
+	}
+
+	// a packet ends with a segment shorter than 255 bytes
+	for _, s := range segments {
+		r.partial = append(r.partial, data[:s]...)
+		data = data[s:]
+		if s < 255 {
+			r.packets = append(r.packets, r.partial)
+			r.partial = nil
+		}
+	}
+	return nil
+}
+
+// truncated ends the stream of a file which was cut off at its last complete
+// page
+func truncated(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
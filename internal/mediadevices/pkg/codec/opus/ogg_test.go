@@ -0,0 +1,148 @@
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+func TestOggRoundTrip(t *testing.T) {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	sine := audio.ReaderFunc(func() (wave.Audio, func(), error) {
+		chunk := wave.NewInt16Interleaved(wave.ChunkInfo{Len: 960, Channels: 2, SamplingRate: 48000})
+		for i := 0; i < 960; i++ {
+			v := int16(8000 * math.Sin(2*math.Pi*440*float64(n)/48000))
+			chunk.SetInt16(i, 0, wave.Int16Sample(v))
+			chunk.SetInt16(i, 1, wave.Int16Sample(v))
+			n++
+		}
+		return chunk, func() {}, nil
+	})
+	encoder, err := p.BuildAudioEncoder(sine, prop.Media{Audio: prop.Audio{SampleRate: 48000, ChannelCount: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+
+	var stream bytes.Buffer
+	w, err := NewOggWriter(&stream, 48000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		packet, _, err := encoder.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WritePacket(packet, 960); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewOggReader(&stream)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if header := r.Header(); header.Channels != 2 || header.SampleRate != 48000 {
+		t.Errorf("Unexpected header %v", header)
+	}
+	decoder, err := NewDecoder(r.Header().Channels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	var packets int
+	var peak int16
+	for {
+		packet, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		chunk, err := decoder.Decode(packet)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if ci := chunk.ChunkInfo(); ci.Len != 960 || ci.Channels != 2 {
+			t.Errorf("Expected 960 stereo samples, got %v", ci)
+		}
+		for _, v := range chunk.Data {
+			if v > peak {
+				peak = v
+			}
+		}
+		packets++
+	}
+	if packets != 50 {
+		t.Errorf("Expected 50 packets, got %d", packets)
+	}
+	if peak < 6000 {
+		t.Errorf("Expected the sine to be decoded, got a peak of %d", peak)
+	}
+}
+
+// oggPage builds a page of the stream serial from its segment table
+func oggPage(serial uint32, headerType byte, lacing []byte) []byte {
+	page := []byte("OggS\x00")
+	page = append(page, headerType)
+	page = append(page, make([]byte, 8)...)
+	page = binary.LittleEndian.AppendUint32(page, serial)
+	page = append(page, make([]byte, 8)...)
+	page = append(page, byte(len(lacing)))
+	page = append(page, lacing...)
+	for i, size := range lacing {
+		page = append(page, bytes.Repeat([]byte{byte(i + 1)}, int(size))...)
+	}
+	return page
+}
+
+func TestOggReaderPackets(t *testing.T) {
+	head := []byte("OpusHead\x01\x01\x38\x01\x80\xbb\x00\x00\x00\x00\x00")
+	tags := []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00")
+	var stream bytes.Buffer
+	stream.Write(oggPage(7, 2, []byte{byte(len(head))})[:28])
+	stream.Write(head)
+	stream.Write(oggPage(7, 0, []byte{byte(len(tags))})[:28])
+	stream.Write(tags)
+	// a page of another stream is skipped
+	stream.Write(oggPage(8, 2, []byte{10}))
+	// two packets, then a packet continued on the next page
+	stream.Write(oggPage(7, 0, []byte{3, 5, 255}))
+	stream.Write(oggPage(7, 1, []byte{45}))
+
+	r, err := NewOggReader(&stream)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if header := r.Header(); header.Channels != 1 || header.PreSkip != 312 {
+		t.Errorf("Unexpected header %v", header)
+	}
+	for _, size := range []int{3, 5, 300} {
+		packet, err := r.ReadPacket()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(packet) != size {
+			t.Errorf("Expected a packet of %d bytes, got %d", size, len(packet))
+		}
+	}
+	if _, err := r.ReadPacket(); err != io.EOF {
+		t.Errorf("Expected %v, got %v", io.EOF, err)
+	}
+}
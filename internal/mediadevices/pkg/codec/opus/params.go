@@ -66,6 +66,12 @@ func (p *Params) RTPCodec() *codec.RTPCodec {
 	return c
 }
 
+// SupportedSampleRates returns the sample rates of the OPUS encoder, the audio
+// of a USB microphone at 44.1kHz is resampled to 48kHz
+func (p *Params) SupportedSampleRates() []int {
+	return []int{8000, 12000, 16000, 24000, 48000}
+}
+
 // BuildAudioEncoder builds opus encoder with given params
 func (p *Params) BuildAudioEncoder(r audio.Reader, property prop.Media) (codec.ReadCloser, error) {
 	return newEncoder(r, property, *p)
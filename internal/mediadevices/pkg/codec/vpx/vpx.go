@@ -303,6 +303,22 @@ func (e *encoder) ForceKeyFrame() error {
 	return nil
 }
 
+// SetBitRate changes the target bit rate of the encoder, it applies from the next frame
+func (e *encoder) SetBitRate(bitRate int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil
+	}
+
+	e.cfg.rc_target_bitrate = C.uint(bitRate) / 1000
+	if ec := C.vpx_codec_enc_config_set(e.codec, e.cfg); ec != 0 {
+		return fmt.Errorf("vpx_codec_enc_config_set failed (%d)", ec)
+	}
+	return nil
+}
+
 func (e *encoder) Controller() codec.EncoderController {
 	return e
 }
@@ -0,0 +1,213 @@
+// Package audiofile provides an audio driver replaying a clip from a file, to play announcements
+// to the viewers or to run the microphone stack without a microphone, on CI or on a laptop.
+//
+// WAV (.wav) files of 16 bits PCM or 32 bits float samples and Ogg OPUS (.ogg, .opus) files in
+// mono or stereo are supported. The OPUS packets are decoded at 48kHz.
+//
+// The clip loops and its chunks are read at their real-time pace. When the
+// PION_MEDIADEVICES_AUDIO_FILE environment variable is set, the file is registered at init with the
+// label in PION_MEDIADEVICES_AUDIO_FILE_LABEL, the file name by default.
+package audiofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/internal/logging"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// latency is the duration of the chunks read from the clips
+const latency = 20 * time.Millisecond
+
+var (
+	logger = logging.NewLogger("mediadevices/driver/audiofile")
+
+	errUnsupportedFile = errors.New("audiofile: unsupported file, expected a wav or an ogg opus file")
+	errNotOpened       = errors.New("audiofile: file isn't opened")
+	errEmptyClip       = errors.New("audiofile: clip has no sample")
+)
+
+func init() {
+	path := os.Getenv("PION_MEDIADEVICES_AUDIO_FILE")
+	if path == "" {
+		return
+	}
+	if _, err := Register(path, os.Getenv("PION_MEDIADEVICES_AUDIO_FILE_LABEL")); err != nil {
+		logger.Errorf("failed to register %s: %s", path, err)
+	}
+}
+
+// Register registers a driver replaying the file at path, with label or the
+// file name when label is empty
+func Register(path, label string) (driver.Driver, error) {
+	c, info, err := openClip(path)
+	if err != nil {
+		return nil, err
+	}
+	c.Close()
+
+	if label == "" {
+		label = filepath.Base(path)
+	}
+	return driver.GetManager().RegisterDriver(&fileSource{path: path, info: info}, driver.Info{
+		Label:      label,
+		DeviceType: driver.Microphone,
+		Priority:   driver.PriorityLow,
+	}), nil
+}
+
+// clipInfo describes the samples of a clip
+type clipInfo struct {
+	sampleRate, channels int
+	float                bool
+}
+
+// clip reads the chunks of a file
+type clip interface {
+	Next() (wave.Audio, error)
+	Close() error
+}
+
+func openClip(path string) (clip, clipInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, clipInfo{}, err
+	}
+
+	var c clip
+	var info clipInfo
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		c, info, err = newWAVClip(f)
+	case ".ogg", ".opus":
+		c, info, err = newOggClip(f)
+	default:
+		err = errUnsupportedFile
+	}
+	if err != nil {
+		f.Close()
+		return nil, clipInfo{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, info, nil
+}
+
+type fileSource struct {
+	path string
+	info clipInfo
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel func()
+}
+
+func (s *fileSource) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return nil
+}
+
+func (s *fileSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+func (s *fileSource) Properties() []prop.Media {
+	sampleSize := 2
+	if s.info.float {
+		sampleSize = 4
+	}
+	return []prop.Media{{
+		Audio: prop.Audio{
+			SampleRate:    s.info.sampleRate,
+			ChannelCount:  s.info.channels,
+			Latency:       latency,
+			SampleSize:    sampleSize,
+			IsFloat:       s.info.float,
+			IsInterleaved: true,
+		},
+	}}
+}
+
+// AudioRecord replays the clip from its start, the chunks have the rate and
+// the channels of the file whatever p asks
+func (s *fileSource) AudioRecord(p prop.Media) (audio.Reader, error) {
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+	if ctx == nil {
+		return nil, errNotOpened
+	}
+
+	c, _, err := openClip(s.path)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		start time.Time
+		// played is the number of samples read since start, loops included
+		played int64
+		// loop is the number of samples read in the current loop
+		loop int
+	)
+	r := audio.ReaderFunc(func() (wave.Audio, func(), error) {
+		if ctx.Err() != nil {
+			// Return EOF if the file is already closed.
+			c.Close()
+			return nil, func() {}, io.EOF
+		}
+
+		chunk, err := c.Next()
+		if err == errEndOfClip {
+			if loop == 0 {
+				return nil, func() {}, errEmptyClip
+			}
+			c.Close()
+			if c, _, err = openClip(s.path); err != nil {
+				return nil, func() {}, err
+			}
+			loop = 0
+			chunk, err = c.Next()
+		}
+		if err != nil {
+			c.Close()
+			return nil, func() {}, err
+		}
+		n := chunk.ChunkInfo().Len
+		loop += n
+
+		// a chunk is returned when its samples would have been captured
+		if start.IsZero() {
+			start = time.Now()
+		}
+		played += int64(n)
+		wait := time.Until(start.Add(time.Duration(played) * time.Second / time.Duration(s.info.sampleRate)))
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				c.Close()
+				return nil, func() {}, io.EOF
+			case <-timer.C:
+			}
+		}
+		return chunk, func() {}, nil
+	})
+	return r, nil
+}
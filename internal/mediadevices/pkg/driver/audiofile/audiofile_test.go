@@ -0,0 +1,196 @@
+package audiofile
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec/opus"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// writeWAV writes a mono clip of 50ms at 8kHz, the samples of the n-th 10ms
+// are n/10
+func writeWAV(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "clip.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := audio.NewWAVWriter(f)
+	for n := 0; n < 5; n++ {
+		chunk := wave.NewFloat32Interleaved(wave.ChunkInfo{Len: 80, Channels: 1, SamplingRate: 8000})
+		for i := range chunk.Data {
+			chunk.Data[i] = float32(n) / 10
+		}
+		w.Write(chunk)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeOgg writes 200ms of a stereo sine encoded in OPUS
+func writeOgg(t *testing.T) string {
+	p, err := opus.NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	sine := audio.ReaderFunc(func() (wave.Audio, func(), error) {
+		chunk := wave.NewInt16Interleaved(wave.ChunkInfo{Len: 960, Channels: 2, SamplingRate: 48000})
+		for i := 0; i < 960; i++ {
+			v := wave.Int16Sample(8000 * math.Sin(2*math.Pi*440*float64(n)/48000))
+			chunk.SetInt16(i, 0, v)
+			chunk.SetInt16(i, 1, v)
+			n++
+		}
+		return chunk, func() {}, nil
+	})
+	encoder, err := p.BuildAudioEncoder(sine, prop.Media{Audio: prop.Audio{SampleRate: 48000, ChannelCount: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+
+	path := filepath.Join(t.TempDir(), "clip.opus")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := opus.NewOggWriter(f, 48000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		packet, _, err := encoder.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WritePacket(packet, 960)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func record(t *testing.T, d driver.Driver) audio.Reader {
+	if err := d.Open(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		d.Close()
+		driver.GetManager().Delete(d.ID())
+	})
+	r, err := d.(driver.AudioRecorder).AudioRecord(d.Properties()[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return r
+}
+
+func TestWAVClip(t *testing.T) {
+	d, err := Register(writeWAV(t), "test clip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	drivers := driver.GetManager().Query(func(d driver.Driver) bool {
+		return d.Info().Label == "test clip"
+	})
+	if len(drivers) != 1 || drivers[0] != d {
+		t.Fatalf("Expected the driver to be registered, got %v", drivers)
+	}
+	if info := d.Info(); info.DeviceType != driver.Microphone {
+		t.Errorf("Expected a microphone, got %v", info.DeviceType)
+	}
+	r := record(t, d)
+	expected := prop.Audio{SampleRate: 8000, ChannelCount: 1, Latency: latency, SampleSize: 4, IsFloat: true, IsInterleaved: true}
+	if properties := d.Properties(); len(properties) != 1 || properties[0].Audio != expected {
+		t.Errorf("Expected %v, got %v", expected, properties)
+	}
+
+	start := time.Now()
+	// the 50ms clip is read in chunks of 20, 20 and 10ms, and loops
+	lengths := []int{160, 160, 80, 160, 160, 80}
+	for i, length := range lengths {
+		chunk, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if n := chunk.ChunkInfo().Len; n != length {
+			t.Errorf("Chunk %d: expected %d samples, got %d", i, length, n)
+		}
+		if v := chunk.At(0, 0).(wave.Float32Sample); i%3 == 1 && math.Abs(float64(v)-0.2) > 1e-6 {
+			t.Errorf("Chunk %d: expected 0.2, got %v", i, v)
+		}
+	}
+	// the last chunk is returned 100ms after the start
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected the chunks to be paced, read in %v", elapsed)
+	}
+
+	d.Close()
+	if _, _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestOggClip(t *testing.T) {
+	d, err := Register(writeOgg(t), "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if label := d.Info().Label; label != "clip.opus" {
+		t.Errorf("Expected the file name as label, got %q", label)
+	}
+	r := record(t, d)
+	expected := prop.Audio{SampleRate: 48000, ChannelCount: 2, Latency: latency, SampleSize: 2, IsInterleaved: true}
+	if properties := d.Properties(); len(properties) != 1 || properties[0].Audio != expected {
+		t.Errorf("Expected %v, got %v", expected, properties)
+	}
+
+	// the pre-skip of the encoder is dropped from each loop
+	var samples int
+	for samples < 2*9600 {
+		chunk, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if ci := chunk.ChunkInfo(); ci.Channels != 2 || ci.SamplingRate != 48000 {
+			t.Errorf("Unexpected chunk %v", ci)
+		}
+		samples += chunk.ChunkInfo().Len
+	}
+	if samples >= 2*9600+960 {
+		t.Errorf("Expected two loops of less than 9600 samples, got %d samples", samples)
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"clip.mp3":  "",
+		"clip.wav":  "RIFF\x00\x00\x00\x00AVI ",
+		"clip.opus": "OggS",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Register(path, ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := Register(filepath.Join(dir, "missing.wav"), ""); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
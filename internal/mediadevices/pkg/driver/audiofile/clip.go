@@ -0,0 +1,117 @@
+package audiofile
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/pion/mediadevices/pkg/codec/opus"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// errEndOfClip is returned by the clips after their last chunk
+var errEndOfClip = errors.New("audiofile: end of clip")
+
+type wavClip struct {
+	f *os.File
+	r *audio.WAVReader
+}
+
+func newWAVClip(f *os.File) (clip, clipInfo, error) {
+	r, err := audio.NewWAVReader(f)
+	if err != nil {
+		return nil, clipInfo{}, err
+	}
+	header := r.Header()
+	info := clipInfo{
+		sampleRate: header.SampleRate,
+		channels:   header.Channels,
+		float:      header.Float,
+	}
+	return &wavClip{f: f, r: r}, info, nil
+}
+
+func (c *wavClip) Next() (wave.Audio, error) {
+	chunk, _, err := c.r.Read()
+	if err == io.EOF {
+		return nil, errEndOfClip
+	}
+	return chunk, err
+}
+
+func (c *wavClip) Close() error {
+	return c.f.Close()
+}
+
+// oggClip decodes the OPUS packets of an Ogg file at 48kHz
+type oggClip struct {
+	f       *os.File
+	r       *opus.OggReader
+	decoder *opus.Decoder
+	// skip is the number of samples left to drop from the start of the clip
+	skip int
+}
+
+func newOggClip(f *os.File) (clip, clipInfo, error) {
+	r, err := opus.NewOggReader(f)
+	if err != nil {
+		return nil, clipInfo{}, err
+	}
+	header := r.Header()
+	if header.Channels > 2 {
+		// the multi-stream mappings aren't supported by the decoder
+		return nil, clipInfo{}, errUnsupportedFile
+	}
+	decoder, err := opus.NewDecoder(header.Channels)
+	if err != nil {
+		return nil, clipInfo{}, err
+	}
+	c := &oggClip{f: f, r: r, decoder: decoder, skip: header.PreSkip}
+	info := clipInfo{
+		sampleRate: 48000,
+		channels:   header.Channels,
+	}
+	return c, info, nil
+}
+
+func (c *oggClip) Next() (wave.Audio, error) {
+	for {
+		packet, err := c.r.ReadPacket()
+		if err == io.EOF {
+			return nil, errEndOfClip
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunk, err := c.decoder.Decode(packet)
+		if err != nil {
+			return nil, err
+		}
+		if chunk == nil {
+			continue
+		}
+		if c.skip > 0 {
+			n := chunk.Size.Len
+			if c.skip >= n {
+				c.skip -= n
+				continue
+			}
+			chunk = &wave.Int16Interleaved{
+				Data: chunk.Data[c.skip*chunk.Size.Channels:],
+				Size: wave.ChunkInfo{
+					Len:          n - c.skip,
+					Channels:     chunk.Size.Channels,
+					SamplingRate: chunk.Size.SamplingRate,
+				},
+			}
+			c.skip = 0
+		}
+		return chunk, nil
+	}
+}
+
+func (c *oggClip) Close() error {
+	c.decoder.Close()
+	return c.f.Close()
+}
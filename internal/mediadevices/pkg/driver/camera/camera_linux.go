@@ -11,6 +11,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"syscall"
@@ -28,11 +29,17 @@ import (
 const (
 	maxEmptyFrameCount = 5
 	prioritizedDevice  = "video0"
+	// pixFmtP010 is V4L2_PIX_FMT_P010, missing from the headers of the older
+	// kernels
+	pixFmtP010 = webcam.PixelFormat('P' | '0'<<8 | '1'<<16 | '0'<<24)
 )
 
 var (
 	errReadTimeout = errors.New("read timeout")
 	errEmptyFrame  = errors.New("empty frame")
+	errNotOpened   = errors.New("camera isn't opened")
+	// errKeyFrameUnsupported is returned when the camera can't be asked for a key frame
+	errKeyFrameUnsupported = errors.New("camera doesn't support key frame requests")
 	// Reference: https://commons.wikimedia.org/wiki/File:Vector_Video_Standards2.svg
 	supportedResolutions = [][2]int{
 		{320, 240},
@@ -67,6 +74,34 @@ var (
 
 const bufCount = 2
 
+// v4l2Controls maps the driver controls to the V4L2 control IDs
+// Reference: https://www.kernel.org/doc/html/latest/userspace-api/media/v4l/control.html
+var v4l2Controls = map[driver.ControlID]webcam.ControlID{
+	driver.ControlBrightness:         C.V4L2_CID_BRIGHTNESS,
+	driver.ControlContrast:           C.V4L2_CID_CONTRAST,
+	driver.ControlSaturation:         C.V4L2_CID_SATURATION,
+	driver.ControlSharpness:          C.V4L2_CID_SHARPNESS,
+	driver.ControlGain:               C.V4L2_CID_GAIN,
+	driver.ControlExposureAuto:       C.V4L2_CID_EXPOSURE_AUTO,
+	driver.ControlExposure:           C.V4L2_CID_EXPOSURE_ABSOLUTE,
+	driver.ControlWhiteBalanceAuto:   C.V4L2_CID_AUTO_WHITE_BALANCE,
+	driver.ControlWhiteBalance:       C.V4L2_CID_WHITE_BALANCE_TEMPERATURE,
+	driver.ControlFocusAuto:          C.V4L2_CID_FOCUS_AUTO,
+	driver.ControlFocus:              C.V4L2_CID_FOCUS_ABSOLUTE,
+	driver.ControlPowerLineFrequency: C.V4L2_CID_POWER_LINE_FREQUENCY,
+}
+
+// v4l2ForceKeyFrame is the button control of the H.264 encoders asking for a key frame
+const v4l2ForceKeyFrame = webcam.ControlID(C.V4L2_CID_MPEG_VIDEO_FORCE_KEY_FRAME)
+
+// controlBackend is the part of webcam.Webcam reading and writing the V4L2
+// controls, it is faked by the tests
+type controlBackend interface {
+	GetControls() map[webcam.ControlID]webcam.Control
+	GetControl(id webcam.ControlID) (int32, error)
+	SetControl(id webcam.ControlID, value int32) error
+}
+
 // Camera implementation using v4l2
 // Reference: https://linuxtv.org/downloads/v4l-dvb-apis/uapi/v4l/videodev.html#videodev
 type camera struct {
@@ -78,24 +113,99 @@ type camera struct {
 	mutex           sync.Mutex
 	cancel          func()
 	prevFrameTime   time.Time
+	// controls is set while the camera is open. It has its own lock, the
+	// reader holds mutex while waiting for a frame.
+	controlsMutex sync.Mutex
+	controls      controlBackend
 }
 
+var (
+	// devicePatterns are the paths scanned for cameras, the links to a same
+	// device are registered once with the first path found
+	devicePatterns = []string{"/dev/v4l/by-id/*", "/dev/v4l/by-path/*", "/dev/video*"}
+
+	// registered maps the device names (video0) of the cameras to the IDs of
+	// their drivers, to delete them when the cameras are unplugged
+	registeredMu sync.Mutex
+	registered   = make(map[string]string)
+)
+
 func init() {
 	Initialize()
 }
 
 // Initialize finds and registers camera devices. This is part of an experimental API.
 func Initialize() {
-	// Clear all registered camera devices to prevent duplicates.
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+
+	// Clear the registered camera devices to prevent duplicates, the
+	// drivers of the other packages are kept.
 	// If first initalize call, this will be a noop.
 	manager := driver.GetManager()
-	for _, d := range manager.Query(driver.FilterVideoRecorder()) {
-		manager.Delete(d.ID())
+	for _, id := range registered {
+		manager.Delete(id)
 	}
+	registered = make(map[string]string)
 	discovered := make(map[string]struct{})
-	discover(discovered, "/dev/v4l/by-id/*")
-	discover(discovered, "/dev/v4l/by-path/*")
-	discover(discovered, "/dev/video*")
+	for _, pattern := range devicePatterns {
+		discover(discovered, pattern)
+	}
+}
+
+// Rescan registers the cameras plugged since the last scan and deletes the
+// drivers of the cameras unplugged, the drivers of the other cameras are kept.
+// This is part of an experimental API.
+func Rescan() {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+
+	present := make(map[string]struct{})
+	for _, pattern := range devicePatterns {
+		devices, _ := filepath.Glob(pattern)
+		for _, device := range devices {
+			present[deviceName(device)] = struct{}{}
+		}
+	}
+
+	discovered := make(map[string]struct{})
+	for name, id := range registered {
+		if _, ok := present[name]; ok {
+			discovered[name] = struct{}{}
+			continue
+		}
+		driver.GetManager().Delete(id)
+		delete(registered, name)
+	}
+	for _, pattern := range devicePatterns {
+		discover(discovered, pattern)
+	}
+}
+
+// Watch rescans the cameras every interval until stop is closed, the cameras
+// plugged or unplugged are then found by Query. This is part of an
+// experimental API.
+func Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			Rescan()
+		}
+	}
+}
+
+// deviceName returns the name of the device a link points to, or the name of
+// the device itself
+func deviceName(device string) string {
+	reallink, err := os.Readlink(device)
+	if err != nil {
+		return filepath.Base(device)
+	}
+	return filepath.Base(reallink)
 }
 
 func discover(discovered map[string]struct{}, pattern string) {
@@ -106,12 +216,7 @@ func discover(discovered map[string]struct{}, pattern string) {
 	}
 	for _, device := range devices {
 		label := filepath.Base(device)
-		reallink, err := os.Readlink(device)
-		if err != nil {
-			reallink = label
-		} else {
-			reallink = filepath.Base(reallink)
-		}
+		reallink := deviceName(device)
 		if _, ok := discovered[reallink]; ok {
 			continue
 		}
@@ -127,9 +232,10 @@ func discover(discovered map[string]struct{}, pattern string) {
 		if webcamCam, err := webcam.Open(cam.path); err == nil {
 			name, _ = webcamCam.GetName()
 			busInfo, _ = webcamCam.GetBusInfo()
+			webcamCam.Close()
 		}
 
-		driver.GetManager().Register(cam, driver.Info{
+		d := driver.GetManager().RegisterDriver(cam, driver.Info{
 			// 	Source: https://www.kernel.org/doc/html/v4.9/media/uapi/v4l/vidioc-querycap.html
 			//	Name of the device, a NUL-terminated UTF-8 string. For example: “Yoyodyne TV/FM”. One driver may support
 			//	different brands or models of video hardware. This information is intended for users, for example in a
@@ -141,6 +247,7 @@ func discover(discovered map[string]struct{}, pattern string) {
 			DeviceType: driver.Camera,
 			Priority:   priority,
 		})
+		registered[reallink] = d.ID()
 	}
 }
 
@@ -151,7 +258,15 @@ func newCamera(path string) *camera {
 		webcam.PixelFormat(C.V4L2_PIX_FMT_NV12):   frame.FormatNV12,
 		webcam.PixelFormat(C.V4L2_PIX_FMT_YUYV):   frame.FormatYUYV,
 		webcam.PixelFormat(C.V4L2_PIX_FMT_UYVY):   frame.FormatUYVY,
+		webcam.PixelFormat(C.V4L2_PIX_FMT_NV16):   frame.FormatNV16,
+		webcam.PixelFormat(C.V4L2_PIX_FMT_YVU420): frame.FormatYV12,
+		pixFmtP010:                                frame.FormatP010,
+		webcam.PixelFormat(C.V4L2_PIX_FMT_GREY):   frame.FormatGREY,
+		webcam.PixelFormat(C.V4L2_PIX_FMT_RGB24):  frame.FormatRGB24,
+		webcam.PixelFormat(C.V4L2_PIX_FMT_BGR24):  frame.FormatBGR24,
+		webcam.PixelFormat(C.V4L2_PIX_FMT_ABGR32): frame.FormatBGRA,
 		webcam.PixelFormat(C.V4L2_PIX_FMT_MJPEG):  frame.FormatMJPEG,
+		webcam.PixelFormat(C.V4L2_PIX_FMT_H264):   frame.FormatH264,
 		webcam.PixelFormat(C.V4L2_PIX_FMT_Z16):    frame.FormatZ16,
 	}
 
@@ -195,6 +310,9 @@ func (c *camera) Open() error {
 
 	c.prevFrameTime = time.Now()
 	c.cam = cam
+	c.controlsMutex.Lock()
+	c.controls = cam
+	c.controlsMutex.Unlock()
 	return nil
 }
 
@@ -203,6 +321,10 @@ func (c *camera) Close() error {
 		return nil
 	}
 
+	c.controlsMutex.Lock()
+	c.controls = nil
+	c.controlsMutex.Unlock()
+
 	if c.cancel != nil {
 		// Let the reader knows that the caller has closed the camera
 		c.cancel()
@@ -225,9 +347,37 @@ func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
+	return c.record(p, decoder)
+}
 
+// EncodedVideoRecord reads the H.264 access units or the JPEG images of the camera without
+// decoding them
+func (c *camera) EncodedVideoRecord(p prop.Media) (video.Reader, error) {
+	decoder, err := frame.NewPassthroughDecoder(p.FrameFormat)
+	if err != nil {
+		return nil, err
+	}
+	return c.record(p, decoder)
+}
+
+// RequestKeyFrame asks the H.264 encoder of the camera for a key frame, the UVC cameras often
+// don't support it and send key frames at their own interval
+func (c *camera) RequestKeyFrame() error {
+	c.controlsMutex.Lock()
+	defer c.controlsMutex.Unlock()
+	if c.controls == nil {
+		return errNotOpened
+	}
+
+	if _, ok := c.controls.GetControls()[v4l2ForceKeyFrame]; !ok {
+		return errKeyFrameUnsupported
+	}
+	return c.controls.SetControl(v4l2ForceKeyFrame, 1)
+}
+
+func (c *camera) record(p prop.Media, decoder frame.Decoder) (video.Reader, error) {
 	pf := c.reversedFormats[p.FrameFormat]
-	_, _, _, err = c.cam.SetImageFormat(pf, uint32(p.Width), uint32(p.Height))
+	_, _, _, err := c.cam.SetImageFormat(pf, uint32(p.Width), uint32(p.Height))
 	if err != nil {
 		return nil, err
 	}
@@ -396,6 +546,73 @@ func (c *camera) Properties() []prop.Media {
 	return properties
 }
 
+// Controls returns the V4L2 controls of the camera known by the driver, the
+// controls of other types are left out
+func (c *camera) Controls() ([]driver.Control, error) {
+	c.controlsMutex.Lock()
+	defer c.controlsMutex.Unlock()
+	if c.controls == nil {
+		return nil, errNotOpened
+	}
+
+	available := c.controls.GetControls()
+	controls := make([]driver.Control, 0, len(v4l2Controls))
+	for id, v4l2ID := range v4l2Controls {
+		control, ok := available[v4l2ID]
+		if !ok {
+			continue
+		}
+		value, err := c.controls.GetControl(v4l2ID)
+		if err != nil {
+			return nil, err
+		}
+		controls = append(controls, driver.Control{
+			ID:    id,
+			Type:  controlType(control.Type),
+			Min:   control.Min,
+			Max:   control.Max,
+			Step:  control.Step,
+			Value: value,
+		})
+	}
+	sort.Slice(controls, func(i, j int) bool { return controls[i].ID < controls[j].ID })
+	return controls, nil
+}
+
+// SetControl sets a V4L2 control of the camera after checking its range
+func (c *camera) SetControl(id driver.ControlID, value int32) error {
+	c.controlsMutex.Lock()
+	defer c.controlsMutex.Unlock()
+	if c.controls == nil {
+		return errNotOpened
+	}
+
+	v4l2ID, ok := v4l2Controls[id]
+	if !ok {
+		return driver.ErrUnknownControl
+	}
+	control, ok := c.controls.GetControls()[v4l2ID]
+	if !ok {
+		return driver.ErrUnknownControl
+	}
+	if err := (driver.Control{Min: control.Min, Max: control.Max, Step: control.Step}).Validate(value); err != nil {
+		return err
+	}
+	return c.controls.SetControl(v4l2ID, value)
+}
+
+// controlType converts the control types of webcam.Control
+func controlType(t int32) driver.ControlType {
+	switch t {
+	case 1:
+		return driver.ControlBoolean
+	case 2:
+		return driver.ControlMenu
+	default:
+		return driver.ControlInteger
+	}
+}
+
 func (c *camera) IsAvailable() (bool, error) {
 	var err error
 
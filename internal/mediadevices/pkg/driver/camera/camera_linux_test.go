@@ -4,11 +4,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
 
+	"github.com/blackjack/webcam"
 	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
 )
 
 func TestDiscover(t *testing.T) {
@@ -133,6 +136,58 @@ func TestDiscoverByID(t *testing.T) {
 	}
 }
 
+func TestRescan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defaultPatterns := devicePatterns
+	devicePatterns = []string{filepath.Join(dir, "rescan-unittest-video*")}
+	defer func() { devicePatterns = defaultPatterns }()
+
+	query := func() []driver.Driver {
+		return driver.GetManager().Query(func(d driver.Driver) bool {
+			return strings.Contains(d.Info().Label, "rescan-unittest")
+		})
+	}
+
+	plug := func(name string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plug("rescan-unittest-video0")
+	Rescan()
+	drvs := query()
+	if len(drvs) != 1 {
+		t.Fatalf("Expected 1 driver, got %d drivers", len(drvs))
+	}
+	id := drvs[0].ID()
+
+	plug("rescan-unittest-video1")
+	Rescan()
+	drvs = query()
+	if len(drvs) != 2 {
+		t.Fatalf("Expected 2 drivers, got %d drivers", len(drvs))
+	}
+	for _, d := range drvs {
+		if strings.HasSuffix(d.Info().Label, "video0") && d.ID() != id {
+			t.Errorf("Expected the driver of the camera still plugged to be kept")
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dir, "rescan-unittest-video0")); err != nil {
+		t.Fatal(err)
+	}
+	Rescan()
+	drvs = query()
+	if len(drvs) != 1 || !strings.HasSuffix(drvs[0].Info().Label, "video1") {
+		t.Errorf("Expected the driver of the unplugged camera to be deleted, got %d drivers", len(drvs))
+	}
+}
+
 func TestGetCameraReadTimeout(t *testing.T) {
 	var expected uint32 = 5
 	value := getCameraReadTimeout()
@@ -191,3 +246,100 @@ func TestCalcFramerate(t *testing.T) {
 		t.Errorf("Expected divide by zero error")
 	}
 }
+
+type fakeControlBackend struct {
+	controls map[webcam.ControlID]webcam.Control
+	values   map[webcam.ControlID]int32
+}
+
+func newFakeControlBackend() *fakeControlBackend {
+	return &fakeControlBackend{
+		controls: map[webcam.ControlID]webcam.Control{
+			v4l2Controls[driver.ControlBrightness]:   {Name: "Brightness", Min: -64, Max: 64, Step: 1},
+			v4l2Controls[driver.ControlExposureAuto]: {Name: "Auto Exposure", Min: 0, Max: 3, Step: 1, Type: 2},
+			v4l2Controls[driver.ControlFocusAuto]:    {Name: "Focus, Auto", Min: 0, Max: 1, Step: 1, Type: 1},
+			// unknown to the driver
+			0x0098091f: {Name: "Backlight Compensation", Min: 0, Max: 2, Step: 1},
+		},
+		values: map[webcam.ControlID]int32{},
+	}
+}
+
+func (b *fakeControlBackend) GetControls() map[webcam.ControlID]webcam.Control {
+	return b.controls
+}
+
+func (b *fakeControlBackend) GetControl(id webcam.ControlID) (int32, error) {
+	return b.values[id], nil
+}
+
+func (b *fakeControlBackend) SetControl(id webcam.ControlID, value int32) error {
+	b.values[id] = value
+	return nil
+}
+
+func TestControls(t *testing.T) {
+	c := newCamera("/dev/null")
+	if _, err := c.Controls(); err != errNotOpened {
+		t.Errorf("Expected %v, got %v", errNotOpened, err)
+	}
+
+	backend := newFakeControlBackend()
+	c.controls = backend
+
+	if err := c.SetControl(driver.ControlBrightness, 32); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value := backend.values[v4l2Controls[driver.ControlBrightness]]; value != 32 {
+		t.Errorf("Expected the brightness to be 32, got %d", value)
+	}
+	if err := c.SetControl(driver.ControlBrightness, 65); err != driver.ErrControlOutOfRange {
+		t.Errorf("Expected %v, got %v", driver.ErrControlOutOfRange, err)
+	}
+	if err := c.SetControl(driver.ControlFocus, 10); err != driver.ErrUnknownControl {
+		t.Errorf("Expected %v, got %v", driver.ErrUnknownControl, err)
+	}
+
+	controls, err := c.Controls()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []driver.Control{
+		{ID: driver.ControlBrightness, Type: driver.ControlInteger, Min: -64, Max: 64, Step: 1, Value: 32},
+		{ID: driver.ControlExposureAuto, Type: driver.ControlMenu, Min: 0, Max: 3, Step: 1},
+		{ID: driver.ControlFocusAuto, Type: driver.ControlBoolean, Min: 0, Max: 1, Step: 1},
+	}
+	if !reflect.DeepEqual(controls, expected) {
+		t.Errorf("Expected %v, got %v", expected, controls)
+	}
+}
+
+func TestRequestKeyFrame(t *testing.T) {
+	c := newCamera("/dev/null")
+	if err := c.RequestKeyFrame(); err != errNotOpened {
+		t.Errorf("Expected %v, got %v", errNotOpened, err)
+	}
+
+	backend := newFakeControlBackend()
+	c.controls = backend
+	if err := c.RequestKeyFrame(); err != errKeyFrameUnsupported {
+		t.Errorf("Expected %v, got %v", errKeyFrameUnsupported, err)
+	}
+
+	backend.controls[v4l2ForceKeyFrame] = webcam.Control{Type: 4}
+	if err := c.RequestKeyFrame(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value := backend.values[v4l2ForceKeyFrame]; value != 1 {
+		t.Errorf("Expected the button to be pressed, got %d", value)
+	}
+}
+
+func TestPassthroughFormats(t *testing.T) {
+	c := newCamera("/dev/null")
+	if c.reversedFormats[frame.FormatH264] == 0 {
+		t.Error("Expected the H.264 of the cameras to be supported")
+	}
+	var _ driver.EncodedVideoRecorder = c
+	var _ driver.KeyFrameRequester = c
+}
@@ -0,0 +1,112 @@
+package driver
+
+import (
+	"errors"
+)
+
+var (
+	// ErrControlsUnsupported is returned when the driver has no controls
+	ErrControlsUnsupported = errors.New("driver doesn't support controls")
+	// ErrUnknownControl is returned when the device doesn't have the control
+	ErrUnknownControl = errors.New("unknown control")
+	// ErrControlOutOfRange is returned when the value is out of the range of the control
+	ErrControlOutOfRange = errors.New("control value out of range")
+)
+
+// ControlID is the name of a device control
+type ControlID string
+
+// List of the device controls
+const (
+	ControlBrightness ControlID = "brightness"
+	ControlContrast   ControlID = "contrast"
+	ControlSaturation ControlID = "saturation"
+	ControlSharpness  ControlID = "sharpness"
+	ControlGain       ControlID = "gain"
+	// ControlExposureAuto is a menu: 0 auto, 1 manual, 2 shutter priority,
+	// 3 aperture priority
+	ControlExposureAuto ControlID = "exposure_auto"
+	// ControlExposure is the exposure time in 100µs units, used when the
+	// exposure is manual
+	ControlExposure         ControlID = "exposure"
+	ControlWhiteBalanceAuto ControlID = "white_balance_auto"
+	// ControlWhiteBalance is the white balance temperature in Kelvin, used
+	// when the white balance isn't automatic
+	ControlWhiteBalance ControlID = "white_balance"
+	ControlFocusAuto    ControlID = "focus_auto"
+	// ControlFocus is the focus distance, used when the focus isn't automatic
+	ControlFocus ControlID = "focus"
+	// ControlPowerLineFrequency is a menu: 0 disabled, 1 50Hz, 2 60Hz, 3 auto
+	ControlPowerLineFrequency ControlID = "power_line_frequency"
+)
+
+// ControlIDs lists the device controls
+var ControlIDs = []ControlID{
+	ControlBrightness,
+	ControlContrast,
+	ControlSaturation,
+	ControlSharpness,
+	ControlGain,
+	ControlExposureAuto,
+	ControlExposure,
+	ControlWhiteBalanceAuto,
+	ControlWhiteBalance,
+	ControlFocusAuto,
+	ControlFocus,
+	ControlPowerLineFrequency,
+}
+
+// ControlType is the type of the value of a control
+type ControlType string
+
+// List of the control types
+const (
+	ControlInteger ControlType = "integer"
+	ControlBoolean ControlType = "boolean"
+	ControlMenu    ControlType = "menu"
+)
+
+// Control describes a device control and its current value
+type Control struct {
+	ID    ControlID   `json:"id"`
+	Type  ControlType `json:"type"`
+	Min   int32       `json:"min"`
+	Max   int32       `json:"max"`
+	Step  int32       `json:"step"`
+	Value int32       `json:"value"`
+}
+
+// ControlAdapter is implemented by the adapters of devices having controls,
+// like the brightness or the exposure of a camera. The controls are only
+// available while the device is open.
+type ControlAdapter interface {
+	Controls() ([]Control, error)
+	SetControl(id ControlID, value int32) error
+}
+
+// Controls returns the controls of d, ErrControlsUnsupported if it has none
+func Controls(d Driver) ([]Control, error) {
+	if ca, ok := d.(ControlAdapter); ok {
+		return ca.Controls()
+	}
+	return nil, ErrControlsUnsupported
+}
+
+// SetControl sets a control of d
+func SetControl(d Driver, id ControlID, value int32) error {
+	if ca, ok := d.(ControlAdapter); ok {
+		return ca.SetControl(id, value)
+	}
+	return ErrControlsUnsupported
+}
+
+// Validate checks that value fits the range and the step of the control
+func (c Control) Validate(value int32) error {
+	if value < c.Min || value > c.Max {
+		return ErrControlOutOfRange
+	}
+	if c.Step > 1 && (value-c.Min)%c.Step != 0 {
+		return ErrControlOutOfRange
+	}
+	return nil
+}
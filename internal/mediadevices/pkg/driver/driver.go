@@ -15,6 +15,19 @@ type AudioRecorder interface {
 	AudioRecord(p prop.Media) (r audio.Reader, err error)
 }
 
+// EncodedVideoRecorder is implemented by the video drivers which can read the frames compressed by
+// the device without decoding them, like the H.264 or the MJPEG of the USB cameras. The frames of
+// the reader are *frame.Encoded in p.FrameFormat.
+type EncodedVideoRecorder interface {
+	EncodedVideoRecord(p prop.Media) (r video.Reader, err error)
+}
+
+// KeyFrameRequester is implemented by the drivers of the devices compressing the frames which can
+// be asked for a key frame
+type KeyFrameRequester interface {
+	RequestKeyFrame() error
+}
+
 // Priority represents device selection priority level
 type Priority float32
 
@@ -0,0 +1,236 @@
+/*
+Package libcamera provides a driver for the cameras handled by libcamera, like the CSI cameras of
+the Raspberry Pi since Raspberry Pi OS Bookworm, where the legacy MMAL stack is gone.
+
+The frames are captured by the rpicam-vid (or libcamera-vid on older releases) tool, which runs the
+sensor through the ISP and writes raw I420 frames to its standard output. The ISP scales the frames,
+so every resolution up to the size of the sensor is available; the width is a multiple of 64 to
+get unpadded rows.
+
+The device label is in the format of:
+
+	imx219;libcamera0
+*/
+package libcamera
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// LabelSeparator is used to separate the model of the sensor from the name of
+// the camera in the label
+const LabelSeparator = ";"
+
+// widthAlignment is the alignment of the rows written by rpicam-vid, narrower
+// frames would be padded
+const widthAlignment = 64
+
+var (
+	errNotOpened = errors.New("libcamera: camera isn't opened")
+	errRecording = errors.New("libcamera: camera is already recording")
+
+	// scaledResolutions are offered on top of the sensor modes, the ISP scales
+	// the frames of the fastest mode covering them
+	scaledResolutions = [][2]int{
+		{320, 240},
+		{640, 480},
+		{1280, 720},
+		{1280, 960},
+		{1920, 1080},
+	}
+)
+
+// SensorMode is a native mode of the sensor
+type SensorMode struct {
+	Width     int
+	Height    int
+	FrameRate float32
+}
+
+// CameraInfo describes a camera listed by the backend
+type CameraInfo struct {
+	// Index of the camera, given to the backend to capture from it
+	Index int
+	// Model of the sensor, like imx219
+	Model string
+	// Path of the sensor in the device tree
+	Path  string
+	Modes []SensorMode
+}
+
+// Backend lists the cameras and captures their frames, the default backend
+// runs the rpicam tools. It's replaced by tests.
+type Backend interface {
+	Cameras() ([]CameraInfo, error)
+	// Capture starts capturing I420 frames, the frames are read back to back
+	// from the returned reader until it's closed
+	Capture(index int, p prop.Media) (io.ReadCloser, error)
+}
+
+var (
+	backendMu sync.Mutex
+	backend   Backend = newToolBackend()
+
+	// registered are the IDs of the drivers registered by Initialize
+	registered []string
+)
+
+func init() {
+	Initialize()
+}
+
+// Initialize finds and registers the libcamera cameras. This is part of an experimental API.
+func Initialize() {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+
+	manager := driver.GetManager()
+	for _, id := range registered {
+		manager.Delete(id)
+	}
+	registered = nil
+
+	cameras, err := backend.Cameras()
+	if err != nil {
+		// No libcamera tools or no camera
+		return
+	}
+	for _, info := range cameras {
+		d := manager.RegisterDriver(newCamera(backend, info), driver.Info{
+			Label:      info.Model + LabelSeparator + "libcamera" + strconv.Itoa(info.Index),
+			Name:       info.Model + LabelSeparator + info.Path,
+			DeviceType: driver.Camera,
+			Priority:   driver.PriorityNormal,
+		})
+		registered = append(registered, d.ID())
+	}
+}
+
+// SetBackend replaces the backend and registers its cameras instead of the
+// ones of the previous backend. This is part of an experimental API.
+func SetBackend(b Backend) {
+	backendMu.Lock()
+	backend = b
+	backendMu.Unlock()
+	Initialize()
+}
+
+type camera struct {
+	backend Backend
+	info    CameraInfo
+
+	mu      sync.Mutex
+	opened  bool
+	capture io.ReadCloser
+}
+
+func newCamera(backend Backend, info CameraInfo) *camera {
+	return &camera{
+		backend: backend,
+		info:    info,
+	}
+}
+
+func (c *camera) Open() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opened = true
+	return nil
+}
+
+func (c *camera) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opened = false
+	if c.capture == nil {
+		return nil
+	}
+	err := c.capture.Close()
+	c.capture = nil
+	return err
+}
+
+func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
+	if p.FrameFormat != frame.FormatI420 {
+		return nil, fmt.Errorf("libcamera: unsupported frame format %s", p.FrameFormat)
+	}
+	decoder, err := frame.NewDecoder(p.FrameFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.opened {
+		return nil, errNotOpened
+	}
+	if c.capture != nil {
+		return nil, errRecording
+	}
+	capture, err := c.backend.Capture(c.info.Index, p)
+	if err != nil {
+		return nil, err
+	}
+	c.capture = capture
+
+	buf := make([]byte, p.Width*p.Height*3/2)
+	r := video.ReaderFunc(func() (image.Image, func(), error) {
+		if _, err := io.ReadFull(capture, buf); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return nil, func() {}, err
+		}
+		return decoder.Decode(buf, p.Width, p.Height)
+	})
+	return r, nil
+}
+
+func (c *camera) Properties() []prop.Media {
+	var properties []prop.Media
+	add := func(width, height int, fps float32) {
+		if width%widthAlignment != 0 {
+			return
+		}
+		for _, p := range properties {
+			if p.Width == width && p.Height == height && p.FrameRate == fps {
+				return
+			}
+		}
+		properties = append(properties, prop.Media{
+			Video: prop.Video{
+				Width:       width,
+				Height:      height,
+				FrameFormat: frame.FormatI420,
+				FrameRate:   fps,
+			},
+		})
+	}
+
+	for _, mode := range c.info.Modes {
+		add(mode.Width, mode.Height, mode.FrameRate)
+	}
+	for _, resolution := range scaledResolutions {
+		width, height := resolution[0], resolution[1]
+		var fps float32
+		for _, mode := range c.info.Modes {
+			if mode.Width >= width && mode.Height >= height && mode.FrameRate > fps {
+				fps = mode.FrameRate
+			}
+		}
+		if fps > 0 {
+			add(width, height, fps)
+		}
+	}
+	return properties
+}
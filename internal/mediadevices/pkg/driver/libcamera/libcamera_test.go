@@ -0,0 +1,168 @@
+package libcamera
+
+import (
+	"image"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+const cameraList = `Available cameras
+-----------------
+0 : imx219 [3280x2464 10-bit RGGB] (/base/soc/i2c0mux/i2c@1/imx219@10)
+    Modes: 'SRGGB10_CSI2P' : 640x480 [206.65 fps - (1000, 752)/1280x960 crop]
+                             1640x1232 [41.85 fps - (0, 0)/3280x2464 crop]
+                             1920x1080 [47.57 fps - (680, 692)/1920x1080 crop]
+                             3280x2464 [21.19 fps - (0, 0)/3280x2464 crop]
+
+1 : ov5647 [2592x1944 10-bit GBRG] (/base/soc/i2c0mux/i2c@0/ov5647@36)
+    Modes: 'SGBRG10_CSI2P' : 640x480 [58.92 fps - (16, 0)/2560x1920 crop]
+`
+
+func TestParseCameraList(t *testing.T) {
+	cameras := parseCameraList(strings.NewReader(cameraList))
+	expected := []CameraInfo{
+		{
+			Index: 0,
+			Model: "imx219",
+			Path:  "/base/soc/i2c0mux/i2c@1/imx219@10",
+			Modes: []SensorMode{
+				{640, 480, 206.65},
+				{1640, 1232, 41.85},
+				{1920, 1080, 47.57},
+				{3280, 2464, 21.19},
+			},
+		},
+		{
+			Index: 1,
+			Model: "ov5647",
+			Path:  "/base/soc/i2c0mux/i2c@0/ov5647@36",
+			Modes: []SensorMode{{640, 480, 58.92}},
+		},
+	}
+	if !reflect.DeepEqual(cameras, expected) {
+		t.Errorf("Expected %v, got %v", expected, cameras)
+	}
+}
+
+// mockBackend captures frames filled with the index of the frame
+type mockBackend struct {
+	cameras  []CameraInfo
+	captures []*mockCapture
+}
+
+type mockCapture struct {
+	index  int
+	p      prop.Media
+	frames int
+	closed bool
+}
+
+func (b *mockBackend) Cameras() ([]CameraInfo, error) {
+	return b.cameras, nil
+}
+
+func (b *mockBackend) Capture(index int, p prop.Media) (io.ReadCloser, error) {
+	capture := &mockCapture{index: index, p: p}
+	b.captures = append(b.captures, capture)
+	return capture, nil
+}
+
+func (c *mockCapture) Read(b []byte) (int, error) {
+	if c.closed {
+		return 0, io.EOF
+	}
+	// a frame is written in two reads
+	n := c.p.Width * c.p.Height * 3 / 4
+	if n > len(b) {
+		n = len(b)
+	}
+	for i := range b[:n] {
+		b[i] = byte(c.frames / 2)
+	}
+	c.frames++
+	return n, nil
+}
+
+func (c *mockCapture) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestCamera(t *testing.T) {
+	backend := &mockBackend{cameras: parseCameraList(strings.NewReader(cameraList))}
+	SetBackend(backend)
+	defer SetBackend(newToolBackend())
+
+	drivers := driver.GetManager().Query(func(d driver.Driver) bool {
+		return d.Info().Label == "ov5647"+LabelSeparator+"libcamera1"
+	})
+	if len(drivers) != 1 {
+		t.Fatalf("Expected 1 driver, got %d drivers", len(drivers))
+	}
+	d := drivers[0]
+	if err := d.Open(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer d.Close()
+
+	expected := []prop.Video{
+		{Width: 640, Height: 480, FrameFormat: frame.FormatI420, FrameRate: 58.92},
+		{Width: 320, Height: 240, FrameFormat: frame.FormatI420, FrameRate: 58.92},
+	}
+	var properties []prop.Video
+	for _, p := range d.Properties() {
+		properties = append(properties, p.Video)
+	}
+	if !reflect.DeepEqual(properties, expected) {
+		t.Errorf("Expected %v, got %v", expected, properties)
+	}
+
+	r, err := d.(driver.VideoRecorder).VideoRecord(prop.Media{Video: expected[1]})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		img, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		yuv := img.(*image.YCbCr)
+		if yuv.Rect.Dx() != 320 || yuv.Rect.Dy() != 240 {
+			t.Errorf("Expected a 320x240 frame, got %v", yuv.Rect)
+		}
+		if yuv.Y[0] != byte(i) || yuv.Cr[len(yuv.Cr)-1] != byte(i) {
+			t.Errorf("Frame %d: expected the frame to be read whole, got %d and %d", i, yuv.Y[0], yuv.Cr[len(yuv.Cr)-1])
+		}
+	}
+	if capture := backend.captures[0]; capture.index != 1 {
+		t.Errorf("Expected to capture the camera 1, got %d", capture.index)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !backend.captures[0].closed {
+		t.Error("Expected the capture to be stopped")
+	}
+	if _, _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestCameraProperties(t *testing.T) {
+	c := newCamera(&mockBackend{}, parseCameraList(strings.NewReader(cameraList))[0])
+	for _, p := range c.Properties() {
+		if p.Width%widthAlignment != 0 {
+			t.Errorf("Expected the widths to be aligned, got %d", p.Width)
+		}
+		if p.Width == 1280 && p.Height == 720 && p.FrameRate != 47.57 {
+			t.Errorf("Expected 1280x720 to be scaled from the fastest mode covering it, got %v fps", p.FrameRate)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package libcamera
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+var (
+	errNoTools = errors.New("libcamera: neither rpicam nor libcamera tools found")
+
+	// 0 : imx219 [3280x2464 10-bit RGGB] (/base/soc/i2c0mux/i2c@1/imx219@10)
+	cameraLine = regexp.MustCompile(`^\s*(\d+)\s*:\s*(\S+)\s*\[[^\]]*\]\s*\(([^)]*)\)`)
+	// 'SRGGB10_CSI2P' : 640x480 [206.65 fps - (1000, 752)/1280x960 crop]
+	modeLine = regexp.MustCompile(`(\d+)x(\d+)\s*\[([\d.]+)\s*fps`)
+)
+
+// toolBackend runs rpicam-hello to list the cameras and rpicam-vid to capture
+// them, or their libcamera- counterparts on releases older than Bookworm
+type toolBackend struct{}
+
+func newToolBackend() *toolBackend {
+	return &toolBackend{}
+}
+
+// tool returns the path of the rpicam or libcamera tool named name
+func tool(name string) (string, error) {
+	for _, prefix := range []string{"rpicam-", "libcamera-"} {
+		if path, err := exec.LookPath(prefix + name); err == nil {
+			return path, nil
+		}
+	}
+	return "", errNoTools
+}
+
+func (b *toolBackend) Cameras() ([]CameraInfo, error) {
+	hello, err := tool("hello")
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.Command(hello, "--list-cameras").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseCameraList(strings.NewReader(string(out))), nil
+}
+
+func (b *toolBackend) Capture(index int, p prop.Media) (io.ReadCloser, error) {
+	vid, err := tool("vid")
+	if err != nil {
+		return nil, err
+	}
+	args := []string{
+		"--camera", strconv.Itoa(index),
+		"--timeout", "0",
+		"--nopreview",
+		"--codec", "yuv420",
+		"--width", strconv.Itoa(p.Width),
+		"--height", strconv.Itoa(p.Height),
+		"--output", "-",
+	}
+	if p.FrameRate > 0 {
+		args = append(args, "--framerate", strconv.FormatFloat(float64(p.FrameRate), 'f', -1, 32))
+	}
+	cmd := exec.Command(vid, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// process is the output of a capture tool, closing it stops the tool
+type process struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (p *process) Close() error {
+	_ = p.cmd.Process.Kill()
+	// the exit status of a killed process is meaningless
+	_ = p.cmd.Wait()
+	return nil
+}
+
+// parseCameraList reads the cameras and their sensor modes printed by
+// rpicam-hello --list-cameras
+func parseCameraList(r io.Reader) []CameraInfo {
+	var cameras []CameraInfo
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := cameraLine.FindStringSubmatch(line); m != nil {
+			index, _ := strconv.Atoi(m[1])
+			cameras = append(cameras, CameraInfo{Index: index, Model: m[2], Path: m[3]})
+			continue
+		}
+		if len(cameras) == 0 {
+			continue
+		}
+		if m := modeLine.FindStringSubmatch(line); m != nil {
+			width, _ := strconv.Atoi(m[1])
+			height, _ := strconv.Atoi(m[2])
+			fps, _ := strconv.ParseFloat(m[3], 32)
+			camera := &cameras[len(cameras)-1]
+			camera.Modes = append(camera.Modes, SensorMode{Width: width, Height: height, FrameRate: float32(fps)})
+		}
+	}
+	return cameras
+}
@@ -72,11 +72,18 @@ func GetManager() *Manager {
 
 // Register registers adapter to be discoverable by Query
 func (m *Manager) Register(a Adapter, info Info) error {
+	m.RegisterDriver(a, info)
+	return nil
+}
+
+// RegisterDriver registers adapter like Register and returns its driver, the ID of the driver
+// is given to Delete when the device is unplugged
+func (m *Manager) RegisterDriver(a Adapter, info Info) Driver {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	d := wrapAdapter(a, info)
 	m.drivers[d.ID()] = d
-	return nil
+	return d
 }
 
 // Query queries by using f to filter drivers, and simply return the filtered results.
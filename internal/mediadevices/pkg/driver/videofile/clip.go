@@ -0,0 +1,217 @@
+package videofile
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/webrtc/v3/pkg/media/ivfreader"
+	"golang.org/x/image/vp8"
+)
+
+// errEndOfClip is returned by the clips after their last frame
+var errEndOfClip = errors.New("videofile: end of clip")
+
+type y4mClip struct {
+	f         *os.File
+	r         *video.Y4MReader
+	frameRate float64
+	n         int
+}
+
+func newY4MClip(f *os.File) (clip, clipInfo, error) {
+	r, err := video.NewY4MReader(f)
+	if err != nil {
+		return nil, clipInfo{}, err
+	}
+	header := r.Header()
+	info := clipInfo{
+		width:     header.Width,
+		height:    header.Height,
+		frameRate: float32(header.FrameRate),
+	}
+	switch header.SubsampleRatio {
+	case image.YCbCrSubsampleRatio420:
+		info.format = frame.FormatI420
+	case image.YCbCrSubsampleRatio444:
+		info.format = frame.FormatI444
+	default:
+		return nil, clipInfo{}, errUnsupportedFile
+	}
+
+	frameRate := header.FrameRate
+	if frameRate <= 0 {
+		frameRate = defaultFrameRate
+	}
+	return &y4mClip{f: f, r: r, frameRate: frameRate}, info, nil
+}
+
+func (c *y4mClip) Next() (image.Image, time.Duration, error) {
+	img, _, err := c.r.Read()
+	if err == io.EOF {
+		return nil, 0, errEndOfClip
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	pts := time.Duration(float64(c.n) * float64(time.Second) / c.frameRate)
+	c.n++
+	return img, pts, nil
+}
+
+func (c *y4mClip) Close() error {
+	return c.f.Close()
+}
+
+// vp8KeyFrames decodes the VP8 key frames, the inter frames repeat the last
+// key frame
+type vp8KeyFrames struct {
+	decoder *vp8.Decoder
+	last    image.Image
+	// onInterFrame is called for the inter frames, the clip isn't all-intra
+	onInterFrame func()
+}
+
+func newVP8KeyFrames(onInterFrame func()) *vp8KeyFrames {
+	return &vp8KeyFrames{decoder: vp8.NewDecoder(), onInterFrame: onInterFrame}
+}
+
+// decode returns the last key frame, nil until the first one
+func (d *vp8KeyFrames) decode(payload []byte) (image.Image, error) {
+	d.decoder.Init(bytes.NewReader(payload), len(payload))
+	fh, err := d.decoder.DecodeFrameHeader()
+	if err != nil {
+		return nil, err
+	}
+	if !fh.KeyFrame {
+		if d.onInterFrame != nil {
+			d.onInterFrame()
+		}
+		return d.last, nil
+	}
+	img, err := d.decoder.DecodeFrame()
+	if err != nil {
+		return nil, err
+	}
+	d.last = img
+	return img, nil
+}
+
+// ivfClip decodes the VP8 key frames of an IVF file
+type ivfClip struct {
+	f        *os.File
+	r        *ivfreader.IVFReader
+	timebase time.Duration
+	frames   *vp8KeyFrames
+}
+
+func newIVFClip(f *os.File, onInterFrame func()) (clip, clipInfo, error) {
+	r, header, err := ivfreader.NewWith(f)
+	if err != nil {
+		return nil, clipInfo{}, err
+	}
+	if header.FourCC != "VP80" || header.TimebaseDenominator == 0 || header.TimebaseNumerator == 0 {
+		return nil, clipInfo{}, errUnsupportedFile
+	}
+	c := &ivfClip{
+		f: f,
+		r: r,
+		// the timestamps are in units of numerator/denominator seconds
+		timebase: time.Duration(float64(time.Second) * float64(header.TimebaseNumerator) / float64(header.TimebaseDenominator)),
+		frames:   newVP8KeyFrames(onInterFrame),
+	}
+	info := clipInfo{
+		width:  int(header.Width),
+		height: int(header.Height),
+		format: frame.FormatI420,
+	}
+	if header.TimebaseNumerator == 1 {
+		// the usual time base of 1/fps
+		info.frameRate = float32(header.TimebaseDenominator)
+	}
+	return c, info, nil
+}
+
+func (c *ivfClip) Next() (image.Image, time.Duration, error) {
+	for {
+		payload, header, err := c.r.ParseNextFrame()
+		if err == io.EOF {
+			return nil, 0, errEndOfClip
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		img, err := c.frames.decode(payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		if img == nil {
+			// the clip doesn't start with a key frame
+			continue
+		}
+		return img, time.Duration(header.Timestamp) * c.timebase, nil
+	}
+}
+
+func (c *ivfClip) Close() error {
+	return c.f.Close()
+}
+
+// webmClip decodes the VP8 key frames of a WebM or Matroska file
+type webmClip struct {
+	f      *os.File
+	r      *video.WebMReader
+	frames *vp8KeyFrames
+}
+
+func newWebMClip(f *os.File, onInterFrame func()) (clip, clipInfo, error) {
+	r, err := video.NewWebMReader(f)
+	if err != nil {
+		return nil, clipInfo{}, err
+	}
+	track := r.Track()
+	if track.CodecID != "V_VP8" {
+		r.Close()
+		return nil, clipInfo{}, errUnsupportedFile
+	}
+	info := clipInfo{
+		width:  track.Width,
+		height: track.Height,
+		format: frame.FormatI420,
+	}
+	if track.FrameDuration > 0 {
+		info.frameRate = float32(float64(time.Second) / float64(track.FrameDuration))
+	}
+	return &webmClip{f: f, r: r, frames: newVP8KeyFrames(onInterFrame)}, info, nil
+}
+
+func (c *webmClip) Next() (image.Image, time.Duration, error) {
+	for {
+		f, err := c.r.ReadFrame()
+		if err == io.EOF {
+			return nil, 0, errEndOfClip
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		img, err := c.frames.decode(f.Data)
+		if err != nil {
+			return nil, 0, err
+		}
+		if img == nil {
+			// the clip doesn't start with a key frame
+			continue
+		}
+		return img, f.Timestamp, nil
+	}
+}
+
+func (c *webmClip) Close() error {
+	c.r.Close()
+	return c.f.Close()
+}
@@ -0,0 +1,230 @@
+// Package videofile provides a video driver replaying a clip from a file, to run the camera stack
+// without a camera, on CI or on a laptop, with realistic footage.
+//
+// Raw YUV4MPEG2 (.y4m) files in 4:2:0 or 4:4:4, and IVF, WebM or Matroska (.mkv) files of VP8 are
+// supported. The VP8 frames are decoded in pure Go, which only decodes the key frames: the inter
+// frames repeat the last key frame, so the clips must be encoded with key frames only (for example
+// ffmpeg -g 1). A warning is logged when a clip with inter frames is played.
+//
+// The clip loops and its frames are read at their presentation time. When the
+// PION_MEDIADEVICES_VIDEO_FILE environment variable is set, the file is registered at init with the
+// label in PION_MEDIADEVICES_VIDEO_FILE_LABEL, the file name by default.
+package videofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/internal/logging"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// defaultFrameRate paces the clips which don't tell their frame rate
+const defaultFrameRate = 30
+
+var (
+	logger = logging.NewLogger("mediadevices/driver/videofile")
+
+	errUnsupportedFile = errors.New("videofile: unsupported file, expected a y4m file or an ivf, webm or mkv file of vp8")
+	errNotOpened       = errors.New("videofile: file isn't opened")
+	errEmptyClip       = errors.New("videofile: clip has no frame")
+)
+
+func init() {
+	path := os.Getenv("PION_MEDIADEVICES_VIDEO_FILE")
+	if path == "" {
+		return
+	}
+	if _, err := Register(path, os.Getenv("PION_MEDIADEVICES_VIDEO_FILE_LABEL")); err != nil {
+		logger.Errorf("failed to register %s: %s", path, err)
+	}
+}
+
+// Register registers a driver replaying the file at path, with label or the
+// file name when label is empty
+func Register(path, label string) (driver.Driver, error) {
+	c, info, err := openClip(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.Close()
+
+	if label == "" {
+		label = filepath.Base(path)
+	}
+	return driver.GetManager().RegisterDriver(&fileSource{path: path, info: info}, driver.Info{
+		Label:      label,
+		DeviceType: driver.Camera,
+		Priority:   driver.PriorityLow,
+	}), nil
+}
+
+// clipInfo describes the frames of a clip
+type clipInfo struct {
+	width, height int
+	frameRate     float32
+	format        frame.Format
+}
+
+// clip reads the frames of a file with their presentation time from the start
+// of the clip
+type clip interface {
+	Next() (image.Image, time.Duration, error)
+	Close() error
+}
+
+// openClip opens the clip at path, onInterFrame is called for the VP8 inter
+// frames which can't be decoded
+func openClip(path string, onInterFrame func()) (clip, clipInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, clipInfo{}, err
+	}
+
+	var c clip
+	var info clipInfo
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".y4m":
+		c, info, err = newY4MClip(f)
+	case ".ivf":
+		c, info, err = newIVFClip(f, onInterFrame)
+	case ".webm", ".mkv":
+		c, info, err = newWebMClip(f, onInterFrame)
+	default:
+		err = errUnsupportedFile
+	}
+	if err != nil {
+		f.Close()
+		return nil, clipInfo{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, info, nil
+}
+
+type fileSource struct {
+	path string
+	info clipInfo
+	// interFrames warns once that the clip isn't all-intra
+	interFrames sync.Once
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel func()
+}
+
+func (s *fileSource) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return nil
+}
+
+func (s *fileSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+func (s *fileSource) Properties() []prop.Media {
+	return []prop.Media{{
+		Video: prop.Video{
+			Width:       s.info.width,
+			Height:      s.info.height,
+			FrameFormat: s.info.format,
+			FrameRate:   s.info.frameRate,
+		},
+	}}
+}
+
+// VideoRecord replays the clip from its start, the frames have the size of
+// the file whatever p asks
+func (s *fileSource) VideoRecord(p prop.Media) (video.Reader, error) {
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+	if ctx == nil {
+		return nil, errNotOpened
+	}
+
+	c, _, err := openClip(s.path, s.warnInterFrames)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		start time.Time
+		// offset is the presentation time of the start of the current loop
+		offset, last time.Duration
+		frames       int
+	)
+	r := video.ReaderFunc(func() (image.Image, func(), error) {
+		if ctx.Err() != nil {
+			// Return EOF if the file is already closed.
+			c.Close()
+			return nil, func() {}, io.EOF
+		}
+
+		img, pts, err := c.Next()
+		if err == errEndOfClip {
+			if frames == 0 {
+				return nil, func() {}, errEmptyClip
+			}
+			// the next loop starts a frame after the last one
+			c.Close()
+			if c, _, err = openClip(s.path, s.warnInterFrames); err != nil {
+				return nil, func() {}, err
+			}
+			offset, frames = offset+last+s.frameDuration(), 0
+			img, pts, err = c.Next()
+		}
+		if err != nil {
+			c.Close()
+			return nil, func() {}, err
+		}
+		frames++
+		last = pts
+
+		if start.IsZero() {
+			start = time.Now()
+		}
+		wait := time.Until(start.Add(offset + pts))
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				c.Close()
+				return nil, func() {}, io.EOF
+			case <-timer.C:
+			}
+		}
+		return img, func() {}, nil
+	})
+	return r, nil
+}
+
+func (s *fileSource) warnInterFrames() {
+	s.interFrames.Do(func() {
+		logger.Warnf("%s has inter frames, they repeat the last key frame: encode it with key frames only", s.path)
+	})
+}
+
+func (s *fileSource) frameDuration() time.Duration {
+	fps := s.info.frameRate
+	if fps <= 0 {
+		fps = defaultFrameRate
+	}
+	return time.Duration(float64(time.Second) / float64(fps))
+}
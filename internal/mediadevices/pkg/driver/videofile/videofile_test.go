@@ -0,0 +1,249 @@
+package videofile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/at-wat/ebml-go/webm"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// writeY4M writes a 4:2:0 clip of 16x8 at 50 fps, the luma of a frame is the
+// index of the frame
+func writeY4M(t *testing.T, frames int) string {
+	path := filepath.Join(t.TempDir(), "clip.y4m")
+	var stream bytes.Buffer
+	stream.WriteString("YUV4MPEG2 W16 H8 F50:1 Ip A1:1 C420jpeg\n")
+	for i := 0; i < frames; i++ {
+		stream.WriteString("FRAME\n")
+		stream.Write(bytes.Repeat([]byte{byte(i)}, 16*8))
+		stream.Write(bytes.Repeat([]byte{128}, 16*8/2))
+	}
+	if err := os.WriteFile(path, stream.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// vp8Frame builds a VP8 frame of 32x16, the zero bits of a key frame decode to
+// a gray frame
+func vp8Frame(key bool) []byte {
+	const partitionSize = 16
+	tag := 1<<4 | partitionSize<<5
+	if !key {
+		return []byte{byte(tag | 1), byte(tag >> 8), byte(tag >> 16), 0, 0, 0}
+	}
+	b := []byte{byte(tag), byte(tag >> 8), byte(tag >> 16), 0x9d, 0x01, 0x2a, 32, 0, 16, 0}
+	return append(b, make([]byte, 2*partitionSize)...)
+}
+
+func writeIVF(t *testing.T, frames ...[]byte) string {
+	path := filepath.Join(t.TempDir(), "clip.ivf")
+	var stream bytes.Buffer
+	header := make([]byte, 32)
+	copy(header, "DKIF")
+	binary.LittleEndian.PutUint16(header[6:], 32)
+	copy(header[8:], "VP80")
+	binary.LittleEndian.PutUint16(header[12:], 32)
+	binary.LittleEndian.PutUint16(header[14:], 16)
+	binary.LittleEndian.PutUint32(header[16:], 25)
+	binary.LittleEndian.PutUint32(header[20:], 1)
+	binary.LittleEndian.PutUint32(header[24:], uint32(len(frames)))
+	stream.Write(header)
+	for i, payload := range frames {
+		frameHeader := make([]byte, 12)
+		binary.LittleEndian.PutUint32(frameHeader, uint32(len(payload)))
+		binary.LittleEndian.PutUint64(frameHeader[4:], uint64(i))
+		stream.Write(frameHeader)
+		stream.Write(payload)
+	}
+	if err := os.WriteFile(path, stream.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// writeWebM writes the VP8 frames 40ms apart
+func writeWebM(t *testing.T, frames ...[]byte) string {
+	path := filepath.Join(t.TempDir(), "clip.webm")
+	var file bytes.Buffer
+	ws, err := webm.NewSimpleBlockWriter(nopWriteCloser{&file}, []webm.TrackEntry{{
+		Name: "Video", TrackNumber: 1, TrackUID: 1, CodecID: "V_VP8", TrackType: 1,
+		DefaultDuration: uint64(40 * time.Millisecond),
+		Video:           &webm.Video{PixelWidth: 32, PixelHeight: 16},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, payload := range frames {
+		if _, err := ws[0].Write(payload[0]&1 == 0, int64(i)*40, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws[0].Close()
+	if err := os.WriteFile(path, file.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func record(t *testing.T, d driver.Driver) video.Reader {
+	if err := d.Open(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		d.Close()
+		driver.GetManager().Delete(d.ID())
+	})
+	r, err := d.(driver.VideoRecorder).VideoRecord(d.Properties()[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return r
+}
+
+func TestY4MClip(t *testing.T) {
+	d, err := Register(writeY4M(t, 3), "test clip")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	drivers := driver.GetManager().Query(func(d driver.Driver) bool {
+		return d.Info().Label == "test clip"
+	})
+	if len(drivers) != 1 || drivers[0] != d {
+		t.Fatalf("Expected the driver to be registered, got %v", drivers)
+	}
+	r := record(t, d)
+	expected := prop.Video{Width: 16, Height: 8, FrameFormat: frame.FormatI420, FrameRate: 50}
+	if properties := d.Properties(); len(properties) != 1 || properties[0].Video != expected {
+		t.Errorf("Expected %v, got %v", expected, properties)
+	}
+	start := time.Now()
+	// the clip loops
+	for i := 0; i < 7; i++ {
+		img, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if luma := img.(*image.YCbCr).Y[0]; int(luma) != i%3 {
+			t.Errorf("Frame %d: expected luma %d, got %d", i, i%3, luma)
+		}
+	}
+	// the 7th frame is presented 120ms after the first one
+	if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+		t.Errorf("Expected the frames to be paced, read in %v", elapsed)
+	}
+
+	d.Close()
+	if _, _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestIVFClip(t *testing.T) {
+	d, err := Register(writeIVF(t, vp8Frame(false), vp8Frame(true), vp8Frame(false)), "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if label := d.Info().Label; label != "clip.ivf" {
+		t.Errorf("Expected the file name as label, got %q", label)
+	}
+	r := record(t, d)
+	expected := prop.Video{Width: 32, Height: 16, FrameFormat: frame.FormatI420, FrameRate: 25}
+	if properties := d.Properties(); len(properties) != 1 || properties[0].Video != expected {
+		t.Errorf("Expected %v, got %v", expected, properties)
+	}
+	// the leading inter frame is skipped, the next one repeats the key frame
+	for i := 0; i < 4; i++ {
+		img, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if bounds := img.Bounds(); bounds.Dx() != 32 || bounds.Dy() != 16 {
+			t.Errorf("Unexpected frame size %v", bounds)
+		}
+	}
+}
+
+func TestWebMClip(t *testing.T) {
+	path := writeWebM(t, vp8Frame(false), vp8Frame(true), vp8Frame(false))
+	d, err := Register(path, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r := record(t, d)
+	expected := prop.Video{Width: 32, Height: 16, FrameFormat: frame.FormatI420, FrameRate: 25}
+	if properties := d.Properties(); len(properties) != 1 || properties[0].Video != expected {
+		t.Errorf("Expected %v, got %v", expected, properties)
+	}
+	for i := 0; i < 4; i++ {
+		img, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if bounds := img.Bounds(); bounds.Dx() != 32 || bounds.Dy() != 16 {
+			t.Errorf("Unexpected frame size %v", bounds)
+		}
+	}
+
+	// the inter frames are reported, the clip isn't all-intra
+	var interFrames int
+	c, _, err := openClip(path, func() { interFrames++ })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer c.Close()
+	var pts []time.Duration
+	for {
+		_, timestamp, err := c.Next()
+		if err == errEndOfClip {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		pts = append(pts, timestamp)
+	}
+	if len(pts) != 2 || pts[0] != 40*time.Millisecond || pts[1] != 80*time.Millisecond {
+		t.Errorf("Expected the frames at 40ms and 80ms, got %v", pts)
+	}
+	if interFrames != 2 {
+		t.Errorf("Expected 2 inter frames, got %d", interFrames)
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"clip.mp4":  "",
+		"clip.y4m":  "YUV4MPEG2 W16 H8 C422\n",
+		"clip.ivf":  "DKIF",
+		"clip.webm": "\x1a\x45\xdf\xa3",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Register(path, ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := Register(filepath.Join(dir, "missing.y4m"), ""); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
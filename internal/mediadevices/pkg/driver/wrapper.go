@@ -1,6 +1,8 @@
 package driver
 
 import (
+	"fmt"
+
 	"github.com/google/uuid"
 	"github.com/pion/mediadevices/pkg/driver/availability"
 	"github.com/pion/mediadevices/pkg/io/audio"
@@ -25,6 +27,9 @@ func wrapAdapter(a Adapter, info Info) Driver {
 	if aa, ok := a.(AvailabilityAdapter); ok {
 		d.isAvailable = aa.IsAvailable
 	}
+	if ca, ok := a.(ControlAdapter); ok {
+		d.controls = ca
+	}
 
 	switch v := a.(type) {
 	case VideoRecorder:
@@ -34,7 +39,8 @@ func wrapAdapter(a Adapter, info Info) Driver {
 			Driver
 			VideoRecorder
 			AvailabilityAdapter
-		}{d, d, d}
+			ControlAdapter
+		}{d, d, d, d}
 		return r
 	case AudioRecorder:
 		// Only expose Driver and AudioRecorder interfaces
@@ -43,7 +49,8 @@ func wrapAdapter(a Adapter, info Info) Driver {
 			Driver
 			AudioRecorder
 			AvailabilityAdapter
-		}{d, d, d}
+			ControlAdapter
+		}{d, d, d, d}
 	default:
 		panic("adapter has to be either VideoRecorder/AudioRecorder")
 	}
@@ -57,6 +64,7 @@ type adapterWrapper struct {
 	info        Info
 	state       State
 	isAvailable func() (bool, error)
+	controls    ControlAdapter
 }
 
 func (w *adapterWrapper) ID() string {
@@ -119,3 +127,23 @@ func (w *adapterWrapper) IsAvailable() (bool, error) {
 	}
 	return w.isAvailable()
 }
+
+func (w *adapterWrapper) Controls() ([]Control, error) {
+	if w.controls == nil {
+		return nil, ErrControlsUnsupported
+	}
+	if w.state == StateClosed {
+		return nil, fmt.Errorf("invalid state: driver is closed")
+	}
+	return w.controls.Controls()
+}
+
+func (w *adapterWrapper) SetControl(id ControlID, value int32) error {
+	if w.controls == nil {
+		return ErrControlsUnsupported
+	}
+	if w.state == StateClosed {
+		return fmt.Errorf("invalid state: driver is closed")
+	}
+	return w.controls.SetControl(id, value)
+}
@@ -43,6 +43,23 @@ type availabilityAdapterMock struct{ videoAdapterMock }
 
 func (a *availabilityAdapterMock) IsAvailable() (bool, error) { return true, nil }
 
+type controlAdapterMock struct {
+	videoAdapterMock
+	brightness int32
+}
+
+func (a *controlAdapterMock) Controls() ([]Control, error) {
+	return []Control{{ID: ControlBrightness, Type: ControlInteger, Max: 255, Step: 1, Value: a.brightness}}, nil
+}
+
+func (a *controlAdapterMock) SetControl(id ControlID, value int32) error {
+	if id != ControlBrightness {
+		return ErrUnknownControl
+	}
+	a.brightness = value
+	return nil
+}
+
 func TestVideoWrapperState(t *testing.T) {
 	var a videoAdapterMock
 	d := wrapAdapter(&a, Info{})
@@ -175,3 +192,44 @@ func TestWrapperAvailabilityAdapter(t *testing.T) {
 		t.Errorf("expected false, but got %v", ok)
 	}
 }
+
+func TestWrapperControlAdapter(t *testing.T) {
+	var ca controlAdapterMock
+	d := wrapAdapter(&ca, Info{})
+
+	if err := SetControl(d, ControlBrightness, 10); err == nil {
+		t.Errorf("expected to get an invalid state")
+	}
+
+	if err := d.Open(); err != nil {
+		t.Fatalf("expected to successfully open, but got %v", err)
+	}
+	if err := SetControl(d, ControlBrightness, 10); err != nil {
+		t.Errorf("expected nil, but got %v", err)
+	}
+	controls, err := Controls(d)
+	if err != nil {
+		t.Fatalf("expected nil, but got %v", err)
+	}
+	if len(controls) != 1 || controls[0].Value != 10 {
+		t.Errorf("expected the brightness to be 10, but got %v", controls)
+	}
+
+	var v videoAdapterMock
+	d = wrapAdapter(&v, Info{})
+	if err := d.Open(); err != nil {
+		t.Fatalf("expected to successfully open, but got %v", err)
+	}
+	if _, err := Controls(d); err != ErrControlsUnsupported {
+		t.Errorf("expected %v, but got %v", ErrControlsUnsupported, err)
+	}
+}
+
+func TestControlValidate(t *testing.T) {
+	c := Control{Min: -10, Max: 10, Step: 5}
+	for value, valid := range map[int32]bool{-10: true, 0: true, 10: true, 3: false, 15: false, -15: false} {
+		if err := c.Validate(value); (err == nil) != valid {
+			t.Errorf("%d: expected valid to be %v, but got %v", value, valid, err)
+		}
+	}
+}
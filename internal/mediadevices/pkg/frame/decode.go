@@ -22,12 +22,33 @@ const (
 	FormatYUYV = "YUYV"
 	// FormatUYVY https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-uyvy.html
 	FormatUYVY = "UYVY"
+	// FormatNV16 https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-nv16.html
+	FormatNV16 = "NV16"
+	// FormatYV12 https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-yuv420.html
+	// YV12 is I420 with the Cr plane before the Cb plane
+	FormatYV12 = "YV12"
+	// FormatP010 https://www.kernel.org/doc/html/latest/userspace-api/media/v4l/pixfmt-yuv-planar.html
+	// P010 is NV12 with 10 bits samples in the high bits of 16 bits little endian words
+	FormatP010 = "P010"
+	// FormatGREY https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-grey.html
+	FormatGREY = "GREY"
 
 	// FormatRGBA https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-rgb.html
 	FormatRGBA Format = "RGBA"
+	// FormatRGB24 https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-rgb.html
+	FormatRGB24 = "RGB24"
+	// FormatBGR24 https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-rgb.html
+	FormatBGR24 = "BGR24"
+	// FormatBGRA https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-rgb.html
+	// BGRA is what V4L2 calls ABGR32
+	FormatBGRA = "BGRA"
 
 	// FormatMJPEG https://wiki.videolan.org/MJPEG
 	FormatMJPEG = "MJPEG"
+	// FormatH264 https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-compressed.html
+	// H264 frames are access units in Annex B byte stream format, they can only be read compressed
+	// with NewPassthroughDecoder
+	FormatH264 = "H264"
 
 	// FormatZ16 https://www.kernel.org/doc/html/v5.9/userspace-api/media/v4l/pixfmt-z16.html
 	FormatZ16 = "Z16"
@@ -35,15 +56,30 @@ const (
 
 var decoderMap = map[Format]decoderFunc{
 	FormatI420:  decodeI420,
+	FormatI444:  decodeI444,
 	FormatNV21:  decodeNV21,
 	FormatNV12:  decodeNV12,
 	FormatYUY2:  decodeYUY2,
 	FormatYUYV:  decodeYUY2,
 	FormatUYVY:  decodeUYVY,
+	FormatNV16:  decodeNV16,
+	FormatYV12:  decodeYV12,
+	FormatP010:  decodeP010,
+	FormatGREY:  decodeGREY,
+	FormatRGBA:  decodeRGBA,
+	FormatRGB24: decodeRGB24,
+	FormatBGR24: decodeBGR24,
+	FormatBGRA:  decodeBGRA,
 	FormatMJPEG: decodeMJPEG,
 	FormatZ16:   decodeZ16,
 }
 
+// FullRange tells if the YCbCr frames decoded from f are in the full range of
+// JPEG, like the MJPEG frames, rather than in the limited range of video
+func (f Format) FullRange() bool {
+	return f == FormatMJPEG
+}
+
 func NewDecoder(f Format) (Decoder, error) {
 	decoder, ok := decoderMap[f]
 
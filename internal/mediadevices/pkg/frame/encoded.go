@@ -0,0 +1,115 @@
+package frame
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+)
+
+// Encoded is a frame kept compressed in the format of the device, like an H.264 access unit or a
+// JPEG image of a camera. It goes through the video readers as an image.Image so that the
+// passthrough encoders can send Data as it is, without decoding and encoding it again.
+//
+// The pixels are decoded on demand for the formats which have a decoder, like MJPEG, the other
+// formats have black pixels. The transforms drawing on the frames replace them with decoded
+// frames, which can't be passed through anymore.
+type Encoded struct {
+	Format Format
+	Data   []byte
+	// KeyFrame tells if the frame can be decoded without the previous ones
+	KeyFrame bool
+	Rect     image.Rectangle
+
+	once    sync.Once
+	decoded image.Image
+}
+
+// ColorModel implements image.Image
+func (e *Encoded) ColorModel() color.Model {
+	if img := e.Decoded(); img != nil {
+		return img.ColorModel()
+	}
+	return color.GrayModel
+}
+
+// Bounds implements image.Image
+func (e *Encoded) Bounds() image.Rectangle {
+	return e.Rect
+}
+
+// At implements image.Image
+func (e *Encoded) At(x, y int) color.Color {
+	if img := e.Decoded(); img != nil {
+		return img.At(x, y)
+	}
+	return color.Gray{}
+}
+
+// Decoded returns the frame decoded with the decoder of its format, nil when the format has no
+// decoder or the frame is invalid
+func (e *Encoded) Decoded() image.Image {
+	e.once.Do(func() {
+		decoder, ok := decoderMap[e.Format]
+		if !ok {
+			return
+		}
+		img, _, err := decoder(e.Data, e.Rect.Dx(), e.Rect.Dy())
+		if err == nil {
+			e.decoded = img
+		}
+	})
+	return e.decoded
+}
+
+// passthroughFormats are the formats which can be kept compressed, with the detection of their
+// key frames
+var passthroughFormats = map[Format]func([]byte) bool{
+	FormatH264:  isH264KeyFrame,
+	FormatMJPEG: func([]byte) bool { return true },
+}
+
+// NewPassthroughDecoder returns a decoder keeping the frames of f compressed, as *Encoded images
+func NewPassthroughDecoder(f Format) (Decoder, error) {
+	isKeyFrame, ok := passthroughFormats[f]
+	if !ok {
+		return nil, fmt.Errorf("%s can't be passed through", f)
+	}
+
+	return decoderFunc(func(frame []byte, width, height int) (image.Image, func(), error) {
+		if len(frame) == 0 {
+			return nil, func() {}, fmt.Errorf("empty %s frame", f)
+		}
+		return &Encoded{
+			Format:   f,
+			Data:     frame,
+			KeyFrame: isKeyFrame(frame),
+			Rect:     image.Rect(0, 0, width, height),
+		}, func() {}, nil
+	}), nil
+}
+
+// isH264KeyFrame tells if an access unit in Annex B format has an IDR slice or a sequence
+// parameter set, which comes before the IDR slices
+func isH264KeyFrame(au []byte) bool {
+	const (
+		nalTypeIDR = 5
+		nalTypeSPS = 7
+	)
+	zeros := 0
+	for i, b := range au {
+		switch {
+		case b == 0:
+			zeros++
+			continue
+		case b == 1 && zeros >= 2 && i+1 < len(au):
+			// a start code, the next byte is the header of a NAL unit
+			switch au[i+1] & 0x1f {
+			case nalTypeIDR, nalTypeSPS:
+				return true
+			}
+		}
+		zeros = 0
+	}
+	return false
+}
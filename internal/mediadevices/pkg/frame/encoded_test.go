@@ -0,0 +1,87 @@
+package frame
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestPassthroughDecoderH264(t *testing.T) {
+	testCases := map[string]struct {
+		au       []byte
+		keyFrame bool
+	}{
+		"IDR":         {[]byte{0, 0, 0, 1, 0x65, 0x88, 0x84}, true},
+		"SPSPPSIDR":   {[]byte{0, 0, 0, 1, 0x67, 0x42, 0, 0, 1, 0x68, 0xce, 0, 0, 1, 0x65, 0x88}, true},
+		"AUDNonIDR":   {[]byte{0, 0, 0, 1, 0x09, 0xf0, 0, 0, 0, 1, 0x41, 0x9a}, false},
+		"Truncated":   {[]byte{0, 0, 1}, false},
+		"NoStartCode": {[]byte{0x65, 0x88}, false},
+	}
+	decoder, err := NewPassthroughDecoder(FormatH264)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, testCase := range testCases {
+		img, _, err := decoder.Decode(testCase.au, 32, 16)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		encoded, ok := img.(*Encoded)
+		if !ok {
+			t.Fatalf("%s: expected an encoded frame, got %T", name, img)
+		}
+		if encoded.KeyFrame != testCase.keyFrame {
+			t.Errorf("%s: expected key frame %v, got %v", name, testCase.keyFrame, encoded.KeyFrame)
+		}
+		if !bytes.Equal(encoded.Data, testCase.au) || encoded.Format != FormatH264 {
+			t.Errorf("%s: expected the access unit to be kept", name)
+		}
+		if bounds := img.Bounds(); bounds != image.Rect(0, 0, 32, 16) {
+			t.Errorf("%s: unexpected bounds %v", name, bounds)
+		}
+		// there is no H.264 decoder, the pixels are black
+		if c := img.At(1, 1); c != (color.Gray{}) {
+			t.Errorf("%s: expected black, got %v", name, c)
+		}
+	}
+
+	if _, _, err := decoder.Decode(nil, 32, 16); err == nil {
+		t.Error("Expected an error for an empty frame")
+	}
+	if _, err := NewPassthroughDecoder(FormatI420); err == nil {
+		t.Error("Expected an error for a raw format")
+	}
+	if _, err := NewDecoder(FormatH264); err == nil {
+		t.Error("Expected H264 to have no decoder")
+	}
+}
+
+func TestPassthroughDecoderMJPEG(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 16, 8))
+	for i := range src.Pix {
+		src.Pix[i] = 200
+	}
+	var data bytes.Buffer
+	if err := jpeg.Encode(&data, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder, err := NewPassthroughDecoder(FormatMJPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := decoder.Decode(data.Bytes(), 16, 8)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	encoded := img.(*Encoded)
+	if !encoded.KeyFrame {
+		t.Error("Expected a key frame")
+	}
+	// the pixels are decoded on demand
+	if y := color.GrayModel.Convert(img.At(3, 3)).(color.Gray).Y; y < 195 || y > 205 {
+		t.Errorf("Expected the decoded pixel, got %d", y)
+	}
+}
@@ -0,0 +1,20 @@
+#include <stdint.h>
+
+void decodePackedRGBCGO(
+    uint8_t* rgba,
+    uint8_t* packed,
+    int width, int height,
+    int size, int r, int g, int b)
+{
+  const int l = width * height;
+  int i, src = 0, dst = 0;
+  for (i = 0; i < l; ++i)
+  {
+    rgba[dst] = packed[src + r];
+    rgba[dst + 1] = packed[src + g];
+    rgba[dst + 2] = packed[src + b];
+    rgba[dst + 3] = 0xff;
+    src += size;
+    dst += 4;
+  }
+}
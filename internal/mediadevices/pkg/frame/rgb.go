@@ -0,0 +1,33 @@
+package frame
+
+import (
+	"fmt"
+	"image"
+)
+
+func decodeRGBA(frame []byte, width, height int) (image.Image, func(), error) {
+	fi := 4 * width * height
+
+	if fi > len(frame) {
+		return nil, func() {}, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), fi)
+	}
+
+	return &image.RGBA{
+		Pix:    frame[:fi],
+		Stride: 4 * width,
+		Rect:   image.Rect(0, 0, width, height),
+	}, func() {}, nil
+}
+
+func decodeRGB24(frame []byte, width, height int) (image.Image, func(), error) {
+	return decodePackedRGB(frame, width, height, 3, 0, 1, 2)
+}
+
+func decodeBGR24(frame []byte, width, height int) (image.Image, func(), error) {
+	return decodePackedRGB(frame, width, height, 3, 2, 1, 0)
+}
+
+func decodeBGRA(frame []byte, width, height int) (image.Image, func(), error) {
+	// the alpha of the cameras isn't meaningful, the frames are opaque
+	return decodePackedRGB(frame, width, height, 4, 2, 1, 0)
+}
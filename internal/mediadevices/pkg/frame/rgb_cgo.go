@@ -0,0 +1,34 @@
+//go:build cgo
+// +build cgo
+
+package frame
+
+import (
+	"fmt"
+	"image"
+)
+
+// #include <stdint.h>
+// void decodePackedRGBCGO(uint8_t* rgba, uint8_t* packed, int width, int height, int size, int r, int g, int b);
+import "C"
+
+// decodePackedRGB decodes pixels of size bytes, r, g and b are the offsets of
+// the components in a pixel
+func decodePackedRGB(frame []byte, width, height, size, r, g, b int) (image.Image, func(), error) {
+	fi := size * width * height
+
+	if len(frame) < fi {
+		return nil, func() {}, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), fi)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	C.decodePackedRGBCGO(
+		(*C.uchar)(&img.Pix[0]),
+		(*C.uchar)(&frame[0]),
+		C.int(width), C.int(height),
+		C.int(size), C.int(r), C.int(g), C.int(b),
+	)
+
+	return img, func() {}, nil
+}
@@ -0,0 +1,32 @@
+//go:build !cgo
+// +build !cgo
+
+package frame
+
+import (
+	"fmt"
+	"image"
+)
+
+// decodePackedRGB decodes pixels of size bytes, r, g and b are the offsets of
+// the components in a pixel
+func decodePackedRGB(frame []byte, width, height, size, r, g, b int) (image.Image, func(), error) {
+	fi := size * width * height
+
+	if len(frame) < fi {
+		return nil, func() {}, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), fi)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	dst := 0
+	for src := 0; src < fi; src += size {
+		img.Pix[dst] = frame[src+r]
+		img.Pix[dst+1] = frame[src+g]
+		img.Pix[dst+2] = frame[src+b]
+		img.Pix[dst+3] = 0xff
+		dst += 4
+	}
+
+	return img, func() {}, nil
+}
@@ -0,0 +1,42 @@
+package frame
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeRGB(t *testing.T) {
+	const (
+		width  = 2
+		height = 1
+	)
+	expected := &image.RGBA{
+		Pix:    []byte{0x01, 0x02, 0x03, 0xff, 0x04, 0x05, 0x06, 0xff},
+		Stride: 4 * width,
+		Rect:   image.Rect(0, 0, width, height),
+	}
+	testCases := map[Format][]byte{
+		FormatRGBA:  {0x01, 0x02, 0x03, 0xff, 0x04, 0x05, 0x06, 0xff},
+		FormatRGB24: {0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+		FormatBGR24: {0x03, 0x02, 0x01, 0x06, 0x05, 0x04},
+		// the alpha of the frame is ignored
+		FormatBGRA: {0x03, 0x02, 0x01, 0x00, 0x06, 0x05, 0x04, 0x00},
+	}
+	for format, input := range testCases {
+		decoder, err := NewDecoder(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, _, err := decoder.Decode(input, width, height)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+		if !reflect.DeepEqual(expected, img) {
+			t.Errorf("%s: wrong decode result,\nexpected:\n%+v\ngot:\n%+v", format, expected, img)
+		}
+		if _, _, err := decoder.Decode(input[:len(input)-1], width, height); err == nil {
+			t.Errorf("%s: expected an error for a short frame", format)
+		}
+	}
+}
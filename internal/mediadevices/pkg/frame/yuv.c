@@ -39,3 +39,42 @@ void decodeUYVYCGO(
     ++slow;
   }
 }
+
+void decodeNV16CGO(
+    uint8_t* cb,
+    uint8_t* cr,
+    uint8_t* nv16,
+    int width, int height)
+{
+  const int l = width * height;
+  int i, slow = 0;
+  for (i = 0; i < l; i += 2)
+  {
+    cb[slow] = nv16[i];
+    cr[slow] = nv16[i + 1];
+    ++slow;
+  }
+}
+
+void decodeP010CGO(
+    uint8_t* y,
+    uint8_t* cb,
+    uint8_t* cr,
+    uint8_t* p010,
+    int width, int height)
+{
+  // the 8 most significant bits are the high byte of the little endian words
+  const int yl = width * height;
+  const int cl = yl / 4;
+  const uint8_t* c = p010 + 2 * yl;
+  int i;
+  for (i = 0; i < yl; ++i)
+  {
+    y[i] = p010[2 * i + 1];
+  }
+  for (i = 0; i < cl; ++i)
+  {
+    cb[i] = c[4 * i + 1];
+    cr[i] = c[4 * i + 3];
+  }
+}
@@ -61,3 +61,63 @@ func decodeNV12(frame []byte, width, height int) (image.Image, func(), error) {
 	yuv.Cb, yuv.Cr = yuv.Cr, yuv.Cb
 	return yuv, release, err
 }
+
+func decodeI444(frame []byte, width, height int) (image.Image, func(), error) {
+	yi := width * height
+	cbi := yi + width*height
+	cri := cbi + width*height
+
+	if cri > len(frame) {
+		return nil, func() {}, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), cri)
+	}
+
+	return &image.YCbCr{
+		Y:              frame[:yi],
+		YStride:        width,
+		Cb:             frame[yi:cbi],
+		Cr:             frame[cbi:cri],
+		CStride:        width,
+		SubsampleRatio: image.YCbCrSubsampleRatio444,
+		Rect:           image.Rect(0, 0, width, height),
+	}, func() {}, nil
+}
+
+func decodeYV12(frame []byte, width, height int) (image.Image, func(), error) {
+	img, release, err := decodeI420(frame, width, height)
+	if err != nil {
+		return img, release, err
+	}
+
+	// YV12 is I420 with the chroma planes swapped
+	yuv := img.(*image.YCbCr)
+	yuv.Cb, yuv.Cr = yuv.Cr, yuv.Cb
+	return yuv, release, err
+}
+
+// decodeGREY decodes the luma of a greyscale frame to I420 with a neutral
+// chroma, which the encoders take without converting it
+func decodeGREY(frame []byte, width, height int) (image.Image, func(), error) {
+	yi := width * height
+
+	if yi > len(frame) {
+		return nil, func() {}, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), yi)
+	}
+
+	// the chroma planes are neutral, each has its own samples as the frame
+	// may be edited in place
+	ci := (width / 2) * (height / 2)
+	cb, cr := make([]byte, ci), make([]byte, ci)
+	for i := 0; i < ci; i++ {
+		cb[i], cr[i] = 128, 128
+	}
+
+	return &image.YCbCr{
+		Y:              frame[:yi],
+		YStride:        width,
+		Cb:             cb,
+		Cr:             cr,
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, width, height),
+	}, func() {}, nil
+}
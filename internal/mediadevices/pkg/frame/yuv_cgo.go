@@ -10,6 +10,8 @@ import (
 // #include <stdint.h>
 // void decodeYUY2CGO(uint8_t* y, uint8_t* cb, uint8_t* cr, uint8_t* yuy2, int width, int height);
 // void decodeUYVYCGO(uint8_t* y, uint8_t* cb, uint8_t* cr, uint8_t* uyvy, int width, int height);
+// void decodeNV16CGO(uint8_t* cb, uint8_t* cr, uint8_t* nv16, int width, int height);
+// void decodeP010CGO(uint8_t* y, uint8_t* cb, uint8_t* cr, uint8_t* p010, int width, int height);
 import "C"
 
 func decodeYUY2(frame []byte, width, height int) (image.Image, func(), error) {
@@ -75,3 +77,65 @@ func decodeUYVY(frame []byte, width, height int) (image.Image, func(), error) {
 		Rect:           image.Rect(0, 0, width, height),
 	}, func() {}, nil
 }
+
+func decodeNV16(frame []byte, width, height int) (image.Image, func(), error) {
+	yi := width * height
+	ci := yi / 2
+	fi := yi + 2*ci
+
+	if len(frame) < fi {
+		return nil, func() {}, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), fi)
+	}
+
+	cb := make([]byte, ci)
+	cr := make([]byte, ci)
+
+	C.decodeNV16CGO(
+		(*C.uchar)(&cb[0]),
+		(*C.uchar)(&cr[0]),
+		(*C.uchar)(&frame[yi]),
+		C.int(width), C.int(height),
+	)
+
+	return &image.YCbCr{
+		Y:              frame[:yi],
+		YStride:        width,
+		Cb:             cb,
+		Cr:             cr,
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio422,
+		Rect:           image.Rect(0, 0, width, height),
+	}, func() {}, nil
+}
+
+func decodeP010(frame []byte, width, height int) (image.Image, func(), error) {
+	yi := width * height
+	ci := yi / 4
+	fi := 2*yi + 4*ci
+
+	if len(frame) < fi {
+		return nil, func() {}, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), fi)
+	}
+
+	y := make([]byte, yi)
+	cb := make([]byte, ci)
+	cr := make([]byte, ci)
+
+	C.decodeP010CGO(
+		(*C.uchar)(&y[0]),
+		(*C.uchar)(&cb[0]),
+		(*C.uchar)(&cr[0]),
+		(*C.uchar)(&frame[0]),
+		C.int(width), C.int(height),
+	)
+
+	return &image.YCbCr{
+		Y:              y,
+		YStride:        width,
+		Cb:             cb,
+		Cr:             cr,
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, width, height),
+	}, func() {}, nil
+}
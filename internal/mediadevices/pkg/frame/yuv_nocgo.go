@@ -76,3 +76,67 @@ func decodeUYVY(frame []byte, width, height int) (image.Image, func(), error) {
 		Rect:           image.Rect(0, 0, width, height),
 	}, func() {}, nil
 }
+
+func decodeNV16(frame []byte, width, height int) (image.Image, func(), error) {
+	yi := width * height
+	ci := yi / 2
+	fi := yi + 2*ci
+
+	if len(frame) < fi {
+		return nil, func() {}, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), fi)
+	}
+
+	cb := make([]byte, ci)
+	cr := make([]byte, ci)
+
+	slow := 0
+	for i := yi; i < fi; i += 2 {
+		cb[slow] = frame[i]
+		cr[slow] = frame[i+1]
+		slow++
+	}
+
+	return &image.YCbCr{
+		Y:              frame[:yi],
+		YStride:        width,
+		Cb:             cb,
+		Cr:             cr,
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio422,
+		Rect:           image.Rect(0, 0, width, height),
+	}, func() {}, nil
+}
+
+func decodeP010(frame []byte, width, height int) (image.Image, func(), error) {
+	yi := width * height
+	ci := yi / 4
+	fi := 2*yi + 4*ci
+
+	if len(frame) < fi {
+		return nil, func() {}, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), fi)
+	}
+
+	y := make([]byte, yi)
+	cb := make([]byte, ci)
+	cr := make([]byte, ci)
+
+	// the 8 most significant bits are the high byte of the little endian words
+	for i := range y {
+		y[i] = frame[2*i+1]
+	}
+	c := frame[2*yi:]
+	for i := range cb {
+		cb[i] = c[4*i+1]
+		cr[i] = c[4*i+3]
+	}
+
+	return &image.YCbCr{
+		Y:              y,
+		YStride:        width,
+		Cb:             cb,
+		Cr:             cr,
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, width, height),
+	}, func() {}, nil
+}
@@ -139,6 +139,179 @@ func TestDecodeNV12(t *testing.T) {
 	}
 }
 
+func TestDecodeNV16(t *testing.T) {
+	const (
+		width  = 2
+		height = 2
+	)
+	input := []byte{
+		0x01, 0x03, 0x05, 0x07, // Y
+		// Cb  Cr
+		0x82, 0x84,
+		0x86, 0x88,
+	}
+	expected := &image.YCbCr{
+		Y:              []byte{0x01, 0x03, 0x05, 0x07},
+		YStride:        width,
+		Cb:             []byte{0x82, 0x86},
+		Cr:             []byte{0x84, 0x88},
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio422,
+		Rect:           image.Rect(0, 0, width, height),
+	}
+
+	decoder, err := NewDecoder(FormatNV16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := decoder.Decode(input, width, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Wrong decode result,\nexpected:\n%+v\ngot:\n%+v", expected, img)
+	}
+}
+
+func TestDecodeYV12(t *testing.T) {
+	const (
+		width  = 2
+		height = 2
+	)
+	input := []byte{
+		0x01, 0x03, 0x05, 0x07, // Y
+		0x82, // Cr
+		0x84, // Cb
+	}
+	expected := &image.YCbCr{
+		Y:              []byte{0x01, 0x03, 0x05, 0x07},
+		YStride:        width,
+		Cb:             []byte{0x84},
+		Cr:             []byte{0x82},
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, width, height),
+	}
+
+	decoder, err := NewDecoder(FormatYV12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := decoder.Decode(input, width, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Wrong decode result,\nexpected:\n%+v\ngot:\n%+v", expected, img)
+	}
+}
+
+func TestDecodeI444(t *testing.T) {
+	const (
+		width  = 2
+		height = 1
+	)
+	input := []byte{
+		0x01, 0x03, // Y
+		0x82, 0x84, // Cb
+		0x86, 0x88, // Cr
+	}
+	expected := &image.YCbCr{
+		Y:              []byte{0x01, 0x03},
+		YStride:        width,
+		Cb:             []byte{0x82, 0x84},
+		Cr:             []byte{0x86, 0x88},
+		CStride:        width,
+		SubsampleRatio: image.YCbCrSubsampleRatio444,
+		Rect:           image.Rect(0, 0, width, height),
+	}
+
+	decoder, err := NewDecoder(FormatI444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := decoder.Decode(input, width, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Wrong decode result,\nexpected:\n%+v\ngot:\n%+v", expected, img)
+	}
+}
+
+func TestDecodeP010(t *testing.T) {
+	const (
+		width  = 2
+		height = 2
+	)
+	input := []byte{
+		// Y, 10 bits in the high bits of little endian words
+		0x40, 0x01, 0xc0, 0x03, 0x40, 0x05, 0xc0, 0x07,
+		// Cb        Cr
+		0x40, 0x82, 0xc0, 0x84,
+	}
+	expected := &image.YCbCr{
+		Y:              []byte{0x01, 0x03, 0x05, 0x07},
+		YStride:        width,
+		Cb:             []byte{0x82},
+		Cr:             []byte{0x84},
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, width, height),
+	}
+
+	decoder, err := NewDecoder(FormatP010)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := decoder.Decode(input, width, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Wrong decode result,\nexpected:\n%+v\ngot:\n%+v", expected, img)
+	}
+	if _, _, err := decoder.Decode(input[:10], width, height); err == nil {
+		t.Error("Expected an error for a short frame")
+	}
+}
+
+func TestDecodeGREY(t *testing.T) {
+	const (
+		width  = 2
+		height = 2
+	)
+	input := []byte{0x01, 0x03, 0x05, 0x07}
+	expected := &image.YCbCr{
+		Y:              []byte{0x01, 0x03, 0x05, 0x07},
+		YStride:        width,
+		Cb:             []byte{0x80},
+		Cr:             []byte{0x80},
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, width, height),
+	}
+
+	decoder, err := NewDecoder(FormatGREY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := decoder.Decode(input, width, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Wrong decode result,\nexpected:\n%+v\ngot:\n%+v", expected, img)
+	}
+
+	// a filter tinting the frame changes one chroma plane only
+	yuv := img.(*image.YCbCr)
+	yuv.Cb[0] = 0x10
+	if yuv.Cr[0] != 0x80 {
+		t.Errorf("Expected the Cr plane to be untouched, got %#x", yuv.Cr[0])
+	}
+}
+
 func BenchmarkDecodeYUY2(b *testing.B) {
 	sizes := []struct {
 		width, height int
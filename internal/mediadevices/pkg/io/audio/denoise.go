@@ -0,0 +1,204 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+const (
+	defaultNoiseReduction = 20
+	// denoiseFrameDuration is the shortest duration of the frames analysed, the
+	// frames have a power of two samples and overlap by half
+	denoiseFrameDuration = 20 * time.Millisecond
+	// denoiseNoiseRise is how fast the estimate of the noise follows a louder
+	// noise in dB/s, the words are shorter than what it takes to follow them
+	denoiseNoiseRise = 5
+	// denoiseSmoothing is the weight of the new frame in the smoothed power of
+	// the frequencies, the noise is estimated on the smoothed power
+	denoiseSmoothing = 0.5
+	// denoiseNoiseSmoothing is the weight of the new frame in the estimate of
+	// the noise, which only averages the frames below denoiseSoundRatio times
+	// the noise
+	denoiseNoiseSmoothing = 0.05
+	denoiseSoundRatio     = 4
+	// denoiseOverSubtraction removes the fluctuations of the noise above its
+	// average
+	denoiseOverSubtraction = 2
+)
+
+// NoiseSuppressionOptions configures the SuppressNoise transform, the zero
+// value uses the defaults
+type NoiseSuppressionOptions struct {
+	// Reduction is the highest attenuation of the noise in dB, 20 dB by default.
+	// A higher reduction leaves less noise but more artifacts.
+	Reduction float64
+}
+
+// SuppressNoise creates an audio transform attenuating the steady noise, like
+// wind or fans, by spectral subtraction. The spectrum of the noise is averaged
+// while every frequency is quiet and is subtracted from the spectrum of the
+// sound, the channels are processed separately. The output is delayed by a
+// frame of about 20ms, the chunks keep their length.
+func SuppressNoise(opts NoiseSuppressionOptions) TransformFunc {
+	if opts.Reduction <= 0 {
+		opts.Reduction = defaultNoiseReduction
+	}
+	return mapSamples(func(ci wave.ChunkInfo) chunkProcessor {
+		denoisers := make([]*spectralDenoiser, ci.Channels)
+		if ci.SamplingRate > 0 {
+			for ch := range denoisers {
+				denoisers[ch] = newSpectralDenoiser(ci.SamplingRate, opts.Reduction)
+			}
+		}
+		return func(wave.Audio) func(i, ch int, v float64) float64 {
+			return func(i, ch int, v float64) float64 {
+				if denoisers[ch] == nil {
+					return v
+				}
+				return denoisers[ch].process(v)
+			}
+		}
+	})
+}
+
+// spectralDenoiser processes the frames of a channel as the samples come, the
+// output of a sample is complete a frame later
+type spectralDenoiser struct {
+	size, hop int
+	// window is a square root Hann window, applied before and after the
+	// processing, the overlapping squares sum to one
+	window []float64
+	floor  float64
+	rise   float64
+
+	// input holds the last frame, its last hop is filled by the samples
+	input []float64
+	// output accumulates the overlapping processed frames, its first hop is
+	// complete and is read by the samples
+	output []float64
+	pos    int
+	frames int
+
+	spectrum []complex128
+	power    []float64
+	noise    []float64
+}
+
+func newSpectralDenoiser(sampleRate int, reduction float64) *spectralDenoiser {
+	size := 1
+	for time.Duration(size)*time.Second < denoiseFrameDuration*time.Duration(sampleRate) {
+		size *= 2
+	}
+	d := &spectralDenoiser{
+		size:     size,
+		hop:      size / 2,
+		window:   make([]float64, size),
+		floor:    math.Pow(10, -reduction/20),
+		input:    make([]float64, size),
+		output:   make([]float64, size),
+		spectrum: make([]complex128, size),
+		power:    make([]float64, size/2+1),
+		noise:    make([]float64, size/2+1),
+	}
+	// the power is multiplied by the rise at every frame
+	d.rise = math.Pow(10, denoiseNoiseRise/10*float64(d.hop)/float64(sampleRate))
+	for i := range d.window {
+		d.window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size)))
+	}
+	return d
+}
+
+func (d *spectralDenoiser) process(v float64) float64 {
+	out := d.output[d.pos]
+	d.input[d.size-d.hop+d.pos] = v
+	d.pos++
+	if d.pos == d.hop {
+		d.processFrame()
+		d.pos = 0
+	}
+	return out
+}
+
+func (d *spectralDenoiser) processFrame() {
+	for i, v := range d.input {
+		d.spectrum[i] = complex(v*d.window[i], 0)
+	}
+	fft(d.spectrum, false)
+
+	// the noise is estimated once the frames are full of samples
+	d.frames++
+	learning := d.frames == d.size/d.hop
+	estimating := d.frames > d.size/d.hop
+	for k := range d.power {
+		p := real(d.spectrum[k])*real(d.spectrum[k]) + imag(d.spectrum[k])*imag(d.spectrum[k])
+		d.power[k] += (p - d.power[k]) * denoiseSmoothing
+		switch {
+		case learning:
+			d.noise[k] = d.power[k]
+		case estimating && d.power[k] < denoiseSoundRatio*d.noise[k]:
+			d.noise[k] += (d.power[k] - d.noise[k]) * denoiseNoiseSmoothing
+		case estimating:
+			d.noise[k] *= d.rise
+		}
+
+		gain := 1.0
+		if estimating && d.power[k] > 0 {
+			gain = math.Max(1-denoiseOverSubtraction*d.noise[k]/d.power[k], d.floor)
+		}
+		d.spectrum[k] *= complex(gain, 0)
+		if k > 0 && k < d.size-k {
+			d.spectrum[d.size-k] = cmplx.Conj(d.spectrum[k])
+		}
+	}
+	fft(d.spectrum, true)
+
+	copy(d.output, d.output[d.hop:])
+	for i := d.size - d.hop; i < d.size; i++ {
+		d.output[i] = 0
+	}
+	for i, c := range d.spectrum {
+		d.output[i] += real(c) * d.window[i]
+	}
+	copy(d.input, d.input[d.hop:])
+}
+
+// fft transforms x in place, its length is a power of two. The inverse
+// transform is scaled by 1/len(x).
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for length := 2; length <= n; length <<= 1 {
+		w := cmplx.Rect(1, sign*2*math.Pi/float64(length))
+		for start := 0; start < n; start += length {
+			t := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				a, b := x[start+k], x[start+k+length/2]*t
+				x[start+k], x[start+k+length/2] = a+b, a-b
+				t *= w
+			}
+		}
+	}
+
+	if inverse {
+		for i := range x {
+			x[i] /= complex(float64(n), 0)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"math"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// DefaultHighPassCutoff removes the rumble of the wind and most of the mains
+// hum, the voices are above it
+const DefaultHighPassCutoff = 100
+
+// HighPass creates an audio transform filtering out the frequencies below
+// cutoff, in Hz, with a second order Butterworth filter. A cutoff of 20 Hz only
+// removes the DC offset of the microphone, 0 uses DefaultHighPassCutoff.
+func HighPass(cutoff float64) TransformFunc {
+	if cutoff <= 0 {
+		cutoff = DefaultHighPassCutoff
+	}
+	return mapSamples(func(ci wave.ChunkInfo) chunkProcessor {
+		filters := make([]biquad, ci.Channels)
+		if ci.SamplingRate <= 0 || cutoff >= float64(ci.SamplingRate)/2 {
+			// the filter can't be designed, the chunks are passed through
+			return func(wave.Audio) func(i, ch int, v float64) float64 {
+				return func(i, ch int, v float64) float64 { return v }
+			}
+		}
+		for ch := range filters {
+			filters[ch] = newHighPassBiquad(cutoff, float64(ci.SamplingRate))
+		}
+		return func(wave.Audio) func(i, ch int, v float64) float64 {
+			return func(i, ch int, v float64) float64 {
+				return filters[ch].filter(v)
+			}
+		}
+	})
+}
+
+// biquad is a second order IIR filter with its state, in direct form I
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// newHighPassBiquad designs the filter with the formulas of the Audio EQ
+// Cookbook, a Q of 1/sqrt(2) gives a Butterworth response
+func newHighPassBiquad(cutoff, sampleRate float64) biquad {
+	w := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w) / math.Sqrt2
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"math"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+const (
+	defaultGainTarget  = -20
+	defaultMaxGain     = 30
+	defaultGainAttack  = 50 * time.Millisecond
+	defaultGainRelease = 2 * time.Second
+	// gainSilence is the level below which the gain is held, the silence isn't
+	// amplified up to the target
+	gainSilence = -60
+)
+
+// AutomaticGainOptions configures the AutomaticGain transform, the zero value
+// uses the defaults. The levels are in dBFS.
+type AutomaticGainOptions struct {
+	// Target is the RMS level of the output, -20 dBFS by default
+	Target float64
+	// MaxGain is the highest amplification in dB, 30 dB by default. The loud
+	// sounds are attenuated without limit.
+	MaxGain float64
+	// Attack is how fast the gain is lowered when the sound gets louder, 50ms
+	// by default
+	Attack time.Duration
+	// Release is how fast the gain is raised when the sound gets quieter, 2s by
+	// default
+	Release time.Duration
+}
+
+// AutomaticGain creates an audio transform bringing the level of the chunks
+// to opts.Target. The gain follows the RMS level of every channel together,
+// it changes smoothly across the samples and the peaks above the full scale
+// are clipped.
+func AutomaticGain(opts AutomaticGainOptions) TransformFunc {
+	if opts.Target >= 0 {
+		opts.Target = defaultGainTarget
+	}
+	if opts.MaxGain <= 0 {
+		opts.MaxGain = defaultMaxGain
+	}
+	if opts.Attack <= 0 {
+		opts.Attack = defaultGainAttack
+	}
+	if opts.Release <= 0 {
+		opts.Release = defaultGainRelease
+	}
+
+	return func(r Reader) Reader {
+		// gain is in dB, it is kept when the format changes
+		var gain float64
+		return mapSamples(func(wave.ChunkInfo) chunkProcessor {
+			return func(chunk wave.Audio) func(i, ch int, v float64) float64 {
+				from := math.Pow(10, gain/20)
+				info := chunk.ChunkInfo()
+				if level := MeasureLevel(chunk).RMS; level > gainSilence && info.SamplingRate > 0 {
+					wanted := math.Min(opts.Target-level, opts.MaxGain)
+					timeConstant := opts.Release
+					if wanted < gain {
+						timeConstant = opts.Attack
+					}
+					duration := time.Duration(info.Len) * time.Second / time.Duration(info.SamplingRate)
+					gain += (wanted - gain) * (1 - math.Exp(-float64(duration)/float64(timeConstant)))
+				}
+
+				// the gain ramps from the one of the previous chunk
+				to := math.Pow(10, gain/20)
+				return func(i, ch int, v float64) float64 {
+					g := to
+					if info.Len > 1 {
+						g = from + (to-from)*float64(i)/float64(info.Len-1)
+					}
+					return math.Max(-1, math.Min(1, v*g))
+				}
+			}
+		})(r)
+	}
+}
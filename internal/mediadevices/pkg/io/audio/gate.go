@@ -0,0 +1,88 @@
+package audio
+
+import (
+	"math"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+const (
+	defaultGateThreshold = -50
+	defaultGateRange     = 40
+	defaultGateHold      = 200 * time.Millisecond
+	defaultGateRelease   = 100 * time.Millisecond
+	// gateAttack is how fast the gate opens, fast enough to keep the start of
+	// the words without clicking
+	gateAttack = time.Millisecond
+)
+
+// NoiseGateOptions configures the NoiseGate transform, the zero value uses the
+// defaults. The levels are in dBFS.
+type NoiseGateOptions struct {
+	// Threshold is the RMS level above which the gate opens, -50 dBFS by
+	// default
+	Threshold float64
+	// Range is the attenuation of the closed gate in dB, 40 dB by default
+	Range float64
+	// Hold is how long the gate stays open after the level falls below the
+	// threshold, 200ms by default
+	Hold time.Duration
+	// Release is how fast the gate closes after the hold time, 100ms by default
+	Release time.Duration
+}
+
+// NoiseGate creates an audio transform attenuating the chunks quieter than
+// opts.Threshold, the background noise is muted between the sounds. The level
+// of every channel is measured together.
+func NoiseGate(opts NoiseGateOptions) TransformFunc {
+	if opts.Threshold >= 0 {
+		opts.Threshold = defaultGateThreshold
+	}
+	if opts.Range <= 0 {
+		opts.Range = defaultGateRange
+	}
+	if opts.Hold <= 0 {
+		opts.Hold = defaultGateHold
+	}
+	if opts.Release <= 0 {
+		opts.Release = defaultGateRelease
+	}
+	closed := math.Pow(10, -opts.Range/20)
+
+	return func(r Reader) Reader {
+		// the gate starts closed, until the first sound
+		gain := closed
+		var quiet time.Duration
+		return mapSamples(func(ci wave.ChunkInfo) chunkProcessor {
+			attack := smoothing(gateAttack, ci.SamplingRate)
+			release := smoothing(opts.Release, ci.SamplingRate)
+			return func(chunk wave.Audio) func(i, ch int, v float64) float64 {
+				target, coef := 1.0, attack
+				if MeasureLevel(chunk).RMS > opts.Threshold {
+					quiet = 0
+				} else if ci.SamplingRate > 0 {
+					quiet += time.Duration(chunk.ChunkInfo().Len) * time.Second / time.Duration(ci.SamplingRate)
+					if quiet > opts.Hold {
+						target, coef = closed, release
+					}
+				}
+				return func(i, ch int, v float64) float64 {
+					if ch == 0 {
+						gain += (target - gain) * coef
+					}
+					return v * gain
+				}
+			}
+		})(r)
+	}
+}
+
+// smoothing returns the coefficient of a one pole smoothing reaching 63% of a
+// step in timeConstant
+func smoothing(timeConstant time.Duration, sampleRate int) float64 {
+	if sampleRate <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-float64(time.Second)/(float64(timeConstant)*float64(sampleRate)))
+}
@@ -0,0 +1,170 @@
+package audio
+
+import (
+	"math"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+const (
+	defaultSoundThreshold   = -30
+	defaultSoundMinDuration = 200 * time.Millisecond
+	defaultSoundHoldTime    = 5 * time.Second
+)
+
+// Level is the level of a chunk in dBFS, from -Inf for silence to 0 for a full
+// scale signal
+type Level struct {
+	RMS  float64
+	Peak float64
+}
+
+// MeasureLevel returns the RMS and the peak level of every channel of chunk
+// together
+func MeasureLevel(chunk wave.Audio) Level {
+	info := chunk.ChunkInfo()
+	n := info.Len * info.Channels
+	if n == 0 {
+		return Level{RMS: math.Inf(-1), Peak: math.Inf(-1)}
+	}
+
+	var sum, peak float64
+	for i := 0; i < info.Len; i++ {
+		for ch := 0; ch < info.Channels; ch++ {
+			v := math.Abs(sampleValue(chunk.At(i, ch)))
+			sum += v * v
+			if v > peak {
+				peak = v
+			}
+		}
+	}
+	return Level{
+		RMS:  decibels(math.Sqrt(sum / float64(n))),
+		Peak: decibels(peak),
+	}
+}
+
+func decibels(v float64) float64 {
+	if v <= 0 {
+		return math.Inf(-1)
+	}
+	return math.Min(20*math.Log10(v), 0)
+}
+
+// SoundEventOptions configures the SoundEvents detector. The levels are in
+// dBFS and the durations are measured from the length of the chunks, not from
+// the wall clock.
+type SoundEventOptions struct {
+	// Threshold is the RMS level above which the sound is loud, -30 dBFS by
+	// default
+	Threshold float64
+	// PeakThreshold is a peak level starting an event at once, for short sounds
+	// like breaking glass. 0 disables it.
+	PeakThreshold float64
+	// MinDuration is how long the sound must stay loud to start an event, a
+	// door slam doesn't start one. 200ms by default.
+	MinDuration time.Duration
+	// HoldTime is how long the sound must stay quiet to end the event, 5s by
+	// default
+	HoldTime time.Duration
+	// OnLevel is called with the level of every chunk, to meter it. It is
+	// called from the reading goroutine and must not block.
+	OnLevel func(level Level)
+	// OnEvent is called when a loud event starts and when it ends, with the
+	// level of the chunk which started or ended it. It is called from the
+	// reading goroutine and must not block.
+	OnEvent func(loud bool, level Level)
+}
+
+// SoundEvents returns a transform measuring the level of the chunks and
+// reporting the loud events, like shouts or alarms, to opts.OnEvent. The
+// chunks are not modified. A loud event ends when the reader fails, for
+// example when the microphone is closed in the middle of the event.
+func SoundEvents(opts SoundEventOptions) TransformFunc {
+	return func(r Reader) Reader {
+		detector := newSoundDetector(opts)
+		return ReaderFunc(func() (wave.Audio, func(), error) {
+			chunk, release, err := r.Read()
+			if err != nil {
+				if detector.loud {
+					detector.loud, detector.elapsed = false, 0
+					if opts.OnEvent != nil {
+						opts.OnEvent(false, Level{RMS: math.Inf(-1), Peak: math.Inf(-1)})
+					}
+				}
+				return nil, func() {}, err
+			}
+
+			level := MeasureLevel(chunk)
+			if opts.OnLevel != nil {
+				opts.OnLevel(level)
+			}
+			info := chunk.ChunkInfo()
+			if info.SamplingRate > 0 {
+				duration := time.Duration(info.Len) * time.Second / time.Duration(info.SamplingRate)
+				if loud, changed := detector.update(level, duration); changed && opts.OnEvent != nil {
+					opts.OnEvent(loud, level)
+				}
+			}
+			return chunk, release, nil
+		})
+	}
+}
+
+// soundDetector applies the thresholds and the durations of the options to
+// the levels
+type soundDetector struct {
+	threshold, peakThreshold float64
+	minDuration, holdTime    time.Duration
+
+	loud bool
+	// elapsed is how long the level has been on the other side of the
+	// threshold of the current state
+	elapsed time.Duration
+}
+
+func newSoundDetector(opts SoundEventOptions) *soundDetector {
+	d := &soundDetector{
+		threshold:     opts.Threshold,
+		peakThreshold: opts.PeakThreshold,
+		minDuration:   opts.MinDuration,
+		holdTime:      opts.HoldTime,
+	}
+	if d.threshold >= 0 {
+		d.threshold = defaultSoundThreshold
+	}
+	if d.peakThreshold >= 0 {
+		// a peak can't exceed the full scale
+		d.peakThreshold = 0
+	}
+	if d.minDuration <= 0 {
+		d.minDuration = defaultSoundMinDuration
+	}
+	if d.holdTime <= 0 {
+		d.holdTime = defaultSoundHoldTime
+	}
+	return d
+}
+
+// update returns the state after a chunk of duration at level and whether it
+// changed
+func (d *soundDetector) update(level Level, duration time.Duration) (bool, bool) {
+	peak := d.peakThreshold < 0 && level.Peak > d.peakThreshold
+	loud := level.RMS > d.threshold || peak
+	if loud == d.loud {
+		d.elapsed = 0
+		return d.loud, false
+	}
+
+	d.elapsed += duration
+	hold := d.holdTime
+	if !d.loud {
+		hold = d.minDuration
+	}
+	if d.elapsed < hold && !peak {
+		return d.loud, false
+	}
+	d.loud, d.elapsed = loud, 0
+	return d.loud, true
+}
@@ -0,0 +1,163 @@
+package audio
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// sineChunk returns 10ms of a 1kHz sine of amplitude from 0 to 1
+func sineChunk(amplitude float64) *wave.Float32Interleaved {
+	chunk := wave.NewFloat32Interleaved(wave.ChunkInfo{Len: 480, Channels: 2, SamplingRate: 48000})
+	for i := 0; i < 480; i++ {
+		v := float32(amplitude * math.Sin(2*math.Pi*1000*float64(i)/48000))
+		chunk.SetFloat32(i, 0, wave.Float32Sample(v))
+		chunk.SetFloat32(i, 1, wave.Float32Sample(v))
+	}
+	return chunk
+}
+
+func TestMeasureLevel(t *testing.T) {
+	square := wave.NewInt16Interleaved(wave.ChunkInfo{Len: 4, Channels: 1, SamplingRate: 48000})
+	copy(square.Data, []int16{-0x8000, 0x4000, -0x4000, 0x4000})
+
+	testCases := map[string]struct {
+		chunk     wave.Audio
+		rms, peak float64
+		tolerance float64
+	}{
+		"Silence":    {wave.NewInt16Interleaved(wave.ChunkInfo{Len: 4, Channels: 2}), math.Inf(-1), math.Inf(-1), 0},
+		"FullScale":  {sineChunk(1), -3.01, 0, 0.01},
+		"HalfScale":  {sineChunk(0.5), -9.03, -6.02, 0.01},
+		"Int16":      {square, -3.59, 0, 0.01},
+		"EmptyChunk": {wave.NewFloat32Interleaved(wave.ChunkInfo{Channels: 2}), math.Inf(-1), math.Inf(-1), 0},
+	}
+	for name, testCase := range testCases {
+		level := MeasureLevel(testCase.chunk)
+		if !closeTo(level.RMS, testCase.rms, testCase.tolerance) || !closeTo(level.Peak, testCase.peak, testCase.tolerance) {
+			t.Errorf("%s: expected %.2f/%.2f dBFS, got %.2f/%.2f", name, testCase.rms, testCase.peak, level.RMS, level.Peak)
+		}
+	}
+}
+
+func closeTo(a, b, tolerance float64) bool {
+	if math.IsInf(b, 0) {
+		return a == b
+	}
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestSoundEvents(t *testing.T) {
+	quiet, loud, click := sineChunk(0.001), sineChunk(0.5), sineChunk(0.001)
+	click.SetFloat32(100, 0, 0.9)
+
+	testCases := map[string]struct {
+		opts     SoundEventOptions
+		chunks   []wave.Audio
+		expected []bool
+	}{
+		"ShortNoise": {
+			// 100ms of noise is shorter than the minimum duration
+			chunks: repeat(quiet, 5, loud, 10, quiet, 100),
+		},
+		"LoudEvent": {
+			// the event starts after 200ms and ends after 5s of silence
+			chunks:   repeat(quiet, 5, loud, 30, quiet, 500),
+			expected: []bool{true, false},
+		},
+		"Peak": {
+			opts:     SoundEventOptions{PeakThreshold: -3, HoldTime: time.Second},
+			chunks:   repeat(quiet, 5, click, 1, quiet, 100),
+			expected: []bool{true, false},
+		},
+		"Threshold": {
+			// -9 dBFS isn't loud for a threshold of -6 dBFS
+			opts:   SoundEventOptions{Threshold: -6},
+			chunks: repeat(loud, 100),
+		},
+	}
+	for name, testCase := range testCases {
+		var events []bool
+		var levels int
+		var eventAt []int
+		opts := testCase.opts
+		opts.OnLevel = func(Level) { levels++ }
+		opts.OnEvent = func(loud bool, level Level) {
+			events = append(events, loud)
+			eventAt = append(eventAt, levels)
+		}
+
+		i := 0
+		r := SoundEvents(opts)(ReaderFunc(func() (wave.Audio, func(), error) {
+			i++
+			return testCase.chunks[i-1], func() {}, nil
+		}))
+		for range testCase.chunks {
+			if _, _, err := r.Read(); err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+		}
+
+		if len(events) != len(testCase.expected) {
+			t.Errorf("%s: expected events %v, got %v", name, testCase.expected, events)
+			continue
+		}
+		for j := range events {
+			if events[j] != testCase.expected[j] {
+				t.Errorf("%s: expected events %v, got %v", name, testCase.expected, events)
+			}
+		}
+		if name == "LoudEvent" && (eventAt[0] != 5+20 || eventAt[1] != 5+30+500) {
+			t.Errorf("%s: unexpected event chunks %v", name, eventAt)
+		}
+	}
+}
+
+// repeat builds a series of chunks from pairs of chunk and count
+func repeat(pairs ...interface{}) []wave.Audio {
+	var chunks []wave.Audio
+	for i := 0; i < len(pairs); i += 2 {
+		for j := 0; j < pairs[i+1].(int); j++ {
+			chunks = append(chunks, pairs[i].(wave.Audio))
+		}
+	}
+	return chunks
+}
+
+func TestSoundEventsReadError(t *testing.T) {
+	var events []bool
+	opts := SoundEventOptions{
+		OnEvent: func(loud bool, level Level) {
+			events = append(events, loud)
+		},
+	}
+	chunks := repeat(sineChunk(0.5), 30)
+
+	// the microphone is closed in the middle of the event
+	i := 0
+	r := SoundEvents(opts)(ReaderFunc(func() (wave.Audio, func(), error) {
+		if i == len(chunks) {
+			return nil, func() {}, io.EOF
+		}
+		i++
+		return chunks[i-1], func() {}, nil
+	}))
+	for range chunks {
+		if _, _, err := r.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	for j := 0; j < 2; j++ {
+		if _, _, err := r.Read(); err != io.EOF {
+			t.Errorf("Expected %v, got %v", io.EOF, err)
+		}
+	}
+
+	expected := []bool{true, false}
+	if len(events) != len(expected) || events[0] != expected[0] || events[1] != expected[1] {
+		t.Errorf("Expected events %v, got %v", expected, events)
+	}
+}
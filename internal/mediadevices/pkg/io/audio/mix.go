@@ -0,0 +1,214 @@
+package audio
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+	"github.com/pion/mediadevices/pkg/wave/mixer"
+)
+
+const (
+	defaultMixBuffer = 60 * time.Millisecond
+	// mixMaxBuffer bounds the samples of a source which comes faster than the
+	// mix, the oldest ones are dropped
+	mixMaxBuffer = time.Second
+	// mixFillSmoothing is the weight of a chunk in the average number of
+	// samples buffered for a source
+	mixFillSmoothing = 0.05
+)
+
+// MixSource is a source added by Mix
+type MixSource struct {
+	Reader Reader
+	// Gain of the source in dB
+	Gain float64
+	// ChannelMixer converts the channels of the source to the channels of the
+	// mix. By default a mixer.StereoMixer is used for a stereo mix and a
+	// mixer.MonoMixer otherwise.
+	ChannelMixer mixer.ChannelMixer
+	// Buffer is the duration of the source buffered against the jitter of its
+	// chunks, 60ms by default
+	Buffer time.Duration
+}
+
+// Mix creates an audio transform adding sources to the chunks of the
+// transformed reader, like the talkback to a recording or a second microphone.
+// The transformed reader keeps the clock: the chunks keep its length, rate,
+// channels and type and the sources are resampled and mixed to them. The
+// sources are read in their own goroutines until they fail, ctx is done or
+// the transformed reader fails, a stopped source is silent. A source blocked
+// in a read stops with its next chunk. The drift between the clocks of a
+// source and of the mix is compensated by stretching the chunks of the source
+// by a sample at most. The sum is clipped at the full scale.
+func Mix(ctx context.Context, sources ...MixSource) TransformFunc {
+	return func(r Reader) Reader {
+		var inputs []*mixInput
+		ctx, stop := context.WithCancel(ctx)
+		return ReaderFunc(func() (wave.Audio, func(), error) {
+			buff, _, err := r.Read()
+			if err != nil {
+				stop()
+				return nil, func() {}, err
+			}
+			ci := buff.ChunkInfo()
+			if inputs == nil && ci.SamplingRate > 0 && ci.Channels > 0 {
+				// the format of the mix is known from its first chunk
+				inputs = make([]*mixInput, len(sources))
+				for i, source := range sources {
+					inputs[i] = newMixInput(ctx, source, ci)
+				}
+			}
+
+			mixed, set, err := newChunkLike(buff, ci)
+			if err != nil {
+				return nil, func() {}, err
+			}
+			sum := make([]float64, ci.Len*ci.Channels)
+			for i := 0; i < ci.Len; i++ {
+				for ch := 0; ch < ci.Channels; ch++ {
+					sum[i*ci.Channels+ch] = sampleValue(buff.At(i, ch))
+				}
+			}
+			for _, input := range inputs {
+				for i, v := range input.take(ci.Len) {
+					sum[i] += v
+				}
+			}
+			for i := 0; i < ci.Len; i++ {
+				for ch := 0; ch < ci.Channels; ch++ {
+					set(i, ch, math.Max(-1, math.Min(1, sum[i*ci.Channels+ch])))
+				}
+			}
+			return mixed, func() {}, nil
+		})
+	}
+}
+
+// mixInput buffers the samples of a source, interleaved and converted to the
+// format of the mix
+type mixInput struct {
+	channels    int
+	target, max int
+
+	mu      sync.Mutex
+	samples []float64
+	// fill is the average number of samples buffered, in samples per channel
+	fill   float64
+	primed bool
+}
+
+func newMixInput(ctx context.Context, source MixSource, ci wave.ChunkInfo) *mixInput {
+	buffer := source.Buffer
+	if buffer <= 0 {
+		buffer = defaultMixBuffer
+	}
+	input := &mixInput{
+		channels: ci.Channels,
+		target:   int(time.Duration(ci.SamplingRate) * buffer / time.Second),
+		max:      int(time.Duration(ci.SamplingRate) * mixMaxBuffer / time.Second),
+	}
+	input.fill = float64(input.target)
+
+	channelMixer := source.ChannelMixer
+	if channelMixer == nil {
+		channelMixer = &mixer.MonoMixer{}
+		if ci.Channels == 2 {
+			channelMixer = &mixer.StereoMixer{}
+		}
+	}
+	reader := Merge(
+		NewResampler(ci.SamplingRate),
+		NewChannelMixer(ci.Channels, channelMixer),
+	)(source.Reader)
+	go input.read(ctx, reader, math.Pow(10, source.Gain/20))
+	return input
+}
+
+func (input *mixInput) read(ctx context.Context, r Reader, gain float64) {
+	for ctx.Err() == nil {
+		chunk, release, err := r.Read()
+		if err != nil {
+			return
+		}
+		if ctx.Err() != nil {
+			release()
+			return
+		}
+		ci := chunk.ChunkInfo()
+		input.mu.Lock()
+		for i := 0; i < ci.Len; i++ {
+			for ch := 0; ch < input.channels; ch++ {
+				input.samples = append(input.samples, gain*sampleValue(chunk.At(i, ch)))
+			}
+		}
+		if excess := len(input.samples) - input.max*input.channels; excess > 0 {
+			input.samples = input.samples[excess:]
+		}
+		input.mu.Unlock()
+		release()
+	}
+}
+
+// take returns n samples per channel of the source, or nil while there are
+// not enough samples buffered. A sample is dropped or repeated when the
+// buffer drifts from its target.
+func (input *mixInput) take(n int) []float64 {
+	input.mu.Lock()
+	defer input.mu.Unlock()
+
+	available := len(input.samples) / input.channels
+	if !input.primed {
+		if available < input.target {
+			return nil
+		}
+		input.primed, input.fill = true, float64(available)
+	}
+	input.fill += (float64(available) - input.fill) * mixFillSmoothing
+
+	m := n
+	underrun := available < n-1
+	switch {
+	case underrun:
+		// the source is late, what's left is played and the buffer fills up
+		// again
+		input.primed = false
+		m = available
+	case available < n || input.fill < float64(input.target-n/2):
+		m = n - 1
+	case available > n && input.fill > float64(input.target+n/2):
+		m = n + 1
+	}
+
+	taken := input.samples[:m*input.channels]
+	out := make([]float64, n*input.channels)
+	if underrun {
+		copy(out, taken)
+	} else {
+		stretch(out, taken, input.channels)
+	}
+	input.samples = input.samples[m*input.channels:]
+	return out
+}
+
+// stretch interpolates the interleaved samples of src linearly into dst
+func stretch(dst, src []float64, channels int) {
+	n, m := len(dst)/channels, len(src)/channels
+	if n == m || n < 2 || m < 2 {
+		copy(dst, src)
+		return
+	}
+	for i := 0; i < n; i++ {
+		pos := float64(i) * float64(m-1) / float64(n-1)
+		j := int(pos)
+		if j >= m-1 {
+			j = m - 2
+		}
+		frac := pos - float64(j)
+		for ch := 0; ch < channels; ch++ {
+			dst[i*channels+ch] = src[j*channels+ch]*(1-frac) + src[(j+1)*channels+ch]*frac
+		}
+	}
+}
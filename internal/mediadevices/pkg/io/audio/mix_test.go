@@ -0,0 +1,135 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// constant returns chunks of the value v, a chunk every millisecond
+func constant(v float64, info wave.ChunkInfo, newChunk func(wave.ChunkInfo) wave.EditableAudio) Reader {
+	return ReaderFunc(func() (wave.Audio, func(), error) {
+		time.Sleep(time.Millisecond)
+		chunk := newChunk(info)
+		for i := 0; i < info.Len; i++ {
+			for ch := 0; ch < info.Channels; ch++ {
+				chunk.Set(i, ch, sampleOf(chunk, v))
+			}
+		}
+		return chunk, func() {}, nil
+	})
+}
+
+func TestMix(t *testing.T) {
+	newFloat32 := func(ci wave.ChunkInfo) wave.EditableAudio { return wave.NewFloat32Interleaved(ci) }
+	newInt16 := func(ci wave.ChunkInfo) wave.EditableAudio { return wave.NewInt16NonInterleaved(ci) }
+	talkback := constant(0.5, wave.ChunkInfo{Len: 240, Channels: 2, SamplingRate: 24000}, newInt16)
+	microphone := constant(0.25, wave.ChunkInfo{Len: 480, Channels: 1, SamplingRate: 48000}, newFloat32)
+	loud := constant(0.9, wave.ChunkInfo{Len: 480, Channels: 1, SamplingRate: 48000}, newFloat32)
+
+	testCases := map[string]struct {
+		sources  []MixSource
+		expected float64
+	}{
+		// -6 dB halves the talkback, its channels are averaged
+		"Talkback": {[]MixSource{{Reader: talkback, Gain: -6.0206}}, 0.5},
+		"Clipping": {[]MixSource{{Reader: loud}}, 1},
+	}
+	for name, testCase := range testCases {
+		r := Mix(context.Background(), testCase.sources...)(microphone)
+		var chunk wave.Audio
+		for i := 0; i < 100; i++ {
+			var err error
+			if chunk, _, err = r.Read(); err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+		}
+		if ci := chunk.ChunkInfo(); ci.Len != 480 || ci.Channels != 1 || ci.SamplingRate != 48000 {
+			t.Errorf("%s: expected the format of the microphone, got %v", name, ci)
+		}
+		if _, ok := chunk.(*wave.Float32Interleaved); !ok {
+			t.Errorf("%s: expected the type of the microphone, got %T", name, chunk)
+		}
+		for i := 0; i < chunk.ChunkInfo().Len; i++ {
+			if v := sampleValue(chunk.At(i, 0)); math.Abs(v-testCase.expected) > 0.001 {
+				t.Fatalf("%s: expected %.3f, got %.3f at %d", name, testCase.expected, v, i)
+			}
+		}
+	}
+}
+
+func TestMixStop(t *testing.T) {
+	info := wave.ChunkInfo{Len: 480, Channels: 1, SamplingRate: 48000}
+	newFloat32 := func(ci wave.ChunkInfo) wave.EditableAudio { return wave.NewFloat32Interleaved(ci) }
+	errEnd := errors.New("end")
+
+	testCases := map[string]func(cancel func(), r Reader){
+		"Context": func(cancel func(), r Reader) {
+			cancel()
+		},
+		"ReaderFailure": func(cancel func(), r Reader) {
+			if _, _, err := r.Read(); err != errEnd {
+				t.Errorf("Expected %v, got %v", errEnd, err)
+			}
+		},
+	}
+	for name, stop := range testCases {
+		var reads int32
+		talkback := constant(0.5, info, newFloat32)
+		source := ReaderFunc(func() (wave.Audio, func(), error) {
+			atomic.AddInt32(&reads, 1)
+			return talkback.Read()
+		})
+		var ended bool
+		microphone := constant(0.25, info, newFloat32)
+		mixed := ReaderFunc(func() (wave.Audio, func(), error) {
+			if ended {
+				return nil, func() {}, errEnd
+			}
+			return microphone.Read()
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		r := Mix(ctx, MixSource{Reader: source})(mixed)
+		for i := 0; i < 10; i++ {
+			if _, _, err := r.Read(); err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+		}
+		ended = true
+		stop(cancel, r)
+
+		// the chunk being read when the mix stopped may still come
+		time.Sleep(10 * time.Millisecond)
+		stopped := atomic.LoadInt32(&reads)
+		time.Sleep(20 * time.Millisecond)
+		if n := atomic.LoadInt32(&reads); n != stopped {
+			t.Errorf("%s: expected the source to stop, got %d more reads", name, n-stopped)
+		}
+		cancel()
+	}
+}
+
+func TestMixDrift(t *testing.T) {
+	// 10ms chunks from sources 0.1% faster and slower than the mix
+	for name, produced := range map[string]int{"Fast": 4805, "Slow": 4795} {
+		input := &mixInput{channels: 1, target: 2880, max: 48000}
+		for i := 0; i < 10000; i++ {
+			if i%10 == 0 {
+				input.samples = append(input.samples, make([]float64, produced)...)
+			}
+			samples := input.take(480)
+			if i > 10 && samples == nil {
+				t.Fatalf("%s: unexpected underrun after %d chunks", name, i)
+			}
+			if buffered := len(input.samples); i > 1000 && (buffered < 2880-2*4800 || buffered > 2880+2*4800) {
+				t.Fatalf("%s: expected the buffer to stay around its target, got %d", name, buffered)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package audio_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/driver"
+	_ "github.com/pion/mediadevices/pkg/driver/audiotest"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// the sine of the audiotest driver has an amplitude of 0.25
+const sineLevel = -15.05
+
+// recordSine returns the 480Hz sine of the audiotest driver in chunks of 10ms
+func recordSine(t *testing.T) audio.Reader {
+	drivers := driver.GetManager().Query(func(d driver.Driver) bool {
+		return d.Info().Label == "AudioTest"
+	})
+	if len(drivers) != 1 {
+		t.Fatalf("Expected the audiotest driver, got %v", drivers)
+	}
+	d := drivers[0]
+	if err := d.Open(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	r, err := d.(driver.AudioRecorder).AudioRecord(prop.Media{
+		Audio: prop.Audio{SampleRate: 48000, ChannelCount: 1, Latency: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return r
+}
+
+// noise returns white noise of amplitude in the int16 format, in chunks of
+// 10ms
+func noise(amplitude float64) audio.Reader {
+	random := rand.New(rand.NewSource(1))
+	return audio.ReaderFunc(func() (wave.Audio, func(), error) {
+		chunk := wave.NewInt16Interleaved(wave.ChunkInfo{Len: 480, Channels: 1, SamplingRate: 48000})
+		for i := range chunk.Data {
+			chunk.Data[i] = int16(amplitude * (2*random.Float64() - 1) * math.MaxInt16)
+		}
+		return chunk, func() {}, nil
+	})
+}
+
+// mapChunk applies fn to every sample of the chunks, in the float32 format
+func mapChunk(r audio.Reader, fn func(v float64) float64) audio.Reader {
+	return audio.ReaderFunc(func() (wave.Audio, func(), error) {
+		chunk, release, err := r.Read()
+		if err != nil {
+			return nil, func() {}, err
+		}
+		ci := chunk.ChunkInfo()
+		mapped := wave.NewFloat32Interleaved(ci)
+		for i := 0; i < ci.Len; i++ {
+			for ch := 0; ch < ci.Channels; ch++ {
+				v := float64(wave.Float32SampleFormat.Convert(chunk.At(i, ch)).(wave.Float32Sample))
+				mapped.SetFloat32(i, ch, wave.Float32Sample(fn(v)))
+			}
+		}
+		release()
+		return mapped, func() {}, nil
+	})
+}
+
+// readChunks reads count chunks and returns the last one
+func readChunks(t *testing.T, r audio.Reader, count int) wave.Audio {
+	var chunk wave.Audio
+	for i := 0; i < count; i++ {
+		var err error
+		if chunk, _, err = r.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	return chunk
+}
+
+func TestHighPass(t *testing.T) {
+	offset := mapChunk(recordSine(t), func(v float64) float64 { return v + 0.25 })
+	chunk := readChunks(t, audio.HighPass(20)(offset), 30)
+
+	var sum float64
+	ci := chunk.ChunkInfo()
+	for i := 0; i < ci.Len; i++ {
+		sum += float64(chunk.At(i, 0).(wave.Float32Sample))
+	}
+	if mean := sum / float64(ci.Len); math.Abs(mean) > 0.01 {
+		t.Errorf("Expected the offset to be removed, got %f", mean)
+	}
+	if level := audio.MeasureLevel(chunk).RMS; math.Abs(level-sineLevel) > 0.5 {
+		t.Errorf("Expected %.2f dBFS, got %.2f", sineLevel, level)
+	}
+}
+
+func TestAutomaticGain(t *testing.T) {
+	testCases := map[string]struct {
+		scale float64
+		opts  audio.AutomaticGainOptions
+		level float64
+	}{
+		"Attenuation": {1, audio.AutomaticGainOptions{}, -20},
+		"Amplification": {
+			0.01, audio.AutomaticGainOptions{Target: -25, Release: 100 * time.Millisecond}, -25,
+		},
+		"MaxGain": {
+			0.01, audio.AutomaticGainOptions{MaxGain: 10, Release: 100 * time.Millisecond}, sineLevel - 40 + 10,
+		},
+	}
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			scaled := mapChunk(recordSine(t), func(v float64) float64 { return v * testCase.scale })
+			chunk := readChunks(t, audio.AutomaticGain(testCase.opts)(scaled), 60)
+			if level := audio.MeasureLevel(chunk).RMS; math.Abs(level-testCase.level) > 0.5 {
+				t.Errorf("Expected %.2f dBFS, got %.2f", testCase.level, level)
+			}
+		})
+	}
+}
+
+func TestNoiseGate(t *testing.T) {
+	testCases := map[string]struct {
+		opts  audio.NoiseGateOptions
+		level float64
+	}{
+		"Open":   {audio.NoiseGateOptions{Threshold: -30}, sineLevel},
+		"Closed": {audio.NoiseGateOptions{Threshold: -10, Hold: 50 * time.Millisecond, Release: 20 * time.Millisecond}, sineLevel - 40},
+		"Range":  {audio.NoiseGateOptions{Threshold: -10, Range: 10, Hold: 50 * time.Millisecond, Release: 20 * time.Millisecond}, sineLevel - 10},
+	}
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			chunk := readChunks(t, audio.NoiseGate(testCase.opts)(recordSine(t)), 30)
+			if level := audio.MeasureLevel(chunk).RMS; math.Abs(level-testCase.level) > 0.5 {
+				t.Errorf("Expected %.2f dBFS, got %.2f", testCase.level, level)
+			}
+		})
+	}
+}
+
+func TestSuppressNoise(t *testing.T) {
+	background := noise(0.01)
+	input := audio.MeasureLevel(readChunks(t, noise(0.01), 1)).RMS
+	denoised := audio.SuppressNoise(audio.NoiseSuppressionOptions{})(background)
+	if level := audio.MeasureLevel(readChunks(t, denoised, 100)).RMS; level > input-10 {
+		t.Errorf("Expected the noise to be reduced from %.2f dBFS, got %.2f", input, level)
+	}
+
+	// the sine starts after the noise was learned and is kept
+	sine := recordSine(t)
+	chunks := 0
+	mixed := audio.ReaderFunc(func() (wave.Audio, func(), error) {
+		chunk, _, err := background.Read()
+		if err != nil || chunks < 50 {
+			chunks++
+			return chunk, func() {}, err
+		}
+		tone, _, err := sine.Read()
+		if err != nil {
+			return nil, func() {}, err
+		}
+		noisy := chunk.(*wave.Int16Interleaved)
+		for i := range noisy.Data {
+			v := float64(noisy.Data[i]) + float64(tone.At(i, 0).(wave.Float32Sample))*math.MaxInt16
+			noisy.Data[i] = int16(v)
+		}
+		return noisy, func() {}, nil
+	})
+	denoised = audio.SuppressNoise(audio.NoiseSuppressionOptions{})(mixed)
+	if level := audio.MeasureLevel(readChunks(t, denoised, 70)).RMS; math.Abs(level-sineLevel) > 1 {
+		t.Errorf("Expected %.2f dBFS, got %.2f", sineLevel, level)
+	}
+}
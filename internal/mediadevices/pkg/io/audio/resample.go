@@ -0,0 +1,192 @@
+package audio
+
+import (
+	"math"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+const (
+	// resamplerZeroCrossings is the number of zero crossings of the sinc on each
+	// side of a sample, the length of the filter
+	resamplerZeroCrossings = 16
+	// resamplerKaiserBeta gives an attenuation of about 90 dB to the stop band
+	resamplerKaiserBeta = 8.6
+	// resamplerCutoff is the cutoff frequency relative to the lowest Nyquist
+	// frequency of the two rates, below 1 to leave room to the transition band
+	resamplerCutoff = 0.95
+)
+
+// NewResampler creates an audio transform converting the sampling rate of the
+// chunks to sampleRate. The samples are interpolated by a polyphase windowed
+// sinc filter, the chunks of the output have the type of the input but their
+// length follows the ratio of the rates. The last samples of a chunk are held
+// back until the next one, the filter needs the samples on both sides.
+func NewResampler(sampleRate int) TransformFunc {
+	return func(r Reader) Reader {
+		var rs *resampler
+		return ReaderFunc(func() (wave.Audio, func(), error) {
+			buff, _, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			ci := buff.ChunkInfo()
+			if ci.SamplingRate == sampleRate || ci.SamplingRate <= 0 || ci.Channels == 0 {
+				return buff, func() {}, nil
+			}
+			if rs == nil || rs.inRate != ci.SamplingRate || len(rs.history) != ci.Channels {
+				rs = newResampler(ci.SamplingRate, sampleRate, ci.Channels)
+			}
+
+			resampled, err := rs.resample(buff)
+			if err != nil {
+				return nil, func() {}, err
+			}
+			return resampled, func() {}, nil
+		})
+	}
+}
+
+// resampler keeps the filter and the samples of the previous chunks, the
+// conversion is the upsampling by up followed by the downsampling by down
+type resampler struct {
+	inRate, outRate int
+	up, down        int
+	// width is the number of input samples on each side of an output sample
+	width int
+	// phases holds the 2*width coefficients of each of the up fractional
+	// positions between two input samples
+	phases [][]float64
+
+	// history holds the samples of every channel not consumed yet, the output
+	// sample pos+phase/up is interpolated from history[pos-width+1:pos+width+1]
+	history [][]float64
+	pos     int
+	phase   int
+}
+
+func newResampler(inRate, outRate, channels int) *resampler {
+	g := gcd(inRate, outRate)
+	rs := &resampler{
+		inRate:  inRate,
+		outRate: outRate,
+		up:      outRate / g,
+		down:    inRate / g,
+		history: make([][]float64, channels),
+	}
+
+	// the cutoff follows the lowest rate to filter the aliases out of a
+	// downsampling, the filter is stretched to keep its quality
+	cutoff := resamplerCutoff
+	if outRate < inRate {
+		cutoff *= float64(outRate) / float64(inRate)
+	}
+	rs.width = int(math.Ceil(resamplerZeroCrossings / cutoff))
+	rs.phases = make([][]float64, rs.up)
+	for p := range rs.phases {
+		coeffs := make([]float64, 2*rs.width)
+		frac := float64(p) / float64(rs.up)
+		var sum float64
+		for i := range coeffs {
+			t := frac - float64(i-rs.width+1)
+			coeffs[i] = cutoff * sinc(cutoff*t) * kaiser(t/float64(rs.width), resamplerKaiserBeta)
+			sum += coeffs[i]
+		}
+		// each phase passes the DC unchanged
+		for i := range coeffs {
+			coeffs[i] /= sum
+		}
+		rs.phases[p] = coeffs
+	}
+
+	// the first output sample is aligned on the first input sample
+	for ch := range rs.history {
+		rs.history[ch] = make([]float64, rs.width-1)
+	}
+	rs.pos = rs.width - 1
+	return rs
+}
+
+// resample appends the samples of buff to the history and interpolates every
+// output sample whose input is complete
+func (rs *resampler) resample(buff wave.Audio) (wave.Audio, error) {
+	ci := buff.ChunkInfo()
+	for ch := range rs.history {
+		for i := 0; i < ci.Len; i++ {
+			rs.history[ch] = append(rs.history[ch], sampleValue(buff.At(i, ch)))
+		}
+	}
+
+	available := len(rs.history[0])
+	n := 0
+	for pos, phase := rs.pos, rs.phase; pos+rs.width < available; n++ {
+		phase += rs.down
+		pos += phase / rs.up
+		phase %= rs.up
+	}
+
+	out := wave.ChunkInfo{Len: n, Channels: ci.Channels, SamplingRate: rs.outRate}
+	resampled, set, err := newChunkLike(buff, out)
+	if err != nil {
+		return nil, err
+	}
+
+	for ch, history := range rs.history {
+		pos, phase := rs.pos, rs.phase
+		for i := 0; i < n; i++ {
+			var v float64
+			for j, c := range rs.phases[phase] {
+				v += c * history[pos-rs.width+1+j]
+			}
+			set(i, ch, v)
+			phase += rs.down
+			pos += phase / rs.up
+			phase %= rs.up
+		}
+		if ch == len(rs.history)-1 {
+			rs.pos, rs.phase = pos, phase
+		}
+	}
+
+	// drop the samples which won't be used anymore
+	consumed := rs.pos - rs.width + 1
+	if consumed > 0 {
+		for ch := range rs.history {
+			rs.history[ch] = append(rs.history[ch][:0], rs.history[ch][consumed:]...)
+		}
+		rs.pos -= consumed
+	}
+	return resampled, nil
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser returns the Kaiser window at x, from -1 to 1
+func kaiser(x, beta float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return besselI0(beta*math.Sqrt(1-x*x)) / besselI0(beta)
+}
+
+// besselI0 is the modified Bessel function of the first kind of order 0
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,150 @@
+package audio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// sineChunks returns chunks of 10ms of a sine at rate, continuous across the
+// chunks, in the type of newChunk
+func sineChunks(rate, freq int, count int, newChunk func(wave.ChunkInfo) wave.EditableAudio) []wave.Audio {
+	var chunks []wave.Audio
+	n := rate / 100
+	for c := 0; c < count; c++ {
+		chunk := newChunk(wave.ChunkInfo{Len: n, Channels: 2, SamplingRate: rate})
+		for i := 0; i < n; i++ {
+			v := 0.5 * math.Sin(2*math.Pi*float64(freq)*float64(c*n+i)/float64(rate))
+			chunk.Set(i, 0, sampleOf(chunk, v))
+			chunk.Set(i, 1, sampleOf(chunk, -v))
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// sampleOf returns v, relative to the full scale, in the format of chunk
+func sampleOf(chunk wave.Audio, v float64) wave.Sample {
+	switch chunk.(type) {
+	case *wave.Int16Interleaved, *wave.Int16NonInterleaved:
+		return int16Sample(v)
+	default:
+		return wave.Float32Sample(v)
+	}
+}
+
+func TestResampler(t *testing.T) {
+	testCases := map[string]struct {
+		from, to int
+		newChunk func(wave.ChunkInfo) wave.EditableAudio
+	}{
+		"Upsampling": {44100, 48000, func(ci wave.ChunkInfo) wave.EditableAudio {
+			return wave.NewFloat32Interleaved(ci)
+		}},
+		"Downsampling": {48000, 16000, func(ci wave.ChunkInfo) wave.EditableAudio {
+			return wave.NewFloat32NonInterleaved(ci)
+		}},
+		"Int16": {16000, 48000, func(ci wave.ChunkInfo) wave.EditableAudio {
+			return wave.NewInt16Interleaved(ci)
+		}},
+		"Int16NonInterleaved": {22050, 8000, func(ci wave.ChunkInfo) wave.EditableAudio {
+			return wave.NewInt16NonInterleaved(ci)
+		}},
+	}
+	for name, testCase := range testCases {
+		chunks := sineChunks(testCase.from, 1000, 20, testCase.newChunk)
+		i := 0
+		r := NewResampler(testCase.to)(ReaderFunc(func() (wave.Audio, func(), error) {
+			i++
+			return chunks[i-1], func() {}, nil
+		}))
+
+		var samples [][2]float64
+		for range chunks {
+			chunk, _, err := r.Read()
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+			ci := chunk.ChunkInfo()
+			if ci.SamplingRate != testCase.to || ci.Channels != 2 {
+				t.Fatalf("%s: expected %d Hz with 2 channels, got %v", name, testCase.to, ci)
+			}
+			if typeName(chunk) != typeName(chunks[0]) {
+				t.Errorf("%s: expected %s, got %s", name, typeName(chunks[0]), typeName(chunk))
+			}
+			for j := 0; j < ci.Len; j++ {
+				samples = append(samples, [2]float64{sampleValue(chunk.At(j, 0)), sampleValue(chunk.At(j, 1))})
+			}
+		}
+
+		// the held back samples are shorter than a chunk
+		expected := len(chunks) * testCase.to / 100
+		if len(samples) > expected || len(samples) < expected-testCase.to/100 {
+			t.Errorf("%s: expected about %d samples, got %d", name, expected, len(samples))
+		}
+		// the output is the same sine at the new rate, past the silence the
+		// filter starts from
+		var maxErr float64
+		for j := testCase.to / 100; j < len(samples); j++ {
+			v := 0.5 * math.Sin(2*math.Pi*1000*float64(j)/float64(testCase.to))
+			maxErr = math.Max(maxErr, math.Abs(samples[j][0]-v))
+			maxErr = math.Max(maxErr, math.Abs(samples[j][1]+v))
+		}
+		if maxErr > 0.001 {
+			t.Errorf("%s: expected the sine, got an error of %f", name, maxErr)
+		}
+	}
+}
+
+func typeName(chunk wave.Audio) string {
+	switch chunk.(type) {
+	case *wave.Int16Interleaved:
+		return "Int16Interleaved"
+	case *wave.Int16NonInterleaved:
+		return "Int16NonInterleaved"
+	case *wave.Float32Interleaved:
+		return "Float32Interleaved"
+	case *wave.Float32NonInterleaved:
+		return "Float32NonInterleaved"
+	default:
+		return "unknown"
+	}
+}
+
+func TestResamplerSameRate(t *testing.T) {
+	chunk := sineChunk(1)
+	r := NewResampler(48000)(ReaderFunc(func() (wave.Audio, func(), error) {
+		return chunk, func() {}, nil
+	}))
+	out, _, err := r.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out != chunk {
+		t.Error("Expected the chunk to be passed through")
+	}
+}
+
+func TestResamplerAliasing(t *testing.T) {
+	// 12kHz is above the Nyquist frequency of 16kHz, it must not fold back
+	chunks := sineChunks(48000, 12000, 20, func(ci wave.ChunkInfo) wave.EditableAudio {
+		return wave.NewFloat32Interleaved(ci)
+	})
+	i := 0
+	r := NewResampler(16000)(ReaderFunc(func() (wave.Audio, func(), error) {
+		i++
+		return chunks[i-1], func() {}, nil
+	}))
+	for range chunks {
+		chunk, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if i > 2 {
+			if level := MeasureLevel(chunk); level.Peak > -60 {
+				t.Errorf("Expected the tone to be filtered out, got %.2f dBFS", level.Peak)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package audio
+
+import (
+	"math"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// sampleValue returns s relative to the full scale of its format
+func sampleValue(s wave.Sample) float64 {
+	switch s := s.(type) {
+	case wave.Int16Sample:
+		return float64(s) / 0x8000
+	case wave.Float32Sample:
+		return float64(s)
+	default:
+		return float64(s.Int()) / 0x80000000
+	}
+}
+
+// int16Sample rounds and clips v, relative to the full scale
+func int16Sample(v float64) wave.Int16Sample {
+	v = math.Round(v * 0x8000)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return wave.Int16Sample(v)
+}
+
+// newChunkLike allocates a chunk of the type of buff and returns it with a
+// setter of its samples relative to the full scale
+func newChunkLike(buff wave.Audio, ci wave.ChunkInfo) (wave.Audio, func(i, ch int, v float64), error) {
+	switch buff.(type) {
+	case *wave.Int16Interleaved:
+		a := wave.NewInt16Interleaved(ci)
+		return a, func(i, ch int, v float64) { a.SetInt16(i, ch, int16Sample(v)) }, nil
+	case *wave.Int16NonInterleaved:
+		a := wave.NewInt16NonInterleaved(ci)
+		return a, func(i, ch int, v float64) { a.SetInt16(i, ch, int16Sample(v)) }, nil
+	case *wave.Float32Interleaved:
+		a := wave.NewFloat32Interleaved(ci)
+		return a, func(i, ch int, v float64) { a.SetFloat32(i, ch, wave.Float32Sample(v)) }, nil
+	case *wave.Float32NonInterleaved:
+		a := wave.NewFloat32NonInterleaved(ci)
+		return a, func(i, ch int, v float64) { a.SetFloat32(i, ch, wave.Float32Sample(v)) }, nil
+	default:
+		return nil, nil, errUnsupported
+	}
+}
+
+// chunkProcessor prepares the processing of a chunk and returns the function
+// processing its samples, which are relative to the full scale. It is called
+// for every chunk in order, the samples are processed in order too.
+type chunkProcessor func(chunk wave.Audio) func(i, ch int, v float64) float64
+
+// mapSamples returns a transform replacing the samples of the chunks by the
+// results of a processor, which is created again when the format of the chunks
+// changes
+func mapSamples(newProcessor func(ci wave.ChunkInfo) chunkProcessor) TransformFunc {
+	return func(r Reader) Reader {
+		var current wave.ChunkInfo
+		var processor chunkProcessor
+		return ReaderFunc(func() (wave.Audio, func(), error) {
+			buff, _, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			ci := buff.ChunkInfo()
+			if processor == nil || ci.Channels != current.Channels || ci.SamplingRate != current.SamplingRate {
+				processor, current = newProcessor(ci), ci
+			}
+
+			processed, set, err := newChunkLike(buff, ci)
+			if err != nil {
+				return nil, func() {}, err
+			}
+			process := processor(buff)
+			for i := 0; i < ci.Len; i++ {
+				for ch := 0; ch < ci.Channels; ch++ {
+					set(i, ch, process(i, ch, sampleValue(buff.At(i, ch))))
+				}
+			}
+			return processed, func() {}, nil
+		})
+	}
+}
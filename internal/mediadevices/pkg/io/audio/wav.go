@@ -0,0 +1,324 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xfffe
+	// wavUnknownSize is written in the sizes of a stream which can't be seeked
+	// back, the data goes on until the end of the file
+	wavUnknownSize = 0xffffffff
+	// wavChunkDuration is the duration of the chunks read
+	wavChunkDuration = 20 * time.Millisecond
+)
+
+var (
+	errWAVSignature = errors.New("wav: not a RIFF WAVE stream")
+	errWAVFormat    = errors.New("wav: unsupported format, expected 16 bits PCM or 32 bits float")
+	errWAVNoData    = errors.New("wav: no data chunk")
+	errWAVChunkInfo = errors.New("wav: the chunks must keep the rate and the channels of the first one")
+)
+
+// WAVHeader describes the samples of a WAV stream
+type WAVHeader struct {
+	SampleRate int
+	Channels   int
+	// Float is true for 32 bits float samples, the others are 16 bits PCM
+	Float bool
+}
+
+// WAVReader reads the samples of a WAV stream in chunks of 20ms, interleaved
+// like in the stream
+type WAVReader struct {
+	r      *bufio.Reader
+	header WAVHeader
+	// remaining is the size of the data left, -1 when it goes on until the end
+	// of the stream
+	remaining int64
+}
+
+// NewWAVReader reads the header of a WAV stream up to its data
+func NewWAVReader(r io.Reader) (*WAVReader, error) {
+	br := bufio.NewReader(r)
+	var riff [12]byte
+	if _, err := io.ReadFull(br, riff[:]); err != nil {
+		return nil, err
+	}
+	if string(riff[:4]) != "RIFF" || string(riff[8:]) != "WAVE" {
+		return nil, errWAVSignature
+	}
+
+	reader := &WAVReader{r: br}
+	var hasFormat bool
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(br, chunk[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, errWAVNoData
+			}
+			return nil, err
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		switch string(chunk[:4]) {
+		case "fmt ":
+			if size < 16 {
+				return nil, errWAVFormat
+			}
+			format := make([]byte, size)
+			if _, err := io.ReadFull(br, format); err != nil {
+				return nil, err
+			}
+			if err := reader.parseFormat(format); err != nil {
+				return nil, err
+			}
+			hasFormat = true
+		case "data":
+			if !hasFormat {
+				return nil, errWAVFormat
+			}
+			reader.remaining = size
+			if size == wavUnknownSize || size == 0 {
+				reader.remaining = -1
+			}
+			return reader, nil
+		default:
+			if _, err := br.Discard(int(size)); err != nil {
+				return nil, err
+			}
+		}
+		// the chunks are aligned on 16 bits
+		if size%2 == 1 {
+			if _, err := br.Discard(1); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (r *WAVReader) parseFormat(format []byte) error {
+	tag := binary.LittleEndian.Uint16(format)
+	bits := binary.LittleEndian.Uint16(format[14:])
+	if tag == wavFormatExtensible && len(format) >= 26 {
+		// the format is the start of the sub format GUID
+		tag = binary.LittleEndian.Uint16(format[24:])
+	}
+	r.header = WAVHeader{
+		Channels:   int(binary.LittleEndian.Uint16(format[2:])),
+		SampleRate: int(binary.LittleEndian.Uint32(format[4:])),
+	}
+	switch {
+	case tag == wavFormatPCM && bits == 16:
+	case tag == wavFormatFloat && bits == 32:
+		r.header.Float = true
+	default:
+		return errWAVFormat
+	}
+	if r.header.Channels <= 0 || r.header.SampleRate <= 0 {
+		return errWAVFormat
+	}
+	return nil
+}
+
+// Header returns the header of the stream
+func (r *WAVReader) Header() WAVHeader {
+	return r.header
+}
+
+// Read reads the next chunk, *wave.Int16Interleaved or *wave.Float32Interleaved
+// following the header, io.EOF at the end of the stream. The last chunk may be
+// shorter.
+func (r *WAVReader) Read() (wave.Audio, func(), error) {
+	frameSize := 2 * r.header.Channels
+	if r.header.Float {
+		frameSize = 4 * r.header.Channels
+	}
+	n := int(time.Duration(r.header.SampleRate) * wavChunkDuration / time.Second)
+	if r.remaining >= 0 && int64(n*frameSize) > r.remaining {
+		n = int(r.remaining) / frameSize
+	}
+	if n == 0 {
+		return nil, func() {}, io.EOF
+	}
+
+	data := make([]byte, n*frameSize)
+	read, err := io.ReadFull(r.r, data)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		return nil, func() {}, err
+	}
+	n = read / frameSize
+	if n == 0 {
+		return nil, func() {}, io.EOF
+	}
+	if r.remaining >= 0 {
+		r.remaining -= int64(n * frameSize)
+	}
+
+	info := wave.ChunkInfo{Len: n, Channels: r.header.Channels, SamplingRate: r.header.SampleRate}
+	if r.header.Float {
+		chunk := wave.NewFloat32Interleaved(info)
+		for i := range chunk.Data {
+			chunk.Data[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		return chunk, func() {}, nil
+	}
+	chunk := wave.NewInt16Interleaved(info)
+	for i := range chunk.Data {
+		chunk.Data[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	return chunk, func() {}, nil
+}
+
+// WAVWriter writes chunks to a WAV stream. The header is written with the
+// first chunk, the float chunks are written as 32 bits float samples and the
+// others as 16 bits PCM. The next chunks must have the same rate and channels.
+// The errors are sticky, a writer which failed ignores the next chunks.
+type WAVWriter struct {
+	w      io.Writer
+	header *WAVHeader
+	size   int64
+	err    error
+}
+
+// NewWAVWriter creates a writer of chunks to w
+func NewWAVWriter(w io.Writer) *WAVWriter {
+	return &WAVWriter{w: w}
+}
+
+// Write writes the samples of chunk, in the format of the first chunk
+func (w *WAVWriter) Write(chunk wave.Audio) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.write(chunk)
+	return w.err
+}
+
+// Err returns the error which stopped the writer
+func (w *WAVWriter) Err() error {
+	return w.err
+}
+
+// Close writes the sizes of the stream in the header when w is an
+// io.WriteSeeker, they are unknown otherwise. w isn't closed.
+func (w *WAVWriter) Close() error {
+	if w.err != nil || w.header == nil {
+		return w.err
+	}
+	seeker, ok := w.w.(io.WriteSeeker)
+	if !ok || w.size > math.MaxUint32-36 {
+		return nil
+	}
+	var size [4]byte
+	for _, field := range []struct {
+		offset int64
+		value  int64
+	}{
+		{4, 36 + w.size},
+		{40, w.size},
+	} {
+		binary.LittleEndian.PutUint32(size[:], uint32(field.value))
+		if _, err := seeker.Seek(field.offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := seeker.Write(size[:]); err != nil {
+			return err
+		}
+	}
+	_, err := seeker.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (w *WAVWriter) write(chunk wave.Audio) error {
+	info := chunk.ChunkInfo()
+	if w.header == nil {
+		header := WAVHeader{SampleRate: info.SamplingRate, Channels: info.Channels}
+		switch chunk.(type) {
+		case *wave.Float32Interleaved, *wave.Float32NonInterleaved:
+			header.Float = true
+		}
+		if err := w.writeHeader(header); err != nil {
+			return err
+		}
+		w.header = &header
+	}
+	if info.SamplingRate != w.header.SampleRate || info.Channels != w.header.Channels {
+		return errWAVChunkInfo
+	}
+
+	sampleSize := 2
+	if w.header.Float {
+		sampleSize = 4
+	}
+	data := make([]byte, 0, info.Len*info.Channels*sampleSize)
+	for i := 0; i < info.Len; i++ {
+		for ch := 0; ch < info.Channels; ch++ {
+			v := sampleValue(chunk.At(i, ch))
+			if w.header.Float {
+				data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(v)))
+			} else {
+				data = binary.LittleEndian.AppendUint16(data, uint16(int16Sample(v)))
+			}
+		}
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+	w.size += int64(len(data))
+	return nil
+}
+
+func (w *WAVWriter) writeHeader(header WAVHeader) error {
+	if header.SampleRate <= 0 || header.Channels <= 0 {
+		return errWAVChunkInfo
+	}
+	tag, bits := uint16(wavFormatPCM), uint16(16)
+	if header.Float {
+		tag, bits = wavFormatFloat, 32
+	}
+	blockAlign := header.Channels * int(bits) / 8
+
+	b := make([]byte, 0, 44)
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, wavUnknownSize)
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, tag)
+	b = binary.LittleEndian.AppendUint16(b, uint16(header.Channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(header.SampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(header.SampleRate*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, bits)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, wavUnknownSize)
+	_, err := w.w.Write(b)
+	return err
+}
+
+// WAVTap records the chunks going through a stage of a TransformFunc chain to
+// w. The chunks aren't changed, and a failure to write them doesn't stop the
+// stream, w.Err tells why the recording stopped.
+func WAVTap(w *WAVWriter) TransformFunc {
+	return func(r Reader) Reader {
+		return ReaderFunc(func() (wave.Audio, func(), error) {
+			chunk, release, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			w.Write(chunk)
+			return chunk, release, nil
+		})
+	}
+}
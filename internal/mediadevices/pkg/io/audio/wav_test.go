@@ -0,0 +1,186 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+func TestWAVWriter(t *testing.T) {
+	testCases := map[string]struct {
+		newChunk func(wave.ChunkInfo) wave.EditableAudio
+		float    bool
+	}{
+		"Int16Interleaved": {func(ci wave.ChunkInfo) wave.EditableAudio { return wave.NewInt16Interleaved(ci) }, false},
+		"Int16NonInterleaved": {func(ci wave.ChunkInfo) wave.EditableAudio {
+			return wave.NewInt16NonInterleaved(ci)
+		}, false},
+		"Float32Interleaved": {func(ci wave.ChunkInfo) wave.EditableAudio {
+			return wave.NewFloat32Interleaved(ci)
+		}, true},
+		"Float32NonInterleaved": {func(ci wave.ChunkInfo) wave.EditableAudio {
+			return wave.NewFloat32NonInterleaved(ci)
+		}, true},
+	}
+	for name, testCase := range testCases {
+		// 30ms of a sine, a chunk and a half of the reader
+		chunks := sineChunks(16000, 1000, 3, testCase.newChunk)
+		path := filepath.Join(t.TempDir(), "clip.wav")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := NewWAVWriter(f)
+		for _, chunk := range chunks {
+			if err := w.Write(chunk); err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		f.Close()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sampleSize := 2
+		if testCase.float {
+			sampleSize = 4
+		}
+		if size := binary.LittleEndian.Uint32(data[40:]); int(size) != 480*2*sampleSize {
+			t.Errorf("%s: expected a data size of %d, got %d", name, 480*2*sampleSize, size)
+		}
+
+		r, err := NewWAVReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		expected := WAVHeader{SampleRate: 16000, Channels: 2, Float: testCase.float}
+		if r.Header() != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, r.Header())
+		}
+		var samples []wave.Audio
+		for {
+			chunk, _, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+			samples = append(samples, chunk)
+		}
+		if len(samples) != 2 || samples[0].ChunkInfo().Len != 320 || samples[1].ChunkInfo().Len != 160 {
+			t.Fatalf("%s: expected chunks of 320 and 160 samples, got %d chunks", name, len(samples))
+		}
+		for i := 0; i < 480; i++ {
+			for ch := 0; ch < 2; ch++ {
+				got := sampleValue(samples[i/320].At(i%320, ch))
+				want := sampleValue(chunks[i/160].At(i%160, ch))
+				if d := got - want; d > 1e-4 || d < -1e-4 {
+					t.Fatalf("%s: sample %d/%d: expected %f, got %f", name, i, ch, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestWAVWriterStream(t *testing.T) {
+	// a stream which can't be seeked keeps the unknown sizes, read until its end
+	var stream bytes.Buffer
+	w := NewWAVWriter(&stream)
+	for _, chunk := range sineChunks(8000, 1000, 5, func(ci wave.ChunkInfo) wave.EditableAudio {
+		return wave.NewInt16Interleaved(ci)
+	}) {
+		w.Write(chunk)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if size := binary.LittleEndian.Uint32(stream.Bytes()[40:]); size != wavUnknownSize {
+		t.Errorf("Expected an unknown size, got %d", size)
+	}
+
+	r, err := NewWAVReader(&stream)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var n int
+	for {
+		chunk, _, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		n += chunk.ChunkInfo().Len
+	}
+	if n != 400 {
+		t.Errorf("Expected 400 samples, got %d", n)
+	}
+
+	w.Write(wave.NewInt16Interleaved(wave.ChunkInfo{Len: 80, Channels: 1, SamplingRate: 8000}))
+	if w.Err() != errWAVChunkInfo {
+		t.Errorf("Expected %v, got %v", errWAVChunkInfo, w.Err())
+	}
+}
+
+func TestWAVReaderInvalid(t *testing.T) {
+	format := func(tag, bits uint16) []byte {
+		b := []byte("RIFF\x00\x00\x00\x00WAVE")
+		// an odd chunk before the format is skipped with its padding
+		b = append(b, "LIST\x03\x00\x00\x00abc\x00"...)
+		b = append(b, "fmt \x10\x00\x00\x00"...)
+		b = binary.LittleEndian.AppendUint16(b, tag)
+		b = binary.LittleEndian.AppendUint16(b, 1)
+		b = binary.LittleEndian.AppendUint32(b, 8000)
+		b = binary.LittleEndian.AppendUint32(b, 8000*uint32(bits)/8)
+		b = binary.LittleEndian.AppendUint16(b, bits/8)
+		b = binary.LittleEndian.AppendUint16(b, bits)
+		return append(b, "data\x00\x00\x00\x00"...)
+	}
+	testCases := map[string]struct {
+		data     []byte
+		expected error
+	}{
+		"Valid":     {format(wavFormatPCM, 16), nil},
+		"Signature": {[]byte("RIFF\x00\x00\x00\x00AVI LIST"), errWAVSignature},
+		"8Bits":     {format(wavFormatPCM, 8), errWAVFormat},
+		"Float64":   {format(wavFormatFloat, 64), errWAVFormat},
+		"NoData":    {format(wavFormatPCM, 16)[:len(format(wavFormatPCM, 16))-8], errWAVNoData},
+	}
+	for name, testCase := range testCases {
+		if _, err := NewWAVReader(bytes.NewReader(testCase.data)); err != testCase.expected {
+			t.Errorf("%s: expected %v, got %v", name, testCase.expected, err)
+		}
+	}
+}
+
+func TestWAVTap(t *testing.T) {
+	chunk := sineChunk(0.5)
+	var stream bytes.Buffer
+	w := NewWAVWriter(&stream)
+	r := WAVTap(w)(ReaderFunc(func() (wave.Audio, func(), error) {
+		return chunk, func() {}, nil
+	}))
+	for i := 0; i < 3; i++ {
+		got, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != chunk {
+			t.Error("Expected the chunk to be passed through")
+		}
+	}
+	if size := stream.Len() - 44; size != 3*480*2*4 {
+		t.Errorf("Expected %d bytes of samples, got %d", 3*480*2*4, size)
+	}
+}
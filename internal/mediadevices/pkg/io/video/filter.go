@@ -0,0 +1,91 @@
+package video
+
+import (
+	"image"
+)
+
+// denoiseMotionThreshold is the sample difference above which a pixel is
+// considered moving, moving pixels are not averaged to avoid ghosts
+const denoiseMotionThreshold = 12
+
+// Grayscale returns a transform removing the colors of the frames, the noisy
+// chroma of a camera at night then costs no bitrate. The frames are converted
+// to I420 and modified in place.
+func Grayscale() TransformFunc {
+	return func(r Reader) Reader {
+		r = ToI420(r)
+		return ReaderFunc(func() (image.Image, func(), error) {
+			img, release, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			yuv := img.(*image.YCbCr)
+			for i := range yuv.Cb {
+				yuv.Cb[i] = 128
+			}
+			for i := range yuv.Cr {
+				yuv.Cr[i] = 128
+			}
+			return img, release, nil
+		})
+	}
+}
+
+// Denoise returns a temporal denoiser: every static sample is averaged with
+// the previous frames, strength from 0 to 1 is the weight of the previous
+// frames. The frames are converted to I420 and modified in place.
+func Denoise(strength float64) TransformFunc {
+	if strength < 0 {
+		strength = 0
+	}
+	if strength > 0.95 {
+		strength = 0.95
+	}
+	weight := int(strength * 256)
+
+	return func(r Reader) Reader {
+		r = ToI420(r)
+		var prev *image.YCbCr
+		return ReaderFunc(func() (image.Image, func(), error) {
+			img, release, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			yuv := img.(*image.YCbCr)
+			w, h := yuv.Rect.Dx(), yuv.Rect.Dy()
+			if prev == nil || prev.Rect.Dx() != w || prev.Rect.Dy() != h {
+				// the history restarts with the first frame of a new size
+				prev = reuseI420(prev, w, h)
+				eachPlane(prev, yuv, func(dst []uint8, dstStride int, src []uint8, srcStride, w, h int) {
+					for y := 0; y < h; y++ {
+						copy(dst[y*dstStride:y*dstStride+w], src[y*srcStride:])
+					}
+				})
+				return img, release, nil
+			}
+
+			eachPlane(prev, yuv, func(prev []uint8, prevStride int, cur []uint8, curStride, w, h int) {
+				denoisePlane(cur, curStride, prev, prevStride, w, h, weight)
+			})
+			return img, release, nil
+		})
+	}
+}
+
+// denoisePlane blends cur with prev where the samples are static, the result
+// is written to both planes
+func denoisePlane(cur []uint8, curStride int, prev []uint8, prevStride, w, h, weight int) {
+	for y := 0; y < h; y++ {
+		c := cur[y*curStride : y*curStride+w]
+		p := prev[y*prevStride : y*prevStride+w]
+		for x := range c {
+			diff := int(c[x]) - int(p[x])
+			if diff > denoiseMotionThreshold || diff < -denoiseMotionThreshold {
+				p[x] = c[x]
+				continue
+			}
+			v := uint8(int(c[x]) - (diff*weight+128)>>8)
+			c[x], p[x] = v, v
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package video
+
+import (
+	"image"
+	"testing"
+)
+
+func TestGrayscale(t *testing.T) {
+	src := newPatternI420(4, 4)
+	luma := append([]uint8{}, src.Y...)
+
+	out := readOne(t, Grayscale(), src)
+	for i := range out.Cb {
+		if out.Cb[i] != 128 || out.Cr[i] != 128 {
+			t.Fatalf("Expected neutral chroma, got (%d, %d)", out.Cb[i], out.Cr[i])
+		}
+	}
+	for i := range luma {
+		if out.Y[i] != luma[i] {
+			t.Fatal("Expected the luma to be kept")
+		}
+	}
+}
+
+func TestDenoise(t *testing.T) {
+	frames := make(chan *image.YCbCr, 3)
+	r := Denoise(0.5)(ReaderFunc(func() (image.Image, func(), error) {
+		return <-frames, func() {}, nil
+	}))
+	read := func(img *image.YCbCr) *image.YCbCr {
+		frames <- img
+		out, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return out.(*image.YCbCr)
+	}
+
+	// the first frame starts the history
+	if out := read(newFlatI420(4, 4, 100)); out.Y[0] != 100 {
+		t.Fatalf("Expected the first frame to be untouched, got %d", out.Y[0])
+	}
+
+	// noise is halved, motion is kept
+	next := newFlatI420(4, 4, 110)
+	next.Y[1] = 200
+	out := read(next)
+	if out.Y[0] != 105 {
+		t.Errorf("Expected static samples to be averaged to 105, got %d", out.Y[0])
+	}
+	if out.Y[1] != 200 {
+		t.Errorf("Expected moving samples to be kept, got %d", out.Y[1])
+	}
+
+	// a new size restarts the history
+	if out := read(newFlatI420(8, 8, 50)); out.Y[0] != 50 {
+		t.Errorf("Expected the history to restart, got %d", out.Y[0])
+	}
+}
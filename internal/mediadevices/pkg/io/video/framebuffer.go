@@ -2,6 +2,8 @@ package video
 
 import (
 	"image"
+
+	"github.com/pion/mediadevices/pkg/frame"
 )
 
 // FrameBuffer is a buffer that can store any image format.
@@ -206,6 +208,15 @@ func (buff *FrameBuffer) StoreCopy(src image.Image) {
 		clone.Cr = buff.buffer[currentLen : currentLen+len(src.Cr) : currentLen+len(src.Cr)]
 
 		buff.tmp = clone
+	case *frame.Encoded:
+		// the frame stays compressed, its pixels are decoded again on demand
+		buff.storeInOrder(src.Data)
+		buff.tmp = &frame.Encoded{
+			Format:   src.Format,
+			Data:     buff.buffer[:len(src.Data):len(src.Data)],
+			KeyFrame: src.KeyFrame,
+			Rect:     src.Rect,
+		}
 	default:
 		var converted image.RGBA
 		imageToRGBA(&converted, src)
@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"reflect"
 	"testing"
+
+	"github.com/pion/mediadevices/pkg/frame"
 )
 
 func randomize(arr []uint8) {
@@ -193,3 +195,22 @@ func TestFrameBufferStoreCopyAndLoad(t *testing.T) {
 		}
 	}
 }
+
+func TestFrameBufferStoreCopyEncoded(t *testing.T) {
+	src := &frame.Encoded{
+		Format:   frame.FormatH264,
+		Data:     []byte{0, 0, 0, 1, 0x65, 0x88},
+		KeyFrame: true,
+		Rect:     image.Rect(0, 0, 32, 16),
+	}
+	frameBuffer := NewFrameBuffer(0)
+	frameBuffer.StoreCopy(src)
+	copied, ok := frameBuffer.Load().(*frame.Encoded)
+	if !ok {
+		t.Fatalf("Expected the frame to stay encoded, got %T", frameBuffer.Load())
+	}
+	src.Data[5] = 0
+	if copied.Format != src.Format || !copied.KeyFrame || copied.Rect != src.Rect || copied.Data[5] != 0x88 {
+		t.Errorf("Expected a copy of the frame, got %+v", copied)
+	}
+}
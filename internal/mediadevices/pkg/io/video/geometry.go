@@ -0,0 +1,160 @@
+package video
+
+import (
+	"errors"
+	"image"
+)
+
+// Rotation is a clockwise rotation in degrees
+type Rotation int
+
+// List of supported rotations
+const (
+	Rotate0   Rotation = 0
+	Rotate90  Rotation = 90
+	Rotate180 Rotation = 180
+	Rotate270 Rotation = 270
+)
+
+var (
+	errUnsupportedRotation = errors.New("rotate: rotation must be a multiple of 90 degrees")
+	errEmptyCrop           = errors.New("crop: the crop rectangle is outside of the frame")
+)
+
+// Rotate returns a transform rotating the frames clockwise. The frames are
+// converted to I420.
+func Rotate(rotation Rotation) TransformFunc {
+	rotation = ((rotation % 360) + 360) % 360
+	return geometryTransform(func(src *image.YCbCr, dst **image.YCbCr) error {
+		if rotation%90 != 0 {
+			return errUnsupportedRotation
+		}
+		w, h := src.Rect.Dx(), src.Rect.Dy()
+		if rotation == Rotate90 || rotation == Rotate270 {
+			w, h = h, w
+		}
+		*dst = reuseI420(*dst, w, h)
+		eachPlane(*dst, src, func(dst []uint8, dstStride int, src []uint8, srcStride, w, h int) {
+			rotatePlane(dst, dstStride, src, srcStride, w, h, rotation)
+		})
+		return nil
+	})
+}
+
+// Flip returns a transform mirroring the frames horizontally and/or
+// vertically. The frames are converted to I420.
+func Flip(horizontal, vertical bool) TransformFunc {
+	return geometryTransform(func(src *image.YCbCr, dst **image.YCbCr) error {
+		*dst = reuseI420(*dst, src.Rect.Dx(), src.Rect.Dy())
+		eachPlane(*dst, src, func(dst []uint8, dstStride int, src []uint8, srcStride, w, h int) {
+			flipPlane(dst, dstStride, src, srcStride, w, h, horizontal, vertical)
+		})
+		return nil
+	})
+}
+
+// Crop returns a transform keeping rect of the frames. The origin of rect is
+// rounded down to even coordinates to keep the chroma aligned, rect is clipped
+// to the frames. The frames are converted to I420.
+func Crop(rect image.Rectangle) TransformFunc {
+	return geometryTransform(func(src *image.YCbCr, dst **image.YCbCr) error {
+		r := alignCrop(rect.Add(src.Rect.Min), src.Rect)
+		if r.Empty() {
+			return errEmptyCrop
+		}
+		*dst = reuseI420(*dst, r.Dx(), r.Dy())
+		eachPlane(*dst, cropI420(src, r), func(dst []uint8, dstStride int, src []uint8, srcStride, w, h int) {
+			for y := 0; y < h; y++ {
+				copy(dst[y*dstStride:y*dstStride+w], src[y*srcStride:])
+			}
+		})
+		return nil
+	})
+}
+
+// Zoom returns a digital zoom transform, the center of the frames is
+// magnified by factor and scaled back to the size of the frames. A factor
+// lower than or equal to 1 leaves the frames untouched. The frames are
+// converted to I420.
+func Zoom(factor float64) TransformFunc {
+	if factor <= 1 {
+		return func(r Reader) Reader {
+			return r
+		}
+	}
+	return geometryTransform(func(src *image.YCbCr, dst **image.YCbCr) error {
+		w, h := src.Rect.Dx(), src.Rect.Dy()
+		zw, zh := int(float64(w)/factor), int(float64(h)/factor)
+		r := image.Rect(0, 0, zw, zh).Add(src.Rect.Min).Add(image.Pt((w-zw)/2, (h-zh)/2))
+		*dst = reuseI420(*dst, w, h)
+		cropScaleI420(*dst, src, alignCrop(r, src.Rect))
+		return nil
+	})
+}
+
+// geometryTransform converts the frames to I420 and gives them to fn, which
+// writes the result to dst. dst is allocated by fn and reused across frames.
+func geometryTransform(fn func(src *image.YCbCr, dst **image.YCbCr) error) TransformFunc {
+	return func(r Reader) Reader {
+		r = ToI420(r)
+		var dst *image.YCbCr
+		return ReaderFunc(func() (image.Image, func(), error) {
+			img, release, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			err = fn(img.(*image.YCbCr), &dst)
+			release()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			return dst, func() {}, nil
+		})
+	}
+}
+
+// reuseI420 returns img if it has the wanted size, a new I420 image otherwise
+func reuseI420(img *image.YCbCr, w, h int) *image.YCbCr {
+	if img != nil && img.Rect.Dx() == w && img.Rect.Dy() == h {
+		return img
+	}
+	return image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+}
+
+// eachPlane calls fn with the Y, Cb and Cr planes of the I420 images src and
+// dst, w and h are the size of the plane of src
+func eachPlane(dst, src *image.YCbCr, fn func(dst []uint8, dstStride int, src []uint8, srcStride, w, h int)) {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	cw, ch := (w+1)/2, (h+1)/2
+	fn(dst.Y, dst.YStride, src.Y[src.YOffset(src.Rect.Min.X, src.Rect.Min.Y):], src.YStride, w, h)
+	cOffset := src.COffset(src.Rect.Min.X, src.Rect.Min.Y)
+	fn(dst.Cb, dst.CStride, src.Cb[cOffset:], src.CStride, cw, ch)
+	fn(dst.Cr, dst.CStride, src.Cr[cOffset:], src.CStride, cw, ch)
+}
+
+// alignCrop rounds the origin of r down to even coordinates, relatively to the
+// origin of bounds, and clips r to bounds
+func alignCrop(r, bounds image.Rectangle) image.Rectangle {
+	r.Min.X -= (r.Min.X - bounds.Min.X) & 1
+	r.Min.Y -= (r.Min.Y - bounds.Min.Y) & 1
+	return r.Intersect(bounds)
+}
+
+// cropI420 returns the part r of img without copy, r must be aligned by
+// alignCrop
+func cropI420(img *image.YCbCr, r image.Rectangle) *image.YCbCr {
+	return img.SubImage(r).(*image.YCbCr)
+}
+
+// cropScaleI420 scales the part r of src to the size of dst, r must be aligned
+// by alignCrop
+func cropScaleI420(dst, src *image.YCbCr, r image.Rectangle) {
+	crop := cropI420(src, r)
+	w, h := crop.Rect.Dx(), crop.Rect.Dy()
+	dw, dh := dst.Rect.Dx(), dst.Rect.Dy()
+	yOffset := crop.YOffset(crop.Rect.Min.X, crop.Rect.Min.Y)
+	cOffset := crop.COffset(crop.Rect.Min.X, crop.Rect.Min.Y)
+	scalePlane(dst.Y, dst.YStride, dw, dh, crop.Y[yOffset:], crop.YStride, w, h)
+	scalePlane(dst.Cb, dst.CStride, (dw+1)/2, (dh+1)/2, crop.Cb[cOffset:], crop.CStride, (w+1)/2, (h+1)/2)
+	scalePlane(dst.Cr, dst.CStride, (dw+1)/2, (dh+1)/2, crop.Cr[cOffset:], crop.CStride, (w+1)/2, (h+1)/2)
+}
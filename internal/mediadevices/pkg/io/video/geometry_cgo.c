@@ -0,0 +1,102 @@
+#include <stdint.h>
+#include <string.h>
+
+#include "_cgo_export.h"
+
+void rotatePlaneCGO(
+    unsigned char* dst, const int dst_stride,
+    const unsigned char* src, const int src_stride,
+    const int w, const int h, const int rotation)
+{
+  for (int y = 0; y < h; y++)
+  {
+    const unsigned char* row = src + y * src_stride;
+    switch (rotation)
+    {
+    case 90:
+      // source row y becomes destination column h-1-y
+      for (int x = 0; x < w; x++)
+        dst[x * dst_stride + (h - 1 - y)] = row[x];
+      break;
+    case 180:
+    {
+      unsigned char* out = dst + (h - 1 - y) * dst_stride + (w - 1);
+      for (int x = 0; x < w; x++)
+        out[-x] = row[x];
+      break;
+    }
+    case 270:
+      // source row y becomes destination column y
+      for (int x = 0; x < w; x++)
+        dst[(w - 1 - x) * dst_stride + y] = row[x];
+      break;
+    default:
+      memcpy(dst + y * dst_stride, row, w);
+    }
+  }
+}
+
+void flipPlaneCGO(
+    unsigned char* dst, const int dst_stride,
+    const unsigned char* src, const int src_stride,
+    const int w, const int h,
+    const int horizontal, const int vertical)
+{
+  for (int y = 0; y < h; y++)
+  {
+    const unsigned char* row = src + y * src_stride;
+    unsigned char* out = dst + (vertical ? h - 1 - y : y) * dst_stride;
+    if (horizontal)
+    {
+      for (int x = 0; x < w; x++)
+        out[w - 1 - x] = row[x];
+    }
+    else
+    {
+      memcpy(out, row, w);
+    }
+  }
+}
+
+// Bilinear scaling in fixed point, the pixel centers are aligned. Must be kept
+// in sync with scalePlane of geometry_nocgo.go.
+void scalePlaneCGO(
+    unsigned char* dst, const int dst_stride, const int dw, const int dh,
+    const unsigned char* src, const int src_stride, const int sw, const int sh)
+{
+  const int64_t xstep = ((int64_t)sw << 16) / dw;
+  const int64_t ystep = ((int64_t)sh << 16) / dh;
+  const int64_t xmax = (int64_t)(sw - 1) << 16;
+  const int64_t ymax = (int64_t)(sh - 1) << 16;
+
+  for (int y = 0; y < dh; y++)
+  {
+    int64_t sy = y * ystep + ystep / 2 - 32768;
+    if (sy < 0)
+      sy = 0;
+    if (sy > ymax)
+      sy = ymax;
+    const int y0 = sy >> 16;
+    const int y1 = y0 + 1 < sh ? y0 + 1 : y0;
+    const uint32_t fy = (sy >> 8) & 0xff;
+    const unsigned char* row0 = src + y0 * src_stride;
+    const unsigned char* row1 = src + y1 * src_stride;
+    unsigned char* out = dst + y * dst_stride;
+
+    for (int x = 0; x < dw; x++)
+    {
+      int64_t sx = x * xstep + xstep / 2 - 32768;
+      if (sx < 0)
+        sx = 0;
+      if (sx > xmax)
+        sx = xmax;
+      const int x0 = sx >> 16;
+      const int x1 = x0 + 1 < sw ? x0 + 1 : x0;
+      const uint32_t fx = (sx >> 8) & 0xff;
+
+      const uint32_t top = row0[x0] * (256 - fx) + row0[x1] * fx;
+      const uint32_t bottom = row1[x0] * (256 - fx) + row1[x1] * fx;
+      out[x] = (top * (256 - fy) + bottom * fy + 32768) >> 16;
+    }
+  }
+}
@@ -0,0 +1,42 @@
+//go:build cgo
+// +build cgo
+
+package video
+
+// #include "geometry_cgo.h"
+// #cgo CFLAGS: -std=c11
+import "C"
+
+// CGO version of the functions will be selected at runtime.
+// All functions switched at runtime must be declared also in geometry_nocgo.go.
+
+func rotatePlane(dst []uint8, dstStride int, src []uint8, srcStride, w, h int, rotation Rotation) {
+	C.rotatePlaneCGO(
+		(*C.uchar)(&dst[0]), C.int(dstStride),
+		(*C.uchar)(&src[0]), C.int(srcStride),
+		C.int(w), C.int(h), C.int(rotation),
+	)
+}
+
+func flipPlane(dst []uint8, dstStride int, src []uint8, srcStride, w, h int, horizontal, vertical bool) {
+	C.flipPlaneCGO(
+		(*C.uchar)(&dst[0]), C.int(dstStride),
+		(*C.uchar)(&src[0]), C.int(srcStride),
+		C.int(w), C.int(h),
+		cBool(horizontal), cBool(vertical),
+	)
+}
+
+func scalePlane(dst []uint8, dstStride, dw, dh int, src []uint8, srcStride, sw, sh int) {
+	C.scalePlaneCGO(
+		(*C.uchar)(&dst[0]), C.int(dstStride), C.int(dw), C.int(dh),
+		(*C.uchar)(&src[0]), C.int(srcStride), C.int(sw), C.int(sh),
+	)
+}
+
+func cBool(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,14 @@
+void rotatePlaneCGO(
+    unsigned char* dst, const int dst_stride,
+    const unsigned char* src, const int src_stride,
+    const int w, const int h, const int rotation);
+
+void flipPlaneCGO(
+    unsigned char* dst, const int dst_stride,
+    const unsigned char* src, const int src_stride,
+    const int w, const int h,
+    const int horizontal, const int vertical);
+
+void scalePlaneCGO(
+    unsigned char* dst, const int dst_stride, const int dw, const int dh,
+    const unsigned char* src, const int src_stride, const int sw, const int sh);
@@ -0,0 +1,95 @@
+//go:build !cgo
+// +build !cgo
+
+package video
+
+func rotatePlane(dst []uint8, dstStride int, src []uint8, srcStride, w, h int, rotation Rotation) {
+	for y := 0; y < h; y++ {
+		row := src[y*srcStride : y*srcStride+w]
+		switch rotation {
+		case Rotate90:
+			// source row y becomes destination column h-1-y
+			for x, v := range row {
+				dst[x*dstStride+(h-1-y)] = v
+			}
+		case Rotate180:
+			out := dst[(h-1-y)*dstStride : (h-1-y)*dstStride+w]
+			for x, v := range row {
+				out[w-1-x] = v
+			}
+		case Rotate270:
+			// source row y becomes destination column y
+			for x, v := range row {
+				dst[(w-1-x)*dstStride+y] = v
+			}
+		default:
+			copy(dst[y*dstStride:], row)
+		}
+	}
+}
+
+func flipPlane(dst []uint8, dstStride int, src []uint8, srcStride, w, h int, horizontal, vertical bool) {
+	for y := 0; y < h; y++ {
+		row := src[y*srcStride : y*srcStride+w]
+		dy := y
+		if vertical {
+			dy = h - 1 - y
+		}
+		out := dst[dy*dstStride : dy*dstStride+w]
+		if !horizontal {
+			copy(out, row)
+			continue
+		}
+		for x, v := range row {
+			out[w-1-x] = v
+		}
+	}
+}
+
+// scalePlane is a bilinear scaling in fixed point, the pixel centers are
+// aligned. Must be kept in sync with scalePlaneCGO of geometry_cgo.c.
+func scalePlane(dst []uint8, dstStride, dw, dh int, src []uint8, srcStride, sw, sh int) {
+	xstep := (int64(sw) << 16) / int64(dw)
+	ystep := (int64(sh) << 16) / int64(dh)
+	xmax := int64(sw-1) << 16
+	ymax := int64(sh-1) << 16
+
+	for y := 0; y < dh; y++ {
+		sy := int64(y)*ystep + ystep/2 - 32768
+		if sy < 0 {
+			sy = 0
+		}
+		if sy > ymax {
+			sy = ymax
+		}
+		y0 := int(sy >> 16)
+		y1 := y0
+		if y0+1 < sh {
+			y1 = y0 + 1
+		}
+		fy := uint32(sy>>8) & 0xff
+		row0 := src[y0*srcStride:]
+		row1 := src[y1*srcStride:]
+		out := dst[y*dstStride : y*dstStride+dw]
+
+		for x := range out {
+			sx := int64(x)*xstep + xstep/2 - 32768
+			if sx < 0 {
+				sx = 0
+			}
+			if sx > xmax {
+				sx = xmax
+			}
+			x0 := int(sx >> 16)
+			x1 := x0
+			if x0+1 < sw {
+				x1 = x0 + 1
+			}
+			fx := uint32(sx>>8) & 0xff
+
+			top := uint32(row0[x0])*(256-fx) + uint32(row0[x1])*fx
+			bottom := uint32(row1[x0])*(256-fx) + uint32(row1[x1])*fx
+			out[x] = uint8((top*(256-fy) + bottom*fy + 32768) >> 16)
+		}
+	}
+}
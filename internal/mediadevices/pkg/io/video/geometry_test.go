@@ -0,0 +1,215 @@
+package video
+
+import (
+	"image"
+	"testing"
+)
+
+// newPatternI420 returns an I420 image whose every sample is unique enough to
+// track where it moves
+func newPatternI420(w, h int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Y[img.YOffset(x, y)] = uint8(y*w + x)
+		}
+	}
+	for i := range img.Cb {
+		img.Cb[i] = uint8(i)
+		img.Cr[i] = uint8(255 - i)
+	}
+	return img
+}
+
+func readOne(t *testing.T, transform TransformFunc, img image.Image) *image.YCbCr {
+	t.Helper()
+	r := transform(ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	}))
+	out, _, err := r.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return out.(*image.YCbCr)
+}
+
+func TestRotate(t *testing.T) {
+	const w, h = 6, 4
+	src := newPatternI420(w, h)
+
+	cases := map[Rotation]struct {
+		w, h int
+		// at returns the source coordinates of the destination pixel (x, y)
+		at func(x, y int) (int, int)
+	}{
+		Rotate0:   {w, h, func(x, y int) (int, int) { return x, y }},
+		Rotate90:  {h, w, func(x, y int) (int, int) { return y, h - 1 - x }},
+		Rotate180: {w, h, func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }},
+		Rotate270: {h, w, func(x, y int) (int, int) { return w - 1 - y, x }},
+		-90:       {h, w, func(x, y int) (int, int) { return w - 1 - y, x }},
+	}
+	for rotation, c := range cases {
+		out := readOne(t, Rotate(rotation), src)
+		if out.Rect.Dx() != c.w || out.Rect.Dy() != c.h {
+			t.Fatalf("Rotation %d: expected %dx%d, got %v", rotation, c.w, c.h, out.Rect)
+		}
+		for y := 0; y < c.h; y++ {
+			for x := 0; x < c.w; x++ {
+				sx, sy := c.at(x, y)
+				if got, want := out.Y[out.YOffset(x, y)], src.Y[src.YOffset(sx, sy)]; got != want {
+					t.Fatalf("Rotation %d: expected luma %d at (%d, %d), got %d", rotation, want, x, y, got)
+				}
+			}
+		}
+		// chroma planes follow the same rotation at half resolution
+		for y := 0; y < c.h/2; y++ {
+			for x := 0; x < c.w/2; x++ {
+				sx, sy := c.at(2*x, 2*y)
+				if got, want := out.Cb[out.COffset(2*x, 2*y)], src.Cb[src.COffset(sx, sy)]; got != want {
+					t.Fatalf("Rotation %d: expected chroma %d at (%d, %d), got %d", rotation, want, x, y, got)
+				}
+			}
+		}
+	}
+
+	r := Rotate(45)(ReaderFunc(func() (image.Image, func(), error) {
+		return src, func() {}, nil
+	}))
+	if _, _, err := r.Read(); err != errUnsupportedRotation {
+		t.Errorf("Expected %v, got %v", errUnsupportedRotation, err)
+	}
+}
+
+func TestFlip(t *testing.T) {
+	const w, h = 6, 4
+	src := newPatternI420(w, h)
+
+	for _, c := range []struct {
+		horizontal, vertical bool
+	}{
+		{false, false}, {true, false}, {false, true}, {true, true},
+	} {
+		out := readOne(t, Flip(c.horizontal, c.vertical), src)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				sx, sy := x, y
+				if c.horizontal {
+					sx = w - 1 - x
+				}
+				if c.vertical {
+					sy = h - 1 - y
+				}
+				if got, want := out.Y[out.YOffset(x, y)], src.Y[src.YOffset(sx, sy)]; got != want {
+					t.Fatalf("Flip %v: expected luma %d at (%d, %d), got %d", c, want, x, y, got)
+				}
+			}
+		}
+	}
+}
+
+func TestCrop(t *testing.T) {
+	src := newPatternI420(8, 8)
+
+	// the origin is rounded down to even coordinates
+	out := readOne(t, Crop(image.Rect(3, 2, 7, 6)), src)
+	if out.Rect != image.Rect(0, 0, 5, 4) {
+		t.Fatalf("Expected a 5x4 frame, got %v", out.Rect)
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 5; x++ {
+			if got, want := out.Y[out.YOffset(x, y)], src.Y[src.YOffset(x+2, y+2)]; got != want {
+				t.Fatalf("Expected luma %d at (%d, %d), got %d", want, x, y, got)
+			}
+		}
+	}
+	if got, want := out.Cb[0], src.Cb[src.COffset(2, 2)]; got != want {
+		t.Errorf("Expected chroma %d, got %d", want, got)
+	}
+
+	// clipped to the frame
+	out = readOne(t, Crop(image.Rect(4, 4, 100, 100)), src)
+	if out.Rect != image.Rect(0, 0, 4, 4) {
+		t.Errorf("Expected a 4x4 frame, got %v", out.Rect)
+	}
+
+	r := Crop(image.Rect(20, 20, 30, 30))(ReaderFunc(func() (image.Image, func(), error) {
+		return src, func() {}, nil
+	}))
+	if _, _, err := r.Read(); err != errEmptyCrop {
+		t.Errorf("Expected %v, got %v", errEmptyCrop, err)
+	}
+}
+
+func TestZoom(t *testing.T) {
+	src := image.NewYCbCr(image.Rect(0, 0, 8, 8), image.YCbCrSubsampleRatio420)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			src.Y[src.YOffset(x, y)] = uint8(x * 10)
+		}
+	}
+
+	out := readOne(t, Zoom(2), src)
+	if out.Rect != src.Rect {
+		t.Fatalf("Expected the frame size to be kept, got %v", out.Rect)
+	}
+	// the 4 center columns (20 to 50) are stretched to the frame width
+	if l := out.Y[out.YOffset(0, 4)]; l != 20 {
+		t.Errorf("Expected the left edge to be 20, got %d", l)
+	}
+	if l := out.Y[out.YOffset(7, 4)]; l != 50 {
+		t.Errorf("Expected the right edge to be 50, got %d", l)
+	}
+	// pixel centers are aligned, x=1 samples 0.25 pixel after the left edge
+	if l := out.Y[out.YOffset(1, 4)]; l != 23 {
+		t.Errorf("Expected pixels to be interpolated, got %d", l)
+	}
+
+	if out := readOne(t, Zoom(1), src); out != src {
+		t.Error("Expected frames to be untouched without zoom")
+	}
+}
+
+func TestScalePlane(t *testing.T) {
+	src := []uint8{
+		0, 10, 20, 30,
+		40, 50, 60, 70,
+	}
+
+	// same size is an exact copy
+	dst := make([]uint8, 8)
+	scalePlane(dst, 4, 4, 2, src, 4, 4, 2)
+	for i := range src {
+		if dst[i] != src[i] {
+			t.Fatalf("Expected %v, got %v", src, dst)
+		}
+	}
+
+	// half size averages the pixels
+	dst = make([]uint8, 2)
+	scalePlane(dst, 2, 2, 1, src, 4, 4, 2)
+	if dst[0] != 25 || dst[1] != 45 {
+		t.Errorf("Expected [25 45], got %v", dst)
+	}
+}
+
+func BenchmarkRotate(b *testing.B) {
+	img := newPatternI420(1280, 720)
+	r := Rotate(Rotate90)(ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	}))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Read()
+	}
+}
+
+func BenchmarkZoom(b *testing.B) {
+	img := newPatternI420(1280, 720)
+	r := Zoom(2)(ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	}))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Read()
+	}
+}
@@ -0,0 +1,231 @@
+package video
+
+import (
+	"image"
+	"sync"
+	"time"
+)
+
+// Scene is the lighting of the scene seen by the camera
+type Scene int
+
+// List of scenes
+const (
+	SceneUnknown Scene = iota
+	SceneDay
+	SceneNight
+)
+
+func (scene Scene) String() string {
+	switch scene {
+	case SceneDay:
+		return "day"
+	case SceneNight:
+		return "night"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	defaultNightThreshold = 40
+	defaultDayThreshold   = 70
+	defaultLumaStep       = 8
+)
+
+// DayNightOptions configures the DayNight analyzer. The thresholds are average
+// luma values from 0 to 255, the gap between them is the hysteresis keeping
+// the scene from flapping at dusk.
+type DayNightOptions struct {
+	// NightThreshold is the luma under which the scene turns to night, 40 by
+	// default
+	NightThreshold float64
+	// DayThreshold is the luma above which the scene turns to day, 70 by
+	// default
+	DayThreshold float64
+	// HoldTime is how long the luma must stay past a threshold before the
+	// scene changes, a passing car's headlights don't turn the night into day
+	HoldTime time.Duration
+	// Interval is the time between two measures, 0 measures every frame
+	Interval time.Duration
+	// OnChange is called with the new scene and the luma which triggered it,
+	// the first measure always reports a scene. It is called from the reading
+	// goroutine and must not block.
+	OnChange func(scene Scene, luma float64)
+}
+
+// DayNight returns a transform measuring the brightness of the frames and
+// reporting the day and night changes to opts.OnChange. The frames are not
+// modified.
+func DayNight(opts DayNightOptions) TransformFunc {
+	return NewDayNightAnalyzer(opts).Transform()
+}
+
+// DayNightAnalyzer measures the brightness of the frames like DayNight. Its
+// scene outlives the readers it is applied to, the hysteresis and the hold
+// time carry over when the source of a track is replaced, and its options can
+// be changed while it runs.
+type DayNightAnalyzer struct {
+	mu          sync.Mutex
+	opts        DayNightOptions
+	detector    *dayNightDetector
+	lastMeasure time.Time
+}
+
+// NewDayNightAnalyzer returns an analyzer configured with opts
+func NewDayNightAnalyzer(opts DayNightOptions) *DayNightAnalyzer {
+	return &DayNightAnalyzer{
+		opts:     opts,
+		detector: newDayNightDetector(opts),
+	}
+}
+
+// SetOptions replaces the options, the current scene is kept
+func (a *DayNightAnalyzer) SetOptions(opts DayNightOptions) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.opts = opts
+	a.detector.configure(opts)
+}
+
+// Transform returns a transform measuring the frames with the analyzer
+func (a *DayNightAnalyzer) Transform() TransformFunc {
+	return func(r Reader) Reader {
+		return ReaderFunc(func() (image.Image, func(), error) {
+			img, release, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			a.measure(img)
+			return img, release, nil
+		})
+	}
+}
+
+func (a *DayNightAnalyzer) measure(img image.Image) {
+	a.mu.Lock()
+	now := time.Now()
+	if now.Sub(a.lastMeasure) < a.opts.Interval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastMeasure = now
+	luma, ok := AverageLuma(img, defaultLumaStep)
+	if !ok {
+		a.mu.Unlock()
+		return
+	}
+	scene, changed := a.detector.update(luma, now)
+	onChange := a.opts.OnChange
+	a.mu.Unlock()
+
+	// OnChange may take the locks of the caller of SetOptions
+	if changed && onChange != nil {
+		onChange(scene, luma)
+	}
+}
+
+// AverageLuma returns the average luma of img from 0 to 255, sampling one
+// pixel every step pixels in both directions. Only YCbCr and gray images are
+// supported.
+func AverageLuma(img image.Image, step int) (float64, bool) {
+	if step <= 0 {
+		step = 1
+	}
+
+	var pix []uint8
+	var stride int
+	var rect image.Rectangle
+	switch img := img.(type) {
+	case *image.YCbCr:
+		pix, stride, rect = img.Y[img.YOffset(img.Rect.Min.X, img.Rect.Min.Y):], img.YStride, img.Rect
+	case *image.Gray:
+		pix, stride, rect = img.Pix[img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y):], img.Stride, img.Rect
+	default:
+		return 0, false
+	}
+	if rect.Empty() {
+		return 0, false
+	}
+
+	var sum, n int
+	for y := 0; y < rect.Dy(); y += step {
+		row := pix[y*stride : y*stride+rect.Dx()]
+		for x := 0; x < len(row); x += step {
+			sum += int(row[x])
+			n++
+		}
+	}
+	return float64(sum) / float64(n), true
+}
+
+// dayNightDetector applies the thresholds and the hold time of the options to
+// the measures
+type dayNightDetector struct {
+	nightThreshold, dayThreshold float64
+	holdTime                     time.Duration
+
+	scene Scene
+	// crossedAt is when the luma crossed the threshold of the other scene,
+	// zero while it stays on the side of the current scene
+	crossedAt time.Time
+}
+
+func newDayNightDetector(opts DayNightOptions) *dayNightDetector {
+	d := &dayNightDetector{}
+	d.configure(opts)
+	return d
+}
+
+// configure applies the thresholds and the hold time of opts, the scene is
+// kept
+func (d *dayNightDetector) configure(opts DayNightOptions) {
+	d.nightThreshold = opts.NightThreshold
+	d.dayThreshold = opts.DayThreshold
+	d.holdTime = opts.HoldTime
+	if d.nightThreshold <= 0 {
+		d.nightThreshold = defaultNightThreshold
+	}
+	if d.dayThreshold <= 0 {
+		d.dayThreshold = defaultDayThreshold
+	}
+	if d.dayThreshold < d.nightThreshold {
+		d.dayThreshold = d.nightThreshold
+	}
+}
+
+func (d *dayNightDetector) update(luma float64, now time.Time) (Scene, bool) {
+	var other Scene
+	switch d.scene {
+	case SceneUnknown:
+		// the first measure picks the closest scene, the middle of the
+		// hysteresis goes to day
+		d.scene = SceneDay
+		if luma < (d.nightThreshold+d.dayThreshold)/2 {
+			d.scene = SceneNight
+		}
+		return d.scene, true
+	case SceneDay:
+		if luma < d.nightThreshold {
+			other = SceneNight
+		}
+	case SceneNight:
+		if luma > d.dayThreshold {
+			other = SceneDay
+		}
+	}
+
+	if other == SceneUnknown {
+		d.crossedAt = time.Time{}
+		return d.scene, false
+	}
+	if d.crossedAt.IsZero() {
+		d.crossedAt = now
+	}
+	if now.Sub(d.crossedAt) < d.holdTime {
+		return d.scene, false
+	}
+	d.scene = other
+	d.crossedAt = time.Time{}
+	return d.scene, true
+}
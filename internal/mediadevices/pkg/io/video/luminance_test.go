@@ -0,0 +1,151 @@
+package video
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+func newFlatI420(w, h int, luma uint8) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = luma
+	}
+	return img
+}
+
+func TestAverageLuma(t *testing.T) {
+	img := newFlatI420(16, 16, 100)
+	for x := 0; x < 16; x++ {
+		img.Y[img.YOffset(x, 0)] = 200
+	}
+
+	if luma, ok := AverageLuma(img, 1); !ok || luma != 106.25 {
+		t.Errorf("Expected 106.25, got %v", luma)
+	}
+	// every 8th row and column, row 0 is half of the samples
+	if luma, ok := AverageLuma(img, 8); !ok || luma != 150 {
+		t.Errorf("Expected 150, got %v", luma)
+	}
+	if _, ok := AverageLuma(image.NewRGBA(image.Rect(0, 0, 4, 4)), 1); ok {
+		t.Error("Expected RGBA images to be unsupported")
+	}
+}
+
+func TestDayNightDetector(t *testing.T) {
+	d := newDayNightDetector(DayNightOptions{
+		NightThreshold: 40,
+		DayThreshold:   70,
+		HoldTime:       10 * time.Second,
+	})
+	now := time.Unix(0, 0)
+
+	steps := []struct {
+		elapsed time.Duration
+		luma    float64
+		scene   Scene
+		changed bool
+	}{
+		// the first measure always reports a scene
+		{0, 100, SceneDay, true},
+		// inside the hysteresis, nothing changes
+		{time.Second, 50, SceneDay, false},
+		{time.Second, 30, SceneDay, false},
+		// back above the threshold, the hold time restarts
+		{5 * time.Second, 45, SceneDay, false},
+		{time.Second, 30, SceneDay, false},
+		{9 * time.Second, 30, SceneDay, false},
+		{time.Second, 30, SceneNight, true},
+		{time.Second, 60, SceneNight, false},
+		{time.Second, 80, SceneNight, false},
+		{10 * time.Second, 80, SceneDay, true},
+	}
+	for i, step := range steps {
+		now = now.Add(step.elapsed)
+		scene, changed := d.update(step.luma, now)
+		if scene != step.scene || changed != step.changed {
+			t.Errorf("Step %d: expected (%v, %v), got (%v, %v)", i, step.scene, step.changed, scene, changed)
+		}
+	}
+}
+
+func TestDayNight(t *testing.T) {
+	img := newFlatI420(16, 16, 10)
+	var scenes []Scene
+	r := DayNight(DayNightOptions{
+		OnChange: func(scene Scene, luma float64) {
+			scenes = append(scenes, scene)
+		},
+	})(ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		out, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if out != img {
+			t.Fatal("Expected the frames to be untouched")
+		}
+	}
+	for i := range img.Y {
+		img.Y[i] = 200
+	}
+	if _, _, err := r.Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(scenes) != 2 || scenes[0] != SceneNight || scenes[1] != SceneDay {
+		t.Errorf("Expected [night day], got %v", scenes)
+	}
+}
+
+func TestDayNightAnalyzer(t *testing.T) {
+	img := newFlatI420(16, 16, 10)
+	var scenes []Scene
+	a := NewDayNightAnalyzer(DayNightOptions{
+		OnChange: func(scene Scene, luma float64) {
+			scenes = append(scenes, scene)
+		},
+	})
+	source := ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	})
+
+	if _, _, err := a.Transform()(source).Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// a new reader keeps the scene, it isn't reported again
+	if _, _, err := a.Transform()(source).Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(scenes) != 1 || scenes[0] != SceneNight {
+		t.Fatalf("Expected [night], got %v", scenes)
+	}
+
+	// the new thresholds apply to the running reader
+	r := a.Transform()(source)
+	for i := range img.Y {
+		img.Y[i] = 50
+	}
+	if _, _, err := r.Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(scenes) != 1 {
+		t.Fatalf("Expected the night to hold under the default day threshold, got %v", scenes)
+	}
+	a.SetOptions(DayNightOptions{
+		NightThreshold: 20,
+		DayThreshold:   40,
+		OnChange: func(scene Scene, luma float64) {
+			scenes = append(scenes, scene)
+		},
+	})
+	if _, _, err := r.Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(scenes) != 2 || scenes[1] != SceneDay {
+		t.Errorf("Expected [night day], got %v", scenes)
+	}
+}
@@ -0,0 +1,271 @@
+package video
+
+import (
+	"image"
+	"math"
+	"sort"
+	"sync"
+)
+
+// MaskStyle is how a privacy mask hides its region
+type MaskStyle int
+
+// List of mask styles
+const (
+	// MaskFill paints the region in black
+	MaskFill MaskStyle = iota
+	// MaskPixelate replaces the region with large blocks of its average color
+	MaskPixelate
+)
+
+const defaultMaskBlockSize = 16
+
+// MaskPoint is a vertex of a mask, its coordinates are relative to the frame
+// size from 0 to 1 so that the masks survive a resolution change
+type MaskPoint struct {
+	X, Y float64
+}
+
+// PrivacyMask is a polygon hidden in every frame
+type PrivacyMask struct {
+	Polygon []MaskPoint
+	Style   MaskStyle
+	// BlockSize is the size in pixels of the blocks of a pixelated mask
+	BlockSize int
+}
+
+// MaskRect returns the polygon of the rectangle from (x0, y0) to (x1, y1)
+func MaskRect(x0, y0, x1, y1 float64) []MaskPoint {
+	return []MaskPoint{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+}
+
+// PrivacyMasks holds the masks applied by its transform, they can be changed
+// while the video is running
+type PrivacyMasks struct {
+	mu    sync.Mutex
+	masks []PrivacyMask
+	// rasterized are the masks rasterized for the last frame size
+	rasterized []rasterizedMask
+	size       image.Point
+}
+
+type rasterizedMask struct {
+	bounds    image.Rectangle
+	coverage  []bool
+	style     MaskStyle
+	blockSize int
+}
+
+// NewPrivacyMasks returns an empty set of privacy masks
+func NewPrivacyMasks() *PrivacyMasks {
+	return &PrivacyMasks{}
+}
+
+// Set replaces the masks, they are applied from the next frame
+func (p *PrivacyMasks) Set(masks []PrivacyMask) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.masks = append([]PrivacyMask(nil), masks...)
+	p.rasterized = nil
+	p.size = image.Point{}
+}
+
+// Get returns the current masks
+func (p *PrivacyMasks) Get() []PrivacyMask {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PrivacyMask(nil), p.masks...)
+}
+
+// Transform returns a transform hiding the masks in the frames. The frames
+// are converted to I420 first so that no format escapes the masks, and are
+// then modified in place.
+func (p *PrivacyMasks) Transform() TransformFunc {
+	return func(r Reader) Reader {
+		r = ToI420(r)
+		return ReaderFunc(func() (image.Image, func(), error) {
+			img, release, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			p.apply(img.(*image.YCbCr))
+			return img, release, nil
+		})
+	}
+}
+
+func (p *PrivacyMasks) apply(img *image.YCbCr) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.masks) == 0 {
+		return
+	}
+
+	size := img.Rect.Size()
+	if p.rasterized == nil || size != p.size {
+		p.rasterized = make([]rasterizedMask, 0, len(p.masks))
+		for _, mask := range p.masks {
+			if m, ok := rasterizeMask(mask, img.Rect); ok {
+				p.rasterized = append(p.rasterized, m)
+			}
+		}
+		p.size = size
+	}
+
+	for i := range p.rasterized {
+		mask := &p.rasterized[i]
+		switch mask.style {
+		case MaskPixelate:
+			mask.pixelate(img)
+		default:
+			mask.fill(img)
+		}
+	}
+}
+
+// rasterizeMask computes the pixels of rect covered by the polygon of mask, a
+// pixel is covered when its center is inside the polygon (even-odd rule)
+func rasterizeMask(mask PrivacyMask, rect image.Rectangle) (rasterizedMask, bool) {
+	if len(mask.Polygon) < 3 {
+		return rasterizedMask{}, false
+	}
+
+	width, height := float64(rect.Dx()), float64(rect.Dy())
+	points := make([]MaskPoint, len(mask.Polygon))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, pt := range mask.Polygon {
+		points[i] = MaskPoint{pt.X*width + float64(rect.Min.X), pt.Y*height + float64(rect.Min.Y)}
+		minX, maxX = math.Min(minX, points[i].X), math.Max(maxX, points[i].X)
+		minY, maxY = math.Min(minY, points[i].Y), math.Max(maxY, points[i].Y)
+	}
+	bounds := image.Rect(
+		int(math.Floor(minX)), int(math.Floor(minY)),
+		int(math.Ceil(maxX)), int(math.Ceil(maxY)),
+	).Intersect(rect)
+	if bounds.Empty() {
+		return rasterizedMask{}, false
+	}
+
+	m := rasterizedMask{
+		bounds:    bounds,
+		coverage:  make([]bool, bounds.Dx()*bounds.Dy()),
+		style:     mask.Style,
+		blockSize: mask.BlockSize,
+	}
+	if m.blockSize <= 0 {
+		m.blockSize = defaultMaskBlockSize
+	}
+
+	var crossings []float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		cy := float64(y) + 0.5
+		crossings = crossings[:0]
+		for i, a := range points {
+			b := points[(i+1)%len(points)]
+			if (a.Y <= cy) == (b.Y <= cy) {
+				continue
+			}
+			crossings = append(crossings, a.X+(cy-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+		}
+		sort.Float64s(crossings)
+
+		row := m.coverage[(y-bounds.Min.Y)*bounds.Dx():]
+		for i := 0; i+1 < len(crossings); i += 2 {
+			// pixels whose center is between the two crossings
+			x0 := int(math.Ceil(crossings[i] - 0.5))
+			x1 := int(math.Ceil(crossings[i+1] - 0.5))
+			if x0 < bounds.Min.X {
+				x0 = bounds.Min.X
+			}
+			if x1 > bounds.Max.X {
+				x1 = bounds.Max.X
+			}
+			for x := x0; x < x1; x++ {
+				row[x-bounds.Min.X] = true
+			}
+		}
+	}
+	return m, true
+}
+
+// fill and pixelate work on I420 frames, the chroma offsets are computed inline
+// instead of calling COffset for every pixel
+
+func (m *rasterizedMask) fill(img *image.YCbCr) {
+	width := m.bounds.Dx()
+	for y := m.bounds.Min.Y; y < m.bounds.Max.Y; y++ {
+		coverage := m.coverage[(y-m.bounds.Min.Y)*width:][:width]
+		luma := img.Y[img.YOffset(m.bounds.Min.X, y):][:width]
+		chromaRow := (y/2 - img.Rect.Min.Y/2) * img.CStride
+		for i, covered := range coverage {
+			if !covered {
+				continue
+			}
+			luma[i] = 16
+			// every chroma sample touching the mask is neutral, nothing of the
+			// color of the region leaks
+			c := chromaRow + (m.bounds.Min.X+i)/2 - img.Rect.Min.X/2
+			img.Cb[c], img.Cr[c] = 128, 128
+		}
+	}
+}
+
+func (m *rasterizedMask) pixelate(img *image.YCbCr) {
+	size := m.blockSize
+	width := m.bounds.Dx()
+	covered := func(x, y int) bool {
+		return m.coverage[(y-m.bounds.Min.Y)*width+(x-m.bounds.Min.X)]
+	}
+	chromaOffset := func(x, y int) int {
+		return (y/2-img.Rect.Min.Y/2)*img.CStride + x/2 - img.Rect.Min.X/2
+	}
+
+	// the blocks are aligned on the frame so that they don't move with the mask
+	x0 := img.Rect.Min.X + (m.bounds.Min.X-img.Rect.Min.X)/size*size
+	y0 := img.Rect.Min.Y + (m.bounds.Min.Y-img.Rect.Min.Y)/size*size
+	for by := y0; by < m.bounds.Max.Y; by += size {
+		for bx := x0; bx < m.bounds.Max.X; bx += size {
+			block := image.Rect(bx, by, bx+size, by+size).Intersect(img.Rect)
+			masked := block.Intersect(m.bounds)
+
+			hit := false
+			for y := masked.Min.Y; y < masked.Max.Y && !hit; y++ {
+				for x := masked.Min.X; x < masked.Max.X; x++ {
+					if covered(x, y) {
+						hit = true
+						break
+					}
+				}
+			}
+			if !hit {
+				continue
+			}
+
+			// averages of the whole block, written to its masked pixels only
+			var sumY, sumCb, sumCr, n int
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for _, luma := range img.Y[img.YOffset(block.Min.X, y):][:block.Dx()] {
+					sumY += int(luma)
+				}
+				for x := block.Min.X; x < block.Max.X; x++ {
+					c := chromaOffset(x, y)
+					sumCb += int(img.Cb[c])
+					sumCr += int(img.Cr[c])
+				}
+				n += block.Dx()
+			}
+			avgY, avgCb, avgCr := uint8(sumY/n), uint8(sumCb/n), uint8(sumCr/n)
+			for y := masked.Min.Y; y < masked.Max.Y; y++ {
+				for x := masked.Min.X; x < masked.Max.X; x++ {
+					if !covered(x, y) {
+						continue
+					}
+					img.Y[img.YOffset(x, y)] = avgY
+					c := chromaOffset(x, y)
+					img.Cb[c], img.Cr[c] = avgCb, avgCr
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package video
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPrivacyMasks(t *testing.T) {
+	newFrame := func() *image.YCbCr {
+		img := image.NewYCbCr(image.Rect(0, 0, 64, 64), image.YCbCrSubsampleRatio420)
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				img.Y[img.YOffset(x, y)] = uint8(x * 4)
+			}
+		}
+		for i := range img.Cb {
+			img.Cb[i], img.Cr[i] = 200, 60
+		}
+		return img
+	}
+
+	masks := NewPrivacyMasks()
+	img := newFrame()
+	r := masks.Transform()(ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	}))
+
+	// no mask, the frame is untouched
+	if _, _, err := r.Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if img.Y[img.YOffset(10, 10)] != 40 {
+		t.Fatal("Expected the frame to be untouched without masks")
+	}
+
+	t.Run("Fill", func(t *testing.T) {
+		masks.Set([]PrivacyMask{{Polygon: MaskRect(0, 0, 0.5, 0.5), Style: MaskFill}})
+		*img = *newFrame()
+		if _, _, err := r.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				luma := img.Y[img.YOffset(x, y)]
+				inside := x < 32 && y < 32
+				if inside && luma != 16 {
+					t.Fatalf("Expected (%d, %d) to be masked, got luma %d", x, y, luma)
+				}
+				if !inside && luma != uint8(x*4) {
+					t.Fatalf("Expected (%d, %d) to be untouched, got luma %d", x, y, luma)
+				}
+			}
+		}
+		if c := img.Cb[img.COffset(10, 10)]; c != 128 {
+			t.Errorf("Expected neutral chroma in the mask, got %d", c)
+		}
+		if c := img.Cb[img.COffset(40, 40)]; c != 200 {
+			t.Errorf("Expected chroma out of the mask to be untouched, got %d", c)
+		}
+	})
+
+	t.Run("Polygon", func(t *testing.T) {
+		// triangle covering the bottom left half of the frame
+		masks.Set([]PrivacyMask{{Polygon: []MaskPoint{{0, 0}, {1, 1}, {0, 1}}}})
+		*img = *newFrame()
+		if _, _, err := r.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if img.Y[img.YOffset(5, 50)] != 16 {
+			t.Error("Expected the inside of the triangle to be masked")
+		}
+		if img.Y[img.YOffset(50, 5)] != 200 {
+			t.Error("Expected the outside of the triangle to be untouched")
+		}
+	})
+
+	t.Run("Pixelate", func(t *testing.T) {
+		masks.Set([]PrivacyMask{{Polygon: MaskRect(0, 0, 0.5, 1), Style: MaskPixelate, BlockSize: 8}})
+		*img = *newFrame()
+		if _, _, err := r.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for x := 0; x < 32; x++ {
+			// luma of the block is the average of its gradient
+			block := x / 8 * 8
+			want := uint8((block*4 + (block+7)*4) / 2)
+			if luma := img.Y[img.YOffset(x, 20)]; luma != want {
+				t.Fatalf("Expected luma %d at x=%d, got %d", want, x, luma)
+			}
+		}
+		if img.Y[img.YOffset(40, 20)] != 160 {
+			t.Error("Expected the outside of the mask to be untouched")
+		}
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+		masks.Set([]PrivacyMask{{Polygon: MaskRect(0.5, 0.5, 1, 1)}})
+		*img = *image.NewYCbCr(image.Rect(0, 0, 32, 32), image.YCbCrSubsampleRatio420)
+		if _, _, err := r.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if img.Y[img.YOffset(20, 20)] != 16 || img.Y[img.YOffset(10, 10)] != 0 {
+			t.Error("Expected the mask to follow the frame size")
+		}
+	})
+}
+
+func TestPrivacyMasksConvert(t *testing.T) {
+	masks := NewPrivacyMasks()
+	masks.Set([]PrivacyMask{{Polygon: MaskRect(0, 0, 1, 1)}})
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	r := masks.Transform()(ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	}))
+	out, _, err := r.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	yuv, ok := out.(*image.YCbCr)
+	if !ok {
+		t.Fatalf("Expected an I420 frame, got %T", out)
+	}
+	if yuv.Y[0] != 16 {
+		t.Errorf("Expected the RGBA frame to be masked, got luma %d", yuv.Y[0])
+	}
+}
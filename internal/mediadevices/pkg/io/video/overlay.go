@@ -0,0 +1,442 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/norm"
+)
+
+// OverlayPosition is the corner of the frame where an overlay is drawn
+type OverlayPosition int
+
+// List of overlay positions
+const (
+	OverlayTopLeft OverlayPosition = iota
+	OverlayTopRight
+	OverlayBottomLeft
+	OverlayBottomRight
+)
+
+// overlayReferenceHeight is the frame height for which the font is drawn at
+// its native size, larger frames get an integer magnification
+const overlayReferenceHeight = 360
+
+// overlayFontSize is the size in pixels of the font at its native size, the
+// lines are 13 pixels high
+const overlayFontSize = 11
+
+var (
+	overlayFontOnce sync.Once
+	overlayFont     *opentype.Font
+)
+
+// OverlayOptions configures the Overlay transform
+type OverlayOptions struct {
+	// Text returns the lines drawn on the frame read at t. It is called for
+	// every frame, the lines are only rendered again when they changed.
+	Text         func(t time.Time) []string
+	TextPosition OverlayPosition
+	// Scale is the magnification of the font, 0 picks one from the frame height
+	Scale int
+	// Margin is the distance in pixels between the overlays and the frame edges
+	Margin int
+	// Logo is blended with its alpha channel at LogoPosition
+	Logo         image.Image
+	LogoPosition OverlayPosition
+}
+
+// Overlay returns a transform drawing text and a logo onto the frames with the
+// embedded Go Mono font. The text is drawn in white over a darkened band. The
+// font covers Latin-1, Latin Extended-A, Greek and Cyrillic: the other letters
+// are drawn without their accents, like the Vietnamese ones, and the
+// characters left are drawn as '?'.
+//
+// The frames are modified in place, only *image.YCbCr and *image.RGBA frames
+// are supported, other formats pass through untouched.
+func Overlay(opts OverlayOptions) TransformFunc {
+	return func(r Reader) Reader {
+		o := &overlay{opts: opts}
+		if opts.Logo != nil {
+			o.logo = newOverlayLogo(opts.Logo)
+		}
+		return ReaderFunc(func() (image.Image, func(), error) {
+			img, release, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			o.draw(img, time.Now())
+			return img, release, nil
+		})
+	}
+}
+
+type overlay struct {
+	opts OverlayOptions
+	// face isn't safe for concurrent use, each overlay has its own
+	face font.Face
+	logo *overlayLogo
+	// lines and mask are the last rendered lines
+	lines []string
+	mask  *overlayMask
+}
+
+// overlayMask is the rendered text, the pixels of the glyphs are set and the
+// others belong to the background band
+type overlayMask struct {
+	pix    []bool
+	width  int
+	height int
+	scale  int
+}
+
+// overlayLogo is the logo with straight alpha, converted once to the color
+// spaces of the frames
+type overlayLogo struct {
+	width, height int
+	r, g, b, a    []uint8
+	y, cb, cr     []uint8
+}
+
+func (o *overlay) draw(img image.Image, t time.Time) {
+	bounds := img.Bounds()
+	switch img.(type) {
+	case *image.YCbCr, *image.RGBA:
+	default:
+		return
+	}
+
+	if o.opts.Text != nil {
+		scale := o.opts.Scale
+		if scale <= 0 {
+			scale = bounds.Dy() / overlayReferenceHeight
+			if scale < 1 {
+				scale = 1
+			}
+		}
+		mask := o.render(o.opts.Text(t), scale)
+		if mask != nil {
+			pt := overlayPoint(o.opts.TextPosition, bounds, mask.width, mask.height, o.opts.Margin)
+			switch img := img.(type) {
+			case *image.YCbCr:
+				mask.drawYCbCr(img, pt)
+			case *image.RGBA:
+				mask.drawRGBA(img, pt)
+			}
+		}
+	}
+
+	if o.logo != nil {
+		pt := overlayPoint(o.opts.LogoPosition, bounds, o.logo.width, o.logo.height, o.opts.Margin)
+		switch img := img.(type) {
+		case *image.YCbCr:
+			o.logo.drawYCbCr(img, pt)
+		case *image.RGBA:
+			o.logo.drawRGBA(img, pt)
+		}
+	}
+}
+
+// render returns the mask of lines, the previous mask is reused as long as the
+// lines and the scale don't change
+func (o *overlay) render(lines []string, scale int) *overlayMask {
+	if o.mask != nil && scale == o.mask.scale && equalLines(lines, o.lines) {
+		return o.mask
+	}
+	o.lines = append(o.lines[:0], lines...)
+	o.mask = nil
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if o.face == nil {
+		o.face = newOverlayFace()
+	}
+	const padding = 2
+	drawer := font.Drawer{Face: o.face}
+	metrics := o.face.Metrics()
+	lineHeight, ascent := metrics.Height.Ceil(), metrics.Ascent.Ceil()
+	printable := make([]string, len(lines))
+	width := 0
+	for i, line := range lines {
+		printable[i] = overlayText(o.face, line)
+		if w := drawer.MeasureString(printable[i]).Ceil(); w > width {
+			width = w
+		}
+	}
+	width += 2 * padding
+	height := len(lines)*lineHeight + 2*padding
+
+	glyphs := image.NewAlpha(image.Rect(0, 0, width, height))
+	drawer.Dst = glyphs
+	drawer.Src = image.NewUniform(color.Alpha{A: 0xff})
+	for i, line := range printable {
+		drawer.Dot = fixed.P(padding, padding+i*lineHeight+ascent)
+		drawer.DrawString(line)
+	}
+
+	mask := &overlayMask{
+		pix:    make([]bool, width*scale*height*scale),
+		width:  width * scale,
+		height: height * scale,
+		scale:  scale,
+	}
+	for y := 0; y < mask.height; y++ {
+		row := glyphs.Pix[(y/scale)*glyphs.Stride:]
+		for x := 0; x < mask.width; x++ {
+			mask.pix[y*mask.width+x] = row[x/scale] >= 0x80
+		}
+	}
+	o.mask = mask
+	return mask
+}
+
+// newOverlayFace returns a face of the Go Mono font, the ASCII bitmap font if
+// it can't be parsed
+func newOverlayFace() font.Face {
+	overlayFontOnce.Do(func() {
+		overlayFont, _ = opentype.Parse(gomono.TTF)
+	})
+	if overlayFont == nil {
+		return basicfont.Face7x13
+	}
+	face, err := opentype.NewFace(overlayFont, &opentype.FaceOptions{
+		Size:    overlayFontSize,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return basicfont.Face7x13
+	}
+	return face
+}
+
+// overlayText replaces the characters missing from face by their letter
+// without its accents, or by '?'
+func overlayText(face font.Face, line string) string {
+	return strings.Map(func(r rune) rune {
+		if _, ok := face.GlyphAdvance(r); ok || r == ' ' {
+			return r
+		}
+		// the decomposed character starts with its base letter
+		for _, base := range norm.NFD.String(string(r)) {
+			if _, ok := face.GlyphAdvance(base); ok {
+				return base
+			}
+			break
+		}
+		return '?'
+	}, line)
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func overlayPoint(position OverlayPosition, bounds image.Rectangle, width, height, margin int) image.Point {
+	pt := image.Pt(bounds.Min.X+margin, bounds.Min.Y+margin)
+	switch position {
+	case OverlayTopRight, OverlayBottomRight:
+		pt.X = bounds.Max.X - margin - width
+	}
+	switch position {
+	case OverlayBottomLeft, OverlayBottomRight:
+		pt.Y = bounds.Max.Y - margin - height
+	}
+	return pt
+}
+
+func (mask *overlayMask) drawYCbCr(img *image.YCbCr, pt image.Point) {
+	r := image.Rect(pt.X, pt.Y, pt.X+mask.width, pt.Y+mask.height).Intersect(img.Rect)
+	if r.Empty() {
+		return
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Y[img.YOffset(r.Min.X, y) : img.YOffset(r.Min.X, y)+r.Dx()]
+		m := mask.pix[(y-pt.Y)*mask.width+(r.Min.X-pt.X):]
+		for x, luma := range row {
+			if m[x] {
+				row[x] = 235
+			} else {
+				row[x] = luma>>1 + 8
+			}
+		}
+	}
+
+	// the band and the text are desaturated, a chroma sample can cover both
+	xStep, yStep := chromaStep(img.SubsampleRatio)
+	for y := alignUp(r.Min.Y, yStep); y < r.Max.Y; y += yStep {
+		for x := alignUp(r.Min.X, xStep); x < r.Max.X; x += xStep {
+			i := img.COffset(x, y)
+			img.Cb[i] = uint8((int(img.Cb[i]) + 128) >> 1)
+			img.Cr[i] = uint8((int(img.Cr[i]) + 128) >> 1)
+		}
+	}
+}
+
+func (mask *overlayMask) drawRGBA(img *image.RGBA, pt image.Point) {
+	r := image.Rect(pt.X, pt.Y, pt.X+mask.width, pt.Y+mask.height).Intersect(img.Rect)
+	if r.Empty() {
+		return
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(r.Min.X, y) : img.PixOffset(r.Min.X, y)+4*r.Dx()]
+		m := mask.pix[(y-pt.Y)*mask.width+(r.Min.X-pt.X):]
+		for x := 0; x < r.Dx(); x++ {
+			p := row[4*x : 4*x+3]
+			if m[x] {
+				p[0], p[1], p[2] = 0xff, 0xff, 0xff
+			} else {
+				p[0], p[1], p[2] = p[0]>>1, p[1]>>1, p[2]>>1
+			}
+		}
+	}
+}
+
+func newOverlayLogo(img image.Image) *overlayLogo {
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Rect, img, bounds.Min, draw.Src)
+
+	size := bounds.Dx() * bounds.Dy()
+	logo := &overlayLogo{
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+		r:      make([]uint8, size),
+		g:      make([]uint8, size),
+		b:      make([]uint8, size),
+		a:      make([]uint8, size),
+		y:      make([]uint8, size),
+		cb:     make([]uint8, size),
+		cr:     make([]uint8, size),
+	}
+	for y := 0; y < logo.height; y++ {
+		for x := 0; x < logo.width; x++ {
+			p := nrgba.Pix[nrgba.PixOffset(x, y):]
+			i := y*logo.width + x
+			logo.r[i], logo.g[i], logo.b[i], logo.a[i] = p[0], p[1], p[2], p[3]
+			logo.y[i], logo.cb[i], logo.cr[i] = color.RGBToYCbCr(p[0], p[1], p[2])
+		}
+	}
+	return logo
+}
+
+func blend(dst, src, alpha uint8) uint8 {
+	return uint8((int(dst)*(255-int(alpha)) + int(src)*int(alpha) + 127) / 255)
+}
+
+func (logo *overlayLogo) drawYCbCr(img *image.YCbCr, pt image.Point) {
+	r := image.Rect(pt.X, pt.Y, pt.X+logo.width, pt.Y+logo.height).Intersect(img.Rect)
+	if r.Empty() {
+		return
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Y[img.YOffset(r.Min.X, y) : img.YOffset(r.Min.X, y)+r.Dx()]
+		i := (y-pt.Y)*logo.width + (r.Min.X - pt.X)
+		for x := range row {
+			if a := logo.a[i+x]; a != 0 {
+				row[x] = blend(row[x], logo.y[i+x], a)
+			}
+		}
+	}
+
+	// a chroma sample takes the color of the logo pixels of its block weighted
+	// by their alpha
+	xStep, yStep := chromaStep(img.SubsampleRatio)
+	for y := alignUp(r.Min.Y, yStep) - yStep; y < r.Max.Y; y += yStep {
+		for x := alignUp(r.Min.X, xStep) - xStep; x < r.Max.X; x += xStep {
+			var sumA, sumCb, sumCr, n int
+			for by := y; by < y+yStep; by++ {
+				for bx := x; bx < x+xStep; bx++ {
+					n++
+					if !(image.Point{bx, by}).In(r) {
+						continue
+					}
+					i := (by-pt.Y)*logo.width + (bx - pt.X)
+					a := int(logo.a[i])
+					sumA += a
+					sumCb += int(logo.cb[i]) * a
+					sumCr += int(logo.cr[i]) * a
+				}
+			}
+			if sumA == 0 {
+				continue
+			}
+			c := img.COffset(x, y)
+			a := uint8(sumA / n)
+			img.Cb[c] = blend(img.Cb[c], uint8(sumCb/sumA), a)
+			img.Cr[c] = blend(img.Cr[c], uint8(sumCr/sumA), a)
+		}
+	}
+}
+
+func (logo *overlayLogo) drawRGBA(img *image.RGBA, pt image.Point) {
+	r := image.Rect(pt.X, pt.Y, pt.X+logo.width, pt.Y+logo.height).Intersect(img.Rect)
+	if r.Empty() {
+		return
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(r.Min.X, y) : img.PixOffset(r.Min.X, y)+4*r.Dx()]
+		i := (y-pt.Y)*logo.width + (r.Min.X - pt.X)
+		for x := 0; x < r.Dx(); x++ {
+			a := logo.a[i+x]
+			if a == 0 {
+				continue
+			}
+			p := row[4*x : 4*x+3]
+			p[0] = blend(p[0], logo.r[i+x], a)
+			p[1] = blend(p[1], logo.g[i+x], a)
+			p[2] = blend(p[2], logo.b[i+x], a)
+		}
+	}
+}
+
+// chromaStep returns the size in luma pixels of a chroma sample
+func chromaStep(ratio image.YCbCrSubsampleRatio) (int, int) {
+	switch ratio {
+	case image.YCbCrSubsampleRatio422:
+		return 2, 1
+	case image.YCbCrSubsampleRatio420:
+		return 2, 2
+	case image.YCbCrSubsampleRatio440:
+		return 1, 2
+	case image.YCbCrSubsampleRatio411:
+		return 4, 1
+	case image.YCbCrSubsampleRatio410:
+		return 4, 2
+	default:
+		return 1, 1
+	}
+}
+
+// alignUp returns the first multiple of step greater than or equal to v
+func alignUp(v, step int) int {
+	if rem := v % step; rem != 0 {
+		if v > 0 {
+			return v + step - rem
+		}
+		return v - rem
+	}
+	return v
+}
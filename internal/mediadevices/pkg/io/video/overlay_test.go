@@ -0,0 +1,201 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func newGrayI420(width, height int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = 100
+	}
+	for i := range img.Cb {
+		img.Cb[i] = 200
+		img.Cr[i] = 60
+	}
+	return img
+}
+
+func TestOverlayText(t *testing.T) {
+	img := newGrayI420(320, 240)
+	var calls int
+	trans := Overlay(OverlayOptions{
+		Text: func(time.Time) []string {
+			calls++
+			return []string{"2024-01-02 03:04:05", "Camera"}
+		},
+		TextPosition: OverlayBottomLeft,
+		Margin:       4,
+	})
+	r := trans(ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	}))
+
+	if _, _, err := r.Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// glyphs are white, the band around them is darkened
+	var glyphs, band int
+	for y := 240 - 4 - (2*13 + 4); y < 240-4; y++ {
+		for x := 4; x < 40; x++ {
+			switch img.Y[img.YOffset(x, y)] {
+			case 235:
+				glyphs++
+			case 100>>1 + 8:
+				band++
+			default:
+				t.Fatalf("Unexpected luma %d at (%d, %d)", img.Y[img.YOffset(x, y)], x, y)
+			}
+		}
+	}
+	if glyphs == 0 || band == 0 {
+		t.Errorf("Expected glyphs and band, got %d glyph pixels and %d band pixels", glyphs, band)
+	}
+	if c := img.Cb[img.COffset(10, 230)]; c != (200+128)/2 {
+		t.Errorf("Expected chroma of the band to be desaturated, got %d", c)
+	}
+
+	// the rest of the frame is untouched
+	if l := img.Y[img.YOffset(0, 0)]; l != 100 {
+		t.Errorf("Expected luma out of the overlay to be untouched, got %d", l)
+	}
+	if l := img.Y[img.YOffset(300, 230)]; l != 100 {
+		t.Errorf("Expected luma out of the overlay to be untouched, got %d", l)
+	}
+	if c := img.Cb[img.COffset(0, 0)]; c != 200 {
+		t.Errorf("Expected chroma out of the overlay to be untouched, got %d", c)
+	}
+
+	// the same text is not rendered again
+	o := &overlay{opts: OverlayOptions{Text: func(time.Time) []string { return []string{"a"} }}}
+	mask := o.render([]string{"a"}, 1)
+	if o.render([]string{"a"}, 1) != mask {
+		t.Error("Expected the mask to be cached")
+	}
+	if o.render([]string{"b"}, 1) == mask {
+		t.Error("Expected a new mask for a new text")
+	}
+	if o.render([]string{"b"}, 2).width != 2*mask.width {
+		t.Error("Expected the mask to be magnified")
+	}
+	if calls != 1 {
+		t.Errorf("Expected Text to be called once per frame, got %d calls", calls)
+	}
+}
+
+func TestOverlayNonASCII(t *testing.T) {
+	face := newOverlayFace()
+	testCases := map[string]string{
+		"Camera":        "Camera",
+		"Café 20°C":     "Café 20°C",
+		"Камера":        "Камера",
+		"Cổng trước":    "Cong truoc",
+		"Gate \u2603 1": "Gate ? 1",
+	}
+	for text, expected := range testCases {
+		if printable := overlayText(face, text); printable != expected {
+			t.Errorf("Expected %q, got %q", expected, printable)
+		}
+	}
+
+	// the accented letters are drawn, not replaced by a box
+	o := &overlay{}
+	plain, accented := o.render([]string{"e"}, 1), o.render([]string{"é"}, 1)
+	var plainPix, accentedPix int
+	for i := range plain.pix {
+		if plain.pix[i] {
+			plainPix++
+		}
+		if accented.pix[i] {
+			accentedPix++
+		}
+	}
+	if accentedPix <= plainPix {
+		t.Errorf("Expected the accent to add pixels, got %d and %d", plainPix, accentedPix)
+	}
+}
+
+func TestOverlayLogo(t *testing.T) {
+	logo := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(logo.Pix); i += 4 {
+		logo.Pix[i], logo.Pix[i+1], logo.Pix[i+2], logo.Pix[i+3] = 0xff, 0, 0, 0xff
+	}
+	logo.SetNRGBA(0, 0, color.NRGBA{})
+
+	cases := map[string]image.Image{
+		"RGBA": image.NewRGBA(image.Rect(0, 0, 16, 16)),
+		"I420": newGrayI420(16, 16),
+	}
+	for name, img := range cases {
+		img := img
+		t.Run(name, func(t *testing.T) {
+			trans := Overlay(OverlayOptions{Logo: logo, LogoPosition: OverlayTopRight})
+			r := trans(ReaderFunc(func() (image.Image, func(), error) {
+				return img, func() {}, nil
+			}))
+			out, _, err := r.Read()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			red, _, _, _ := out.At(14, 2).RGBA()
+			if red>>8 < 0xf0 {
+				t.Errorf("Expected the logo to be drawn, got red %d", red>>8)
+			}
+
+			// chroma is shared with the neighbours in I420, only luma is kept
+			switch out := out.(type) {
+			case *image.RGBA:
+				if p := out.RGBAAt(12, 0); p != out.RGBAAt(0, 0) {
+					t.Errorf("Expected transparent pixels to be untouched, got %v", p)
+				}
+			case *image.YCbCr:
+				if l := out.Y[out.YOffset(12, 0)]; l != 100 {
+					t.Errorf("Expected transparent pixels to be untouched, got luma %d", l)
+				}
+			}
+		})
+	}
+}
+
+func TestOverlayUnsupported(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	trans := Overlay(OverlayOptions{Text: func(time.Time) []string { return []string{"a"} }})
+	r := trans(ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	}))
+	if _, _, err := r.Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, p := range img.Pix {
+		if p != 0 {
+			t.Fatal("Expected unsupported frames to be untouched")
+		}
+	}
+}
+
+func BenchmarkOverlay(b *testing.B) {
+	img := newGrayI420(1280, 720)
+	logo := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for i := range logo.Pix {
+		logo.Pix[i] = 0x80
+	}
+	trans := Overlay(OverlayOptions{
+		Text: func(t time.Time) []string {
+			return []string{t.Format("2006-01-02 15:04:05"), "Camera", "Location"}
+		},
+		Logo: logo,
+	})
+	r := trans(ReaderFunc(func() (image.Image, func(), error) {
+		return img, func() {}, nil
+	}))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Read()
+	}
+}
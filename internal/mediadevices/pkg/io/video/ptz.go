@@ -0,0 +1,148 @@
+package video
+
+import (
+	"image"
+	"math"
+	"sync"
+	"time"
+)
+
+// ROI is a region of interest of the frames, its coordinates are relative to
+// the frame size from 0 to 1 so that it survives a resolution change
+type ROI struct {
+	X, Y, Width, Height float64
+}
+
+// FullFrame is the region covering the whole frame
+var FullFrame = ROI{0, 0, 1, 1}
+
+// ZoomROI returns the region magnified by zoom around the center (pan, tilt),
+// pan and tilt are relative to the frame size from 0 to 1. The region is moved
+// inside the frame when the center is too close to an edge.
+func ZoomROI(pan, tilt, zoom float64) ROI {
+	if zoom < 1 {
+		zoom = 1
+	}
+	size := 1 / zoom
+	return ROI{pan - size/2, tilt - size/2, size, size}.Clamp()
+}
+
+// Clamp returns the region moved and shrunk to fit inside the frame
+func (roi ROI) Clamp() ROI {
+	roi.Width = math.Min(math.Max(roi.Width, 0), 1)
+	roi.Height = math.Min(math.Max(roi.Height, 0), 1)
+	roi.X = math.Min(math.Max(roi.X, 0), 1-roi.Width)
+	roi.Y = math.Min(math.Max(roi.Y, 0), 1-roi.Height)
+	return roi
+}
+
+// Center returns the center of the region and its zoom factor, the inverse of
+// ZoomROI for square regions
+func (roi ROI) Center() (pan, tilt, zoom float64) {
+	zoom = 1
+	if size := math.Max(roi.Width, roi.Height); size > 0 {
+		zoom = 1 / size
+	}
+	return roi.X + roi.Width/2, roi.Y + roi.Height/2, zoom
+}
+
+func (roi ROI) rect(bounds image.Rectangle) image.Rectangle {
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	return image.Rect(
+		int(math.Round(roi.X*w)), int(math.Round(roi.Y*h)),
+		int(math.Round((roi.X+roi.Width)*w)), int(math.Round((roi.Y+roi.Height)*h)),
+	).Add(bounds.Min)
+}
+
+func lerpROI(from, to ROI, t float64) ROI {
+	lerp := func(a, b float64) float64 {
+		return a + (b-a)*t
+	}
+	return ROI{
+		lerp(from.X, to.X), lerp(from.Y, to.Y),
+		lerp(from.Width, to.Width), lerp(from.Height, to.Height),
+	}
+}
+
+// PTZ is a digital pan, tilt and zoom: its transform shows a region of the
+// frames scaled to the size of the frames. The region can be moved while the
+// video is running, the moves are interpolated over a duration.
+type PTZ struct {
+	mu sync.Mutex
+	// a move goes from start to target between startTime and startTime+duration
+	start     ROI
+	target    ROI
+	startTime time.Time
+	duration  time.Duration
+	now       func() time.Time
+}
+
+// NewPTZ returns a PTZ showing the full frame
+func NewPTZ() *PTZ {
+	return &PTZ{
+		start:  FullFrame,
+		target: FullFrame,
+		now:    time.Now,
+	}
+}
+
+// MoveTo moves the region to roi in duration, a zero duration jumps to it. A
+// move in progress continues from where it is.
+func (p *PTZ) MoveTo(roi ROI, duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	p.start = p.position(now)
+	p.target = roi.Clamp()
+	p.startTime = now
+	p.duration = duration
+}
+
+// Position returns the region shown now
+func (p *PTZ) Position() ROI {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position(p.now())
+}
+
+// Target returns the region at the end of the current move
+func (p *PTZ) Target() ROI {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.target
+}
+
+func (p *PTZ) position(now time.Time) ROI {
+	elapsed := now.Sub(p.startTime)
+	if p.duration <= 0 || elapsed >= p.duration {
+		return p.target
+	}
+	t := float64(elapsed) / float64(p.duration)
+	// smoothstep eases in and out, the view doesn't jerk at both ends
+	return lerpROI(p.start, p.target, t*t*(3-2*t))
+}
+
+// Transform returns a transform showing the region of the frames. The frames
+// are left untouched while the full frame is shown, they are converted to I420
+// otherwise. A region whose aspect ratio differs from the frames is stretched.
+func (p *PTZ) Transform() TransformFunc {
+	return func(r Reader) Reader {
+		r = ToI420(r)
+		var dst *image.YCbCr
+		return ReaderFunc(func() (image.Image, func(), error) {
+			img, release, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			src := img.(*image.YCbCr)
+			crop := alignCrop(p.Position().rect(src.Rect), src.Rect)
+			if crop == src.Rect || crop.Empty() {
+				return img, release, nil
+			}
+			dst = reuseI420(dst, src.Rect.Dx(), src.Rect.Dy())
+			cropScaleI420(dst, src, crop)
+			release()
+			return dst, func() {}, nil
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package video
+
+import (
+	"image"
+	"math"
+	"testing"
+	"time"
+)
+
+func roiEqual(a, b ROI) bool {
+	const epsilon = 1e-9
+	return math.Abs(a.X-b.X) < epsilon && math.Abs(a.Y-b.Y) < epsilon &&
+		math.Abs(a.Width-b.Width) < epsilon && math.Abs(a.Height-b.Height) < epsilon
+}
+
+func TestZoomROI(t *testing.T) {
+	cases := map[string]struct {
+		pan, tilt, zoom float64
+		expected        ROI
+	}{
+		"Center":  {0.5, 0.5, 2, ROI{0.25, 0.25, 0.5, 0.5}},
+		"Corner":  {0, 1, 4, ROI{0, 0.75, 0.25, 0.25}},
+		"NoZoom":  {0.2, 0.7, 1, FullFrame},
+		"Unzoom":  {0.5, 0.5, 0.5, FullFrame},
+		"Shifted": {0.9, 0.5, 2, ROI{0.5, 0.25, 0.5, 0.5}},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			if roi := ZoomROI(c.pan, c.tilt, c.zoom); !roiEqual(roi, c.expected) {
+				t.Errorf("Expected %v, got %v", c.expected, roi)
+			}
+		})
+	}
+
+	pan, tilt, zoom := ZoomROI(0.5, 0.5, 2).Center()
+	if pan != 0.5 || tilt != 0.5 || zoom != 2 {
+		t.Errorf("Expected (0.5, 0.5, 2), got (%v, %v, %v)", pan, tilt, zoom)
+	}
+}
+
+func TestPTZMove(t *testing.T) {
+	now := time.Unix(0, 0)
+	p := NewPTZ()
+	p.now = func() time.Time { return now }
+
+	target := ROI{0.5, 0.5, 0.5, 0.5}
+	p.MoveTo(target, time.Second)
+	if roi := p.Position(); !roiEqual(roi, FullFrame) {
+		t.Errorf("Expected the move to start from the full frame, got %v", roi)
+	}
+	if roi := p.Target(); !roiEqual(roi, target) {
+		t.Errorf("Expected the target to be %v, got %v", target, roi)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	half := ROI{0.25, 0.25, 0.75, 0.75}
+	if roi := p.Position(); !roiEqual(roi, half) {
+		t.Errorf("Expected %v half way, got %v", half, roi)
+	}
+
+	// a new move starts from the current position
+	p.MoveTo(FullFrame, time.Second)
+	if roi := p.Position(); !roiEqual(roi, half) {
+		t.Errorf("Expected the move to start from %v, got %v", half, roi)
+	}
+
+	now = now.Add(2 * time.Second)
+	if roi := p.Position(); !roiEqual(roi, FullFrame) {
+		t.Errorf("Expected the move to end on the full frame, got %v", roi)
+	}
+
+	p.MoveTo(ROI{0.8, -1, 0.5, 2}, 0)
+	if roi, expected := p.Position(), (ROI{0.5, 0, 0.5, 1}); !roiEqual(roi, expected) {
+		t.Errorf("Expected the region to be clamped to %v, got %v", expected, roi)
+	}
+}
+
+func TestPTZTransform(t *testing.T) {
+	src := image.NewYCbCr(image.Rect(0, 0, 8, 8), image.YCbCrSubsampleRatio420)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			src.Y[src.YOffset(x, y)] = uint8(x*10 + y)
+		}
+	}
+
+	p := NewPTZ()
+	transform := p.Transform()
+	// ToI420 wraps the frame without copying it
+	if out := readOne(t, transform, src); &out.Y[0] != &src.Y[0] {
+		t.Error("Expected the full frame to be untouched")
+	}
+
+	// the bottom right quarter is scaled to the frame size
+	p.MoveTo(ROI{0.5, 0.5, 0.5, 0.5}, 0)
+	out := readOne(t, transform, src)
+	if out.Rect != src.Rect {
+		t.Fatalf("Expected the frame size to be kept, got %v", out.Rect)
+	}
+	if l := out.Y[out.YOffset(0, 0)]; l != 44 {
+		t.Errorf("Expected the top left corner to be 44, got %d", l)
+	}
+	if l := out.Y[out.YOffset(7, 7)]; l != 77 {
+		t.Errorf("Expected the bottom right corner to be 77, got %d", l)
+	}
+}
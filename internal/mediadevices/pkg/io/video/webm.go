@@ -0,0 +1,176 @@
+package video
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/at-wat/ebml-go"
+	"github.com/at-wat/ebml-go/webm"
+)
+
+// webmTrackTypeVideo is the TrackType of the video tracks
+const webmTrackTypeVideo = 1
+
+var errWebMNoVideo = errors.New("webm: no video track")
+
+// WebMTrack describes the video track of a WebM or Matroska file
+type WebMTrack struct {
+	// CodecID is the Matroska codec of the frames, like V_VP8 or V_MJPEG
+	CodecID      string
+	CodecPrivate []byte
+	Width        int
+	Height       int
+	// FrameDuration is 0 when the file doesn't tell
+	FrameDuration time.Duration
+}
+
+// WebMFrame is a frame of the video track, compressed as it is stored
+type WebMFrame struct {
+	Data []byte
+	// KeyFrame tells if the frame can be decoded without the previous ones
+	KeyFrame bool
+	// Timestamp is the presentation time of the frame from the start of the file
+	Timestamp time.Duration
+}
+
+// WebMReader demuxes the frames of the first video track of a WebM or Matroska file as they come,
+// a cluster at a time, without loading the whole file. The frames aren't decoded.
+//
+// A file which was cut off, like a recording in progress, ends with its last complete cluster.
+type WebMReader struct {
+	track         WebMTrack
+	trackNumber   uint64
+	timecodeScale time.Duration
+
+	clusters  chan webm.Cluster
+	pending   []WebMFrame
+	err       error
+	closeOnce sync.Once
+}
+
+// NewWebMReader reads the header of a WebM or Matroska file up to its tracks
+func NewWebMReader(r io.Reader) (*WebMReader, error) {
+	reader := &WebMReader{
+		timecodeScale: time.Millisecond,
+		clusters:      make(chan webm.Cluster),
+	}
+
+	// the elements are sent one by one as they are unmarshalled, the clusters aren't kept
+	infos := make(chan webm.Info, 1)
+	tracks := make(chan webm.Tracks, 1)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(reader.clusters)
+		var doc struct {
+			Segment struct {
+				Info    chan webm.Info    `ebml:"Info"`
+				Tracks  chan webm.Tracks  `ebml:"Tracks"`
+				Cluster chan webm.Cluster `ebml:"Cluster"`
+			} `ebml:"Segment"`
+		}
+		doc.Segment.Info = infos
+		doc.Segment.Tracks = tracks
+		doc.Segment.Cluster = reader.clusters
+		// a cut off file fails with an unexpected EOF after its last complete cluster
+		errc <- ebml.Unmarshal(r, &doc)
+	}()
+
+	var header webm.Tracks
+	select {
+	case header = <-tracks:
+	case err := <-errc:
+		if err == nil {
+			err = errWebMNoVideo
+		}
+		return nil, err
+	}
+	select {
+	case info := <-infos:
+		if info.TimecodeScale > 0 {
+			reader.timecodeScale = time.Duration(info.TimecodeScale)
+		}
+	default:
+	}
+
+	for _, entry := range header.TrackEntry {
+		if entry.TrackType != webmTrackTypeVideo {
+			continue
+		}
+		reader.trackNumber = entry.TrackNumber
+		reader.track = WebMTrack{
+			CodecID:       entry.CodecID,
+			CodecPrivate:  entry.CodecPrivate,
+			FrameDuration: time.Duration(entry.DefaultDuration),
+		}
+		if entry.Video != nil {
+			reader.track.Width = int(entry.Video.PixelWidth)
+			reader.track.Height = int(entry.Video.PixelHeight)
+		}
+		return reader, nil
+	}
+	reader.Close()
+	return nil, errWebMNoVideo
+}
+
+// Track describes the video track
+func (r *WebMReader) Track() WebMTrack {
+	return r.track
+}
+
+// ReadFrame returns the next frame of the video track, io.EOF at the end of the file
+func (r *WebMReader) ReadFrame() (WebMFrame, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return WebMFrame{}, r.err
+		}
+		cluster, ok := <-r.clusters
+		if !ok {
+			r.err = io.EOF
+			continue
+		}
+		r.pending = r.clusterFrames(cluster)
+	}
+
+	f := r.pending[0]
+	r.pending = r.pending[1:]
+	return f, nil
+}
+
+// clusterFrames returns the frames of the video track in cluster
+func (r *WebMReader) clusterFrames(cluster webm.Cluster) []WebMFrame {
+	var frames []WebMFrame
+	add := func(block ebml.Block, keyFrame bool) {
+		if block.TrackNumber != r.trackNumber {
+			return
+		}
+		timestamp := time.Duration(int64(cluster.Timecode)+int64(block.Timecode)) * r.timecodeScale
+		// the laced frames share the timestamp of their block
+		for _, data := range block.Data {
+			frames = append(frames, WebMFrame{Data: data, KeyFrame: keyFrame, Timestamp: timestamp})
+		}
+	}
+	// the files written by the block writers hold either simple blocks or block groups
+	for _, block := range cluster.SimpleBlock {
+		add(block, block.Keyframe)
+	}
+	for _, group := range cluster.BlockGroup {
+		// a block referencing no other block is a key frame
+		add(group.Block, group.ReferenceBlock == 0)
+	}
+	return frames
+}
+
+// Close stops reading the frames. The underlying reader isn't closed, the rest of the file is
+// skipped until it is.
+func (r *WebMReader) Close() error {
+	r.closeOnce.Do(func() {
+		r.pending, r.err = nil, io.EOF
+		go func() {
+			for range r.clusters {
+			}
+		}()
+	})
+	return nil
+}
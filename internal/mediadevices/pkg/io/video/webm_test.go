@@ -0,0 +1,117 @@
+package video
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/at-wat/ebml-go/webm"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// webmFile writes a file with an audio track and a video track of frames
+// 10s apart, the first and the fourth frames are key frames
+func webmFile(t *testing.T, frames int) []byte {
+	var file bytes.Buffer
+	ws, err := webm.NewSimpleBlockWriter(nopWriteCloser{&file}, []webm.TrackEntry{
+		{Name: "Audio", TrackNumber: 1, TrackUID: 1, CodecID: "A_OPUS", TrackType: 2},
+		{
+			Name: "Video", TrackNumber: 2, TrackUID: 2, CodecID: "V_VP8", TrackType: 1,
+			DefaultDuration: 33333333,
+			Video:           &webm.Video{PixelWidth: 64, PixelHeight: 48},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i < frames; i++ {
+		if _, err := ws[0].Write(true, int64(i)*10000, []byte{0xff}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := ws[1].Write(i%3 == 0, int64(i)*10000, []byte{byte(i)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	for _, w := range ws {
+		w.Close()
+	}
+	return file.Bytes()
+}
+
+func TestWebMReader(t *testing.T) {
+	file := webmFile(t, 5)
+	// the file is cut off in the last SimpleBlock of 5 bytes of the video
+	// track, the fifth frame
+	lastBlock := bytes.LastIndex(file, []byte{0xa3, 0x85, 0x82})
+
+	testCases := map[string]struct {
+		data   []byte
+		frames int
+	}{
+		"Complete": {
+			data:   file,
+			frames: 5,
+		},
+		"CutOff": {
+			// the second cluster starts after 32.767s, it is incomplete
+			data:   file[:lastBlock+3],
+			frames: 4,
+		},
+	}
+	for name, testCase := range testCases {
+		r, err := NewWebMReader(bytes.NewReader(testCase.data))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		track := r.Track()
+		if track.CodecID != "V_VP8" || track.Width != 64 || track.Height != 48 || track.FrameDuration != 33333333 {
+			t.Errorf("%s: unexpected track: %+v", name, track)
+		}
+
+		for i := 0; i < testCase.frames; i++ {
+			f, err := r.ReadFrame()
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+			if len(f.Data) != 1 || f.Data[0] != byte(i) {
+				t.Errorf("%s: expected frame %d, got %v", name, i, f.Data)
+			}
+			if f.KeyFrame != (i%3 == 0) {
+				t.Errorf("%s: expected the key frame of frame %d to be %v", name, i, i%3 == 0)
+			}
+			if expected := time.Duration(i) * 10 * time.Second; f.Timestamp != expected {
+				t.Errorf("%s: expected timestamp %v, got %v", name, expected, f.Timestamp)
+			}
+		}
+		if _, err := r.ReadFrame(); err != io.EOF {
+			t.Errorf("%s: expected %v, got %v", name, io.EOF, err)
+		}
+		r.Close()
+	}
+}
+
+func TestWebMReaderClose(t *testing.T) {
+	r, err := NewWebMReader(bytes.NewReader(webmFile(t, 5)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := r.ReadFrame(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r.Close()
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("Expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestWebMReaderNoVideo(t *testing.T) {
+	if _, err := NewWebMReader(bytes.NewReader([]byte("not a webm file"))); err == nil {
+		t.Error("Expected an error")
+	}
+}
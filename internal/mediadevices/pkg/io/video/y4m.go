@@ -0,0 +1,328 @@
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pion/mediadevices/pkg/frame"
+)
+
+const (
+	y4mSignature   = "YUV4MPEG2"
+	y4mFrameMarker = "FRAME"
+	// y4mMaxHeaderLength bounds the header lines of broken streams
+	y4mMaxHeaderLength = 1024
+
+	y4mFullRange    = "COLORRANGE=FULL"
+	y4mLimitedRange = "COLORRANGE=LIMITED"
+)
+
+var errY4MSignature = errors.New("y4m: not a YUV4MPEG2 stream")
+
+// Y4MHeader describes the frames of a YUV4MPEG2 stream
+type Y4MHeader struct {
+	Width  int
+	Height int
+	// FrameRate in frames per second, 0 when the stream doesn't tell
+	FrameRate float64
+	// SubsampleRatio of the chroma, 4:2:0, 4:2:2 and 4:4:4 streams of 8 bit
+	// samples are supported
+	SubsampleRatio image.YCbCrSubsampleRatio
+	// FullRange is set for the samples in the full range of JPEG rather than
+	// the limited range of video, given by the XCOLORRANGE extension
+	FullRange bool
+}
+
+// Y4MReader reads the frames of a YUV4MPEG2 stream. The frames read share the
+// same buffer, a frame is valid until the next one is read.
+type Y4MReader struct {
+	r      *bufio.Reader
+	header Y4MHeader
+	img    *image.YCbCr
+}
+
+// NewY4MReader reads the header of a YUV4MPEG2 stream
+func NewY4MReader(r io.Reader) (*Y4MReader, error) {
+	br := bufio.NewReader(r)
+	line, err := readY4MLine(br)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != y4mSignature {
+		return nil, errY4MSignature
+	}
+
+	header := Y4MHeader{SubsampleRatio: image.YCbCrSubsampleRatio420}
+	for _, field := range fields[1:] {
+		value := field[1:]
+		switch field[0] {
+		case 'W':
+			header.Width, err = strconv.Atoi(value)
+		case 'H':
+			header.Height, err = strconv.Atoi(value)
+		case 'F':
+			header.FrameRate, err = parseY4MRatio(value)
+		case 'C':
+			header.SubsampleRatio, err = parseY4MChroma(value)
+		case 'X':
+			header.FullRange = value == y4mFullRange
+		}
+		if err != nil {
+			return nil, fmt.Errorf("y4m: invalid header field %q: %w", field, err)
+		}
+	}
+	if header.Width <= 0 || header.Height <= 0 {
+		return nil, fmt.Errorf("y4m: invalid frame size %dx%d", header.Width, header.Height)
+	}
+
+	return &Y4MReader{
+		r:      br,
+		header: header,
+		img:    image.NewYCbCr(image.Rect(0, 0, header.Width, header.Height), header.SubsampleRatio),
+	}, nil
+}
+
+// Header returns the header of the stream
+func (r *Y4MReader) Header() Y4MHeader {
+	return r.header
+}
+
+// Read reads the next frame, io.EOF at the end of the stream
+func (r *Y4MReader) Read() (image.Image, func(), error) {
+	line, err := readY4MLine(r.r)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	if !strings.HasPrefix(line, y4mFrameMarker) {
+		return nil, func() {}, fmt.Errorf("y4m: invalid frame header %q", line)
+	}
+
+	for _, plane := range [][]uint8{r.img.Y, r.img.Cb, r.img.Cr} {
+		if _, err := io.ReadFull(r.r, plane); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, func() {}, err
+		}
+	}
+	return r.img, func() {}, nil
+}
+
+// readY4MLine reads a header line without its line feed
+func readY4MLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == nil {
+			return string(bytes.TrimSuffix(line, []byte{'\n'})), nil
+		}
+		if err == io.EOF && len(line) > 0 {
+			return "", io.ErrUnexpectedEOF
+		}
+		if err != bufio.ErrBufferFull {
+			return "", err
+		}
+		if len(line) > y4mMaxHeaderLength {
+			return "", errors.New("y4m: header line too long")
+		}
+	}
+}
+
+func parseY4MRatio(s string) (float64, error) {
+	num, den, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, errors.New("expected a ratio")
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil {
+		return 0, err
+	}
+	d, err := strconv.Atoi(den)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 || d <= 0 {
+		// 0:0 is an unknown frame rate
+		return 0, nil
+	}
+	return float64(n) / float64(d), nil
+}
+
+func parseY4MChroma(s string) (image.YCbCrSubsampleRatio, error) {
+	switch s {
+	case "420", "420jpeg", "420paldv", "420mpeg2":
+		return image.YCbCrSubsampleRatio420, nil
+	case "422":
+		return image.YCbCrSubsampleRatio422, nil
+	case "444":
+		return image.YCbCrSubsampleRatio444, nil
+	default:
+		return 0, errors.New("unsupported chroma")
+	}
+}
+
+// Y4MWriter writes frames to a YUV4MPEG2 stream. The header is written with
+// the first frame, the next frames must have the same size and chroma
+// subsampling. The errors are sticky, a writer which failed ignores the next
+// frames.
+type Y4MWriter struct {
+	w         *bufio.Writer
+	frameRate float64
+	fullRange bool
+	header    *Y4MHeader
+	img       image.YCbCr
+	err       error
+}
+
+// NewY4MWriter creates a writer of frames to w, frameRate is written in the
+// header, 0 when it's unknown. format is the format the frames were decoded
+// from, it tells the range of their samples.
+func NewY4MWriter(w io.Writer, frameRate float64, format frame.Format) *Y4MWriter {
+	return &Y4MWriter{
+		w:         bufio.NewWriter(w),
+		frameRate: frameRate,
+		fullRange: format.FullRange(),
+	}
+}
+
+// Write writes img, the YCbCr frames are written as is in the range of the
+// format they were decoded from: the full range for MJPEG, the limited range
+// of video otherwise. The frames decoded from NV12 and NV21 are already planar
+// 4:2:0. The other images are converted to 4:4:4 in the full range.
+func (w *Y4MWriter) Write(img image.Image) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.write(img)
+	return w.err
+}
+
+// Err returns the error which stopped the writer
+func (w *Y4MWriter) Err() error {
+	return w.err
+}
+
+func (w *Y4MWriter) write(img image.Image) error {
+	yuv, ok := img.(*image.YCbCr)
+	fullRange := !ok || w.fullRange
+	if !ok {
+		imageToYCbCr(&w.img, img)
+		yuv = &w.img
+	}
+
+	header := Y4MHeader{
+		Width:          yuv.Rect.Dx(),
+		Height:         yuv.Rect.Dy(),
+		FrameRate:      w.frameRate,
+		SubsampleRatio: yuv.SubsampleRatio,
+		FullRange:      fullRange,
+	}
+	if w.header == nil {
+		if err := w.writeHeader(header); err != nil {
+			return err
+		}
+		w.header = &header
+	} else if header != *w.header {
+		return fmt.Errorf("y4m: frame of %dx%d %s changes the stream of %dx%d %s",
+			header.Width, header.Height, header.SubsampleRatio,
+			w.header.Width, w.header.Height, w.header.SubsampleRatio)
+	}
+
+	if _, err := w.w.WriteString(y4mFrameMarker + "\n"); err != nil {
+		return err
+	}
+	cw, ch := y4mChromaSize(header)
+	if err := writeY4MPlane(w.w, yuv.Y[yuv.YOffset(yuv.Rect.Min.X, yuv.Rect.Min.Y):], yuv.YStride, header.Width, header.Height); err != nil {
+		return err
+	}
+	offset := yuv.COffset(yuv.Rect.Min.X, yuv.Rect.Min.Y)
+	for _, plane := range [][]uint8{yuv.Cb[offset:], yuv.Cr[offset:]} {
+		if err := writeY4MPlane(w.w, plane, yuv.CStride, cw, ch); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+func (w *Y4MWriter) writeHeader(header Y4MHeader) error {
+	var chroma string
+	switch header.SubsampleRatio {
+	case image.YCbCrSubsampleRatio420:
+		// the chroma of image.YCbCr and libyuv is centered like JPEG
+		chroma = "420jpeg"
+	case image.YCbCrSubsampleRatio422:
+		chroma = "422"
+	case image.YCbCrSubsampleRatio444:
+		chroma = "444"
+	default:
+		return fmt.Errorf("y4m: unsupported chroma subsampling %s", header.SubsampleRatio)
+	}
+	colorRange := y4mLimitedRange
+	if header.FullRange {
+		colorRange = y4mFullRange
+	}
+	_, err := fmt.Fprintf(w.w, "%s W%d H%d F%s Ip A1:1 C%s X%s\n",
+		y4mSignature, header.Width, header.Height, formatY4MRatio(header.FrameRate), chroma, colorRange)
+	return err
+}
+
+// y4mChromaSize returns the size of the chroma planes
+func y4mChromaSize(header Y4MHeader) (int, int) {
+	switch header.SubsampleRatio {
+	case image.YCbCrSubsampleRatio420:
+		return (header.Width + 1) / 2, (header.Height + 1) / 2
+	case image.YCbCrSubsampleRatio422:
+		return (header.Width + 1) / 2, header.Height
+	default:
+		return header.Width, header.Height
+	}
+}
+
+func writeY4MPlane(w io.Writer, plane []uint8, stride, width, height int) error {
+	for y := 0; y < height; y++ {
+		if _, err := w.Write(plane[y*stride : y*stride+width]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatY4MRatio formats a frame rate, 0:0 when it's unknown
+func formatY4MRatio(fps float64) string {
+	if fps <= 0 {
+		return "0:0"
+	}
+	if fps == math.Trunc(fps) {
+		return strconv.Itoa(int(fps)) + ":1"
+	}
+	// NTSC rates like 29.97 are 30000:1001
+	if ntsc := math.Round(fps * 1.001); math.Abs(ntsc-fps*1.001) < 0.01 {
+		return strconv.Itoa(int(ntsc)*1000) + ":1001"
+	}
+	return strconv.Itoa(int(math.Round(fps*1000))) + ":1000"
+}
+
+// Y4MTap dumps the frames going through a stage of a TransformFunc chain to w,
+// to be replayed by a Y4MReader. The frames aren't changed, and a failure to
+// write them doesn't stop the stream, w.Err tells why the dump stopped.
+func Y4MTap(w *Y4MWriter) TransformFunc {
+	return func(r Reader) Reader {
+		return ReaderFunc(func() (image.Image, func(), error) {
+			img, release, err := r.Read()
+			if err != nil {
+				return nil, func() {}, err
+			}
+			w.Write(img)
+			return img, release, nil
+		})
+	}
+}
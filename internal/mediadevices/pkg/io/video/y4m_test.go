@@ -0,0 +1,211 @@
+package video
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/frame"
+)
+
+func TestY4MReader(t *testing.T) {
+	var stream bytes.Buffer
+	stream.WriteString("YUV4MPEG2 W4 H2 F30000:1001 Ip A1:1 C444 XYSCSS=444\n")
+	for i := byte(0); i < 2; i++ {
+		stream.WriteString("FRAME\n")
+		stream.Write(bytes.Repeat([]byte{i}, 8))
+		stream.Write(bytes.Repeat([]byte{i + 10}, 8))
+		stream.Write(bytes.Repeat([]byte{i + 20}, 8))
+	}
+
+	r, err := NewY4MReader(&stream)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	header := r.Header()
+	if header.Width != 4 || header.Height != 2 || header.SubsampleRatio != image.YCbCrSubsampleRatio444 {
+		t.Errorf("Unexpected header: %+v", header)
+	}
+	if fps := header.FrameRate; fps < 29.97 || fps > 29.98 {
+		t.Errorf("Expected 29.97 fps, got %v", fps)
+	}
+
+	for i := byte(0); i < 2; i++ {
+		img, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		yuv := img.(*image.YCbCr)
+		if yuv.Y[7] != i || yuv.Cb[7] != i+10 || yuv.Cr[7] != i+20 {
+			t.Errorf("Frame %d: unexpected samples %d %d %d", i, yuv.Y[7], yuv.Cb[7], yuv.Cr[7])
+		}
+	}
+	if _, _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestY4MReaderInvalid(t *testing.T) {
+	for name, stream := range map[string]string{
+		"signature": "YUV4MPEG W4 H2\n",
+		"size":      "YUV4MPEG2 W0 H2\n",
+		"chroma":    "YUV4MPEG2 W4 H2 C420p10\n",
+	} {
+		if _, err := NewY4MReader(strings.NewReader(stream)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	r, err := NewY4MReader(strings.NewReader("YUV4MPEG2 W4 H2 C420jpeg\nFRAME\nshort"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, _, err := r.Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestY4MWriter(t *testing.T) {
+	testCases := map[string]struct {
+		img    image.Image
+		format frame.Format
+		header string
+	}{
+		"I420": {
+			img:    image.NewYCbCr(image.Rect(0, 0, 5, 3), image.YCbCrSubsampleRatio420),
+			header: "YUV4MPEG2 W5 H3 F30000:1001 Ip A1:1 C420jpeg XCOLORRANGE=LIMITED\n",
+		},
+		"I444": {
+			img:    image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio444),
+			header: "YUV4MPEG2 W4 H2 F30000:1001 Ip A1:1 C444 XCOLORRANGE=LIMITED\n",
+		},
+		"MJPEG": {
+			img:    image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio422),
+			format: frame.FormatMJPEG,
+			header: "YUV4MPEG2 W4 H2 F30000:1001 Ip A1:1 C422 XCOLORRANGE=FULL\n",
+		},
+		"RGBA": {
+			img:    image.NewRGBA(image.Rect(0, 0, 4, 2)),
+			header: "YUV4MPEG2 W4 H2 F30000:1001 Ip A1:1 C444 XCOLORRANGE=FULL\n",
+		},
+	}
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			// fill the frame with a gradient
+			bounds := testCase.img.Bounds()
+			for y := 0; y < bounds.Dy(); y++ {
+				for x := 0; x < bounds.Dx(); x++ {
+					switch img := testCase.img.(type) {
+					case *image.YCbCr:
+						img.Y[img.YOffset(x, y)] = uint8(10 * (x + y))
+						img.Cb[img.COffset(x, y)] = uint8(100 + x)
+						img.Cr[img.COffset(x, y)] = uint8(200 - y)
+					case *image.RGBA:
+						img.Pix[img.PixOffset(x, y)] = uint8(10 * (x + y))
+					}
+				}
+			}
+
+			var stream bytes.Buffer
+			w := NewY4MWriter(&stream, 29.97, testCase.format)
+			for i := 0; i < 2; i++ {
+				if err := w.Write(testCase.img); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			if header := stream.String()[:len(testCase.header)]; header != testCase.header {
+				t.Errorf("Expected header %q, got %q", testCase.header, header)
+			}
+
+			r, err := NewY4MReader(&stream)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expected := testCase.img
+			if _, ok := expected.(*image.YCbCr); !ok {
+				converted := &image.YCbCr{}
+				imageToYCbCr(converted, expected)
+				expected = converted
+			}
+			for i := 0; i < 2; i++ {
+				img, _, err := r.Read()
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(img, expected) {
+					t.Errorf("Frame %d: expected %v, got %v", i, expected, img)
+				}
+			}
+			if _, _, err := r.Read(); err != io.EOF {
+				t.Errorf("Expected %v, got %v", io.EOF, err)
+			}
+		})
+	}
+}
+
+func TestY4MTap(t *testing.T) {
+	src := image.NewYCbCr(image.Rect(0, 0, 8, 4), image.YCbCrSubsampleRatio420)
+	// a cropped frame has rows longer than its width
+	cropped := src.SubImage(image.Rect(2, 2, 6, 4))
+	frames := []image.Image{cropped, cropped, image.NewYCbCr(image.Rect(0, 0, 8, 4), image.YCbCrSubsampleRatio420)}
+	i := 0
+	var stream bytes.Buffer
+	w := NewY4MWriter(&stream, 0, frame.FormatI420)
+	r := Y4MTap(w)(ReaderFunc(func() (image.Image, func(), error) {
+		if i == len(frames) {
+			return nil, func() {}, io.EOF
+		}
+		i++
+		return frames[i-1], func() {}, nil
+	}))
+
+	for j := range frames {
+		img, _, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if img != frames[j] {
+			t.Errorf("Frame %d: expected the frame to be passed through", j)
+		}
+	}
+	if _, _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected %v, got %v", io.EOF, err)
+	}
+	// the last frame has another size
+	if w.Err() == nil {
+		t.Error("Expected the dump to be stopped")
+	}
+
+	replay, err := NewY4MReader(&stream)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if header := replay.Header(); header.Width != 4 || header.Height != 2 || header.FrameRate != 0 {
+		t.Errorf("Unexpected header: %+v", header)
+	}
+	for j := 0; j < 2; j++ {
+		if _, _, err := replay.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if _, _, err := replay.Read(); err != io.EOF {
+		t.Errorf("Expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestFormatY4MRatio(t *testing.T) {
+	for fps, expected := range map[float64]string{
+		0:     "0:0",
+		30:    "30:1",
+		29.97: "30000:1001",
+		12.5:  "12500:1000",
+	} {
+		if ratio := formatY4MRatio(fps); ratio != expected {
+			t.Errorf("%v: expected %s, got %s", fps, expected, ratio)
+		}
+	}
+}
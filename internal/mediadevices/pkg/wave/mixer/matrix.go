@@ -0,0 +1,94 @@
+package mixer
+
+import (
+	"errors"
+	"math"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// MatrixMixer mixes channels with a matrix of gains, the channel ch of the
+// destination is the sum of the channels of the source weighted by Gains[ch].
+// The missing rows and gains are zero, the gains of channels which don't exist
+// are ignored. The sums are clipped to the range of the destination.
+type MatrixMixer struct {
+	Gains [][]float64
+}
+
+// NewChannelMap returns a mixer copying the channel channels[ch] of the source
+// into the channel ch of the destination, a channel out of the source is
+// silent. NewChannelMap(1, 0) swaps the left and the right.
+func NewChannelMap(channels ...int) *MatrixMixer {
+	gains := make([][]float64, len(channels))
+	for ch, src := range channels {
+		if src >= 0 {
+			gains[ch] = make([]float64, src+1)
+			gains[ch][src] = 1
+		}
+	}
+	return &MatrixMixer{Gains: gains}
+}
+
+func (m *MatrixMixer) Mix(dst wave.Audio, src wave.Audio) error {
+	if dst.ChunkInfo().Len != src.ChunkInfo().Len {
+		return errors.New("buffer size mismatch")
+	}
+	dstSetter, ok := dst.(wave.EditableAudio)
+	if !ok {
+		return errors.New("destination buffer is not settable")
+	}
+
+	n := src.ChunkInfo().Len
+	channels := src.ChunkInfo().Channels
+	dstChannels := dst.ChunkInfo().Channels
+	for i := 0; i < n; i++ {
+		for ch := 0; ch < dstChannels; ch++ {
+			var sum float64
+			if ch < len(m.Gains) {
+				for srcCh, gain := range m.Gains[ch] {
+					if srcCh < channels && gain != 0 {
+						sum += gain * float64(src.At(i, srcCh).Int())
+					}
+				}
+			}
+			dstSetter.Set(i, ch, saturate(dst, sum))
+		}
+	}
+	return nil
+}
+
+// StereoMixer mixes channels into stereo audio. A mono source is copied into
+// both sides, the channels of a source beyond the first two, like a center
+// channel, are added to both sides at -3 dB.
+type StereoMixer struct {
+}
+
+func (m *StereoMixer) Mix(dst wave.Audio, src wave.Audio) error {
+	if dst.ChunkInfo().Channels != 2 {
+		return errors.New("destination buffer is not stereo")
+	}
+
+	channels := src.ChunkInfo().Channels
+	gains := [][]float64{make([]float64, channels), make([]float64, channels)}
+	switch channels {
+	case 0:
+	case 1:
+		gains[0][0], gains[1][0] = 1, 1
+	default:
+		gains[0][0], gains[1][1] = 1, 1
+		for ch := 2; ch < channels; ch++ {
+			gains[0][ch], gains[1][ch] = math.Sqrt2/2, math.Sqrt2/2
+		}
+	}
+	return (&MatrixMixer{Gains: gains}).Mix(dst, src)
+}
+
+// saturate returns v, in the scale of wave.Sample.Int, clipped to the range of
+// the format of dst
+func saturate(dst wave.Audio, v float64) wave.Sample {
+	max := math.MaxInt64 / 2.0
+	if dst.SampleFormat() == wave.Int16SampleFormat {
+		max = math.MaxInt16 << 16
+	}
+	return wave.Int64Sample(math.Round(math.Max(-max, math.Min(max, v))))
+}
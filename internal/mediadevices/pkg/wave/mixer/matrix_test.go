@@ -0,0 +1,99 @@
+package mixer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+func TestMatrixMixer(t *testing.T) {
+	stereo := &wave.Int16Interleaved{
+		Size: wave.ChunkInfo{Len: 2, Channels: 2},
+		Data: []int16{1, 2, 3, 4},
+	}
+	testCases := map[string]struct {
+		mixer    ChannelMixer
+		src      wave.Audio
+		expected wave.Audio
+	}{
+		"Swap": {
+			mixer: NewChannelMap(1, 0),
+			src:   stereo,
+			expected: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 2, Channels: 2},
+				Data: []int16{2, 1, 4, 3},
+			},
+		},
+		"Silent": {
+			mixer: NewChannelMap(-1, 0, 5),
+			src:   stereo,
+			expected: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 2, Channels: 3},
+				Data: []int16{0, 1, 0, 0, 3, 0},
+			},
+		},
+		"Gains": {
+			mixer: &MatrixMixer{Gains: [][]float64{{0.5, 0.5}, {2}}},
+			src:   stereo,
+			expected: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 2, Channels: 2},
+				Data: []int16{1, 2, 3, 6},
+			},
+		},
+		"Clipping": {
+			mixer: &MatrixMixer{Gains: [][]float64{{1, 1}}},
+			src: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 2, Channels: 2},
+				Data: []int16{30000, 30000, -30000, -30000},
+			},
+			expected: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 2, Channels: 1},
+				Data: []int16{32767, -32767},
+			},
+		},
+		"MonoToStereo": {
+			mixer: &StereoMixer{},
+			src: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 2, Channels: 1},
+				Data: []int16{1, 2},
+			},
+			expected: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 2, Channels: 2},
+				Data: []int16{1, 1, 2, 2},
+			},
+		},
+		"CenterToStereo": {
+			mixer: &StereoMixer{},
+			src: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 1, Channels: 3},
+				Data: []int16{100, 200, 1000},
+			},
+			expected: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 1, Channels: 2},
+				Data: []int16{807, 907},
+			},
+		},
+	}
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			ci := testCase.expected.ChunkInfo()
+			dst := wave.NewInt16Interleaved(ci)
+			if err := testCase.mixer.Mix(dst, testCase.src); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(testCase.expected, dst) {
+				t.Errorf("Mix result is wrong\nexpected: %v\ngot: %v", testCase.expected, dst)
+			}
+		})
+	}
+}
+
+func TestStereoMixerNotStereo(t *testing.T) {
+	src := wave.NewFloat32Interleaved(wave.ChunkInfo{Len: 1, Channels: 2})
+	dst := wave.NewFloat32Interleaved(wave.ChunkInfo{Len: 1, Channels: 1})
+	if err := (&StereoMixer{}).Mix(dst, src); err == nil {
+		t.Error("Expected an error")
+	}
+}
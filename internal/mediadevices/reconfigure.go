@@ -0,0 +1,395 @@
+package mediadevices
+
+import (
+	"errors"
+	"image"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+var (
+	errNotReconfigurable      = errors.New("track source isn't a video driver")
+	errBitRateNotControllable = errors.New("encoder doesn't support bit rate changes")
+	errTrackClosed            = errors.New("track is closed")
+	// errEncoderStale is returned to the encoders reading a track reconfigured since they were
+	// built, they are rebuilt before the next frame
+	errEncoderStale = errors.New("track was reconfigured")
+)
+
+// Reconfigure re-opens the driver of the track with the properties fitting opt best, for example
+// to change the resolution or the frame rate of a live track. The new source of the broadcaster goes
+// through the transforms of the track, and the encoders reading the track are rebuilt on their next
+// frame, starting with a key frame. The peer connections bound to the track are kept.
+//
+// If the driver fails to re-open, it's opened again with its previous properties and the error is
+// returned. If that fails too, the track ends with the error unless OnSourceLost is set.
+func (track *VideoTrack) Reconfigure(opt MediaOption) error {
+	track.sourceMu.Lock()
+	defer track.sourceMu.Unlock()
+
+	d, ok := track.source.(driver.Driver)
+	recorder, isRecorder := track.source.(driver.VideoRecorder)
+	if !ok || !isRecorder {
+		return errNotReconfigurable
+	}
+
+	var constraints MediaTrackConstraints
+	opt(&constraints)
+	selected, err := selectBestProp(d.Properties(), constraints, track.selector)
+	if err != nil {
+		return err
+	}
+
+	// From here the reader of the driver fails, the frames are read again once it's replaced
+	generation := atomic.AddUint32(&track.sourceGeneration, 1)
+	err = track.reopen(d, recorder, selected, generation)
+	if err == nil {
+		track.selected = selected
+		return nil
+	}
+	if track.selected.Width > 0 {
+		if restoreErr := track.reopen(d, recorder, track.selected, generation); restoreErr != nil {
+			logger.Warnf("failed to restore the previous properties: %s", restoreErr)
+		}
+	}
+	return err
+}
+
+// reopen must be called with sourceMu held, it opens d again with p and replaces the reader of the
+// track
+func (track *VideoTrack) reopen(d driver.Driver, recorder driver.VideoRecorder, p prop.Media, generation uint32) error {
+	if d.Status() != driver.StateClosed {
+		if err := d.Close(); err != nil {
+			return err
+		}
+	}
+	if err := d.Open(); err != nil {
+		return err
+	}
+	reader, err := recordVideo(recorder, p, track.selector)
+	if err != nil {
+		return err
+	}
+	return track.replaceReader(reader, generation)
+}
+
+// OnSourceLost sets a handler called when the source of the track fails, for example when the
+// camera is unplugged. The track doesn't end then: its readers wait until the source is replaced
+// with ReplaceDriver or the track is closed. The handler is called once per failed source, from
+// its own goroutine.
+func (track *VideoTrack) OnSourceLost(handler func(error)) {
+	track.sourceMu.Lock()
+	defer track.sourceMu.Unlock()
+	track.onSourceLost = handler
+}
+
+// ReplaceDriver reads the track from d, opened with the properties fitting opt best, instead of
+// its current source which is closed. d may be the current driver, it is then re-opened. Like with Reconfigure, the transforms of the track are applied
+// to the new source and the encoders are rebuilt, the peer connections bound to the track are kept.
+// The ID of the track doesn't change.
+func (track *VideoTrack) ReplaceDriver(d driver.Driver, opt MediaOption) error {
+	recorder, ok := d.(driver.VideoRecorder)
+	if !ok {
+		return errInvalidDriverType
+	}
+	if Source(d) == track.currentSource() {
+		// the device came back under the same driver, it's opened again
+		_ = d.Close()
+	}
+	if d.Status() == driver.StateClosed {
+		if err := d.Open(); err != nil {
+			return err
+		}
+	}
+
+	var constraints MediaTrackConstraints
+	opt(&constraints)
+	selected, err := selectBestProp(d.Properties(), constraints, track.selector)
+	if err != nil {
+		d.Close()
+		return err
+	}
+	reader, err := recordVideo(recorder, selected, track.selector)
+	if err != nil {
+		d.Close()
+		return err
+	}
+
+	track.sourceMu.Lock()
+	defer track.sourceMu.Unlock()
+	if track.closed {
+		d.Close()
+		return errTrackClosed
+	}
+	previous := track.source
+	generation := atomic.AddUint32(&track.sourceGeneration, 1)
+	track.source = d
+	track.selected = selected
+	if previous != d {
+		// the lost driver fails to close when the device is gone
+		_ = previous.Close()
+	}
+	return track.replaceReader(reader, generation)
+}
+
+// replaceReader must be called with sourceMu held, it makes reader the source of the broadcaster
+// and wakes up the readers waiting for a lost source
+func (track *VideoTrack) replaceReader(reader video.Reader, generation uint32) error {
+	track.reader = reader
+	source := video.Merge(track.transforms...)(track.wrapReader(reader, generation))
+	// The encoders are made stale before the frames of the new source reach them, they may be of
+	// another kind, compressed or not
+	atomic.AddUint32(&track.encoderGeneration, 1)
+	if err := track.Broadcaster.ReplaceSource(source); err != nil {
+		return err
+	}
+	if track.sourceReplaced != nil {
+		close(track.sourceReplaced)
+		track.sourceReplaced = nil
+	}
+	return nil
+}
+
+// waitSource reports the loss of the source of the given generation to the OnSourceLost handler
+// and waits until it is replaced. It returns false when there is no handler or the track is closed,
+// the track then ends with err.
+func (track *VideoTrack) waitSource(err error, generation uint32) bool {
+	track.sourceMu.Lock()
+	if atomic.LoadUint32(&track.sourceGeneration) != generation {
+		// replaced in the meantime
+		track.sourceMu.Unlock()
+		return true
+	}
+	if track.onSourceLost == nil || track.closed {
+		track.sourceMu.Unlock()
+		return false
+	}
+	if track.sourceReplaced == nil {
+		track.sourceReplaced = make(chan struct{})
+		go track.onSourceLost(err)
+	}
+	replaced := track.sourceReplaced
+	track.sourceMu.Unlock()
+
+	<-replaced
+	track.sourceMu.RLock()
+	defer track.sourceMu.RUnlock()
+	return !track.closed
+}
+
+// currentSource returns the driver read by the track
+func (track *VideoTrack) currentSource() Source {
+	track.sourceMu.RLock()
+	defer track.sourceMu.RUnlock()
+	return track.source
+}
+
+// selectBestProp returns the properties of props fitting constraints best, merged with them like
+// the ones selected by GetUserMedia. The properties the tracks of selector can't read are skipped.
+func selectBestProp(props []prop.Media, constraints MediaTrackConstraints, selector *CodecSelector) (prop.Media, error) {
+	var bestProp prop.Media
+	minFitnessDist := math.Inf(1)
+	for _, p := range props {
+		if !selector.canRead(p) {
+			continue
+		}
+		fitnessDist, ok := constraints.MediaConstraints.FitnessDistance(p)
+		if ok && fitnessDist < minFitnessDist {
+			minFitnessDist = fitnessDist
+			bestProp = p
+		}
+	}
+	if math.IsInf(minFitnessDist, 1) {
+		return prop.Media{}, errNotFound
+	}
+
+	var selected prop.Media
+	selected.MergeConstraints(constraints.MediaConstraints)
+	selected.Merge(bestProp)
+	return selected, nil
+}
+
+// newVideoEncoder builds an encoder of the first codec of codecNames that can be built, the encoder
+// is rebuilt with the same codec after every reconfiguration of the track
+func (track *VideoTrack) newVideoEncoder(codecNames ...string) (codec.ReadCloser, *codec.RTPCodec, error) {
+	generation := atomic.LoadUint32(&track.encoderGeneration)
+	encoder, selectedCodec, err := track.buildVideoEncoder(generation, codecNames...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e := &reconfigurableEncoder{
+		track:      track,
+		codecName:  selectedCodec.MimeType,
+		encoder:    encoder,
+		generation: generation,
+	}
+
+	track.encodersMu.Lock()
+	defer track.encodersMu.Unlock()
+	if track.encoders == nil {
+		track.encoders = make(map[*reconfigurableEncoder]struct{})
+	}
+	track.encoders[e] = struct{}{}
+	if track.bitRate > 0 {
+		if err := e.SetBitRate(track.bitRate); err != nil {
+			logger.Warnf("failed to set bit rate: %s", err)
+		}
+	}
+	return e, selectedCodec, nil
+}
+
+// SetBitRate changes the bit rate of the encoders reading the track and of the ones built
+// afterwards. The encoders which can't change their bit rate keep theirs.
+func (track *VideoTrack) SetBitRate(bitRate int) error {
+	track.encodersMu.Lock()
+	defer track.encodersMu.Unlock()
+
+	track.bitRate = bitRate
+	for e := range track.encoders {
+		if err := e.SetBitRate(bitRate); err != nil && err != errBitRateNotControllable {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildVideoEncoder builds an encoder reading the track from the given encoder generation, its reads
+// fail with errEncoderStale once the track is reconfigured
+func (track *VideoTrack) buildVideoEncoder(generation uint32, codecNames ...string) (codec.ReadCloser, *codec.RTPCodec, error) {
+	trackReader := track.NewReader(track.shouldCopyFrames)
+	reader := video.ReaderFunc(func() (image.Image, func(), error) {
+		if atomic.LoadUint32(&track.encoderGeneration) != generation {
+			return nil, func() {}, errEncoderStale
+		}
+		return trackReader.Read()
+	})
+	inputProp, err := detectCurrentVideoProp(track.Broadcaster)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encoder, selectedCodec, err := track.selector.selectVideoCodecByNames(reader, inputProp, codecNames...)
+	if e, ok := encoder.(*passthroughEncoder); ok {
+		// the key frames are requested from the device read by the track
+		e.source = track.currentSource
+	}
+	return encoder, selectedCodec, err
+}
+
+// reconfigurableEncoder is an encoder of a video track which is rebuilt when the track is
+// reconfigured. Its controller stays valid across the rebuilds, the last bit rate set is applied
+// to the new encoders.
+type reconfigurableEncoder struct {
+	track     *VideoTrack
+	codecName string
+
+	mu         sync.Mutex
+	encoder    codec.ReadCloser
+	generation uint32
+	bitRate    int
+	closed     bool
+}
+
+func (e *reconfigurableEncoder) Read() ([]byte, func(), error) {
+	for {
+		encoder, err := e.current()
+		if err != nil {
+			return nil, func() {}, err
+		}
+		data, release, err := encoder.Read()
+		if errors.Is(err, errEncoderStale) && !e.isClosed() {
+			// the track was reconfigured during the read
+			continue
+		}
+		return data, release, err
+	}
+}
+
+func (e *reconfigurableEncoder) isClosed() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.closed
+}
+
+// current returns the encoder, rebuilt first if the track was reconfigured
+func (e *reconfigurableEncoder) current() (codec.ReadCloser, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	generation := atomic.LoadUint32(&e.track.encoderGeneration)
+	if e.closed || generation == e.generation {
+		return e.encoder, nil
+	}
+
+	encoder, _, err := e.track.buildVideoEncoder(generation, e.codecName)
+	if err != nil {
+		return nil, err
+	}
+	e.encoder.Close()
+	e.encoder = encoder
+	e.generation = generation
+
+	if e.bitRate > 0 {
+		if controller, ok := encoder.Controller().(codec.BitRateController); ok {
+			if err := controller.SetBitRate(e.bitRate); err != nil {
+				logger.Warnf("failed to set bit rate: %s", err)
+			}
+		}
+	}
+	// The remote peers can't decode the new frames from the previous ones
+	if controller, ok := encoder.Controller().(codec.KeyFrameController); ok {
+		if err := controller.ForceKeyFrame(); err != nil {
+			logger.Warnf("failed to force key frame: %s", err)
+		}
+	}
+	return encoder, nil
+}
+
+func (e *reconfigurableEncoder) Close() error {
+	e.track.encodersMu.Lock()
+	delete(e.track.encoders, e)
+	e.track.encodersMu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+	return e.encoder.Close()
+}
+
+func (e *reconfigurableEncoder) Controller() codec.EncoderController {
+	return e
+}
+
+// ForceKeyFrame forces a key frame on the current encoder if it supports it
+func (e *reconfigurableEncoder) ForceKeyFrame() error {
+	e.mu.Lock()
+	encoder := e.encoder
+	e.mu.Unlock()
+
+	if controller, ok := encoder.Controller().(codec.KeyFrameController); ok {
+		return controller.ForceKeyFrame()
+	}
+	return nil
+}
+
+// SetBitRate sets the bit rate of the current encoder and of the ones rebuilt afterwards
+func (e *reconfigurableEncoder) SetBitRate(bitRate int) error {
+	e.mu.Lock()
+	encoder := e.encoder
+	e.bitRate = bitRate
+	e.mu.Unlock()
+
+	controller, ok := encoder.Controller().(codec.BitRateController)
+	if !ok {
+		return errBitRateNotControllable
+	}
+	return controller.SetBitRate(bitRate)
+}
@@ -0,0 +1,354 @@
+package mediadevices
+
+import (
+	"errors"
+	"image"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+type fakeReconfigurableDriver struct {
+	mu     sync.Mutex
+	closed chan struct{}
+	opens  int
+	// unplugged makes the reader fail like a camera unplugged
+	unplugged chan struct{}
+	// failWidth makes the frames of this width fail to be recorded
+	failWidth int
+}
+
+func (d *fakeReconfigurableDriver) Open() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = make(chan struct{})
+	d.opens++
+	return nil
+}
+
+func (d *fakeReconfigurableDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	close(d.closed)
+	return nil
+}
+
+func (d *fakeReconfigurableDriver) Properties() []prop.Media {
+	return []prop.Media{
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420, FrameRate: 30}},
+		{Video: prop.Video{Width: 320, Height: 240, FrameFormat: frame.FormatI420, FrameRate: 15}},
+	}
+}
+
+func (d *fakeReconfigurableDriver) VideoRecord(p prop.Media) (video.Reader, error) {
+	d.mu.Lock()
+	closed := d.closed
+	d.mu.Unlock()
+	if p.Width == d.failWidth {
+		return nil, errors.New("unsupported width")
+	}
+
+	img := image.NewYCbCr(image.Rect(0, 0, p.Width, p.Height), image.YCbCrSubsampleRatio420)
+	return video.ReaderFunc(func() (image.Image, func(), error) {
+		select {
+		case <-closed:
+			return nil, func() {}, io.EOF
+		case <-d.unplugged:
+			return nil, func() {}, errors.New("no such device")
+		case <-time.After(time.Millisecond):
+			return img, func() {}, nil
+		}
+	}), nil
+}
+
+func (d *fakeReconfigurableDriver) ID() string                 { return "fake" }
+func (d *fakeReconfigurableDriver) Info() driver.Info          { return driver.Info{DeviceType: driver.Camera} }
+func (d *fakeReconfigurableDriver) Status() driver.State       { return driver.StateRunning }
+func (d *fakeReconfigurableDriver) Label() string              { return "fake" }
+func (d *fakeReconfigurableDriver) IsAvailable() (bool, error) { return true, nil }
+
+// fakeSizeEncoder "encodes" the frames to their width
+type fakeSizeEncoder struct {
+	reader    video.Reader
+	keyFrames int32
+	bitRate   int32
+}
+
+func (e *fakeSizeEncoder) Read() ([]byte, func(), error) {
+	img, _, err := e.reader.Read()
+	if err != nil {
+		return nil, func() {}, err
+	}
+	return []byte(strconv.Itoa(img.Bounds().Dx())), func() {}, nil
+}
+
+func (e *fakeSizeEncoder) Close() error                        { return nil }
+func (e *fakeSizeEncoder) Controller() codec.EncoderController { return e }
+
+func (e *fakeSizeEncoder) ForceKeyFrame() error {
+	atomic.AddInt32(&e.keyFrames, 1)
+	return nil
+}
+
+func (e *fakeSizeEncoder) SetBitRate(bitRate int) error {
+	atomic.StoreInt32(&e.bitRate, int32(bitRate))
+	return nil
+}
+
+type fakeSizeEncoderBuilder struct {
+	mu       sync.Mutex
+	encoders []*fakeSizeEncoder
+}
+
+func (b *fakeSizeEncoderBuilder) RTPCodec() *codec.RTPCodec {
+	return codec.NewRTPVP8Codec(90000)
+}
+
+func (b *fakeSizeEncoderBuilder) BuildVideoEncoder(r video.Reader, p prop.Media) (codec.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	encoder := &fakeSizeEncoder{reader: r}
+	b.encoders = append(b.encoders, encoder)
+	return encoder, nil
+}
+
+func TestVideoTrackReconfigure(t *testing.T) {
+	d := &fakeReconfigurableDriver{}
+	builder := &fakeSizeEncoderBuilder{}
+	constraints := MediaTrackConstraints{
+		selectedMedia: prop.Media{Video: prop.Video{Width: 640, Height: 480}},
+	}
+	track, err := newTrackFromDriver(d, constraints, NewCodecSelector(WithVideoEncoders(builder)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer track.Close()
+	track.OnEnded(func(err error) {
+		if err == io.EOF {
+			return
+		}
+		t.Errorf("Expected the track to survive the reconfiguration, got %v", err)
+	})
+	videoTrack := track.(*VideoTrack)
+
+	var transformed int32
+	videoTrack.Transform(func(r video.Reader) video.Reader {
+		return video.ReaderFunc(func() (image.Image, func(), error) {
+			atomic.AddInt32(&transformed, 1)
+			return r.Read()
+		})
+	})
+
+	encoded, err := track.NewEncodedReader("vp8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer encoded.Close()
+	buffer, _, err := encoded.Read()
+	if err != nil || string(buffer.Data) != "640" {
+		t.Fatalf("Expected a 640 pixels wide frame, got %q (%v)", buffer.Data, err)
+	}
+	if err := encoded.Controller().(codec.BitRateController).SetBitRate(100000); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err = videoTrack.Reconfigure(func(c *MediaTrackConstraints) {
+		c.Width = prop.Int(320)
+		c.Height = prop.Int(240)
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if d.opens != 2 {
+		t.Errorf("Expected the driver to be opened again, got %d opens", d.opens)
+	}
+
+	atomic.StoreInt32(&transformed, 0)
+	buffer, _, err = encoded.Read()
+	if err != nil || string(buffer.Data) != "320" {
+		t.Fatalf("Expected a 320 pixels wide frame, got %q (%v)", buffer.Data, err)
+	}
+	if atomic.LoadInt32(&transformed) == 0 {
+		t.Error("Expected the transforms to be applied on the new source")
+	}
+
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	if n := len(builder.encoders); n != 2 {
+		t.Fatalf("Expected the encoder to be rebuilt, got %d encoders", n)
+	}
+	rebuilt := builder.encoders[1]
+	if atomic.LoadInt32(&rebuilt.keyFrames) == 0 {
+		t.Error("Expected a key frame to be forced on the new encoder")
+	}
+	if bitRate := atomic.LoadInt32(&rebuilt.bitRate); bitRate != 100000 {
+		t.Errorf("Expected the bit rate to be kept, got %d", bitRate)
+	}
+}
+
+func TestVideoTrackReconfigureRestore(t *testing.T) {
+	d := &fakeReconfigurableDriver{failWidth: 320}
+	constraints := MediaTrackConstraints{
+		selectedMedia: prop.Media{Video: prop.Video{Width: 640, Height: 480}},
+	}
+	track, err := newTrackFromDriver(d, constraints, NewCodecSelector(WithVideoEncoders(&fakeSizeEncoderBuilder{})))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer track.Close()
+	track.OnEnded(func(err error) {
+		if err == io.EOF {
+			return
+		}
+		t.Errorf("Expected the track to survive the failed reconfiguration, got %v", err)
+	})
+	videoTrack := track.(*VideoTrack)
+
+	encoded, err := track.NewEncodedReader("vp8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer encoded.Close()
+
+	err = videoTrack.Reconfigure(func(c *MediaTrackConstraints) {
+		c.Width = prop.Int(320)
+		c.Height = prop.Int(240)
+	})
+	if err == nil {
+		t.Fatal("Expected the reconfiguration to fail")
+	}
+
+	// the frames of the previous properties come again
+	buffer, _, err := encoded.Read()
+	if err != nil || string(buffer.Data) != "640" {
+		t.Fatalf("Expected a 640 pixels wide frame, got %q (%v)", buffer.Data, err)
+	}
+}
+
+func TestVideoTrackSetBitRate(t *testing.T) {
+	builder := &fakeSizeEncoderBuilder{}
+	track := NewVideoTrack(&fakeVideoSource{}, NewCodecSelector(WithVideoEncoders(builder)))
+	defer track.Close()
+	videoTrack := track.(*VideoTrack)
+
+	first, err := track.NewEncodedReader("vp8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := videoTrack.SetBitRate(300000); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, err := track.NewEncodedReader("vp8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer second.Close()
+
+	// closed encoders are forgotten
+	first.Close()
+	if err := videoTrack.SetBitRate(200000); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	for i, expected := range []int32{300000, 200000} {
+		if bitRate := atomic.LoadInt32(&builder.encoders[i].bitRate); bitRate != expected {
+			t.Errorf("Encoder %d: expected a bit rate of %d, got %d", i, expected, bitRate)
+		}
+	}
+}
+
+func TestVideoTrackReplaceDriver(t *testing.T) {
+	d := &fakeReconfigurableDriver{unplugged: make(chan struct{})}
+	builder := &fakeSizeEncoderBuilder{}
+	constraints := MediaTrackConstraints{
+		selectedMedia: prop.Media{Video: prop.Video{Width: 640, Height: 480}},
+	}
+	track, err := newTrackFromDriver(d, constraints, NewCodecSelector(WithVideoEncoders(builder)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer track.Close()
+	id := track.ID()
+	track.OnEnded(func(err error) {
+		if err == io.EOF {
+			return
+		}
+		t.Errorf("Expected the track to survive the loss of its driver, got %v", err)
+	})
+	videoTrack := track.(*VideoTrack)
+	lost := make(chan error, 1)
+	videoTrack.OnSourceLost(func(err error) { lost <- err })
+
+	encoded, err := track.NewEncodedReader("vp8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer encoded.Close()
+	if buffer, _, err := encoded.Read(); err != nil || string(buffer.Data) != "640" {
+		t.Fatalf("Expected a 640 pixels wide frame, got %q (%v)", buffer.Data, err)
+	}
+
+	close(d.unplugged)
+	read := make(chan string)
+	go func() {
+		buffer, _, err := encoded.Read()
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		read <- string(buffer.Data)
+	}()
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the loss of the driver to be reported")
+	}
+
+	replugged := &fakeReconfigurableDriver{}
+	replugged.Open()
+	err = videoTrack.ReplaceDriver(replugged, func(c *MediaTrackConstraints) {
+		c.Width = prop.Int(320)
+		c.Height = prop.Int(240)
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case width := <-read:
+		if width != "320" {
+			t.Errorf("Expected a 320 pixels wide frame, got %q", width)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the reader to resume on the new driver")
+	}
+	if track.ID() != id {
+		t.Errorf("Expected the ID of the track to be kept, got %s", track.ID())
+	}
+}
+
+func TestVideoTrackReconfigureNotDriver(t *testing.T) {
+	track := NewVideoTrack(&fakeVideoSource{}, NewCodecSelector())
+	defer track.Close()
+	if err := track.(*VideoTrack).Reconfigure(func(c *MediaTrackConstraints) {}); err != errNotReconfigurable {
+		t.Errorf("Expected %v, got %v", errNotReconfigurable, err)
+	}
+}
+
+type fakeVideoSource struct{}
+
+func (s *fakeVideoSource) ID() string   { return "fake" }
+func (s *fakeVideoSource) Close() error { return nil }
+
+func (s *fakeVideoSource) Read() (image.Image, func(), error) {
+	return image.NewYCbCr(image.Rect(0, 0, 2, 2), image.YCbCrSubsampleRatio420), func() {}, nil
+}
@@ -0,0 +1,192 @@
+package mediadevices
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/rtp"
+)
+
+const (
+	// sharedKeyFrameInterval is the minimum interval between two key frames forced on a shared encoder.
+	// Key frame requests of all the peers received in this interval are coalesced into one.
+	sharedKeyFrameInterval = 500 * time.Millisecond
+	// sharedRTPReaderBufferSize is the number of packet batches buffered for each peer. When a peer
+	// is slower than the encoder, the batches overflowing the buffer are dropped for this peer only.
+	sharedRTPReaderBufferSize = 32
+)
+
+var errSharedEncoderClosed = errors.New("shared encoder is closed")
+
+// sharedRTPEncoder runs a single encoder and fans out its RTP packets to every subscribed reader,
+// each reader rewrites the packets with its own SSRC, payload type and sequence numbers.
+type sharedRTPEncoder struct {
+	mu           sync.Mutex
+	reader       RTPReadCloser
+	subscribers  map[*sharedRTPReader]struct{}
+	closed       bool
+	lastKeyFrame time.Time
+}
+
+// newSharedRTPEncoder returns an encoder of reader which isn't running yet, it's started once its
+// first reader is subscribed. Started without readers, it would stop right away.
+func newSharedRTPEncoder(reader RTPReadCloser) *sharedRTPEncoder {
+	return &sharedRTPEncoder{
+		reader:      reader,
+		subscribers: make(map[*sharedRTPReader]struct{}),
+	}
+}
+
+// start runs the encoder, the first reader must have been subscribed
+func (encoder *sharedRTPEncoder) start() {
+	go encoder.run()
+}
+
+// subscribe adds a new reader to the encoder. It returns nil if the encoder has already been closed.
+func (encoder *sharedRTPEncoder) subscribe(ssrc uint32, payloadType uint8) *sharedRTPReader {
+	encoder.mu.Lock()
+	if encoder.closed {
+		encoder.mu.Unlock()
+		return nil
+	}
+
+	reader := &sharedRTPReader{
+		encoder:     encoder,
+		ssrc:        ssrc,
+		payloadType: payloadType,
+		sequencer:   rtp.NewRandomSequencer(),
+		packets:     make(chan []*rtp.Packet, sharedRTPReaderBufferSize),
+		done:        make(chan struct{}),
+	}
+	encoder.subscribers[reader] = struct{}{}
+	encoder.mu.Unlock()
+
+	// The new peer can't decode anything until the next key frame
+	if err := encoder.ForceKeyFrame(); err != nil {
+		logger.Warnf("failed to force key frame: %s", err)
+	}
+
+	return reader
+}
+
+func (encoder *sharedRTPEncoder) unsubscribe(reader *sharedRTPReader) {
+	encoder.mu.Lock()
+	defer encoder.mu.Unlock()
+
+	delete(encoder.subscribers, reader)
+}
+
+func (encoder *sharedRTPEncoder) run() {
+	for {
+		encoder.mu.Lock()
+		if len(encoder.subscribers) == 0 {
+			// The last reader is gone, the encoder is stopped here instead of in unsubscribe
+			// so that an in-flight Read is never interrupted by Close.
+			encoder.closed = true
+			encoder.mu.Unlock()
+			encoder.reader.Close()
+			return
+		}
+		encoder.mu.Unlock()
+
+		pkts, release, err := encoder.reader.Read()
+		if err != nil {
+			encoder.mu.Lock()
+			encoder.closed = true
+			for reader := range encoder.subscribers {
+				reader.end(err)
+			}
+			encoder.subscribers = nil
+			encoder.mu.Unlock()
+			encoder.reader.Close()
+			return
+		}
+
+		encoder.mu.Lock()
+		for reader := range encoder.subscribers {
+			reader.push(pkts)
+		}
+		encoder.mu.Unlock()
+		release()
+	}
+}
+
+// ForceKeyFrame forces a key frame on the shared encoder unless one has been forced recently
+func (encoder *sharedRTPEncoder) ForceKeyFrame() error {
+	keyFrameController, ok := encoder.reader.Controller().(codec.KeyFrameController)
+	if !ok {
+		return nil
+	}
+
+	encoder.mu.Lock()
+	now := time.Now()
+	if now.Sub(encoder.lastKeyFrame) < sharedKeyFrameInterval {
+		encoder.mu.Unlock()
+		return nil
+	}
+	encoder.lastKeyFrame = now
+	encoder.mu.Unlock()
+
+	return keyFrameController.ForceKeyFrame()
+}
+
+// sharedRTPReader is the RTPReadCloser given to each peer subscribed to a sharedRTPEncoder
+type sharedRTPReader struct {
+	encoder     *sharedRTPEncoder
+	ssrc        uint32
+	payloadType uint8
+	sequencer   rtp.Sequencer
+	packets     chan []*rtp.Packet
+	done        chan struct{}
+	endOnce     sync.Once
+	err         error
+}
+
+// push rewrites pkts for this reader, it must be called with the encoder lock held
+func (reader *sharedRTPReader) push(pkts []*rtp.Packet) {
+	rewritten := make([]*rtp.Packet, len(pkts))
+	for i, pkt := range pkts {
+		header := pkt.Header
+		header.SSRC = reader.ssrc
+		header.PayloadType = reader.payloadType
+		// Sequence numbers are consumed even if the batch is dropped below, so that the remote
+		// peer detects the loss and asks for a new key frame.
+		header.SequenceNumber = reader.sequencer.NextSequenceNumber()
+		rewritten[i] = &rtp.Packet{Header: header, Payload: pkt.Payload}
+	}
+
+	select {
+	case reader.packets <- rewritten:
+	default:
+	}
+}
+
+func (reader *sharedRTPReader) end(err error) {
+	reader.endOnce.Do(func() {
+		reader.err = err
+		close(reader.done)
+	})
+}
+
+func (reader *sharedRTPReader) Read() ([]*rtp.Packet, func(), error) {
+	select {
+	case pkts := <-reader.packets:
+		return pkts, func() {}, nil
+	case <-reader.done:
+		return nil, func() {}, reader.err
+	}
+}
+
+func (reader *sharedRTPReader) Close() error {
+	reader.encoder.unsubscribe(reader)
+	reader.end(io.EOF)
+	return nil
+}
+
+// Controller only exposes key frame requests, other controls would affect every peer
+func (reader *sharedRTPReader) Controller() codec.EncoderController {
+	return reader.encoder
+}
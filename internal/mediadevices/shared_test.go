@@ -0,0 +1,208 @@
+package mediadevices
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+type fakeSharedSource struct {
+	packets   chan []*rtp.Packet
+	closed    chan struct{}
+	closeOnce sync.Once
+	keyFrames chan struct{}
+}
+
+func newFakeSharedSource() *fakeSharedSource {
+	return &fakeSharedSource{
+		packets:   make(chan []*rtp.Packet),
+		closed:    make(chan struct{}),
+		keyFrames: make(chan struct{}, 10),
+	}
+}
+
+func (source *fakeSharedSource) Read() ([]*rtp.Packet, func(), error) {
+	select {
+	case pkts := <-source.packets:
+		return pkts, func() {}, nil
+	case <-source.closed:
+		return nil, func() {}, io.EOF
+	}
+}
+
+func (source *fakeSharedSource) Close() error {
+	source.closeOnce.Do(func() {
+		close(source.closed)
+	})
+	return nil
+}
+
+func (source *fakeSharedSource) Controller() codec.EncoderController {
+	return source
+}
+
+func (source *fakeSharedSource) ForceKeyFrame() error {
+	source.keyFrames <- struct{}{}
+	return nil
+}
+
+type fakeSharedTrack struct {
+	Track
+	newRTPReaderCalls int
+	source            *fakeSharedSource
+}
+
+func (track *fakeSharedTrack) NewRTPReader(codecName string, ssrc uint32, mtu int) (RTPReadCloser, error) {
+	track.newRTPReaderCalls++
+	return track.source, nil
+}
+
+func TestSharedEncoding(t *testing.T) {
+	source := newFakeSharedSource()
+	specializedTrack := &fakeSharedTrack{source: source}
+	tr := &baseTrack{}
+	tr.SetSharedEncoding(true)
+
+	vp8 := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8},
+		PayloadType:        100,
+	}
+	vp8Other := vp8
+	vp8Other.PayloadType = 120
+
+	reader1, err := tr.newRTPReader(specializedTrack, vp8, 1111, rtpOutboundMTU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader2, err := tr.newRTPReader(specializedTrack, vp8Other, 2222, rtpOutboundMTU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if specializedTrack.newRTPReaderCalls != 1 {
+		t.Errorf("Expected a single encoder, got %d", specializedTrack.newRTPReaderCalls)
+	}
+
+	// Both subscriptions happened in the same key frame interval
+	if len(source.keyFrames) != 1 {
+		t.Errorf("Expected key frame requests to be coalesced, got %d key frames", len(source.keyFrames))
+	}
+	if err := reader2.Controller().(codec.KeyFrameController).ForceKeyFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if len(source.keyFrames) != 1 {
+		t.Errorf("Expected key frame requests to be coalesced, got %d key frames", len(source.keyFrames))
+	}
+
+	payload := []byte{0x01, 0x02, 0x03}
+	for i := 0; i < 2; i++ {
+		source.packets <- []*rtp.Packet{
+			{Header: rtp.Header{SSRC: 1, PayloadType: 96, SequenceNumber: 42, Timestamp: 1000}, Payload: payload},
+		}
+	}
+
+	for _, c := range []struct {
+		reader      RTPReadCloser
+		ssrc        uint32
+		payloadType uint8
+	}{
+		{reader1, 1111, 100},
+		{reader2, 2222, 120},
+	} {
+		var lastSequenceNumber uint16
+		for i := 0; i < 2; i++ {
+			pkts, _, err := c.reader.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pkts) != 1 {
+				t.Fatalf("Expected 1 packet, got %d", len(pkts))
+			}
+			pkt := pkts[0]
+			if pkt.SSRC != c.ssrc {
+				t.Errorf("Expected SSRC %d, got %d", c.ssrc, pkt.SSRC)
+			}
+			if pkt.PayloadType != c.payloadType {
+				t.Errorf("Expected payload type %d, got %d", c.payloadType, pkt.PayloadType)
+			}
+			if pkt.Timestamp != 1000 {
+				t.Errorf("Expected timestamp to be kept, got %d", pkt.Timestamp)
+			}
+			if i > 0 && pkt.SequenceNumber != lastSequenceNumber+1 {
+				t.Errorf("Expected sequence number %d, got %d", lastSequenceNumber+1, pkt.SequenceNumber)
+			}
+			lastSequenceNumber = pkt.SequenceNumber
+		}
+	}
+
+	reader1.Close()
+	select {
+	case <-source.closed:
+		t.Fatal("Encoder is unexpectedly closed while a reader is subscribed")
+	default:
+	}
+
+	reader2.Close()
+	if _, _, err := reader2.Read(); err != io.EOF {
+		t.Errorf("Expected EOF after close, got %v", err)
+	}
+
+	// The encoder notices that it has no readers left after the next read
+	source.packets <- []*rtp.Packet{{Payload: payload}}
+	select {
+	case <-source.closed:
+	case <-time.After(time.Second):
+		t.Error("Timeout: encoder not closed after the last reader left")
+	}
+
+	// A new peer gets a new encoder
+	newSource := newFakeSharedSource()
+	specializedTrack.source = newSource
+	reader3, err := tr.newRTPReader(specializedTrack, vp8, 3333, rtpOutboundMTU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader3.Close()
+	if specializedTrack.newRTPReaderCalls != 2 {
+		t.Errorf("Expected a new encoder, got %d encoders", specializedTrack.newRTPReaderCalls)
+	}
+}
+
+func TestSharedEncodingSourceError(t *testing.T) {
+	source := newFakeSharedSource()
+	tr := &baseTrack{}
+	tr.SetSharedEncoding(true)
+
+	reader, err := tr.newRTPReader(&fakeSharedTrack{source: source}, webrtc.RTPCodecParameters{}, 1, rtpOutboundMTU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.Close()
+	if _, _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Expected the source error, got %v", err)
+	}
+}
+
+func TestSharedEncodingClosedSource(t *testing.T) {
+	// The encoder must not stop before its first reader is subscribed
+	for i := 0; i < 100; i++ {
+		source := newFakeSharedSource()
+		source.Close()
+		tr := &baseTrack{}
+		tr.SetSharedEncoding(true)
+
+		reader, err := tr.newRTPReader(&fakeSharedTrack{source: source}, webrtc.RTPCodecParameters{}, 1, rtpOutboundMTU)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := reader.Read(); err != io.EOF {
+			t.Fatalf("Expected the source error, got %v", err)
+		}
+	}
+}
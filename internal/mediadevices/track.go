@@ -7,6 +7,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pion/interceptor"
 	"github.com/pion/rtcp"
@@ -16,6 +17,7 @@ import (
 	"github.com/pion/mediadevices/pkg/driver"
 	"github.com/pion/mediadevices/pkg/io/audio"
 	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
 	"github.com/pion/mediadevices/pkg/wave"
 	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
@@ -89,6 +91,10 @@ type baseTrack struct {
 	kind                  MediaDeviceType
 	selector              *CodecSelector
 	activePeerConnections map[string]chan<- chan<- struct{}
+
+	sharedMu       sync.Mutex
+	sharedEncoding bool
+	sharedEncoders map[string]*sharedRTPEncoder
 }
 
 func newBaseTrack(source Source, kind MediaDeviceType, selector *CodecSelector) *baseTrack {
@@ -157,6 +163,61 @@ func (track *baseTrack) onError(err error) {
 	}
 }
 
+// SharedEncoding indicates if the peer connections bound to this track share a single encoder.
+func (track *baseTrack) SharedEncoding() bool {
+	track.sharedMu.Lock()
+	defer track.sharedMu.Unlock()
+
+	return track.sharedEncoding
+}
+
+// SetSharedEncoding enables the encode-once mode for the peer connections bound afterwards. In this
+// mode the source is encoded once per codec and the RTP packets are rewritten for each peer connection,
+// so that the encoding cost doesn't depend on the number of peer connections. Key frame requests of the
+// peer connections are coalesced, and their bitrate can't be controlled individually.
+func (track *baseTrack) SetSharedEncoding(sharedEncoding bool) {
+	track.sharedMu.Lock()
+	defer track.sharedMu.Unlock()
+
+	track.sharedEncoding = sharedEncoding
+}
+
+// newRTPReader creates the rtp reader of a peer connection, either a new encoder or a subscription
+// to the shared encoder of the codec.
+func (track *baseTrack) newRTPReader(specializedTrack Track, wantedCodec webrtc.RTPCodecParameters, ssrc uint32, mtu int) (RTPReadCloser, error) {
+	track.sharedMu.Lock()
+	defer track.sharedMu.Unlock()
+
+	if !track.sharedEncoding {
+		return specializedTrack.NewRTPReader(wantedCodec.MimeType, ssrc, mtu)
+	}
+
+	// a closed encoder is replaced by a new one
+	if encoder, ok := track.sharedEncoders[wantedCodec.MimeType]; ok {
+		if reader := encoder.subscribe(ssrc, uint8(wantedCodec.PayloadType)); reader != nil {
+			return reader, nil
+		}
+	}
+
+	rtpReader, err := specializedTrack.NewRTPReader(wantedCodec.MimeType, ssrc, mtu)
+	if err != nil {
+		return nil, err
+	}
+
+	if track.sharedEncoders == nil {
+		track.sharedEncoders = make(map[string]*sharedRTPEncoder)
+	}
+	encoder := newSharedRTPEncoder(rtpReader)
+	reader := encoder.subscribe(ssrc, uint8(wantedCodec.PayloadType))
+	if reader == nil {
+		rtpReader.Close()
+		return nil, errSharedEncoderClosed
+	}
+	encoder.start()
+	track.sharedEncoders[wantedCodec.MimeType] = encoder
+	return reader, nil
+}
+
 func (track *baseTrack) bind(ctx webrtc.TrackLocalContext, specializedTrack Track) (webrtc.RTPCodecParameters, error) {
 	track.mu.Lock()
 	defer track.mu.Unlock()
@@ -171,7 +232,7 @@ func (track *baseTrack) bind(ctx webrtc.TrackLocalContext, specializedTrack Trac
 	var errReasons []string
 	for _, wantedCodec := range ctx.CodecParameters() {
 		logger.Debugf("trying to build %s rtp reader", wantedCodec.MimeType)
-		encodedReader, err = specializedTrack.NewRTPReader(wantedCodec.MimeType, uint32(ctx.SSRC()), rtpOutboundMTU)
+		encodedReader, err = track.newRTPReader(specializedTrack, wantedCodec, uint32(ctx.SSRC()), rtpOutboundMTU)
 
 		track.errMu.Lock()
 		if track.err != nil {
@@ -322,6 +383,29 @@ type VideoTrack struct {
 	*baseTrack
 	*video.Broadcaster
 	shouldCopyFrames bool
+
+	// sourceMu is held while the driver is reconfigured. The transforms are kept to be applied
+	// again on the new source. source is the driver read, replaced by ReplaceDriver, while
+	// baseTrack.Source keeps the ID of the track.
+	sourceMu   sync.RWMutex
+	source     Source
+	reader     video.Reader
+	transforms []video.TransformFunc
+	closed     bool
+	// selected are the properties the driver is opened with, restored when a reconfiguration fails
+	selected prop.Media
+	// onSourceLost is called when the source fails, sourceReplaced is closed when it is replaced
+	onSourceLost   func(error)
+	sourceReplaced chan struct{}
+	// sourceGeneration is incremented when a reconfiguration starts, readers of an older generation
+	// are stale. encoderGeneration is incremented when it succeeded, the encoders are rebuilt.
+	sourceGeneration  uint32
+	encoderGeneration uint32
+
+	// encoders are the encoders reading the track, bitRate is applied to them when it is set
+	encodersMu sync.Mutex
+	encoders   map[*reconfigurableEncoder]struct{}
+	bitRate    int
 }
 
 // NewVideoTrack constructs a new VideoTrack
@@ -340,36 +424,76 @@ func (track *VideoTrack) SetShouldCopyFrames(shouldCopyFrames bool) {
 }
 
 func newVideoTrackFromReader(source Source, reader video.Reader, selector *CodecSelector) Track {
-	base := newBaseTrack(source, VideoInput, selector)
-	wrappedReader := video.ReaderFunc(func() (img image.Image, release func(), err error) {
-		img, _, err = reader.Read()
-		if err != nil {
-			base.onError(err)
+	track := &VideoTrack{
+		baseTrack: newBaseTrack(source, VideoInput, selector),
+		source:    source,
+		reader:    reader,
+	}
+
+	// TODO: Allow users to configure broadcaster
+	track.Broadcaster = video.NewBroadcaster(track.wrapReader(reader, 0), nil)
+
+	return track
+}
+
+// wrapReader reports the errors of reader to the track. A reader replaced by Reconfigure or
+// ReplaceDriver fails when its driver is closed, the error isn't reported and the frame is read
+// from the new reader. A source lost while OnSourceLost is set is waited for.
+func (track *VideoTrack) wrapReader(reader video.Reader, generation uint32) video.Reader {
+	return video.ReaderFunc(func() (img image.Image, release func(), err error) {
+		for {
+			img, _, err = reader.Read()
+			if err == nil {
+				return img, func() {}, nil
+			}
+			if atomic.LoadUint32(&track.sourceGeneration) == generation && !track.waitSource(err, generation) {
+				track.onError(err)
+				return nil, func() {}, err
+			}
+			reader, generation = track.currentReader()
 		}
-		return img, func() {}, err
 	})
+}
 
-	// TODO: Allow users to configure broadcaster
-	broadcaster := video.NewBroadcaster(wrappedReader, nil)
+// currentReader waits for the reconfiguration in progress and returns the reader of the driver
+// with its generation
+func (track *VideoTrack) currentReader() (video.Reader, uint32) {
+	track.sourceMu.RLock()
+	defer track.sourceMu.RUnlock()
+	return track.reader, atomic.LoadUint32(&track.sourceGeneration)
+}
 
-	return &VideoTrack{
-		baseTrack:   base,
-		Broadcaster: broadcaster,
+// Close closes the source of the track, the readers waiting for a lost source get its error
+func (track *VideoTrack) Close() error {
+	track.sourceMu.Lock()
+	track.closed = true
+	if track.sourceReplaced != nil {
+		close(track.sourceReplaced)
+		track.sourceReplaced = nil
 	}
+	source := track.source
+	track.sourceMu.Unlock()
+	return source.Close()
 }
 
 // newVideoTrackFromDriver is an internal video track creation from driver
 func newVideoTrackFromDriver(d driver.Driver, recorder driver.VideoRecorder, constraints MediaTrackConstraints, selector *CodecSelector) (Track, error) {
-	reader, err := recorder.VideoRecord(constraints.selectedMedia)
+	reader, err := recordVideo(recorder, constraints.selectedMedia, selector)
 	if err != nil {
 		return nil, err
 	}
 
-	return newVideoTrackFromReader(d, reader, selector), nil
+	track := newVideoTrackFromReader(d, reader, selector).(*VideoTrack)
+	track.selected = constraints.selectedMedia
+	return track, nil
 }
 
 // Transform transforms the underlying source by applying the given fns in serial order
 func (track *VideoTrack) Transform(fns ...video.TransformFunc) {
+	track.sourceMu.Lock()
+	defer track.sourceMu.Unlock()
+
+	track.transforms = append(track.transforms, fns...)
 	src := track.Broadcaster.Source()
 	track.Broadcaster.ReplaceSource(video.Merge(fns...)(src))
 }
@@ -383,13 +507,7 @@ func (track *VideoTrack) Unbind(ctx webrtc.TrackLocalContext) error {
 }
 
 func (track *VideoTrack) newEncodedReader(codecNames ...string) (EncodedReadCloser, *codec.RTPCodec, error) {
-	reader := track.NewReader(track.shouldCopyFrames)
-	inputProp, err := detectCurrentVideoProp(track.Broadcaster)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	encodedReader, selectedCodec, err := track.selector.selectVideoCodecByNames(reader, inputProp, codecNames...)
+	encodedReader, selectedCodec, err := track.newVideoEncoder(codecNames...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -428,6 +546,10 @@ func (track *VideoTrack) NewRTPReader(codecName string, ssrc uint32, mtu int) (R
 	if err != nil {
 		return nil, err
 	}
+	if selectedCodec.Payloader == nil {
+		encodedReader.Close()
+		return nil, fmt.Errorf("%s: %w", selectedCodec.MimeType, errNoPayloader)
+	}
 
 	packetizer := rtp.NewPacketizer(uint16(mtu), uint8(selectedCodec.PayloadType), ssrc, selectedCodec.Payloader, rtp.NewRandomSequencer(), selectedCodec.ClockRate)
 
@@ -0,0 +1,66 @@
+package pirtc
+
+import (
+	"github.com/pion/mediadevices/pkg/io/audio"
+)
+
+// AudioProcessingConfig cleans the sound of the microphone up, against the
+// wind and the hum of the outdoor cameras. The stages run in the order of the
+// fields, the zero value of a field disables its stage.
+type AudioProcessingConfig struct {
+	// HighPass is the cutoff of the high-pass filter in Hz, 20 Hz only removes
+	// the DC offset
+	HighPass float64
+	// NoiseSuppression is the highest attenuation of the steady noise in dB
+	NoiseSuppression float64
+	// NoiseGate is the level in dBFS below which the sound is muted
+	NoiseGate float64
+	// AutomaticGain brings the level of the sound to GainTarget in dBFS, 0 uses
+	// the default of audio.AutomaticGainOptions
+	AutomaticGain bool
+	GainTarget    float64
+}
+
+// SetAudioProcessing changes the processing of the microphone, nil removes it.
+// It is applied the next time the microphone is opened, the sound detection
+// measures the processed sound.
+func (pirtc *PiRTC) SetAudioProcessing(config *AudioProcessingConfig) {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	pirtc.audioProcessing = config
+}
+
+func (config *AudioProcessingConfig) transform() audio.TransformFunc {
+	var transforms []audio.TransformFunc
+	if config.HighPass > 0 {
+		transforms = append(transforms, audio.HighPass(config.HighPass))
+	}
+	if config.NoiseSuppression > 0 {
+		transforms = append(transforms, audio.SuppressNoise(audio.NoiseSuppressionOptions{
+			Reduction: config.NoiseSuppression,
+		}))
+	}
+	if config.NoiseGate < 0 {
+		transforms = append(transforms, audio.NoiseGate(audio.NoiseGateOptions{
+			Threshold: config.NoiseGate,
+		}))
+	}
+	if config.AutomaticGain {
+		transforms = append(transforms, audio.AutomaticGain(audio.AutomaticGainOptions{
+			Target: config.GainTarget,
+		}))
+	}
+	if len(transforms) == 0 {
+		return nil
+	}
+	return audio.Merge(transforms...)
+}
+
+// audioProcessingTransform must be called with pirtc.mu held, it returns nil
+// without processing
+func (pirtc *PiRTC) audioProcessingTransform() audio.TransformFunc {
+	if pirtc.audioProcessing == nil {
+		return nil
+	}
+	return pirtc.audioProcessing.transform()
+}
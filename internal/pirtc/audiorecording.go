@@ -0,0 +1,109 @@
+package pirtc
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec/opus"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/webrtc/v3"
+)
+
+// RecordAudio records the microphone alone to savePath until stopCh is
+// closed, in Ogg OPUS for a .ogg or a .opus path and in WAV otherwise. It
+// returns once the file is complete. The microphone is shared with the stream.
+func (pirtc *PiRTC) RecordAudio(savePath string, stopCh <-chan struct{}) error {
+	pirtc.mu.Lock()
+	track, err := pirtc.acquireMicrophone()
+	pirtc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		pirtc.mu.Lock()
+		pirtc.releaseMicrophone()
+		pirtc.mu.Unlock()
+	}()
+
+	f, err := os.Create(savePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	log.Println("Recording audio...")
+	switch strings.ToLower(filepath.Ext(savePath)) {
+	case ".ogg", ".opus":
+		err = recordOgg(f, track, stopCh)
+	default:
+		err = recordWAV(f, track, stopCh)
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("Audio recorded to", savePath)
+	return f.Close()
+}
+
+// recordWAV writes the samples of the microphone after the audio processing
+func recordWAV(f *os.File, track *mediadevices.AudioTrack, stopCh <-chan struct{}) error {
+	reader := track.NewReader(false)
+	w := audio.NewWAVWriter(f)
+	for {
+		select {
+		case <-stopCh:
+			return w.Close()
+		default:
+		}
+		chunk, release, err := reader.Read()
+		if err != nil {
+			return err
+		}
+		err = w.Write(chunk)
+		release()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// recordOgg writes the packets of an encoder of the microphone, the stream
+// keeps its own encoder
+func recordOgg(f *os.File, track *mediadevices.AudioTrack, stopCh <-chan struct{}) error {
+	// the channels of the header are the channels of the microphone
+	chunk, release, err := track.NewReader(false).Read()
+	if err != nil {
+		return err
+	}
+	info := chunk.ChunkInfo()
+	release()
+
+	reader, err := track.NewEncodedReader(webrtc.MimeTypeOpus)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	w, err := opus.NewOggWriter(f, info.SamplingRate, info.Channels)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stopCh:
+			return w.Close()
+		default:
+		}
+		buffer, release, err := reader.Read()
+		if err != nil {
+			return err
+		}
+		err = w.WritePacket(buffer.Data, buffer.Samples)
+		release()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package pirtc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	aviHasIndex   = 0x10
+	aviKeyFrame   = 0x10
+	aviHeaderSize = 56
+	aviBitmapSize = 40
+)
+
+// aviWriter writes MJPEG frames into an AVI file. The sizes and the frame
+// counts of the headers are updated after every frame so that a file cut by a
+// power loss stays playable, the index is only written by Close.
+type aviWriter struct {
+	w     io.WriteSeeker
+	index bytes.Buffer
+	// offsets of the header fields updated after every frame
+	riffSizeOffset    int64
+	totalFramesOffset int64
+	lengthOffset      int64
+	moviSizeOffset    int64
+	moviOffset        int64
+	size              int64
+	frames            uint32
+}
+
+func newAviWriter(w io.WriteSeeker, width, height, fps int) (*aviWriter, error) {
+	var header bytes.Buffer
+	le := binary.LittleEndian
+	u32 := func(v uint32) { binary.Write(&header, le, v) }
+	u16 := func(v uint16) { binary.Write(&header, le, v) }
+	fourcc := func(s string) { header.WriteString(s) }
+
+	writer := &aviWriter{w: w}
+
+	fourcc("RIFF")
+	writer.riffSizeOffset = int64(header.Len())
+	u32(0)
+	fourcc("AVI ")
+
+	fourcc("LIST")
+	u32(4 + (8 + aviHeaderSize) + (8 + 4 + (8 + aviHeaderSize) + (8 + aviBitmapSize)))
+	fourcc("hdrl")
+
+	fourcc("avih")
+	u32(aviHeaderSize)
+	u32(uint32(1_000_000 / fps)) // microseconds per frame
+	u32(0)                       // max bytes per second
+	u32(0)                       // padding granularity
+	u32(aviHasIndex)
+	writer.totalFramesOffset = int64(header.Len())
+	u32(0) // total frames
+	u32(0) // initial frames
+	u32(1) // streams
+	u32(0) // suggested buffer size
+	u32(uint32(width))
+	u32(uint32(height))
+	for i := 0; i < 4; i++ {
+		u32(0) // reserved
+	}
+
+	fourcc("LIST")
+	u32(4 + (8 + aviHeaderSize) + (8 + aviBitmapSize))
+	fourcc("strl")
+
+	fourcc("strh")
+	u32(aviHeaderSize)
+	fourcc("vids")
+	fourcc("MJPG")
+	u32(0) // flags
+	u16(0) // priority
+	u16(0) // language
+	u32(0) // initial frames
+	u32(1) // scale
+	u32(uint32(fps))
+	u32(0) // start
+	writer.lengthOffset = int64(header.Len())
+	u32(0)          // length
+	u32(0)          // suggested buffer size
+	u32(0xffffffff) // quality
+	u32(0)          // sample size
+	u16(0)
+	u16(0)
+	u16(uint16(width))
+	u16(uint16(height))
+
+	fourcc("strf")
+	u32(aviBitmapSize)
+	u32(aviBitmapSize)
+	u32(uint32(width))
+	u32(uint32(height))
+	u16(1)  // planes
+	u16(24) // bit count
+	fourcc("MJPG")
+	u32(uint32(width * height * 3))
+	for i := 0; i < 4; i++ {
+		u32(0) // resolution and palette
+	}
+
+	fourcc("LIST")
+	writer.moviSizeOffset = int64(header.Len())
+	u32(4)
+	writer.moviOffset = int64(header.Len())
+	fourcc("movi")
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+	writer.size = int64(header.Len())
+	return writer, writer.updateHeader()
+}
+
+// WriteFrame appends a JPEG image as the next frame
+func (writer *aviWriter) WriteFrame(frame []byte) error {
+	var chunk bytes.Buffer
+	chunk.WriteString("00dc")
+	binary.Write(&chunk, binary.LittleEndian, uint32(len(frame)))
+	chunk.Write(frame)
+	if len(frame)%2 == 1 {
+		chunk.WriteByte(0)
+	}
+
+	// index offsets are relative to the movi fourcc
+	writer.index.WriteString("00dc")
+	binary.Write(&writer.index, binary.LittleEndian, []uint32{
+		aviKeyFrame,
+		uint32(writer.size - writer.moviOffset),
+		uint32(len(frame)),
+	})
+
+	if _, err := writer.w.Write(chunk.Bytes()); err != nil {
+		return err
+	}
+	writer.size += int64(chunk.Len())
+	writer.frames++
+	return writer.updateHeader()
+}
+
+// Close writes the index, the underlying writer is not closed
+func (writer *aviWriter) Close() error {
+	var chunk bytes.Buffer
+	chunk.WriteString("idx1")
+	binary.Write(&chunk, binary.LittleEndian, uint32(writer.index.Len()))
+	chunk.Write(writer.index.Bytes())
+	if _, err := writer.w.Write(chunk.Bytes()); err != nil {
+		return err
+	}
+	moviEnd := writer.size
+	writer.size += int64(chunk.Len())
+	return writer.patch(moviEnd)
+}
+
+func (writer *aviWriter) updateHeader() error {
+	return writer.patch(writer.size)
+}
+
+func (writer *aviWriter) patch(moviEnd int64) error {
+	fields := []struct {
+		offset int64
+		value  uint32
+	}{
+		{writer.riffSizeOffset, uint32(writer.size - 8)},
+		{writer.totalFramesOffset, writer.frames},
+		{writer.lengthOffset, writer.frames},
+		{writer.moviSizeOffset, uint32(moviEnd - writer.moviOffset)},
+	}
+	for _, field := range fields {
+		if _, err := writer.w.Seek(field.offset, io.SeekStart); err != nil {
+			return err
+		}
+		if err := binary.Write(writer.w, binary.LittleEndian, field.value); err != nil {
+			return err
+		}
+	}
+	_, err := writer.w.Seek(writer.size, io.SeekStart)
+	return err
+}
@@ -0,0 +1,147 @@
+package pirtc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// aviFile is what the tests read back from an AVI file
+type aviFile struct {
+	totalFrames uint32
+	length      uint32
+	rate        uint32
+	width       uint32
+	height      uint32
+	frames      [][]byte
+}
+
+// readAvi checks the chunk sizes of an AVI file written by aviWriter and
+// returns its frames, read through the index
+func readAvi(t *testing.T, data []byte) aviFile {
+	t.Helper()
+	le := binary.LittleEndian
+	fourcc := func(offset int) string { return string(data[offset : offset+4]) }
+	u32 := func(offset int) uint32 { return le.Uint32(data[offset:]) }
+
+	if fourcc(0) != "RIFF" || fourcc(8) != "AVI " {
+		t.Fatalf("Expected a RIFF AVI file, got %q %q", fourcc(0), fourcc(8))
+	}
+	if size := u32(4); int(size) != len(data)-8 {
+		t.Errorf("Expected the RIFF size to be %d, got %d", len(data)-8, size)
+	}
+
+	// hdrl list: avih, then the strl list with strh and strf
+	if fourcc(12) != "LIST" || fourcc(20) != "hdrl" {
+		t.Fatalf("Expected the hdrl list, got %q %q", fourcc(12), fourcc(20))
+	}
+	hdrlEnd := 20 + int(u32(16))
+	if fourcc(24) != "avih" || u32(28) != aviHeaderSize {
+		t.Fatalf("Expected a %d bytes avih chunk, got %q of %d bytes", aviHeaderSize, fourcc(24), u32(28))
+	}
+	avih := 32
+	var file aviFile
+	file.totalFrames = u32(avih + 16)
+	file.width, file.height = u32(avih+32), u32(avih+36)
+
+	strl := avih + aviHeaderSize
+	if fourcc(strl) != "LIST" || fourcc(strl+8) != "strl" || strl+8+int(u32(strl+4)) != hdrlEnd {
+		t.Fatalf("Expected the strl list to end the hdrl list, got %q %q", fourcc(strl), fourcc(strl+8))
+	}
+	strh := strl + 12
+	if fourcc(strh) != "strh" || u32(strh+4) != aviHeaderSize || fourcc(strh+8) != "vids" || fourcc(strh+12) != "MJPG" {
+		t.Fatalf("Expected a MJPEG strh chunk of %d bytes, got %q", aviHeaderSize, data[strh:strh+16])
+	}
+	file.rate = u32(strh + 8 + 24)
+	file.length = u32(strh + 8 + 32)
+	strf := strh + 8 + aviHeaderSize
+	if fourcc(strf) != "strf" || u32(strf+4) != aviBitmapSize {
+		t.Fatalf("Expected a %d bytes strf chunk, got %q of %d bytes", aviBitmapSize, fourcc(strf), u32(strf+4))
+	}
+	if end := strf + 8 + aviBitmapSize; end != hdrlEnd {
+		t.Fatalf("Expected the hdrl list to end at %d, got %d", end, hdrlEnd)
+	}
+
+	if fourcc(hdrlEnd) != "LIST" || fourcc(hdrlEnd+8) != "movi" {
+		t.Fatalf("Expected the movi list, got %q %q", fourcc(hdrlEnd), fourcc(hdrlEnd+8))
+	}
+	movi := hdrlEnd + 8
+	idx1 := movi + int(u32(hdrlEnd+4))
+	if fourcc(idx1) != "idx1" {
+		t.Fatalf("Expected the index after the movi list, got %q", fourcc(idx1))
+	}
+	index := data[idx1+8:]
+	if int(u32(idx1+4)) != len(index) || len(index)%16 != 0 {
+		t.Fatalf("Expected the index to end the file, got %d bytes for %d", u32(idx1+4), len(index))
+	}
+
+	// the offsets of the index are relative to the movi fourcc
+	for entry := 0; entry < len(index); entry += 16 {
+		if string(index[entry:entry+4]) != "00dc" || le.Uint32(index[entry+4:]) != aviKeyFrame {
+			t.Fatalf("Expected a key frame entry, got %q", index[entry:entry+8])
+		}
+		chunk := movi + int(le.Uint32(index[entry+8:]))
+		size := le.Uint32(index[entry+12:])
+		if fourcc(chunk) != "00dc" || u32(chunk+4) != size {
+			t.Fatalf("Expected the index entry to point to a %d bytes frame, got %q of %d bytes", size, fourcc(chunk), u32(chunk+4))
+		}
+		file.frames = append(file.frames, data[chunk+8:chunk+8+int(size)])
+	}
+	return file
+}
+
+func TestAviWriter(t *testing.T) {
+	var frames [][]byte
+	for _, width := range []int{16, 17, 31} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, 8)), nil); err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, buf.Bytes())
+	}
+	// an odd sized frame is padded
+	frames = append(frames, []byte{0xff, 0xd8, 0xff})
+
+	path := filepath.Join(t.TempDir(), "test.avi")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer, err := newAviWriter(file, 640, 480, 25)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, frame := range frames {
+		if err := writer.WriteFrame(frame); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	avi := readAvi(t, data)
+	if avi.totalFrames != uint32(len(frames)) || avi.length != uint32(len(frames)) {
+		t.Errorf("Expected %d frames in the headers, got %d and %d", len(frames), avi.totalFrames, avi.length)
+	}
+	if avi.rate != 25 || avi.width != 640 || avi.height != 480 {
+		t.Errorf("Expected 640x480 at 25 fps, got %dx%d at %d fps", avi.width, avi.height, avi.rate)
+	}
+	if len(avi.frames) != len(frames) {
+		t.Fatalf("Expected %d indexed frames, got %d", len(frames), len(avi.frames))
+	}
+	for i := range frames {
+		if !bytes.Equal(avi.frames[i], frames[i]) {
+			t.Errorf("Expected the frame %d to be kept", i)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package pirtc
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/driver"
+)
+
+var errCameraNotOpen = errors.New("CAMERA IS NOT OPEN")
+
+// LoadCameraControls reads the camera controls saved by SetCameraControls, a
+// missing file keeps the settings of the camera. They are applied each time
+// the camera is opened.
+func (pirtc *PiRTC) LoadCameraControls(path string) error {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	pirtc.cameraControlsPath = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	controls := make(map[driver.ControlID]int32)
+	if err := json.Unmarshal(data, &controls); err != nil {
+		return err
+	}
+	pirtc.cameraControls = controls
+	return nil
+}
+
+// CameraControls returns the controls of the camera with their range and
+// current value, the camera must be open
+func (pirtc *PiRTC) CameraControls() ([]driver.Control, error) {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	if pirtc.stream == nil {
+		return nil, errCameraNotOpen
+	}
+	for _, track := range pirtc.stream.GetVideoTracks() {
+		return track.(*mediadevices.VideoTrack).Controls()
+	}
+	return nil, errCameraNotOpen
+}
+
+// SetCameraControls changes the controls of the camera, like its exposure or
+// its white balance, and saves them. The controls of a closed camera are
+// applied when it opens. While a scene profile overrides a control, the new
+// value is applied when the profile is left.
+func (pirtc *PiRTC) SetCameraControls(controls map[driver.ControlID]int32) error {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+
+	for id, value := range controls {
+		if _, ok := pirtc.sceneControls[id]; ok {
+			pirtc.controlsBeforeScene[id] = value
+		} else if err := pirtc.setCameraControl(id, value); err != nil {
+			return err
+		}
+		if pirtc.cameraControls == nil {
+			pirtc.cameraControls = make(map[driver.ControlID]int32)
+		}
+		pirtc.cameraControls[id] = value
+	}
+
+	path := pirtc.cameraControlsPath
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(pirtc.cameraControls)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// applyCameraControls must be called with pirtc.mu held, it sets the saved
+// controls and the ones of the scene profile on the camera just opened
+func (pirtc *PiRTC) applyCameraControls() {
+	for id, value := range pirtc.cameraControls {
+		if _, ok := pirtc.sceneControls[id]; ok {
+			continue
+		}
+		if err := pirtc.setCameraControl(id, value); err != nil {
+			log.Printf("[camera controls error]: %s: %v\n", id, err)
+		}
+	}
+	for id, value := range pirtc.sceneControls {
+		if err := pirtc.setCameraControl(id, value); err != nil {
+			log.Printf("[camera controls error]: %s: %v\n", id, err)
+		}
+	}
+}
+
+// applySceneControls must be called with pirtc.mu held. The controls left by
+// the previous profile get back the value they had before it.
+func (pirtc *PiRTC) applySceneControls(controls map[driver.ControlID]int32) error {
+	for id, value := range pirtc.controlsBeforeScene {
+		if _, ok := controls[id]; ok {
+			continue
+		}
+		if err := pirtc.setCameraControl(id, value); err != nil {
+			return err
+		}
+		delete(pirtc.controlsBeforeScene, id)
+	}
+
+	if pirtc.controlsBeforeScene == nil {
+		pirtc.controlsBeforeScene = make(map[driver.ControlID]int32)
+	}
+	for id, value := range controls {
+		if _, ok := pirtc.controlsBeforeScene[id]; !ok {
+			before, ok := pirtc.cameraControls[id]
+			if !ok {
+				var err error
+				if before, ok, err = pirtc.cameraControl(id); err != nil {
+					return err
+				}
+			}
+			if ok {
+				pirtc.controlsBeforeScene[id] = before
+			}
+		}
+		if err := pirtc.setCameraControl(id, value); err != nil {
+			return err
+		}
+	}
+	pirtc.sceneControls = controls
+	return nil
+}
+
+// setCameraControl must be called with pirtc.mu held, it does nothing while
+// the camera is closed
+func (pirtc *PiRTC) setCameraControl(id driver.ControlID, value int32) error {
+	if pirtc.stream == nil {
+		return nil
+	}
+	for _, track := range pirtc.stream.GetVideoTracks() {
+		if err := track.(*mediadevices.VideoTrack).SetControl(id, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cameraControl must be called with pirtc.mu held, it returns the current
+// value of a control of the open camera
+func (pirtc *PiRTC) cameraControl(id driver.ControlID) (int32, bool, error) {
+	if pirtc.stream == nil {
+		return 0, false, nil
+	}
+	for _, track := range pirtc.stream.GetVideoTracks() {
+		controls, err := track.(*mediadevices.VideoTrack).Controls()
+		if err != nil {
+			return 0, false, err
+		}
+		for _, control := range controls {
+			if control.ID == id {
+				return control.Value, true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
+
+// writeFileAtomic writes data aside and renames it over path once it is on
+// the disk, a power cut leaves either the old or the new file
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// the rename is only durable once the directory is synced too
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,288 @@
+package pirtc
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+const dayNightEventsBufferSize = 8
+
+// time between two brightness measures
+const dayNightInterval = time.Second
+
+// weight of the previous frames in the night denoiser
+const nightDenoiseStrength = 0.6
+
+var errInvalidProfile = errors.New("INVALID SCENE PROFILE")
+
+// SceneProfile is applied when the scene turns to day or night
+type SceneProfile struct {
+	// FrameRate of the camera, 0 keeps the frame rate of the video mode
+	FrameRate float32 `json:"fps,omitempty"`
+	// BitRate of the video in bit/s, 0 keeps the default bit rate
+	BitRate   int  `json:"bitrate,omitempty"`
+	Grayscale bool `json:"grayscale,omitempty"`
+	Denoise   bool `json:"denoise,omitempty"`
+	// Controls are set on the camera while the profile is applied, like a
+	// longer exposure at night
+	Controls map[driver.ControlID]int32 `json:"controls,omitempty"`
+}
+
+// ParseSceneProfile reads a profile written as "fps=10,bitrate=250000,grayscale,denoise",
+// the format of the profiles in the .env file. The other integer fields are
+// camera controls, like "exposure_auto=1,exposure=300", the unknown ones are rejected.
+func ParseSceneProfile(s string) (SceneProfile, error) {
+	var profile SceneProfile
+	for _, field := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		var err error
+		switch key {
+		case "":
+		case "fps":
+			var fps float64
+			fps, err = strconv.ParseFloat(value, 32)
+			profile.FrameRate = float32(fps)
+		case "bitrate":
+			profile.BitRate, err = strconv.Atoi(value)
+		case "grayscale":
+			profile.Grayscale = true
+		case "denoise":
+			profile.Denoise = true
+		default:
+			var control int64
+			control, err = strconv.ParseInt(value, 10, 32)
+			if profile.Controls == nil {
+				profile.Controls = make(map[driver.ControlID]int32)
+			}
+			profile.Controls[driver.ControlID(key)] = int32(control)
+		}
+		if err != nil {
+			return SceneProfile{}, fmt.Errorf("%w: %q", errInvalidProfile, field)
+		}
+	}
+	if err := profile.Validate(); err != nil {
+		return SceneProfile{}, err
+	}
+	return profile, nil
+}
+
+// Validate checks the rates and that the controls are known
+func (profile SceneProfile) Validate() error {
+	if profile.FrameRate < 0 || profile.BitRate < 0 {
+		return errInvalidProfile
+	}
+	for id := range profile.Controls {
+		if !knownControl(id) {
+			return fmt.Errorf("%w: unknown control %q", errInvalidProfile, id)
+		}
+	}
+	return nil
+}
+
+func knownControl(id driver.ControlID) bool {
+	for _, known := range driver.ControlIDs {
+		if id == known {
+			return true
+		}
+	}
+	return false
+}
+
+// String writes the profile in the format read by ParseSceneProfile
+func (profile SceneProfile) String() string {
+	var fields []string
+	if profile.FrameRate > 0 {
+		fields = append(fields, "fps="+strconv.FormatFloat(float64(profile.FrameRate), 'g', -1, 32))
+	}
+	if profile.BitRate > 0 {
+		fields = append(fields, "bitrate="+strconv.Itoa(profile.BitRate))
+	}
+	if profile.Grayscale {
+		fields = append(fields, "grayscale")
+	}
+	if profile.Denoise {
+		fields = append(fields, "denoise")
+	}
+	controls := make([]string, 0, len(profile.Controls))
+	for id, value := range profile.Controls {
+		controls = append(controls, string(id)+"="+strconv.Itoa(int(value)))
+	}
+	sort.Strings(controls)
+	fields = append(fields, controls...)
+	return strings.Join(fields, ",")
+}
+
+// DayNightConfig switches the video between the day and night profiles
+// following the brightness of the scene. The thresholds are average luma
+// values from 0 to 255, 0 uses the defaults of video.DayNightOptions.
+type DayNightConfig struct {
+	NightThreshold float64
+	DayThreshold   float64
+	// HoldTime is how long the brightness must stay past a threshold before
+	// the profile changes
+	HoldTime time.Duration
+	Day      SceneProfile
+	Night    SceneProfile
+}
+
+// DayNightEvent is emitted when the scene turns to day or night
+type DayNightEvent struct {
+	Scene string
+	Luma  float64
+	Time  time.Time
+}
+
+// SetDayNight enables the day and night profiles, nil disables them. The
+// profiles, the thresholds and the hold time are applied at once.
+func (pirtc *PiRTC) SetDayNight(config *DayNightConfig) {
+	pirtc.mu.Lock()
+	pirtc.dayNight = config
+	pirtc.dayNightAnalyzer.SetOptions(pirtc.dayNightOptions())
+	pirtc.mu.Unlock()
+	pirtc.signalScene()
+}
+
+// DayNight returns the current configuration, nil when disabled, and the
+// current scene
+func (pirtc *PiRTC) DayNight() (*DayNightConfig, string) {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	if pirtc.dayNight == nil {
+		return nil, pirtc.scene.String()
+	}
+	config := *pirtc.dayNight
+	return &config, pirtc.scene.String()
+}
+
+// DayNightEvents returns the stream of scene changes, the events are dropped
+// when nobody reads them
+func (pirtc *PiRTC) DayNightEvents() <-chan DayNightEvent {
+	return pirtc.dayNightEvents
+}
+
+// dayNightOptions must be called with pirtc.mu held
+func (pirtc *PiRTC) dayNightOptions() video.DayNightOptions {
+	opts := video.DayNightOptions{
+		Interval: dayNightInterval,
+		OnChange: pirtc.onSceneChange,
+	}
+	if pirtc.dayNight != nil {
+		opts.NightThreshold = pirtc.dayNight.NightThreshold
+		opts.DayThreshold = pirtc.dayNight.DayThreshold
+		opts.HoldTime = pirtc.dayNight.HoldTime
+	}
+	return opts
+}
+
+// dayNightTransform must be called with pirtc.mu held. The brightness is
+// always measured so that the profiles can be enabled while the camera is
+// open, the filters are switched by the profiles. The analyzer is shared by
+// the readers, a reconfigured camera keeps its scene.
+func (pirtc *PiRTC) dayNightTransform() video.TransformFunc {
+	return video.Merge(
+		pirtc.dayNightAnalyzer.Transform(),
+		switchable(&pirtc.denoise, video.Denoise(nightDenoiseStrength)),
+		switchable(&pirtc.grayscale, video.Grayscale()),
+	)
+}
+
+// onSceneChange is called from the reading goroutine of the camera, the
+// profile is applied by runSceneProfiles
+func (pirtc *PiRTC) onSceneChange(scene video.Scene, luma float64) {
+	pirtc.mu.Lock()
+	pirtc.scene = scene
+	pirtc.sceneLuma = luma
+	pirtc.mu.Unlock()
+
+	select {
+	case pirtc.dayNightEvents <- DayNightEvent{Scene: scene.String(), Luma: luma, Time: time.Now()}:
+	default:
+		log.Printf("[Scene]: %v event dropped, nobody is listening\n", scene)
+	}
+	pirtc.signalScene()
+}
+
+func (pirtc *PiRTC) signalScene() {
+	select {
+	case pirtc.sceneSignal <- struct{}{}:
+	default:
+	}
+}
+
+// runSceneProfiles applies the profile of the last scene, the camera can't be
+// reconfigured from its own reading goroutine
+func (pirtc *PiRTC) runSceneProfiles() {
+	for {
+		select {
+		case <-pirtc.sceneSignal:
+			if err := pirtc.applySceneProfile(); err != nil {
+				log.Printf("[scene profile error]: %v\n", err)
+			}
+		case <-pirtc.closed:
+			return
+		}
+	}
+}
+
+func (pirtc *PiRTC) applySceneProfile() error {
+	pirtc.reconfigureMu.Lock()
+	defer pirtc.reconfigureMu.Unlock()
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+
+	var profile SceneProfile
+	if pirtc.dayNight != nil {
+		switch pirtc.scene {
+		case video.SceneDay:
+			profile = pirtc.dayNight.Day
+		case video.SceneNight:
+			profile = pirtc.dayNight.Night
+		}
+	}
+
+	pirtc.grayscale.Store(profile.Grayscale)
+	pirtc.denoise.Store(profile.Denoise)
+	if pirtc.sceneBitRate != profile.BitRate {
+		pirtc.sceneBitRate = profile.BitRate
+		bitRate := profile.BitRate
+		if bitRate == 0 {
+			bitRate = pirtc.params.BitRate
+		}
+		if pirtc.stream != nil {
+			for _, track := range pirtc.stream.GetVideoTracks() {
+				if err := track.(*mediadevices.VideoTrack).SetBitRate(bitRate); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := pirtc.applySceneControls(profile.Controls); err != nil {
+		return err
+	}
+	pirtc.sceneFrameRate = profile.FrameRate
+	return pirtc.reconfigureCamera()
+}
+
+// switchable applies fn to the frames while enabled is set
+func switchable(enabled *atomic.Bool, fn video.TransformFunc) video.TransformFunc {
+	return func(r video.Reader) video.Reader {
+		filtered := fn(r)
+		return video.ReaderFunc(func() (image.Image, func(), error) {
+			if enabled.Load() {
+				return filtered.Read()
+			}
+			return r.Read()
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package pirtc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/driver"
+)
+
+func TestParseSceneProfile(t *testing.T) {
+	testCases := map[string]struct {
+		profile  string
+		expected SceneProfile
+		// canonical is the profile written by String, when it differs
+		canonical string
+	}{
+		"Empty": {
+			profile: "",
+		},
+		"Rates": {
+			profile:  "fps=7.5,bitrate=250000",
+			expected: SceneProfile{FrameRate: 7.5, BitRate: 250000},
+		},
+		"Flags": {
+			profile:  "grayscale,denoise",
+			expected: SceneProfile{Grayscale: true, Denoise: true},
+		},
+		"Controls": {
+			profile: "fps=10,grayscale,exposure=300,exposure_auto=1",
+			expected: SceneProfile{
+				FrameRate: 10,
+				Grayscale: true,
+				Controls: map[driver.ControlID]int32{
+					driver.ControlExposureAuto: 1,
+					driver.ControlExposure:     300,
+				},
+			},
+		},
+		"Unordered": {
+			profile: " gain=-2 , denoise,fps=15,",
+			expected: SceneProfile{
+				FrameRate: 15,
+				Denoise:   true,
+				Controls:  map[driver.ControlID]int32{driver.ControlGain: -2},
+			},
+			canonical: "fps=15,denoise,gain=-2",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			profile, err := ParseSceneProfile(testCase.profile)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(profile, testCase.expected) {
+				t.Errorf("Expected %+v, got %+v", testCase.expected, profile)
+			}
+
+			canonical := testCase.canonical
+			if canonical == "" {
+				canonical = testCase.profile
+			}
+			if s := profile.String(); s != canonical {
+				t.Errorf("Expected %q, got %q", canonical, s)
+			}
+			again, err := ParseSceneProfile(profile.String())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(again, profile) {
+				t.Errorf("Expected the profile to round trip, got %+v", again)
+			}
+		})
+	}
+}
+
+func TestParseSceneProfileInvalid(t *testing.T) {
+	testCases := map[string]string{
+		"FrameRate":       "fps=fast",
+		"NegativeRate":    "fps=-1",
+		"BitRate":         "bitrate=1.5",
+		"NegativeBitRate": "bitrate=-100",
+		"ControlValue":    "exposure=long",
+		"ControlOverflow": "gain=4294967296",
+		"UnknownControl":  "shutter=3",
+	}
+
+	for name, profile := range testCases {
+		if _, err := ParseSceneProfile(profile); !errors.Is(err, errInvalidProfile) {
+			t.Errorf("%s: expected %v, got %v", name, errInvalidProfile, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package pirtc
+
+import (
+	"image"
+
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+// GeometryConfig corrects the mounting of the camera, it is applied before
+// anything else so that the masks and the overlay follow the corrected frame
+type GeometryConfig struct {
+	// Crop is the part of the sensor kept, in pixels, an empty rectangle keeps
+	// the whole frame
+	Crop image.Rectangle
+	// Zoom magnifies the center of the frame, 1 or less disables it
+	Zoom           float64
+	FlipHorizontal bool
+	FlipVertical   bool
+	// Rotation is clockwise, in multiples of 90 degrees
+	Rotation video.Rotation
+}
+
+// SetGeometry changes the rotation, flip, crop and zoom of the video, nil
+// removes them. The geometry is applied the next time the camera is opened,
+// a camera passed through is decoded right away.
+func (pirtc *PiRTC) SetGeometry(config *GeometryConfig) {
+	pirtc.mu.Lock()
+	pirtc.geometry = config
+	pirtc.mu.Unlock()
+	// the compressed frames can't be corrected
+	pirtc.decodeCamera()
+}
+
+func (config *GeometryConfig) transform() video.TransformFunc {
+	var transforms []video.TransformFunc
+	if !config.Crop.Empty() {
+		transforms = append(transforms, video.Crop(config.Crop))
+	}
+	if config.Zoom > 1 {
+		transforms = append(transforms, video.Zoom(config.Zoom))
+	}
+	if config.FlipHorizontal || config.FlipVertical {
+		transforms = append(transforms, video.Flip(config.FlipHorizontal, config.FlipVertical))
+	}
+	if config.Rotation%360 != 0 {
+		transforms = append(transforms, video.Rotate(config.Rotation))
+	}
+	if len(transforms) == 0 {
+		return nil
+	}
+	return video.Merge(transforms...)
+}
+
+// geometryTransform must be called with pirtc.mu held, it returns nil without
+// geometry
+func (pirtc *PiRTC) geometryTransform() video.TransformFunc {
+	if pirtc.geometry == nil {
+		return nil
+	}
+	return pirtc.geometry.transform()
+}
@@ -0,0 +1,106 @@
+package pirtc
+
+import (
+	"log"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/driver"
+)
+
+const (
+	// time between two scans of the cameras plugged
+	cameraRescanInterval = 2 * time.Second
+	// time between two attempts to reopen a lost camera, doubled after each
+	// failure up to cameraRecoveryMaxBackoff
+	cameraRecoveryMinBackoff = 500 * time.Millisecond
+	cameraRecoveryMaxBackoff = 30 * time.Second
+)
+
+// watchCameraLoss must be called with pirtc.mu held, it recovers the video
+// track when its camera is unplugged or fails. The viewers and the recordings
+// see the last frame until the camera is back.
+func (pirtc *PiRTC) watchCameraLoss(track *mediadevices.VideoTrack) {
+	drivers := driver.GetManager().Query(driver.FilterID(track.ID()))
+	if len(drivers) > 0 {
+		pirtc.cameraLabel = drivers[0].Info().Label
+	}
+	track.OnSourceLost(func(err error) {
+		log.Printf("[Camera]: lost: %v\n", err)
+		pirtc.recoverCamera(track)
+	})
+}
+
+// recoverCamera reopens the camera of track with backoff, until it succeeds
+// or the stream is disabled. The camera with the same label is preferred, any
+// other camera is used otherwise.
+func (pirtc *PiRTC) recoverCamera(track *mediadevices.VideoTrack) {
+	backoff := cameraRecoveryMinBackoff
+	for attempt := 1; ; attempt++ {
+		time.Sleep(backoff)
+
+		pirtc.mu.Lock()
+		if !pirtc.streams(track) {
+			pirtc.mu.Unlock()
+			return
+		}
+		mode := pirtc.cameraMode()
+		label := pirtc.cameraLabel
+		pirtc.mu.Unlock()
+
+		for _, d := range cameraCandidates(label) {
+			if err := track.ReplaceDriver(d, mode.constraints); err != nil {
+				log.Printf("[camera recovery error]: %s: %v\n", d.Info().Label, err)
+				continue
+			}
+
+			pirtc.mu.Lock()
+			pirtc.cameraLabel = d.Info().Label
+			pirtc.openedMode = mode
+			pirtc.applyCameraControls()
+			pirtc.mu.Unlock()
+			log.Printf("[Camera]: recovered on %s after %d attempts\n", d.Info().Label, attempt)
+			return
+		}
+
+		backoff *= 2
+		if backoff > cameraRecoveryMaxBackoff {
+			backoff = cameraRecoveryMaxBackoff
+		}
+	}
+}
+
+// streams must be called with pirtc.mu held, it tells if track belongs to the
+// open stream
+func (pirtc *PiRTC) streams(track *mediadevices.VideoTrack) bool {
+	if pirtc.stream == nil {
+		return false
+	}
+	for _, t := range pirtc.stream.GetVideoTracks() {
+		if t == mediadevices.Track(track) {
+			return true
+		}
+	}
+	return false
+}
+
+// cameraCandidates returns the cameras registered to the manager, the ones
+// with label first
+func cameraCandidates(label string) []driver.Driver {
+	cameras := driver.GetManager().Query(driver.FilterAnd(
+		driver.FilterVideoRecorder(),
+		driver.FilterDeviceType(driver.Camera),
+	))
+	candidates := make([]driver.Driver, 0, len(cameras))
+	for _, d := range cameras {
+		if d.Info().Label == label {
+			candidates = append(candidates, d)
+		}
+	}
+	for _, d := range cameras {
+		if d.Info().Label != label {
+			candidates = append(candidates, d)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,252 @@
+package pirtc
+
+import (
+	"errors"
+	"log"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/webrtc/v3"
+)
+
+var (
+	errNoOfferHandler = errors.New("NO OFFER HANDLER")
+	errOfferPending   = errors.New("CAMERA OFFER PENDING")
+)
+
+// OnOffer sets the handler receiving the offers made by the camera, the
+// handler forwards them to the viewer which replies through ApplyAnswer
+func (pirtc *PiRTC) OnOffer(handler func(uuid string, offerSD webrtc.SessionDescription)) {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	pirtc.onOffer = handler
+}
+
+// Offer makes the camera the offerer: a sendonly offer is sent to the viewer
+// through the OnOffer handler and its reply is applied with ApplyAnswer. An
+// existing session of the viewer is renegotiated instead.
+//
+// When the viewer offers at the same time, a session which was never
+// established is replaced by the one answering the viewer. The offer of an
+// established session can't be rolled back by pion, it stands: the offer of
+// the viewer fails and the viewer offers again once it answered the camera.
+func (pirtc *PiRTC) Offer(uuid string) error {
+	s, ok := pirtc.peers.session(uuid)
+	if !ok {
+		return errUserNotFound
+	}
+	s.negotiation.Lock()
+	defer s.negotiation.Unlock()
+
+	if s.conn != nil && s.conn.ConnectionState() != webrtc.PeerConnectionStateClosed {
+		return pirtc.renegotiate(s, false)
+	}
+
+	peer, err := pirtc.openSession(uuid)
+	if err != nil {
+		return err
+	}
+	s.conn = peer
+	if err := pirtc.renegotiate(s, false); err != nil {
+		pirtc.peers.drop(uuid, peer, "negotiation failed")
+		return err
+	}
+	return nil
+}
+
+// Renegotiate makes a new offer to a connected viewer, with iceRestart the
+// offer carries new ICE credentials. The ICE of a failed connection is
+// restarted this way before the viewer is dropped.
+func (pirtc *PiRTC) Renegotiate(uuid string, iceRestart bool) error {
+	s, ok := pirtc.peers.session(uuid)
+	if !ok || s.conn == nil {
+		return errUserNotFound
+	}
+	s.negotiation.Lock()
+	defer s.negotiation.Unlock()
+
+	return pirtc.renegotiate(s, iceRestart)
+}
+
+// ApplyAnswer applies the answer of a viewer to the last offer of the camera
+func (pirtc *PiRTC) ApplyAnswer(uuid string, answerSD webrtc.SessionDescription) error {
+	s, ok := pirtc.peers.session(uuid)
+	if !ok || s.conn == nil {
+		return errUserNotFound
+	}
+	s.negotiation.Lock()
+	defer s.negotiation.Unlock()
+
+	return s.conn.SetRemoteDescription(answerSD)
+}
+
+// renegotiate must be called with the negotiation lock of s held
+func (pirtc *PiRTC) renegotiate(s session, iceRestart bool) error {
+	pirtc.mu.Lock()
+	handler := pirtc.onOffer
+	pirtc.mu.Unlock()
+	if handler == nil {
+		return errNoOfferHandler
+	}
+
+	offer, err := createOffer(s.conn, &webrtc.OfferOptions{ICERestart: iceRestart})
+	if err != nil {
+		return err
+	}
+	log.Printf("[Peer - %s]: offer sent (ICE restart: %v)\n", s.uuid, iceRestart)
+	handler(s.uuid, *offer)
+	return nil
+}
+
+// renegotiateAll makes a new offer to every connected viewer, it is used
+// after the tracks of the stream changed
+func (pirtc *PiRTC) renegotiateAll(update func(s session) error) {
+	for _, s := range pirtc.peers.sessions() {
+		s.negotiation.Lock()
+		err := update(s)
+		if err == nil {
+			err = pirtc.renegotiate(s, false)
+		}
+		s.negotiation.Unlock()
+		if err != nil {
+			log.Printf("[Peer - %s]: renegotiation failed: %v\n", s.uuid, err)
+		}
+	}
+}
+
+// EnableAudio adds the microphone to the stream, connected viewers receive it
+// after a renegotiation
+func (pirtc *PiRTC) EnableAudio() error {
+	pirtc.mu.Lock()
+	if pirtc.audioEnabled {
+		pirtc.mu.Unlock()
+		return nil
+	}
+	pirtc.audioEnabled = true
+	pirtc.mu.Unlock()
+
+	track, err := pirtc.addMicrophone()
+	if err != nil {
+		pirtc.mu.Lock()
+		pirtc.audioEnabled = false
+		pirtc.mu.Unlock()
+		return err
+	}
+	if track == nil {
+		// the microphone is added when the camera opens
+		return nil
+	}
+	log.Println("Audio Enabled")
+
+	pirtc.renegotiateAll(func(s session) error {
+		return addSendonlyTrack(s.conn, track)
+	})
+	return nil
+}
+
+// addMicrophone adds the microphone to the stream. It is opened without
+// pirtc.mu held, the other calls don't wait for the audio device. It returns
+// nil when there is no stream, or when the audio was disabled or added to
+// the stream in the meantime.
+func (pirtc *PiRTC) addMicrophone() (*mediadevices.AudioTrack, error) {
+	var opened *mediadevices.AudioTrack
+	for {
+		pirtc.mu.Lock()
+		if !pirtc.audioEnabled || pirtc.stream == nil || len(pirtc.stream.GetAudioTracks()) > 0 {
+			pirtc.mu.Unlock()
+			if opened != nil {
+				opened.Close()
+			}
+			return nil, nil
+		}
+		if opened != nil && pirtc.microphone == nil {
+			pirtc.microphone = opened
+		} else if opened != nil {
+			// the sound detection opened it in the meantime
+			if err := opened.Close(); err != nil {
+				log.Printf("[Sound error]: %v\n", err)
+			}
+		}
+		if pirtc.microphone != nil {
+			pirtc.microphoneUsers++
+			track := pirtc.microphone
+			pirtc.stream.AddTrack(track)
+			pirtc.mu.Unlock()
+			return track, nil
+		}
+		selector, transform := pirtc.codecSelector, pirtc.audioProcessingTransform()
+		pirtc.mu.Unlock()
+
+		var err error
+		if opened, err = openMicrophone(selector, transform); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// DisableAudio removes the microphone from the stream and from the connected
+// viewers
+func (pirtc *PiRTC) DisableAudio() error {
+	pirtc.mu.Lock()
+	if !pirtc.audioEnabled {
+		pirtc.mu.Unlock()
+		return nil
+	}
+	pirtc.audioEnabled = false
+	var tracks []mediadevices.Track
+	if pirtc.stream != nil {
+		tracks = pirtc.stream.GetAudioTracks()
+		for _, track := range tracks {
+			pirtc.stream.RemoveTrack(track)
+		}
+	}
+	pirtc.mu.Unlock()
+
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	pirtc.renegotiateAll(func(s session) error {
+		for _, sender := range s.conn.GetSenders() {
+			for _, track := range tracks {
+				if sender.Track() == track {
+					if err := s.conn.RemoveTrack(sender); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+
+	// the sound detection may still listen to the microphone
+	pirtc.mu.Lock()
+	for range tracks {
+		pirtc.releaseMicrophone()
+	}
+	pirtc.mu.Unlock()
+	log.Println("Audio Disabled")
+	return nil
+}
+
+func addSendonlyTrack(peer *webrtc.PeerConnection, track mediadevices.Track) error {
+	_, err := peer.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionSendonly,
+	})
+	return err
+}
+
+func createOffer(peer *webrtc.PeerConnection, options *webrtc.OfferOptions) (*webrtc.SessionDescription, error) {
+	offerSD, err := peer.CreateOffer(options)
+	if err != nil {
+		return nil, err
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(peer)
+
+	err = peer.SetLocalDescription(offerSD)
+	if err != nil {
+		return nil, err
+	}
+	<-gatherComplete
+
+	return peer.LocalDescription(), nil
+}
@@ -0,0 +1,248 @@
+package pirtc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// newTestPiRTC returns a PiRTC negotiating the sessions of its registry, the
+// offers of the camera are sent to the returned channel
+func newTestPiRTC(t *testing.T) (*PiRTC, <-chan webrtc.SessionDescription) {
+	pirtc := &PiRTC{peers: newPeerRegistry(testGracePeriod, nil)}
+	pirtc.peers.onICEFailed = func(uuid string) error {
+		return pirtc.Renegotiate(uuid, true)
+	}
+	offers := make(chan webrtc.SessionDescription, 8)
+	pirtc.OnOffer(func(uuid string, offerSD webrtc.SessionDescription) {
+		offers <- offerSD
+	})
+	return pirtc, offers
+}
+
+// newTestCamera registers a viewer whose session sends a video track
+func newTestCamera(t *testing.T, pirtc *PiRTC, uuid string) *webrtc.PeerConnection {
+	conn := newTestConnection(t)
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "video", "camera")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := conn.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := pirtc.NewUser(uuid); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := pirtc.peers.attach(uuid, conn); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return conn
+}
+
+// newTestViewer returns a peer connection receiving the video of the camera
+func newTestViewer(t *testing.T) *webrtc.PeerConnection {
+	conn := newTestConnection(t)
+	if _, err := conn.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return conn
+}
+
+// answerAs makes viewer answer offerSD
+func answerAs(t *testing.T, viewer *webrtc.PeerConnection, offerSD webrtc.SessionDescription) webrtc.SessionDescription {
+	t.Helper()
+	answer, err := answerOffer(viewer, offerSD)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return *answer
+}
+
+// nextOffer returns the next offer of the camera, it fails the test when none comes
+func nextOffer(t *testing.T, offers <-chan webrtc.SessionDescription) webrtc.SessionDescription {
+	t.Helper()
+	select {
+	case offer := <-offers:
+		return offer
+	case <-time.After(time.Second):
+		t.Fatal("Expected an offer")
+		return webrtc.SessionDescription{}
+	}
+}
+
+func expectNoOffer(t *testing.T, offers <-chan webrtc.SessionDescription) {
+	t.Helper()
+	select {
+	case offer := <-offers:
+		t.Errorf("Expected no offer, got %s", offer.SDP)
+	case <-time.After(3 * testGracePeriod):
+	}
+}
+
+// nextEventOf returns the next event of type eventType, the events before it
+// are skipped
+func nextEventOf(t *testing.T, r *peerRegistry, eventType PeerEventType) PeerEvent {
+	t.Helper()
+	for {
+		if event := nextEvent(t, r); event.Type == eventType {
+			return event
+		}
+	}
+}
+
+func iceUfrag(sdp string) string {
+	for _, line := range strings.Split(sdp, "\r\n") {
+		if strings.HasPrefix(line, "a=ice-ufrag:") {
+			return strings.TrimPrefix(line, "a=ice-ufrag:")
+		}
+	}
+	return ""
+}
+
+func TestRenegotiateICERestart(t *testing.T) {
+	pirtc, offers := newTestPiRTC(t)
+	camera := newTestCamera(t, pirtc, "viewer")
+	viewer := newTestViewer(t)
+
+	// the camera offers on the existing session
+	if err := pirtc.Offer("viewer"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	offer := nextOffer(t, offers)
+	if err := pirtc.ApplyAnswer("viewer", answerAs(t, viewer, offer)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if state := camera.SignalingState(); state != webrtc.SignalingStateStable {
+		t.Fatalf("Expected the session to be stable, got %s", state)
+	}
+
+	// the viewer comes back under the same UUID and renegotiates the session
+	if err := pirtc.NewUser("viewer"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	viewerOffer, err := createOffer(viewer, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	answer, err := pirtc.Answer("viewer", *viewerOffer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := viewer.SetRemoteDescription(*answer); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s, _ := pirtc.peers.session("viewer"); s.conn != camera {
+		t.Fatal("Expected the session to keep its peer connection")
+	}
+
+	// the first failure restarts the ICE with a single offer
+	pirtc.peers.handleICEState("viewer", camera, webrtc.ICEConnectionStateFailed)
+	restart := nextOffer(t, offers)
+	if ufrag := iceUfrag(restart.SDP); ufrag == "" || ufrag == iceUfrag(offer.SDP) {
+		t.Errorf("Expected new ICE credentials, got %q", ufrag)
+	}
+	nextEventOf(t, pirtc.peers, PeerDisconnected)
+	if err := pirtc.ApplyAnswer("viewer", answerAs(t, viewer, restart)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectNoOffer(t, offers)
+
+	// the second failure drops the viewer
+	pirtc.peers.handleICEState("viewer", camera, webrtc.ICEConnectionStateFailed)
+	if event := nextEventOf(t, pirtc.peers, PeerDropped); event.Reason != "failed" {
+		t.Errorf("Expected the viewer to be dropped after a failure, got %q", event.Reason)
+	}
+	if _, ok := pirtc.peers.session("viewer"); ok {
+		t.Error("Expected the viewer to be removed")
+	}
+	expectNoOffer(t, offers)
+}
+
+func TestRenegotiateICERestartErrors(t *testing.T) {
+	testCases := map[string]struct {
+		// answer replies to the restart offer, the viewer doesn't reconnect
+		// within the grace period then
+		answer bool
+	}{
+		"NoOfferHandler": {},
+		"NotReconnected": {answer: true},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			pirtc, offers := newTestPiRTC(t)
+			camera := newTestCamera(t, pirtc, "viewer")
+			viewer := newTestViewer(t)
+			if err := pirtc.Offer("viewer"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := pirtc.ApplyAnswer("viewer", answerAs(t, viewer, nextOffer(t, offers))); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !testCase.answer {
+				pirtc.OnOffer(nil)
+			}
+
+			pirtc.peers.handleICEState("viewer", camera, webrtc.ICEConnectionStateFailed)
+			if testCase.answer {
+				if err := pirtc.ApplyAnswer("viewer", answerAs(t, viewer, nextOffer(t, offers))); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			nextEventOf(t, pirtc.peers, PeerDropped)
+			if _, ok := pirtc.peers.session("viewer"); ok {
+				t.Error("Expected the viewer to be removed")
+			}
+		})
+	}
+}
+
+func TestOfferGlare(t *testing.T) {
+	pirtc, offers := newTestPiRTC(t)
+	camera := newTestCamera(t, pirtc, "viewer")
+	viewer := newTestViewer(t)
+	if err := pirtc.Offer("viewer"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := pirtc.ApplyAnswer("viewer", answerAs(t, viewer, nextOffer(t, offers))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// both sides offer at the same time, the offer of the camera stands
+	if err := pirtc.Offer("viewer"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	offer := nextOffer(t, offers)
+	viewerOffer, err := createOffer(viewer, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := pirtc.Answer("viewer", *viewerOffer); err != errOfferPending {
+		t.Fatalf("Expected %v, got %v", errOfferPending, err)
+	}
+	if pending := camera.PendingLocalDescription(); pending == nil || pending.SDP != offer.SDP {
+		t.Error("Expected the offer of the camera to be kept")
+	}
+	if s, _ := pirtc.peers.session("viewer"); s.conn != camera {
+		t.Error("Expected the session to keep its peer connection")
+	}
+	expectNoOffer(t, offers)
+
+	// the viewer answers the camera with another peer connection, pion can't
+	// roll back its own offer either
+	viewer = newTestViewer(t)
+	if err := pirtc.ApplyAnswer("viewer", answerAs(t, viewer, offer)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	viewerOffer, err = createOffer(viewer, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := pirtc.Answer("viewer", *viewerOffer); err != nil {
+		t.Errorf("Expected the offer of the viewer to be answered, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package pirtc
+
+import (
+	"image"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+// overlayMargin is the distance in pixels between the overlay and the frame
+// edges
+const overlayMargin = 8
+
+// OverlayConfig is burned into the video of the camera: the stream, the
+// recordings and the snapshots
+type OverlayConfig struct {
+	// TimestampLayout is the time.Format layout of the timestamp, the
+	// timestamp is hidden when it is empty
+	TimestampLayout string
+	// Location is the timezone of the timestamp, nil is the local time
+	Location *time.Location
+	// Lines are drawn under the timestamp, the camera name and location
+	Lines []string
+	Logo  image.Image
+}
+
+// SetOverlay changes the overlay of the video, nil removes it. The overlay is
+// applied the next time the camera is opened.
+func (pirtc *PiRTC) SetOverlay(config *OverlayConfig) {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	pirtc.overlay = config
+}
+
+func (config *OverlayConfig) transform() video.TransformFunc {
+	location := config.Location
+	if location == nil {
+		location = time.Local
+	}
+
+	// the timestamp is only formatted once per second
+	var lastSecond int64
+	lines := make([]string, 0, len(config.Lines)+1)
+	text := func(t time.Time) []string {
+		if config.TimestampLayout == "" {
+			return config.Lines
+		}
+		if second := t.Unix(); second != lastSecond || len(lines) == 0 {
+			lastSecond = second
+			lines = append(lines[:0], t.In(location).Format(config.TimestampLayout))
+			lines = append(lines, config.Lines...)
+		}
+		return lines
+	}
+
+	return video.Overlay(video.OverlayOptions{
+		Text:         text,
+		TextPosition: video.OverlayBottomLeft,
+		Margin:       overlayMargin,
+		Logo:         config.Logo,
+		LogoPosition: video.OverlayTopRight,
+	})
+}
+
+// overlayTransform must be called with pirtc.mu held, it returns nil without
+// overlay
+func (pirtc *PiRTC) overlayTransform() video.TransformFunc {
+	if pirtc.overlay == nil {
+		return nil
+	}
+	return pirtc.overlay.transform()
+}
+
+// overlayStampsTime tells if the overlay of the open camera draws the time on
+// the frames
+func (pirtc *PiRTC) overlayStampsTime() bool {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	return pirtc.overlayStamped
+}
@@ -0,0 +1,184 @@
+package pirtc
+
+import (
+	"errors"
+	"image"
+	"log"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+var (
+	errPassthroughFormat = errors.New("VIDEO FORMAT CAN'T BE PASSED THROUGH")
+	errCompressedVideo   = errors.New("THE SNAPSHOTS NEED THE DECODED VIDEO")
+)
+
+// passthroughMimeTypes are the codecs of the formats which can be passed
+// through
+var passthroughMimeTypes = map[frame.Format]string{
+	frame.FormatH264:  "video/H264",
+	frame.FormatMJPEG: "video/MJPEG",
+}
+
+// SetVideoPassthrough records the video of the cameras which compress it in
+// format without decoding and encoding it again, which spares the CPU of the
+// Pi. The H.264 is sent to the viewers as it comes too, the MJPEG is decoded
+// for them. An empty format decodes the camera.
+//
+// The camera is opened in format the next time it opens, unless it can't or
+// the geometry or the privacy masks have to be applied to the frames. The
+// overlay, the PTZ view and the night filters don't apply to the compressed
+// video. The recordings are saved in Matroska, see VideoExtension, and the
+// H.264 recordings have no previews.
+func (pirtc *PiRTC) SetVideoPassthrough(format frame.Format) error {
+	if _, ok := passthroughMimeTypes[format]; !ok && format != "" {
+		return errPassthroughFormat
+	}
+
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+
+	pirtc.videoPassthrough = format
+	opts := []mediadevices.CodecSelectorOption{
+		mediadevices.WithVideoEncoders(&pirtc.params),
+		mediadevices.WithAudioEncoders(&pirtc.audioParams),
+	}
+	if format != "" {
+		opts = append(opts, mediadevices.WithPassthrough(format))
+	}
+	pirtc.codecSelector = mediadevices.NewCodecSelector(opts...)
+	return nil
+}
+
+// VideoExtension is the extension of the recordings, .mkv when the video is
+// passed through, WebM can't hold H.264 and MJPEG
+func (pirtc *PiRTC) VideoExtension() string {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	if pirtc.videoPassthrough != "" {
+		return ".mkv"
+	}
+	return ".webM"
+}
+
+// passthroughAllowed must be called with pirtc.mu held, it tells if the next
+// stream may be opened compressed
+func (pirtc *PiRTC) passthroughAllowed() bool {
+	if pirtc.videoPassthrough == "" {
+		return false
+	}
+	if pirtc.geometryTransform() != nil {
+		log.Println("[passthrough]: the geometry needs the decoded frames")
+		return false
+	}
+	if len(pirtc.privacyMaskConfig) > 0 {
+		log.Println("[passthrough]: the privacy masks need the decoded frames")
+		return false
+	}
+	return true
+}
+
+// decodeCamera reopens the camera decoded when it is passed through and the
+// geometry or the privacy masks now need the decoded frames. The viewers of
+// the H.264 can't switch to the encoded video, they are dropped and connect
+// again. The recording of the compressed video stops.
+func (pirtc *PiRTC) decodeCamera() {
+	pirtc.mu.Lock()
+	if pirtc.stream == nil || pirtc.videoFormat == "" || pirtc.passthroughAllowed() {
+		pirtc.mu.Unlock()
+		return
+	}
+	format := pirtc.videoFormat
+	pirtc.mu.Unlock()
+
+	// the sessions are dropped without pirtc.mu, they release the stream
+	if format == frame.FormatH264 {
+		for _, session := range pirtc.peers.sessions() {
+			pirtc.peers.drop(session.uuid, session.conn, "camera decoded")
+		}
+	}
+
+	pirtc.reconfigureMu.Lock()
+	defer pirtc.reconfigureMu.Unlock()
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	if pirtc.stream == nil || pirtc.videoFormat == "" {
+		return
+	}
+	pirtc.videoFormat = ""
+	// the transforms are installed before the decoded frames come, none of
+	// them is sent unmasked
+	for _, track := range pirtc.stream.GetVideoTracks() {
+		pirtc.transformVideo(track.(*mediadevices.VideoTrack))
+	}
+	if err := pirtc.reconfigureCamera(); err != nil {
+		log.Printf("[passthrough error]: %v\n", err)
+		return
+	}
+	log.Println("[passthrough]: the camera is decoded")
+}
+
+// decodedFrames decodes the frames passed through, the H.264 frames can't be
+// decoded
+func decodedFrames(r video.Reader) video.Reader {
+	return video.ReaderFunc(func() (image.Image, func(), error) {
+		img, release, err := r.Read()
+		if err != nil {
+			return nil, func() {}, err
+		}
+		encoded, ok := img.(*frame.Encoded)
+		if !ok {
+			return img, release, nil
+		}
+		decoded := encoded.Decoded()
+		if decoded == nil {
+			release()
+			return nil, func() {}, errCompressedVideo
+		}
+		return decoded, release, nil
+	})
+}
+
+// recordPassthrough records the frames of the camera as they come, the stream
+// must have been opened in format
+func recordPassthrough(savePath string, videoTrack *mediadevices.VideoTrack, format frame.Format, stopChan <-chan struct{}) {
+	// the size of the video is the size of the frames of the camera
+	img, release, err := videoTrack.NewReader(false).Read()
+	if err != nil {
+		log.Printf("[record error]: %v\n", err)
+		return
+	}
+	bounds := img.Bounds()
+	release()
+
+	reader, err := videoTrack.NewEncodedReader(passthroughMimeTypes[format])
+	if err != nil {
+		log.Printf("[record error]: %v\n", err)
+		return
+	}
+	defer reader.Close()
+
+	saver := newWebmSaver()
+	defer saver.Close()
+	log.Println("Recording video...")
+	for {
+		select {
+		case <-stopChan:
+			return
+		default:
+		}
+		buffer, release, err := reader.Read()
+		if err != nil {
+			log.Printf("[record error]: %v\n", err)
+			return
+		}
+		if format == frame.FormatMJPEG {
+			saver.PushMJPEG(savePath, buffer.Data, buffer.Samples, bounds.Dx(), bounds.Dy())
+		} else {
+			saver.PushH264(savePath, buffer.Data, buffer.Samples, bounds.Dx(), bounds.Dy())
+		}
+		release()
+	}
+}
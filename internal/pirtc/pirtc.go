@@ -2,23 +2,27 @@ package pirtc
 
 import (
 	"errors"
-	"fmt"
-	"image/jpeg"
 	"log"
 	"math/rand"
-	"os"
-	"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/mediadevices"
 	"github.com/pion/webrtc/v3"
 
+	"github.com/pion/mediadevices/pkg/codec/opus"
 	"github.com/pion/mediadevices/pkg/codec/vpx"
-	_ "github.com/pion/mediadevices/pkg/driver/camera"
+	"github.com/pion/mediadevices/pkg/driver"
+	_ "github.com/pion/mediadevices/pkg/driver/audiofile"
+	"github.com/pion/mediadevices/pkg/driver/camera"
+	_ "github.com/pion/mediadevices/pkg/driver/libcamera"
+	_ "github.com/pion/mediadevices/pkg/driver/microphone"
+	_ "github.com/pion/mediadevices/pkg/driver/videofile"
 	"github.com/pion/mediadevices/pkg/frame"
-	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/io/video"
 )
 
 var defaultConfig = webrtc.Configuration{
@@ -34,8 +38,91 @@ type PiRTC struct {
 	stream           mediadevices.MediaStream
 	mediaEngine      webrtc.MediaEngine
 	params           vpx.VP8Params
-	Connections      map[string]*webrtc.PeerConnection
+	audioParams      opus.Params
+	audioEnabled     bool
+	overlay          *OverlayConfig
+	codecSelector    *mediadevices.CodecSelector
+	peers            *peerRegistry
+	onOffer          func(uuid string, offerSD webrtc.SessionDescription)
 	mu               sync.Mutex
+	// reconfigureMu serializes the reconfigurations of the open camera, it
+	// is taken before pirtc.mu
+	reconfigureMu sync.Mutex
+
+	// privacyMasks is shared by every stream so that the masks can change
+	// while the camera is running
+	privacyMasks      *video.PrivacyMasks
+	privacyMaskConfig []PrivacyMask
+	privacyMasksPath  string
+	geometry          *GeometryConfig
+
+	// ptz is shared by every stream so that the view can move while the camera
+	// is running
+	ptz            *video.PTZ
+	ptzPresets     map[string]PTZRegion
+	ptzPresetsPath string
+
+	// videoMode is nil until a mode is set, the default mode is used then.
+	// openedMode is the mode of the open camera.
+	videoMode  *VideoMode
+	openedMode VideoMode
+	// overlayStamped tells if the overlay of the open camera draws the time,
+	// the snapshots and the time-lapses aren't stamped again
+	overlayStamped bool
+	// bandwidths are the last estimates of the viewers in bit/s, the mode
+	// picked by AdaptToBandwidth was set at bandwidthModeSet
+	bandwidths       map[string]int
+	bandwidthModeSet time.Time
+
+	// videoPassthrough is the compressed format the camera is opened in when
+	// possible, videoFormat is the compressed format of the open camera. They
+	// are empty when the camera is decoded.
+	videoPassthrough frame.Format
+	videoFormat      frame.Format
+
+	// dayNight switches the scene profiles, the frame rate and the bit rate of
+	// the current profile override the defaults when they are set
+	dayNight       *DayNightConfig
+	dayNightEvents chan DayNightEvent
+	// dayNightAnalyzer measures the brightness, it keeps the scene across the
+	// readers of the camera
+	dayNightAnalyzer *video.DayNightAnalyzer
+	scene            video.Scene
+	sceneLuma        float64
+	sceneSignal      chan struct{}
+	sceneFrameRate   float32
+	sceneBitRate     int
+	grayscale        atomic.Bool
+	denoise          atomic.Bool
+
+	// cameraControls are set by the operator and applied each time the camera
+	// opens. sceneControls are set by the current scene profile over them,
+	// controlsBeforeScene keeps the values to restore when it is left.
+	cameraControls      map[driver.ControlID]int32
+	cameraControlsPath  string
+	sceneControls       map[driver.ControlID]int32
+	controlsBeforeScene map[driver.ControlID]int32
+
+	// cameraLabel is the label of the camera read, a lost camera is
+	// preferably replaced by a camera with the same label
+	cameraLabel string
+
+	// microphone is shared by the stream and the sound detection, it is
+	// opened by its first user and closed by the last one
+	microphone      *mediadevices.AudioTrack
+	microphoneUsers int
+	audioProcessing *AudioProcessingConfig
+
+	// soundDetection listens to the microphone until stopSoundDetection is
+	// closed
+	soundDetection     *SoundDetectionConfig
+	stopSoundDetection chan struct{}
+	soundEvents        chan SoundEvent
+	audioLevels        chan audio.Level
+
+	// closed stops the background goroutines, like the camera rescans
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 func Init() (*PiRTC, error) {
@@ -45,67 +132,160 @@ func Init() (*PiRTC, error) {
 	}
 	VP8Params.BitRate = 500_000 // 5Kbps
 
+	opusParams, err := opus.NewParams()
+	if err != nil {
+		return nil, err
+	}
+
 	pirtc := PiRTC{
 		usageStreamCount: 0,
 		stream:           nil,
 		params:           VP8Params,
+		audioParams:      opusParams,
 		mediaEngine:      webrtc.MediaEngine{},
-		Connections:      make(map[string]*webrtc.PeerConnection),
+		privacyMasks:     video.NewPrivacyMasks(),
+		ptz:              video.NewPTZ(),
+		dayNightEvents:   make(chan DayNightEvent, dayNightEventsBufferSize),
+		sceneSignal:      make(chan struct{}, 1),
+		soundEvents:      make(chan SoundEvent, soundEventsBufferSize),
+		audioLevels:      make(chan audio.Level, 1),
+		bandwidths:       make(map[string]int),
+		closed:           make(chan struct{}),
 	}
+	pirtc.codecSelector = mediadevices.NewCodecSelector(
+		mediadevices.WithVideoEncoders(&pirtc.params),
+		mediadevices.WithAudioEncoders(&pirtc.audioParams),
+	)
+	pirtc.peers = newPeerRegistry(defaultPeerGracePeriod, func(uuid string, conn *webrtc.PeerConnection) {
+		// every peer connection in the registry holds one stream usage
+		pirtc.forgetBandwidth(uuid)
+		pirtc.decrementStreamUsage()
+	})
+	pirtc.peers.onICEFailed = func(uuid string) error {
+		return pirtc.Renegotiate(uuid, true)
+	}
+	pirtc.dayNightAnalyzer = video.NewDayNightAnalyzer(pirtc.dayNightOptions())
+	go pirtc.runSceneProfiles()
+	go camera.Watch(cameraRescanInterval, pirtc.closed)
 	return &pirtc, nil
 }
 
+// Close stops the camera rescans, the scene profiles and the sound detection,
+// and closes the camera
+func (pirtc *PiRTC) Close() error {
+	pirtc.closeOnce.Do(func() {
+		close(pirtc.closed)
+	})
+	if err := pirtc.SetSoundDetection(nil); err != nil {
+		return err
+	}
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	return pirtc.disableStream()
+}
+
 func (pirtc *PiRTC) NewUser(uuid string) error {
-	if _, ok := pirtc.Connections[uuid]; ok {
-		return errors.New("USER EXIST")
-		} else {
-			pirtc.Connections[uuid] = nil
-		}
-	log.Println(pirtc.Connections)
-	return nil
+	err := pirtc.peers.add(uuid)
+	if errors.Is(err, errUserExist) {
+		// the viewer keeps its session, its next offer renegotiates it
+		log.Printf("[Peer - %s]: already registered\n", uuid)
+		return nil
+	}
+	return err
 }
 
 func (pirtc *PiRTC) UserDisconnect(uuid string) error {
-	if _, ok := pirtc.Connections[uuid]; ok {
-		pirtc.mu.Lock()
-		if pirtc.Connections[uuid] != nil {
-			err := pirtc.Connections[uuid].Close()
-			if err != nil {
-				return err
+	return pirtc.peers.remove(uuid)
+}
+
+// Peers returns a snapshot of the state of every known viewer
+func (pirtc *PiRTC) Peers() []PeerInfo {
+	return pirtc.peers.snapshot()
+}
+
+// PeerEvents returns the stream of peer changes, the app reads it to notify
+// the backend when a viewer drops
+func (pirtc *PiRTC) PeerEvents() <-chan PeerEvent {
+	return pirtc.peers.events
+}
+
+func (pirtc *PiRTC) Answer(uuid string, offerSD webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
+	s, ok := pirtc.peers.session(uuid)
+	if !ok {
+		return nil, errors.New("USER NOT EXISTS")
+	}
+	s.negotiation.Lock()
+	defer s.negotiation.Unlock()
+
+	if s.conn != nil && s.conn.ConnectionState() != webrtc.PeerConnectionStateClosed {
+		if s.conn.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+			// glare: both sides made an offer
+			if s.conn.CurrentRemoteDescription() != nil {
+				// the offer of an established session can't be rolled
+				// back, the viewer answers it before offering again
+				log.Printf("[Peer - %s]: glare, keeping our offer\n", uuid)
+				return nil, errOfferPending
 			}
-			pirtc.Connections[uuid] = nil
+			// the session was never established, a new one answers the viewer
+			log.Printf("[Peer - %s]: glare, answering the viewer offer\n", uuid)
+		} else {
+			// new offer of a connected viewer: renegotiation or ICE restart
+			log.Printf("[Peer - %s]: renegotiating\n", uuid)
+			return answerOffer(s.conn, offerSD)
 		}
-		delete(pirtc.Connections, uuid)
-		pirtc.mu.Unlock()
-	} else {
-		return errors.New("USER NOT FOUND")
 	}
 
-	return nil
-}
+	log.Println(uuid)
+	peer, err := pirtc.openSession(uuid)
+	if err != nil {
+		return nil, err
+	}
 
-func (pirtc *PiRTC) Answer(uuid string, offerSD webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
+	answer, err := answerOffer(peer, offerSD)
+	if err != nil {
+		pirtc.peers.drop(uuid, peer, "negotiation failed")
+		return nil, err
+	}
+	return answer, nil
+}
 
+// openSession enables the stream and attaches a new peer connection to the
+// viewer, a previous peer connection of the viewer is closed
+func (pirtc *PiRTC) openSession(uuid string) (*webrtc.PeerConnection, error) {
 	pirtc.incrementStreamUsage()
 
 	err := pirtc.enableStream()
 	if err != nil {
+		pirtc.decrementStreamUsage()
 		return nil, err
 	}
-	pirtc.mu.Lock()
-	defer pirtc.mu.Unlock()
 
-	log.Println(uuid)
-	peer, ok := pirtc.Connections[uuid]
-	if !ok {
-		return nil, errors.New("USER NOT EXISTS")
+	peer, err := pirtc.newPeerConnection()
+	if err != nil {
+		pirtc.decrementStreamUsage()
+		return nil, err
+	}
+
+	// from here the registry owns the peer and its stream usage
+	if err := pirtc.peers.attach(uuid, peer); err != nil {
+		peer.Close()
+		pirtc.decrementStreamUsage()
+		return nil, err
 	}
+	return peer, nil
+}
+
+// newPeerConnection creates a peer connection sending every track of the stream
+func (pirtc *PiRTC) newPeerConnection() (*webrtc.PeerConnection, error) {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
 
 	api := webrtc.NewAPI(webrtc.WithMediaEngine(&pirtc.mediaEngine))
-	peer, err = api.NewPeerConnection(defaultConfig)
+	peer, err := api.NewPeerConnection(defaultConfig)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	peer.OnDataChannel(pirtc.handlePTZChannel)
 
 	for _, track := range pirtc.stream.GetTracks() {
 		track.OnEnded(func(err error) {
@@ -113,32 +293,22 @@ func (pirtc *PiRTC) Answer(uuid string, offerSD webrtc.SessionDescription) (*web
 				log.Printf("Track error: %v\n", err)
 			}
 			log.Printf("Track (ID: %s) ended \n", track.ID())
-			pirtc.decrementStreamUsage()
-		})
-		_, err = peer.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{
-			Direction: webrtc.RTPTransceiverDirectionSendonly,
+			// the audio track may be closed alone by DisableAudio
+			if track.Kind() == webrtc.RTPCodecTypeVideo {
+				pirtc.decrementStreamUsage()
+			}
 		})
+		err = addSendonlyTrack(peer, track)
 		if err != nil {
+			peer.Close()
 			return nil, err
 		}
 	}
+	return peer, nil
+}
 
-	peer.OnICEConnectionStateChange(func(is webrtc.ICEConnectionState) {
-		if is == webrtc.ICEConnectionStateDisconnected {
-			log.Printf("[Peer - %s]: peer disconnected\n", uuid)
-			peer.Close()
-			// TODO: need to do something to remove the closed peer from the list
-		} else if is == webrtc.ICEConnectionStateFailed {
-			log.Printf("[Peer - %s]: peer failed\n", uuid)
-			peer.Close()
-		} else if is == webrtc.ICEConnectionStateClosed {
-			log.Printf("[Peer - %s]: peer closed\n", uuid)
-			pirtc.decrementStreamUsage()
-
-		}
-	})
-
-	err = peer.SetRemoteDescription(offerSD)
+func answerOffer(peer *webrtc.PeerConnection, offerSD webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
+	err := peer.SetRemoteDescription(offerSD)
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +325,6 @@ func (pirtc *PiRTC) Answer(uuid string, offerSD webrtc.SessionDescription) (*web
 	}
 	<-gatherComplete
 
-	pirtc.Connections[uuid] = peer
 	return peer.LocalDescription(), nil
 }
 
@@ -168,29 +337,94 @@ func (pirtc *PiRTC) enableStream() error {
 	if pirtc.stream == nil {
 		var err error
 
-		codecSelector := mediadevices.NewCodecSelector(mediadevices.WithVideoEncoders(&pirtc.params))
-		codecSelector.Populate(&pirtc.mediaEngine)
+		pirtc.codecSelector.Populate(&pirtc.mediaEngine)
 
-		pirtc.stream, err = mediadevices.GetUserMedia(mediadevices.MediaStreamConstraints{
-			Video: func(constraint *mediadevices.MediaTrackConstraints) {
-				constraint.FrameFormat = prop.FrameFormat(frame.FormatI420)
-				constraint.Width = prop.Int(1280)
-				constraint.Height = prop.Int(720)
-			},
-			Codec: codecSelector,
-		})
+		pirtc.videoFormat = ""
+		if pirtc.passthroughAllowed() {
+			pirtc.videoFormat = pirtc.videoPassthrough
+		}
+		constraints := mediadevices.MediaStreamConstraints{
+			Video: pirtc.cameraMode().constraints,
+			Codec: pirtc.codecSelector,
+		}
+		var microphone *mediadevices.AudioTrack
+		if pirtc.audioEnabled {
+			if microphone, err = pirtc.acquireMicrophone(); err != nil {
+				return err
+			}
+		}
+		pirtc.stream, err = mediadevices.GetUserMedia(constraints)
+		if err != nil && pirtc.videoFormat != "" {
+			log.Printf("[passthrough error]: %v, the video is encoded\n", err)
+			pirtc.videoFormat = ""
+			constraints.Video = pirtc.cameraMode().constraints
+			pirtc.stream, err = mediadevices.GetUserMedia(constraints)
+		}
 		if err != nil {
+			if microphone != nil {
+				pirtc.releaseMicrophone()
+			}
 			return err
 		}
-		log.Println("Camera Enabled")
+		if microphone != nil {
+			pirtc.stream.AddTrack(microphone)
+		}
+		pirtc.openedMode = pirtc.cameraMode()
+		pirtc.overlayStamped = false
+		// The compressed frames are sent as they come.
+		for _, track := range pirtc.stream.GetVideoTracks() {
+			videoTrack := track.(*mediadevices.VideoTrack)
+			if pirtc.videoFormat == "" {
+				pirtc.transformVideo(videoTrack)
+			}
+			if pirtc.sceneBitRate > 0 {
+				if err := videoTrack.SetBitRate(pirtc.sceneBitRate); err != nil {
+					log.Printf("[Scene error]: %v\n", err)
+				}
+			}
+			pirtc.watchCameraLoss(videoTrack)
+		}
+		pirtc.applyCameraControls()
+		for _, track := range pirtc.stream.GetTracks() {
+			shareEncoder(track)
+		}
+		if pirtc.videoFormat != "" {
+			log.Printf("Camera Enabled in %s\n", pirtc.videoFormat)
+		} else {
+			log.Println("Camera Enabled")
+		}
 	}
 	return nil
 }
 
+// transformVideo must be called with pirtc.mu held. The geometry comes first
+// so that the masks are drawn on the corrected frame. The brightness is
+// measured and the night filters are applied before the masks, nothing drawn
+// afterwards can be hidden by them. The PTZ view is taken from the masked
+// frame and the overlay is drawn on the view.
+func (pirtc *PiRTC) transformVideo(videoTrack *mediadevices.VideoTrack) {
+	videoTrack.Transform(
+		pirtc.geometryTransform(),
+		pirtc.dayNightTransform(),
+		pirtc.privacyMasks.Transform(),
+		pirtc.ptz.Transform(),
+		pirtc.overlayTransform(),
+	)
+	pirtc.overlayStamped = pirtc.overlay != nil && pirtc.overlay.TimestampLayout != ""
+}
+
 func (pirtc *PiRTC) disableStream() error {
+	if pirtc.stream == nil {
+		return nil
+	}
 	tracks := pirtc.stream.GetTracks()
 	if len(tracks) > 0 {
 		for _, track := range tracks {
+			if pirtc.microphone != nil && track == mediadevices.Track(pirtc.microphone) {
+				// the sound detection may still listen to it
+				pirtc.releaseMicrophone()
+				continue
+			}
 			if err := track.Close(); err != nil {
 				return err
 			}
@@ -201,69 +435,21 @@ func (pirtc *PiRTC) disableStream() error {
 	return nil
 }
 
-func (pirtc *PiRTC) TakeShot(name string) error {
-	/*
-	* Take a shot and save with the name given
-	* @param name the name of the file will saved
-	 */
-	if err := pirtc.enableStream(); err != nil {
-		panic(err)
-	}
-	pirtc.incrementStreamUsage()
-	defer pirtc.decrementStreamUsage()
-
-	track := pirtc.stream.GetVideoTracks()[0]
-	videoTrack := track.(*mediadevices.VideoTrack)
-
-	videoReader := videoTrack.NewReader(false)
-
-	// skip first frame for warm up camera	
-	for i := 0; i < 1; i++ {
-		_, release, err := videoReader.Read()
-		if err != nil {
-			return fmt.Errorf("failed to read frame: %v", err)
-		}
-		release()
-	}
-	
-	// take image
-	frame, release, _ := videoReader.Read()
-	defer release()
-
-	nameImg := name + ".jpeg"
-	dir := filepath.Dir(nameImg)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-        // Nếu chưa tồn tại, tạo thư mục
-        err := os.MkdirAll(dir, 0755)
-        if err != nil {
-            panic(fmt.Sprintf("Failed to create directory: %s", err))
-        }
-    }
-	output, err := os.Create(nameImg)
-	if err != nil {
-		panic(err)
-	}
-	err = jpeg.Encode(output, frame, nil)
-	if err != nil {
-		panic(err)
-	}
-	// 	runtime.Gosched()
-	// }
-	// time.AfterFunc(time.Duration(1)*time.Second, func() {
-
-	// })
-	log.Println("Captured Image")
-	return nil
-}
-
+// Record records the video to savePath until stopCh is closed, it returns once
+// the file is complete
 func (pirtc *PiRTC) Record(savePath string, stopCh chan struct{}) chan struct{} {
 	// enableStream if necessary
 
+	stoppedChan := make(chan struct{})
 	doneChan := make(chan struct{})
 
-	go pirtc.record(savePath, doneChan)
+	go func() {
+		pirtc.record(savePath, stoppedChan)
+		close(doneChan)
+	}()
 	<-stopCh
-	close(doneChan)
+	close(stoppedChan)
+	<-doneChan
 
 	return doneChan
 }
@@ -273,14 +459,11 @@ func (pirtc *PiRTC) RecordWithTimer(savePath string, duration time.Duration) cha
 	* Record video to @params savePath in @params second seconds
 	* Return the name of video after recored
 	 */
-	doneChan := make(chan struct{})
-	timer := time.NewTimer(duration)
-	go pirtc.record(savePath, doneChan)
-	<-timer.C
-	close(doneChan)
-	timer.Stop()
-
-	return doneChan
+	stopChan := make(chan struct{})
+	time.AfterFunc(duration, func() {
+		close(stopChan)
+	})
+	return pirtc.Record(savePath, stopChan)
 }
 
 func (pirtc *PiRTC) record(savePath string, stopChan <-chan struct{}) {
@@ -288,8 +471,17 @@ func (pirtc *PiRTC) record(savePath string, stopChan <-chan struct{}) {
 	pirtc.incrementStreamUsage()
 	defer pirtc.decrementStreamUsage()
 
-	saver := newWebmSaver()
+	pirtc.mu.Lock()
+	format := pirtc.videoFormat
+	pirtc.mu.Unlock()
 	videoTrack := pirtc.stream.GetVideoTracks()[0].(*mediadevices.VideoTrack)
+	if format != "" {
+		recordPassthrough(savePath, videoTrack, format, stopChan)
+		return
+	}
+
+	saver := newWebmSaver()
+	defer saver.Close()
 	reader, err := videoTrack.NewRTPReader(pirtc.params.RTPCodec().MimeType, rand.Uint32(), 1000)
 	if err != nil {
 		panic(err)
@@ -334,6 +526,14 @@ func (pirtc *PiRTC) decrementStreamUsage() {
 
 }
 
+// shareEncoder makes every viewer of the track receive the packets of a single
+// encoder, the Pi cannot afford one encoder per viewer
+func shareEncoder(track mediadevices.Track) {
+	if t, ok := track.(interface{ SetSharedEncoding(bool) }); ok {
+		t.SetSharedEncoding(true)
+	}
+}
+
 func CreateSessionDescription(typeSd string, sdp string) webrtc.SessionDescription {
 	sd := webrtc.SessionDescription{}
 	switch typeSd {
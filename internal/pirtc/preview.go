@@ -0,0 +1,280 @@
+package pirtc
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/io/video"
+	"golang.org/x/image/vp8"
+)
+
+// ImageGIF is the format of the animated previews of the recordings
+const ImageGIF ImageFormat = "gif"
+
+const (
+	defaultPreviewWidth  = 320
+	defaultPreviewFrames = 10
+	defaultPreviewDelay  = 500 * time.Millisecond
+)
+
+var (
+	errNoKeyFrame   = errors.New("NO KEY FRAME IN THE RECORDING")
+	errPreviewCodec = errors.New("THE PREVIEWS NEED A VP8 OR MJPEG RECORDING")
+)
+
+// PreviewOptions configures the poster frame and the animated preview of a
+// recording, the zero value uses the defaults
+type PreviewOptions struct {
+	// Width of the preview, the height keeps the aspect ratio. The poster frame
+	// has the size of the video.
+	Width int
+	// Frames is the maximum number of key frames of the preview, picked evenly
+	// across the recording
+	Frames int
+	// FrameDelay is the display time of a frame of the preview
+	FrameDelay time.Duration
+	// Quality of the JPEG poster frame from 1 to 100, 0 keeps the encoder default
+	Quality int
+}
+
+// RecordingPreviews decodes the key frames of the WebM or Matroska recording at
+// videoPath and saves a JPEG poster frame and an animated GIF preview next to
+// it, as <name>_poster.jpeg and <name>_preview.gif. The VP8 and MJPEG frames
+// are decoded in pure Go, only the key frames are used. The H.264 recordings
+// of the passthrough have no previews. The file is streamed twice, to count
+// the key frames and to read the ones used, it is never loaded at once.
+func RecordingPreviews(videoPath string, opts PreviewOptions) ([]Shot, error) {
+	if opts.Width <= 0 {
+		opts.Width = defaultPreviewWidth
+	}
+	if opts.Frames <= 0 {
+		opts.Frames = defaultPreviewFrames
+	}
+	if opts.FrameDelay <= 0 {
+		opts.FrameDelay = defaultPreviewDelay
+	}
+
+	codecID, total, err := countKeyFrames(videoPath)
+	if err != nil {
+		return nil, err
+	}
+	decodeKeyFrame, err := keyFrameDecoder(codecID)
+	if err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return nil, errNoKeyFrame
+	}
+	info, err := os.Stat(videoPath)
+	if err != nil {
+		return nil, err
+	}
+	posterPath, previewPath := previewPaths(videoPath)
+
+	// the first frames of a camera are often too dark, the poster is the key
+	// frame in the middle of the recording. The preview picks its key frames
+	// evenly across the recording.
+	count := opts.Frames
+	if count > total {
+		count = total
+	}
+	picked := map[int]bool{total / 2: true}
+	for i := 0; i < count; i++ {
+		picked[i*total/count] = true
+	}
+	keyFrames, err := readKeyFrames(videoPath, picked)
+	if err != nil {
+		return nil, err
+	}
+
+	poster, err := decodeKeyFrame(keyFrames[total/2])
+	if err != nil {
+		return nil, err
+	}
+	posterShot, err := savePreview(posterPath, ImageJPEG, poster.Bounds(), info.ModTime(), func(w io.Writer) error {
+		var jpegOpts *jpeg.Options
+		if opts.Quality > 0 {
+			jpegOpts = &jpeg.Options{Quality: opts.Quality}
+		}
+		return jpeg.Encode(w, poster, jpegOpts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	shots := []Shot{*posterShot}
+
+	i := 0
+	frames := video.Scale(opts.Width, -1, video.ScalerBiLinear)(video.ReaderFunc(func() (image.Image, func(), error) {
+		img, err := decodeKeyFrame(keyFrames[i*total/count])
+		i++
+		return img, func() {}, err
+	}))
+	animation := &gif.GIF{}
+	delay := int(opts.FrameDelay / (10 * time.Millisecond))
+	for i < count {
+		img, _, err := frames.Read()
+		if err != nil {
+			return shots, err
+		}
+		bounds := img.Bounds()
+		paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, paletted.Rect, img, bounds.Min)
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, delay)
+	}
+	previewShot, err := savePreview(previewPath, ImageGIF, animation.Image[0].Rect, info.ModTime(), func(w io.Writer) error {
+		return gif.EncodeAll(w, animation)
+	})
+	if err != nil {
+		return shots, err
+	}
+
+	// the recording may have been removed while its previews were made
+	if _, err := os.Stat(videoPath); os.IsNotExist(err) {
+		removePreviews(videoPath)
+		return nil, err
+	}
+	return append(shots, *previewShot), nil
+}
+
+// RemoveRecording removes the recording at videoPath and its previews
+func RemoveRecording(videoPath string) error {
+	err := os.Remove(videoPath)
+	if previewErr := removePreviews(videoPath); err == nil {
+		err = previewErr
+	}
+	return err
+}
+
+// removePreviews removes the previews of the recording at videoPath, the
+// missing ones are skipped
+func removePreviews(videoPath string) error {
+	posterPath, previewPath := previewPaths(videoPath)
+	for _, path := range []string{posterPath, previewPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// previewPaths returns the paths of the poster frame and the animated preview
+// of the recording at videoPath
+func previewPaths(videoPath string) (string, string) {
+	name := strings.TrimSuffix(videoPath, ".webM")
+	name = strings.TrimSuffix(name, ".webm")
+	name = strings.TrimSuffix(name, ".mkv")
+	return name + "_poster.jpeg", name + "_preview.gif"
+}
+
+// countKeyFrames returns the codec and the number of key frames of the video
+// track of a WebM or Matroska file. The file of a recording which was cut off
+// is read up to its last complete cluster.
+func countKeyFrames(path string) (string, int, error) {
+	count := 0
+	codecID, err := forEachKeyFrame(path, func(int, []byte) {
+		count++
+	})
+	return codecID, count, err
+}
+
+// readKeyFrames returns the picked key frames of the video track of a WebM or
+// Matroska file by index
+func readKeyFrames(path string, picked map[int]bool) (map[int][]byte, error) {
+	keyFrames := make(map[int][]byte, len(picked))
+	_, err := forEachKeyFrame(path, func(i int, data []byte) {
+		if picked[i] {
+			keyFrames[i] = data
+		}
+	})
+	if err == nil && len(keyFrames) < len(picked) {
+		// the file changed between the two reads
+		err = errNoKeyFrame
+	}
+	return keyFrames, err
+}
+
+// forEachKeyFrame streams the key frames of the video track of a WebM or
+// Matroska file to fn with their index, and returns the codec of the track
+func forEachKeyFrame(path string, fn func(i int, data []byte)) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	r, err := video.NewWebMReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	i := 0
+	for {
+		f, err := r.ReadFrame()
+		if err == io.EOF {
+			return r.Track().CodecID, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if f.KeyFrame && len(f.Data) > 0 {
+			fn(i, f.Data)
+			i++
+		}
+	}
+}
+
+// keyFrameDecoder returns the decoder of the key frames of codecID
+func keyFrameDecoder(codecID string) (func([]byte) (image.Image, error), error) {
+	switch codecID {
+	case "V_VP8":
+		decoder := vp8.NewDecoder()
+		return func(data []byte) (image.Image, error) {
+			decoder.Init(bytes.NewReader(data), len(data))
+			if _, err := decoder.DecodeFrameHeader(); err != nil {
+				return nil, err
+			}
+			return decoder.DecodeFrame()
+		}, nil
+	case "V_MJPEG":
+		return func(data []byte) (image.Image, error) {
+			return jpeg.Decode(bytes.NewReader(data))
+		}, nil
+	}
+	return nil, errPreviewCodec
+}
+
+// savePreview writes a preview with encode and describes it like a snapshot
+func savePreview(path string, format ImageFormat, bounds image.Rectangle, takenAt time.Time, encode func(io.Writer) error) (*Shot, error) {
+	output, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+
+	if err := encode(output); err != nil {
+		return nil, err
+	}
+	info, err := output.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &Shot{
+		Path:    path,
+		Format:  format,
+		Width:   bounds.Dx(),
+		Height:  bounds.Dy(),
+		Size:    info.Size(),
+		TakenAt: takenAt,
+	}, nil
+}
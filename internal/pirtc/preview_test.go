@@ -0,0 +1,152 @@
+package pirtc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// previewFrameLevel is the gray level of the frame i of the test recordings
+func previewFrameLevel(i int) uint8 {
+	return uint8(20 + i*30)
+}
+
+// writeMJPEGRecording records gray frames of 64x48 pixels, each one
+// brighter than the previous one
+func writeMJPEGRecording(t *testing.T, path string, frames int) {
+	saver := newWebmSaver()
+	for i := 0; i < frames; i++ {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+		level := previewFrameLevel(i)
+		draw.Draw(img, img.Rect, image.NewUniform(color.RGBA{R: level, G: level, B: level, A: 0xff}), image.Point{}, draw.Src)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			t.Fatal(err)
+		}
+		saver.PushMJPEG(path, buf.Bytes(), 9000, 64, 48)
+	}
+	saver.Close()
+}
+
+// centerLevel returns the gray level in the middle of img
+func centerLevel(img image.Image) int {
+	bounds := img.Bounds()
+	r, _, _, _ := img.At((bounds.Min.X+bounds.Max.X)/2, (bounds.Min.Y+bounds.Max.Y)/2).RGBA()
+	return int(r >> 8)
+}
+
+func TestRecordingPreviews(t *testing.T) {
+	testCases := map[string]struct {
+		frames int
+		opts   PreviewOptions
+		// poster and picked are the indexes of the frames of the previews
+		poster int
+		picked []int
+	}{
+		"Picked": {
+			frames: 7,
+			opts:   PreviewOptions{Width: 32, Frames: 3},
+			poster: 3,
+			picked: []int{0, 2, 4},
+		},
+		"FewerFrames": {
+			frames: 2,
+			opts:   PreviewOptions{Width: 32},
+			poster: 1,
+			picked: []int{0, 1},
+		},
+		"SingleFrame": {
+			frames: 1,
+			opts:   PreviewOptions{Width: 32, Frames: 4},
+			poster: 0,
+			picked: []int{0},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			videoPath := filepath.Join(t.TempDir(), "video.mkv")
+			writeMJPEGRecording(t, videoPath, testCase.frames)
+
+			shots, err := RecordingPreviews(videoPath, testCase.opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(shots) != 2 {
+				t.Fatalf("Expected a poster and a preview, got %+v", shots)
+			}
+			posterPath, previewPath := previewPaths(videoPath)
+
+			poster, posterFile := shots[0], readPreviewFile(t, posterPath)
+			if poster.Path != posterPath || poster.Format != ImageJPEG || poster.Width != 64 || poster.Height != 48 {
+				t.Errorf("Expected a 64x48 JPEG poster at %s, got %+v", posterPath, poster)
+			}
+			posterImage, err := jpeg.Decode(bytes.NewReader(posterFile))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if level := centerLevel(posterImage); !closeLevel(level, previewFrameLevel(testCase.poster), 4) {
+				t.Errorf("Expected the frame %d as poster, got the level %d", testCase.poster, level)
+			}
+
+			preview := shots[1]
+			if preview.Path != previewPath || preview.Format != ImageGIF || preview.Width != 32 || preview.Height != 24 {
+				t.Errorf("Expected a 32x24 GIF preview at %s, got %+v", previewPath, preview)
+			}
+			animation, err := gif.DecodeAll(bytes.NewReader(readPreviewFile(t, previewPath)))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(animation.Image) != len(testCase.picked) {
+				t.Fatalf("Expected %d frames, got %d", len(testCase.picked), len(animation.Image))
+			}
+			for i, frame := range animation.Image {
+				if frame.Bounds().Dx() != 32 || frame.Bounds().Dy() != 24 {
+					t.Errorf("Expected 32x24 frames, got %v", frame.Bounds())
+				}
+				// the GIF palette only approximates the levels
+				if level := centerLevel(frame); !closeLevel(level, previewFrameLevel(testCase.picked[i]), 16) {
+					t.Errorf("Expected the frame %d of the recording, got the level %d", testCase.picked[i], level)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveRecording(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "video.mkv")
+	writeMJPEGRecording(t, videoPath, 2)
+	if _, err := RecordingPreviews(videoPath, PreviewOptions{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := RemoveRecording(videoPath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	posterPath, previewPath := previewPaths(videoPath)
+	for _, path := range []string{videoPath, posterPath, previewPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", path, err)
+		}
+	}
+}
+
+func readPreviewFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return data
+}
+
+func closeLevel(level int, expected uint8, tolerance int) bool {
+	diff := level - int(expected)
+	return diff >= -tolerance && diff <= tolerance
+}
@@ -0,0 +1,121 @@
+package pirtc
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+const (
+	MaskFill     = "fill"
+	MaskPixelate = "pixelate"
+)
+
+var errInvalidMask = errors.New("INVALID PRIVACY MASK")
+
+// PrivacyMask is a region hidden in the stream, the recordings and the
+// snapshots. Points are the vertices of the polygon, relative to the frame
+// size from 0 to 1.
+type PrivacyMask struct {
+	Points    [][2]float64 `json:"points"`
+	Style     string       `json:"style"`
+	BlockSize int          `json:"blockSize,omitempty"`
+}
+
+func (mask PrivacyMask) toVideo() (video.PrivacyMask, error) {
+	if len(mask.Points) < 3 {
+		return video.PrivacyMask{}, errInvalidMask
+	}
+	polygon := make([]video.MaskPoint, len(mask.Points))
+	for i, pt := range mask.Points {
+		if pt[0] < 0 || pt[0] > 1 || pt[1] < 0 || pt[1] > 1 {
+			return video.PrivacyMask{}, errInvalidMask
+		}
+		polygon[i] = video.MaskPoint{X: pt[0], Y: pt[1]}
+	}
+
+	var style video.MaskStyle
+	switch mask.Style {
+	case MaskFill, "":
+		style = video.MaskFill
+	case MaskPixelate:
+		style = video.MaskPixelate
+	default:
+		return video.PrivacyMask{}, errInvalidMask
+	}
+	return video.PrivacyMask{Polygon: polygon, Style: style, BlockSize: mask.BlockSize}, nil
+}
+
+// LoadPrivacyMasks applies the masks saved in path, the masks set afterwards
+// are saved there. A missing file means no mask.
+func (pirtc *PiRTC) LoadPrivacyMasks(path string) error {
+	pirtc.mu.Lock()
+	pirtc.privacyMasksPath = path
+	pirtc.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var masks []PrivacyMask
+	if err := json.Unmarshal(data, &masks); err != nil {
+		return err
+	}
+	return pirtc.applyPrivacyMasks(masks)
+}
+
+// SetPrivacyMasks replaces the masks, they are applied from the next frame
+// without restarting the camera. A camera passed through is decoded first.
+func (pirtc *PiRTC) SetPrivacyMasks(masks []PrivacyMask) error {
+	if err := pirtc.applyPrivacyMasks(masks); err != nil {
+		return err
+	}
+
+	pirtc.mu.Lock()
+	path := pirtc.privacyMasksPath
+	pirtc.mu.Unlock()
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(masks)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// PrivacyMasks returns the current masks
+func (pirtc *PiRTC) PrivacyMasks() []PrivacyMask {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	return append([]PrivacyMask{}, pirtc.privacyMaskConfig...)
+}
+
+func (pirtc *PiRTC) applyPrivacyMasks(masks []PrivacyMask) error {
+	videoMasks := make([]video.PrivacyMask, len(masks))
+	for i, mask := range masks {
+		videoMask, err := mask.toVideo()
+		if err != nil {
+			return err
+		}
+		videoMasks[i] = videoMask
+	}
+
+	pirtc.mu.Lock()
+	pirtc.privacyMaskConfig = append([]PrivacyMask{}, masks...)
+	pirtc.mu.Unlock()
+	pirtc.privacyMasks.Set(videoMasks)
+	// the compressed frames can't be masked
+	pirtc.decodeCamera()
+	return nil
+}
@@ -0,0 +1,224 @@
+package pirtc
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	PTZMove   = "move"
+	PTZSave   = "save"
+	PTZRecall = "recall"
+	PTZDelete = "delete"
+	PTZGet    = "get"
+)
+
+// PTZChannelLabel is the label of the data channel a viewer opens to send PTZ
+// commands, each message is a PTZCommand and is answered with a PTZState
+const PTZChannelLabel = "ptz"
+
+// defaultPTZMoveDuration is used when a command doesn't give a duration
+const defaultPTZMoveDuration = 500 * time.Millisecond
+
+var (
+	errUnknownPTZAction = errors.New("UNKNOWN PTZ ACTION")
+	errPresetNotFound   = errors.New("PTZ PRESET NOT FOUND")
+	errInvalidPreset    = errors.New("INVALID PTZ PRESET")
+)
+
+// PTZRegion is the part of the frame shown to the viewers, relative to the
+// frame size from 0 to 1
+type PTZRegion struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+func (region PTZRegion) toVideo() video.ROI {
+	return video.ROI{X: region.X, Y: region.Y, Width: region.Width, Height: region.Height}
+}
+
+func regionFromVideo(roi video.ROI) PTZRegion {
+	return PTZRegion{X: roi.X, Y: roi.Y, Width: roi.Width, Height: roi.Height}
+}
+
+// PTZCommand moves the view of the camera, it is received from the websocket
+// or the PTZ data channel. A move gives either a region or a pan, tilt and
+// zoom, the pan and tilt being the center of the view from 0 to 1. Missing
+// values of a pan, tilt and zoom keep the current ones.
+type PTZCommand struct {
+	Action string     `json:"action"`
+	Region *PTZRegion `json:"region,omitempty"`
+	Pan    *float64   `json:"pan,omitempty"`
+	Tilt   *float64   `json:"tilt,omitempty"`
+	Zoom   *float64   `json:"zoom,omitempty"`
+	// Preset is the name of the preset to save, recall or delete
+	Preset string `json:"preset,omitempty"`
+	// Duration of the move in milliseconds, 0 jumps to the new view
+	Duration *int `json:"duration,omitempty"`
+}
+
+// PTZState is the view of the camera after a command
+type PTZState struct {
+	// Region is the view shown now, Target is the view at the end of the move
+	Region  PTZRegion            `json:"region"`
+	Target  PTZRegion            `json:"target"`
+	Presets map[string]PTZRegion `json:"presets"`
+}
+
+// LoadPTZPresets reads the presets saved in path, the presets saved afterwards
+// are written there. A missing file means no preset.
+func (pirtc *PiRTC) LoadPTZPresets(path string) error {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	pirtc.ptzPresetsPath = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	presets := make(map[string]PTZRegion)
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return err
+	}
+	pirtc.ptzPresets = presets
+	return nil
+}
+
+// HandlePTZCommand runs a command and returns the resulting state. The view is
+// shared by every viewer, the recordings and the snapshots.
+func (pirtc *PiRTC) HandlePTZCommand(command PTZCommand) (PTZState, error) {
+	duration := defaultPTZMoveDuration
+	if command.Duration != nil {
+		duration = time.Duration(*command.Duration) * time.Millisecond
+	}
+
+	var err error
+	switch command.Action {
+	case PTZMove:
+		pirtc.ptz.MoveTo(pirtc.ptzTarget(command), duration)
+	case PTZSave:
+		err = pirtc.savePTZPreset(command.Preset, regionFromVideo(pirtc.ptz.Target()))
+	case PTZRecall:
+		pirtc.mu.Lock()
+		region, ok := pirtc.ptzPresets[command.Preset]
+		pirtc.mu.Unlock()
+		if !ok {
+			err = errPresetNotFound
+			break
+		}
+		pirtc.ptz.MoveTo(region.toVideo(), duration)
+	case PTZDelete:
+		err = pirtc.savePTZPreset(command.Preset, PTZRegion{})
+	case PTZGet:
+	default:
+		err = errUnknownPTZAction
+	}
+	return pirtc.PTZState(), err
+}
+
+// PTZState returns the current view and the presets
+func (pirtc *PiRTC) PTZState() PTZState {
+	pirtc.mu.Lock()
+	presets := make(map[string]PTZRegion, len(pirtc.ptzPresets))
+	for name, region := range pirtc.ptzPresets {
+		presets[name] = region
+	}
+	pirtc.mu.Unlock()
+
+	return PTZState{
+		Region:  regionFromVideo(pirtc.ptz.Position()),
+		Target:  regionFromVideo(pirtc.ptz.Target()),
+		Presets: presets,
+	}
+}
+
+func (pirtc *PiRTC) ptzTarget(command PTZCommand) video.ROI {
+	if command.Region != nil {
+		return command.Region.toVideo()
+	}
+	pan, tilt, zoom := pirtc.ptz.Target().Center()
+	if command.Pan != nil {
+		pan = *command.Pan
+	}
+	if command.Tilt != nil {
+		tilt = *command.Tilt
+	}
+	if command.Zoom != nil {
+		zoom = *command.Zoom
+	}
+	return video.ZoomROI(pan, tilt, zoom)
+}
+
+// savePTZPreset stores region under name and writes the presets to disk, an
+// empty region deletes the preset
+func (pirtc *PiRTC) savePTZPreset(name string, region PTZRegion) error {
+	if name == "" {
+		return errInvalidPreset
+	}
+
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	if region == (PTZRegion{}) {
+		if _, ok := pirtc.ptzPresets[name]; !ok {
+			return errPresetNotFound
+		}
+		delete(pirtc.ptzPresets, name)
+	} else {
+		if pirtc.ptzPresets == nil {
+			pirtc.ptzPresets = make(map[string]PTZRegion)
+		}
+		pirtc.ptzPresets[name] = region
+	}
+
+	path := pirtc.ptzPresetsPath
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(pirtc.ptzPresets)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// handlePTZChannel answers the PTZ commands sent by a viewer on its data
+// channel, the other channels are ignored
+func (pirtc *PiRTC) handlePTZChannel(channel *webrtc.DataChannel) {
+	if channel.Label() != PTZChannelLabel {
+		return
+	}
+	channel.OnMessage(func(msg webrtc.DataChannelMessage) {
+		var command PTZCommand
+		err := json.Unmarshal(msg.Data, &command)
+		if err == nil {
+			_, err = pirtc.HandlePTZCommand(command)
+		}
+		if err != nil {
+			log.Printf("[ptz error]: %v\n", err)
+		}
+
+		reply, err := json.Marshal(pirtc.PTZState())
+		if err != nil {
+			log.Printf("[ptz error]: %v\n", err)
+			return
+		}
+		if err := channel.SendText(string(reply)); err != nil {
+			log.Printf("[ptz error]: %v\n", err)
+		}
+	})
+}
@@ -0,0 +1,357 @@
+package pirtc
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// time a peer may stay in the ICE disconnected state before it is dropped,
+// this leaves room for the viewer to recover or restart ICE
+const defaultPeerGracePeriod = 10 * time.Second
+
+const peerEventsBufferSize = 32
+
+var (
+	errUserExist    = errors.New("USER EXIST")
+	errUserNotFound = errors.New("USER NOT FOUND")
+)
+
+// PeerEventType is the kind of change reported on the peer event stream
+type PeerEventType string
+
+const (
+	// PeerConnected is emitted when the ICE connection of a peer is established
+	PeerConnected PeerEventType = "connected"
+	// PeerDisconnected is emitted when a peer lost its ICE connection, the peer
+	// is kept in the registry during the grace period
+	PeerDisconnected PeerEventType = "disconnected"
+	// PeerReconnected is emitted when a disconnected peer recovered in time
+	PeerReconnected PeerEventType = "reconnected"
+	// PeerDropped is emitted when a peer was removed because its connection
+	// failed or did not recover during the grace period
+	PeerDropped PeerEventType = "dropped"
+	// PeerRemoved is emitted when a peer was removed on request
+	PeerRemoved PeerEventType = "removed"
+)
+
+// PeerEvent describes a change of a peer in the registry
+type PeerEvent struct {
+	Type   PeerEventType
+	UUID   string
+	Reason string
+}
+
+// PeerInfo is a snapshot of the state of a peer
+type PeerInfo struct {
+	UUID           string
+	SignalingState webrtc.SignalingState
+	ICEState       webrtc.ICEConnectionState
+	ConnectedSince time.Time
+	BytesSent      uint64
+}
+
+type peer struct {
+	uuid           string
+	conn           *webrtc.PeerConnection
+	signalingState webrtc.SignalingState
+	iceState       webrtc.ICEConnectionState
+	connectedSince time.Time
+	graceTimer     *time.Timer
+	// iceRestarted is set by an ICE restart until the connection is back, a
+	// second failure drops the viewer
+	iceRestarted bool
+	// negotiation serializes the SDP exchanges of the viewer
+	negotiation sync.Mutex
+}
+
+// session is the negotiation handle of a viewer, conn is nil until the
+// viewer made its first offer
+type session struct {
+	uuid        string
+	conn        *webrtc.PeerConnection
+	negotiation *sync.Mutex
+}
+
+// peerRegistry keeps track of the viewers known by the camera and of their
+// peer connection. It is safe for concurrent use.
+type peerRegistry struct {
+	mu          sync.Mutex
+	peers       map[string]*peer
+	events      chan PeerEvent
+	gracePeriod time.Duration
+	// onRelease is called, without the registry lock held, every time a peer
+	// connection leaves the registry
+	onRelease func(uuid string, conn *webrtc.PeerConnection)
+	// onICEFailed restarts the ICE of a viewer whose connection failed, the
+	// viewer is dropped when it is nil or fails
+	onICEFailed func(uuid string) error
+}
+
+func newPeerRegistry(gracePeriod time.Duration, onRelease func(string, *webrtc.PeerConnection)) *peerRegistry {
+	return &peerRegistry{
+		peers:       make(map[string]*peer),
+		events:      make(chan PeerEvent, peerEventsBufferSize),
+		gracePeriod: gracePeriod,
+		onRelease:   onRelease,
+	}
+}
+
+// add registers a new viewer without peer connection
+func (r *peerRegistry) add(uuid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.peers[uuid]; ok {
+		return errUserExist
+	}
+	r.peers[uuid] = &peer{uuid: uuid}
+	return nil
+}
+
+func (r *peerRegistry) session(uuid string) (session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.peers[uuid]
+	if !ok {
+		return session{}, false
+	}
+	return session{uuid: uuid, conn: p.conn, negotiation: &p.negotiation}, true
+}
+
+// sessions returns the viewers having a peer connection
+func (r *peerRegistry) sessions() []session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := make([]session, 0, len(r.peers))
+	for uuid, p := range r.peers {
+		if p.conn != nil {
+			sessions = append(sessions, session{uuid: uuid, conn: p.conn, negotiation: &p.negotiation})
+		}
+	}
+	return sessions
+}
+
+// attach binds conn to a registered viewer. A previous peer connection of the
+// viewer is closed and released.
+func (r *peerRegistry) attach(uuid string, conn *webrtc.PeerConnection) error {
+	r.mu.Lock()
+	p, ok := r.peers[uuid]
+	if !ok {
+		r.mu.Unlock()
+		return errUserNotFound
+	}
+	old := p.conn
+	p.stopGraceTimer()
+	p.conn = conn
+	p.signalingState = conn.SignalingState()
+	p.iceState = conn.ICEConnectionState()
+	p.connectedSince = time.Time{}
+	r.mu.Unlock()
+
+	if old != nil {
+		r.release(uuid, old)
+	}
+
+	conn.OnSignalingStateChange(func(state webrtc.SignalingState) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if p, ok := r.peers[uuid]; ok && p.conn == conn {
+			p.signalingState = state
+		}
+	})
+	conn.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
+		r.handleICEState(uuid, conn, state)
+	})
+	return nil
+}
+
+// remove unregisters a viewer and closes its peer connection if any
+func (r *peerRegistry) remove(uuid string) error {
+	r.mu.Lock()
+	p, ok := r.peers[uuid]
+	if !ok {
+		r.mu.Unlock()
+		return errUserNotFound
+	}
+	p.stopGraceTimer()
+	delete(r.peers, uuid)
+	r.mu.Unlock()
+
+	if p.conn != nil {
+		r.release(uuid, p.conn)
+	}
+	r.emit(PeerEvent{Type: PeerRemoved, UUID: uuid})
+	return nil
+}
+
+// drop removes a viewer only if conn is still its current peer connection,
+// late callbacks of a replaced connection must not remove the new one
+func (r *peerRegistry) drop(uuid string, conn *webrtc.PeerConnection, reason string) {
+	r.mu.Lock()
+	p, ok := r.peers[uuid]
+	if !ok || p.conn != conn {
+		r.mu.Unlock()
+		return
+	}
+	p.stopGraceTimer()
+	delete(r.peers, uuid)
+	r.mu.Unlock()
+
+	log.Printf("[Peer - %s]: dropped (%s)\n", uuid, reason)
+	r.release(uuid, conn)
+	r.emit(PeerEvent{Type: PeerDropped, UUID: uuid, Reason: reason})
+}
+
+func (r *peerRegistry) handleICEState(uuid string, conn *webrtc.PeerConnection, state webrtc.ICEConnectionState) {
+	log.Printf("[Peer - %s]: ICE state %s\n", uuid, state)
+
+	switch state {
+	case webrtc.ICEConnectionStateFailed:
+		if !r.restartICE(uuid, conn) {
+			r.drop(uuid, conn, "failed")
+		}
+		return
+	case webrtc.ICEConnectionStateClosed:
+		r.drop(uuid, conn, "closed")
+		return
+	}
+
+	r.mu.Lock()
+	p, ok := r.peers[uuid]
+	if !ok || p.conn != conn {
+		r.mu.Unlock()
+		return
+	}
+	previous := p.iceState
+	p.iceState = state
+
+	var event *PeerEvent
+	switch state {
+	case webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted:
+		if p.graceTimer != nil {
+			p.stopGraceTimer()
+			event = &PeerEvent{Type: PeerReconnected, UUID: uuid}
+		} else if previous != webrtc.ICEConnectionStateConnected && previous != webrtc.ICEConnectionStateCompleted {
+			event = &PeerEvent{Type: PeerConnected, UUID: uuid}
+		}
+		if p.connectedSince.IsZero() {
+			p.connectedSince = time.Now()
+		}
+		p.iceRestarted = false
+	case webrtc.ICEConnectionStateDisconnected:
+		if p.graceTimer == nil {
+			p.graceTimer = time.AfterFunc(r.gracePeriod, func() {
+				r.drop(uuid, conn, "disconnected")
+			})
+			event = &PeerEvent{Type: PeerDisconnected, UUID: uuid}
+		}
+	}
+	r.mu.Unlock()
+
+	if event != nil {
+		r.emit(*event)
+	}
+}
+
+// restartICE restarts the ICE of conn on its first failure, the viewer is
+// dropped if it doesn't reconnect within the grace period. It returns false
+// when conn isn't restarted.
+func (r *peerRegistry) restartICE(uuid string, conn *webrtc.PeerConnection) bool {
+	if r.onICEFailed == nil {
+		return false
+	}
+	r.mu.Lock()
+	p, ok := r.peers[uuid]
+	if !ok || p.conn != conn || p.iceRestarted {
+		r.mu.Unlock()
+		return false
+	}
+	p.iceRestarted = true
+	p.iceState = webrtc.ICEConnectionStateFailed
+	disconnected := p.graceTimer == nil
+	p.stopGraceTimer()
+	p.graceTimer = time.AfterFunc(r.gracePeriod, func() {
+		r.drop(uuid, conn, "failed")
+	})
+	r.mu.Unlock()
+
+	if disconnected {
+		r.emit(PeerEvent{Type: PeerDisconnected, UUID: uuid})
+	}
+	log.Printf("[Peer - %s]: restarting ICE\n", uuid)
+	// the offer takes the negotiation lock, the callback of the connection
+	// mustn't wait for it
+	go func() {
+		if err := r.onICEFailed(uuid); err != nil {
+			log.Printf("[Peer - %s]: ICE restart failed: %v\n", uuid, err)
+			r.drop(uuid, conn, "failed")
+		}
+	}()
+	return true
+}
+
+// release closes conn and hands it to the onRelease callback
+func (r *peerRegistry) release(uuid string, conn *webrtc.PeerConnection) {
+	if err := conn.Close(); err != nil {
+		log.Printf("[Peer - %s]: error while closing: %v\n", uuid, err)
+	}
+	if r.onRelease != nil {
+		r.onRelease(uuid, conn)
+	}
+}
+
+func (r *peerRegistry) emit(event PeerEvent) {
+	select {
+	case r.events <- event:
+	default:
+		log.Printf("[Peer - %s]: event %s dropped, nobody is listening\n", event.UUID, event.Type)
+	}
+}
+
+// snapshot returns the state of every registered viewer
+func (r *peerRegistry) snapshot() []PeerInfo {
+	r.mu.Lock()
+	infos := make([]PeerInfo, 0, len(r.peers))
+	conns := make([]*webrtc.PeerConnection, 0, len(r.peers))
+	for uuid, p := range r.peers {
+		infos = append(infos, PeerInfo{
+			UUID:           uuid,
+			SignalingState: p.signalingState,
+			ICEState:       p.iceState,
+			ConnectedSince: p.connectedSince,
+		})
+		conns = append(conns, p.conn)
+	}
+	r.mu.Unlock()
+
+	// stats are collected outside of the lock, GetStats may take a while
+	for i, conn := range conns {
+		if conn != nil {
+			infos[i].BytesSent = bytesSent(conn)
+		}
+	}
+	return infos
+}
+
+func (p *peer) stopGraceTimer() {
+	if p.graceTimer != nil {
+		p.graceTimer.Stop()
+		p.graceTimer = nil
+	}
+}
+
+func bytesSent(conn *webrtc.PeerConnection) uint64 {
+	var total uint64
+	for _, stat := range conn.GetStats() {
+		if outbound, ok := stat.(webrtc.OutboundRTPStreamStats); ok {
+			total += outbound.BytesSent
+		}
+	}
+	return total
+}
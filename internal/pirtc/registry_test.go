@@ -0,0 +1,220 @@
+package pirtc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const testGracePeriod = 20 * time.Millisecond
+
+// releaseCounter counts the connections released by a registry
+type releaseCounter struct {
+	mu    sync.Mutex
+	conns map[*webrtc.PeerConnection]int
+}
+
+func (c *releaseCounter) release(uuid string, conn *webrtc.PeerConnection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conns == nil {
+		c.conns = make(map[*webrtc.PeerConnection]int)
+	}
+	c.conns[conn]++
+}
+
+func (c *releaseCounter) count(conn *webrtc.PeerConnection) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conns[conn]
+}
+
+// newTestConnection returns a peer connection without ICE candidates, its ICE
+// states are only the ones reported by the tests
+func newTestConnection(t *testing.T) *webrtc.PeerConnection {
+	var settings webrtc.SettingEngine
+	settings.SetInterfaceFilter(func(string) bool { return false })
+	mediaEngine := &webrtc.MediaEngine{}
+	if err := mediaEngine.RegisterDefaultCodecs(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(mediaEngine), webrtc.WithSettingEngine(settings))
+	conn, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// nextEvent returns the next event of r, it fails the test when none comes
+func nextEvent(t *testing.T, r *peerRegistry) PeerEvent {
+	t.Helper()
+	select {
+	case event := <-r.events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Expected an event")
+		return PeerEvent{}
+	}
+}
+
+func TestPeerRegistryICEStates(t *testing.T) {
+	testCases := map[string]struct {
+		states []webrtc.ICEConnectionState
+		// wait lets the grace period elapse after the states, late are the states reported then
+		wait       bool
+		late       []webrtc.ICEConnectionState
+		events     []PeerEventType
+		registered bool
+	}{
+		"Connected": {
+			states:     []webrtc.ICEConnectionState{webrtc.ICEConnectionStateChecking, webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted},
+			events:     []PeerEventType{PeerConnected},
+			registered: true,
+		},
+		"ReconnectedInGracePeriod": {
+			states:     []webrtc.ICEConnectionState{webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateDisconnected, webrtc.ICEConnectionStateConnected},
+			wait:       true,
+			events:     []PeerEventType{PeerConnected, PeerDisconnected, PeerReconnected},
+			registered: true,
+		},
+		"DroppedAfterGracePeriod": {
+			states: []webrtc.ICEConnectionState{webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateDisconnected},
+			wait:   true,
+			events: []PeerEventType{PeerConnected, PeerDisconnected, PeerDropped},
+		},
+		"ClosedAfterDrop": {
+			states: []webrtc.ICEConnectionState{webrtc.ICEConnectionStateDisconnected},
+			wait:   true,
+			late:   []webrtc.ICEConnectionState{webrtc.ICEConnectionStateClosed},
+			events: []PeerEventType{PeerDisconnected, PeerDropped},
+		},
+		"Failed": {
+			states: []webrtc.ICEConnectionState{webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateFailed, webrtc.ICEConnectionStateClosed},
+			events: []PeerEventType{PeerConnected, PeerDropped},
+		},
+		"Closed": {
+			states: []webrtc.ICEConnectionState{webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateClosed},
+			events: []PeerEventType{PeerConnected, PeerDropped},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			var releases releaseCounter
+			r := newPeerRegistry(testGracePeriod, releases.release)
+			conn := newTestConnection(t)
+			if err := r.add("viewer"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := r.attach("viewer", conn); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for _, state := range testCase.states {
+				r.handleICEState("viewer", conn, state)
+			}
+			for _, expected := range testCase.events {
+				if event := nextEvent(t, r); event.Type != expected || event.UUID != "viewer" {
+					t.Fatalf("Expected a %s event, got %+v", expected, event)
+				}
+			}
+			if testCase.wait {
+				time.Sleep(3 * testGracePeriod)
+			}
+			// the late callbacks of a dropped connection are ignored
+			for _, state := range testCase.late {
+				r.handleICEState("viewer", conn, state)
+			}
+			select {
+			case event := <-r.events:
+				t.Errorf("Expected no more events, got %+v", event)
+			default:
+			}
+
+			_, registered := r.session("viewer")
+			if registered != testCase.registered {
+				t.Errorf("Expected registered to be %v, got %v", testCase.registered, registered)
+			}
+			if registered {
+				if err := r.remove("viewer"); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			if n := releases.count(conn); n != 1 {
+				t.Errorf("Expected the connection to be released once, got %d", n)
+			}
+		})
+	}
+}
+
+func TestPeerRegistryAttach(t *testing.T) {
+	var releases releaseCounter
+	r := newPeerRegistry(testGracePeriod, releases.release)
+	if err := r.attach("viewer", newTestConnection(t)); err != errUserNotFound {
+		t.Errorf("Expected %v, got %v", errUserNotFound, err)
+	}
+	if err := r.add("viewer"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.add("viewer"); err != errUserExist {
+		t.Errorf("Expected %v, got %v", errUserExist, err)
+	}
+
+	first, second := newTestConnection(t), newTestConnection(t)
+	if err := r.attach("viewer", first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r.handleICEState("viewer", first, webrtc.ICEConnectionStateDisconnected)
+	nextEvent(t, r)
+	if err := r.attach("viewer", second); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := releases.count(first); n != 1 {
+		t.Errorf("Expected the replaced connection to be released once, got %d", n)
+	}
+
+	// the grace timer of the replaced connection was stopped, its late callbacks are ignored
+	time.Sleep(3 * testGracePeriod)
+	r.handleICEState("viewer", first, webrtc.ICEConnectionStateFailed)
+	s, ok := r.session("viewer")
+	if !ok || s.conn != second {
+		t.Fatal("Expected the viewer to keep its new connection")
+	}
+	if err := r.remove("viewer"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if event := nextEvent(t, r); event.Type != PeerRemoved {
+		t.Errorf("Expected a %s event, got %+v", PeerRemoved, event)
+	}
+	if err := r.remove("viewer"); err != errUserNotFound {
+		t.Errorf("Expected %v, got %v", errUserNotFound, err)
+	}
+	if n, m := releases.count(first), releases.count(second); n != 1 || m != 1 {
+		t.Errorf("Expected each connection to be released once, got %d and %d", n, m)
+	}
+}
+
+func TestPeerRegistryEventsFull(t *testing.T) {
+	r := newPeerRegistry(testGracePeriod, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2*peerEventsBufferSize; i++ {
+			r.emit(PeerEvent{Type: PeerConnected, UUID: "viewer"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the events to be dropped without blocking")
+	}
+	if n := len(r.events); n != peerEventsBufferSize {
+		t.Errorf("Expected %d buffered events, got %d", peerEventsBufferSize, n)
+	}
+}
@@ -0,0 +1,231 @@
+package pirtc
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+// ImageFormat is the file format of a snapshot
+type ImageFormat string
+
+const (
+	ImageJPEG ImageFormat = "jpeg"
+	ImagePNG  ImageFormat = "png"
+	ImageWebP ImageFormat = "webp"
+)
+
+const defaultTimestampLayout = "2006-01-02 15:04:05"
+
+var (
+	errUnknownImageFormat = errors.New("UNKNOWN IMAGE FORMAT")
+	errNoVideoTrack       = errors.New("NO VIDEO TRACK")
+)
+
+// ShotOptions configures TakeShots, the zero value takes one full frame JPEG
+type ShotOptions struct {
+	Format ImageFormat
+	// Quality of JPEG snapshots from 1 to 100, 0 keeps the encoder default
+	Quality int
+	// Width and Height scale the frame to a thumbnail. When only one of them is
+	// set the aspect ratio is kept.
+	Width  int
+	Height int
+	// Timestamp burns the capture time in the bottom left corner, unless the
+	// overlay of the camera already draws it
+	Timestamp       bool
+	TimestampLayout string
+	// Count frames are taken every Interval, more than one makes a burst or a
+	// time-lapse
+	Count    int
+	Interval time.Duration
+	// WarmupFrames are skipped before each capture, the first frames of a
+	// camera are often too dark
+	WarmupFrames int
+}
+
+// Shot describes a saved snapshot
+type Shot struct {
+	Path    string
+	Format  ImageFormat
+	Width   int
+	Height  int
+	Size    int64
+	TakenAt time.Time
+}
+
+func (pirtc *PiRTC) TakeShot(name string) error {
+	/*
+	* Take a shot and save with the name given
+	* @param name the name of the file will saved
+	 */
+	_, err := pirtc.TakeShots(name, ShotOptions{WarmupFrames: 1})
+	return err
+}
+
+// TakeShots captures opts.Count frames and saves them as name.<format>, or as
+// name_001.<format>, name_002.<format>... for bursts. The shots saved before an
+// error are returned with it.
+func (pirtc *PiRTC) TakeShots(name string, opts ShotOptions) ([]Shot, error) {
+	if opts.Format == "" {
+		opts.Format = ImageJPEG
+	}
+	switch opts.Format {
+	case ImageJPEG, ImagePNG, ImageWebP:
+	default:
+		return nil, errUnknownImageFormat
+	}
+	if opts.Count < 1 {
+		opts.Count = 1
+	}
+	if opts.TimestampLayout == "" {
+		opts.TimestampLayout = defaultTimestampLayout
+	}
+
+	pirtc.incrementStreamUsage()
+	defer pirtc.decrementStreamUsage()
+	if err := pirtc.enableStream(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	shots := make([]Shot, 0, opts.Count)
+	for i := 0; i < opts.Count; i++ {
+		if i > 0 && opts.Interval > 0 {
+			time.Sleep(opts.Interval)
+		}
+
+		path := name + "." + string(opts.Format)
+		if opts.Count > 1 {
+			path = fmt.Sprintf("%s_%03d.%s", name, i+1, opts.Format)
+		}
+		shot, err := pirtc.takeShot(path, opts)
+		if err != nil {
+			return shots, err
+		}
+		shots = append(shots, *shot)
+	}
+	log.Printf("Captured %d image(s)\n", len(shots))
+	return shots, nil
+}
+
+func (pirtc *PiRTC) takeShot(path string, opts ShotOptions) (*Shot, error) {
+	frame, release, err := pirtc.captureFrame(opts.Width, opts.Height, opts.WarmupFrames)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	takenAt := time.Now()
+
+	img := frame
+	if opts.Timestamp && !pirtc.overlayStampsTime() {
+		img = drawTimestamp(frame, takenAt.Format(opts.TimestampLayout))
+	}
+
+	output, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+
+	switch opts.Format {
+	case ImagePNG:
+		err = png.Encode(output, img)
+	case ImageWebP:
+		err = encodeWebp(output, img)
+	default:
+		var jpegOpts *jpeg.Options
+		if opts.Quality > 0 {
+			jpegOpts = &jpeg.Options{Quality: opts.Quality}
+		}
+		err = jpeg.Encode(output, img, jpegOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := output.Stat()
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	return &Shot{
+		Path:    path,
+		Format:  opts.Format,
+		Width:   bounds.Dx(),
+		Height:  bounds.Dy(),
+		Size:    info.Size(),
+		TakenAt: takenAt,
+	}, nil
+}
+
+// captureFrame reads a frame of the running stream, scaled when width or height
+// is given. The stream must have been enabled by the caller.
+func (pirtc *PiRTC) captureFrame(width, height, warmupFrames int) (image.Image, func(), error) {
+	pirtc.mu.Lock()
+	if pirtc.stream == nil || len(pirtc.stream.GetVideoTracks()) == 0 {
+		pirtc.mu.Unlock()
+		return nil, nil, errNoVideoTrack
+	}
+	videoTrack := pirtc.stream.GetVideoTracks()[0].(*mediadevices.VideoTrack)
+	pirtc.mu.Unlock()
+
+	// a new reader for every capture, a reader left idle between two captures
+	// would return old frames
+	videoReader := decodedFrames(videoTrack.NewReader(false))
+	if width > 0 || height > 0 {
+		if width <= 0 {
+			width = -1
+		}
+		if height <= 0 {
+			height = -1
+		}
+		videoReader = video.Scale(width, height, video.ScalerBiLinear)(videoReader)
+	}
+
+	for i := 0; i < warmupFrames; i++ {
+		_, release, err := videoReader.Read()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read frame: %v", err)
+		}
+		release()
+	}
+
+	frame, release, err := videoReader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read frame: %v", err)
+	}
+	return frame, release, nil
+}
+
+// drawTimestamp returns a copy of img with text drawn like the timestamp of
+// the overlay
+func drawTimestamp(img image.Image, text string) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	stamp := video.Overlay(video.OverlayOptions{
+		Text:         func(time.Time) []string { return []string{text} },
+		TextPosition: video.OverlayBottomLeft,
+		Margin:       overlayMargin,
+	})
+	// the overlay draws in place and never fails on a frame it is given
+	stamp(video.ReaderFunc(func() (image.Image, func(), error) {
+		return dst, func() {}, nil
+	})).Read()
+	return dst
+}
@@ -0,0 +1,64 @@
+package pirtc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+func TestDrawTimestamp(t *testing.T) {
+	takenAt := time.Date(2026, 3, 14, 15, 9, 26, 0, time.Local)
+	background := color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+	gray := func() *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+		draw.Draw(img, img.Rect, image.NewUniform(background), image.Point{}, draw.Src)
+		return img
+	}
+
+	frame := gray()
+	stamped := drawTimestamp(frame, takenAt.Format(defaultTimestampLayout))
+	if stamped == image.Image(frame) {
+		t.Fatal("Expected a copy of the frame")
+	}
+
+	// the snapshots are stamped like the overlay of the camera stamps the video
+	overlay := &OverlayConfig{TimestampLayout: defaultTimestampLayout}
+	withOverlay := gray()
+	reader := overlay.transform()(video.ReaderFunc(func() (image.Image, func(), error) {
+		return withOverlay, func() {}, nil
+	}))
+	if _, _, err := reader.Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stampedRGBA := stamped.(*image.RGBA)
+	if stampedRGBA.Rect != withOverlay.Rect {
+		t.Fatalf("Expected %v, got %v", withOverlay.Rect, stampedRGBA.Rect)
+	}
+	// the times differ, the bands are drawn at the same place
+	band := func(img *image.RGBA) image.Rectangle {
+		var bounds image.Rectangle
+		for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+			for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+				if img.RGBAAt(x, y) != background {
+					bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		return bounds
+	}
+	stampedBand, overlayBand := band(stampedRGBA), band(withOverlay)
+	if stampedBand.Empty() {
+		t.Fatal("Expected a timestamp")
+	}
+	if stampedBand != overlayBand {
+		t.Errorf("Expected the timestamp in %v, got %v", overlayBand, stampedBand)
+	}
+	if band(frame) != (image.Rectangle{}) {
+		t.Error("Expected the frame to be kept")
+	}
+}
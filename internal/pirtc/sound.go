@@ -0,0 +1,195 @@
+package pirtc
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/io/audio"
+)
+
+const soundEventsBufferSize = 8
+
+// audioLevelInterval is the time between two levels of the meter, the loudest
+// chunk of the interval is reported
+const audioLevelInterval = 250 * time.Millisecond
+
+// SoundDetectionConfig configures the detection of loud sounds, like shouts,
+// alarms or breaking glass. The levels are in dBFS, the zero values use the
+// defaults of audio.SoundEventOptions.
+type SoundDetectionConfig struct {
+	Threshold     float64
+	PeakThreshold float64
+	MinDuration   time.Duration
+	HoldTime      time.Duration
+}
+
+// SoundEvent tells when a loud sound starts and when the silence is back
+type SoundEvent struct {
+	Loud  bool
+	Level audio.Level
+	Time  time.Time
+}
+
+// SetSoundDetection listens to the microphone and reports the loud sounds and
+// the levels, nil stops listening. The microphone is shared with the stream.
+func (pirtc *PiRTC) SetSoundDetection(config *SoundDetectionConfig) error {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	if pirtc.stopSoundDetection != nil {
+		close(pirtc.stopSoundDetection)
+		pirtc.stopSoundDetection = nil
+		pirtc.releaseMicrophone()
+	}
+	pirtc.soundDetection = nil
+	if config == nil {
+		return nil
+	}
+
+	track, err := pirtc.acquireMicrophone()
+	if err != nil {
+		return err
+	}
+	current := *config
+	pirtc.soundDetection = &current
+	pirtc.stopSoundDetection = make(chan struct{})
+	go pirtc.detectSounds(track, current, pirtc.stopSoundDetection)
+	return nil
+}
+
+// SoundDetection returns the current configuration, nil when disabled
+func (pirtc *PiRTC) SoundDetection() *SoundDetectionConfig {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	if pirtc.soundDetection == nil {
+		return nil
+	}
+	config := *pirtc.soundDetection
+	return &config
+}
+
+// SoundEvents returns the stream of loud sounds, the events are dropped when
+// nobody reads them
+func (pirtc *PiRTC) SoundEvents() <-chan SoundEvent {
+	return pirtc.soundEvents
+}
+
+// AudioLevels returns the levels of the microphone while the sounds are
+// detected, a level is dropped when the previous one wasn't read
+func (pirtc *PiRTC) AudioLevels() <-chan audio.Level {
+	return pirtc.audioLevels
+}
+
+func (pirtc *PiRTC) detectSounds(track *mediadevices.AudioTrack, config SoundDetectionConfig, stop <-chan struct{}) {
+	var loud bool
+	var lastLevel time.Time
+	meter := audio.Level{RMS: math.Inf(-1), Peak: math.Inf(-1)}
+	reader := audio.SoundEvents(audio.SoundEventOptions{
+		Threshold:     config.Threshold,
+		PeakThreshold: config.PeakThreshold,
+		MinDuration:   config.MinDuration,
+		HoldTime:      config.HoldTime,
+		OnLevel: func(level audio.Level) {
+			meter.RMS = math.Max(meter.RMS, level.RMS)
+			meter.Peak = math.Max(meter.Peak, level.Peak)
+		},
+		OnEvent: func(isLoud bool, level audio.Level) {
+			loud = isLoud
+			pirtc.signalSoundEvent(SoundEvent{Loud: isLoud, Level: level, Time: time.Now()})
+		},
+	})(track.NewReader(false))
+	log.Println("Sound detection started")
+	defer func() {
+		if loud {
+			// the recordings started by the sound must end
+			pirtc.signalSoundEvent(SoundEvent{Level: meter, Time: time.Now()})
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			log.Println("Sound detection stopped")
+			return
+		default:
+		}
+
+		_, release, err := reader.Read()
+		if err != nil {
+			select {
+			case <-stop:
+			default:
+				log.Printf("[Sound error]: %v\n", err)
+			}
+			return
+		}
+		release()
+
+		if now := time.Now(); now.Sub(lastLevel) >= audioLevelInterval {
+			select {
+			case pirtc.audioLevels <- meter:
+			default:
+			}
+			lastLevel = now
+			meter = audio.Level{RMS: math.Inf(-1), Peak: math.Inf(-1)}
+		}
+	}
+}
+
+func (pirtc *PiRTC) signalSoundEvent(event SoundEvent) {
+	select {
+	case pirtc.soundEvents <- event:
+	default:
+		log.Printf("[Sound]: event dropped, nobody is listening\n")
+	}
+}
+
+// acquireMicrophone returns the microphone, it is opened by its first user.
+// It must be called with pirtc.mu held.
+func (pirtc *PiRTC) acquireMicrophone() (*mediadevices.AudioTrack, error) {
+	if pirtc.microphone == nil {
+		track, err := openMicrophone(pirtc.codecSelector, pirtc.audioProcessingTransform())
+		if err != nil {
+			return nil, err
+		}
+		pirtc.microphone = track
+	}
+	pirtc.microphoneUsers++
+	return pirtc.microphone, nil
+}
+
+// openMicrophone opens the microphone with the audio processing transform,
+// it doesn't need pirtc.mu
+func openMicrophone(selector *mediadevices.CodecSelector, transform audio.TransformFunc) (*mediadevices.AudioTrack, error) {
+	audioStream, err := mediadevices.GetUserMedia(mediadevices.MediaStreamConstraints{
+		Audio: func(constraint *mediadevices.MediaTrackConstraints) {},
+		Codec: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	track := audioStream.GetAudioTracks()[0].(*mediadevices.AudioTrack)
+	if transform != nil {
+		track.Transform(transform)
+	}
+	shareEncoder(track)
+	return track, nil
+}
+
+// releaseMicrophone closes the microphone when its last user releases it. It
+// must be called with pirtc.mu held.
+func (pirtc *PiRTC) releaseMicrophone() {
+	if pirtc.microphone == nil {
+		return
+	}
+	pirtc.microphoneUsers--
+	if pirtc.microphoneUsers > 0 {
+		return
+	}
+	if err := pirtc.microphone.Close(); err != nil {
+		log.Printf("[Sound error]: %v\n", err)
+	}
+	pirtc.microphone = nil
+	pirtc.microphoneUsers = 0
+}
@@ -0,0 +1,335 @@
+package pirtc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/at-wat/ebml-go/webm"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/codec/vpx"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// TimelapseFormat is the container of a time-lapse
+type TimelapseFormat string
+
+const (
+	// TimelapseWebM encodes the frames in VP8 with an encoder separated from the
+	// stream encoder
+	TimelapseWebM TimelapseFormat = "webm"
+	// TimelapseMJPEG stores every frame as a JPEG image in an AVI file, it
+	// needs more space but no video encoder
+	TimelapseMJPEG TimelapseFormat = "avi"
+)
+
+const (
+	defaultTimelapseInterval    = time.Minute
+	defaultTimelapsePlaybackFPS = 25
+	timelapseDayLayout          = "2006-01-02"
+)
+
+var errUnknownTimelapseFormat = errors.New("UNKNOWN TIMELAPSE FORMAT")
+
+// TimelapseOptions configures RecordTimelapse
+type TimelapseOptions struct {
+	// Dir receives one file per day, named timelapse_<date>_<time>.<format>
+	Dir    string
+	Format TimelapseFormat
+	// Interval between two captures
+	Interval time.Duration
+	// PlaybackFPS is the frame rate of the saved video
+	PlaybackFPS int
+	// Quality of the JPEG frames of a MJPEG time-lapse
+	Quality int
+	// Width and Height scale the frames, see ShotOptions
+	Width  int
+	Height int
+	// Timestamp burns the capture time on every frame, unless the overlay of
+	// the camera already draws it
+	Timestamp bool
+}
+
+// timelapseSegment is the file of a time-lapse for a day
+type timelapseSegment interface {
+	WriteFrame(img image.Image) error
+	Close() error
+}
+
+// RecordTimelapse captures a frame every opts.Interval until stopCh is closed.
+// The camera is only held during the captures, between them it is released if
+// no viewer is watching. The time-lapse rolls over every day, the path of each
+// finished file is sent on the returned channel which is closed at the end.
+func (pirtc *PiRTC) RecordTimelapse(opts TimelapseOptions, stopCh <-chan struct{}) (<-chan string, error) {
+	if opts.Format == "" {
+		opts.Format = TimelapseWebM
+	}
+	if opts.Format != TimelapseWebM && opts.Format != TimelapseMJPEG {
+		return nil, errUnknownTimelapseFormat
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultTimelapseInterval
+	}
+	if opts.PlaybackFPS <= 0 {
+		opts.PlaybackFPS = defaultTimelapsePlaybackFPS
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	recorder := &timelapseRecorder{
+		opts: opts,
+		capture: func() (image.Image, time.Time, error) {
+			return pirtc.captureTimelapseFrame(opts)
+		},
+		newSegment: func(path string, size image.Point) (timelapseSegment, error) {
+			return pirtc.newTimelapseSegment(path, size, opts)
+		},
+	}
+	segments := make(chan string, 1)
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		recorder.run(ticker.C, stopCh, segments)
+	}()
+	return segments, nil
+}
+
+// timelapseRecorder writes the frames of a time-lapse and rolls its files over,
+// capture returns a frame with the time it was taken
+type timelapseRecorder struct {
+	opts       TimelapseOptions
+	capture    func() (image.Image, time.Time, error)
+	newSegment func(path string, size image.Point) (timelapseSegment, error)
+}
+
+// run captures a frame now and at every tick until stopCh is closed
+func (recorder *timelapseRecorder) run(ticks <-chan time.Time, stopCh <-chan struct{}, segments chan<- string) {
+	defer close(segments)
+
+	var (
+		segment     timelapseSegment
+		segmentPath string
+		segmentDay  string
+		segmentSize image.Point
+	)
+	closeSegment := func() {
+		if segment == nil {
+			return
+		}
+		if err := segment.Close(); err != nil {
+			log.Printf("[Timelapse]: failed to close %s: %v\n", segmentPath, err)
+		}
+		log.Printf("[Timelapse]: %s saved\n", segmentPath)
+		segments <- segmentPath
+		segment = nil
+	}
+	defer closeSegment()
+
+	log.Println("Recording time-lapse...")
+	for {
+		img, takenAt, err := recorder.capture()
+		if err != nil {
+			log.Printf("[Timelapse]: capture failed: %v\n", err)
+		} else {
+			size := img.Bounds().Size()
+			day := takenAt.Format(timelapseDayLayout)
+			// a new file every day, and when the resolution of the camera changed
+			if segment != nil && (day != segmentDay || size != segmentSize) {
+				closeSegment()
+			}
+			if segment == nil {
+				segmentPath = filepath.Join(recorder.opts.Dir, fmt.Sprintf("timelapse_%s.%s", takenAt.Format("2006-01-02_150405"), recorder.opts.Format))
+				segment, err = recorder.newSegment(segmentPath, size)
+				if err != nil {
+					log.Printf("[Timelapse]: failed to create %s: %v\n", segmentPath, err)
+				}
+				segmentDay, segmentSize = day, size
+			}
+			if segment != nil {
+				if err := segment.WriteFrame(img); err != nil {
+					log.Printf("[Timelapse]: failed to write frame: %v\n", err)
+				}
+			}
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticks:
+		}
+	}
+}
+
+// captureTimelapseFrame opens the camera for a single frame, the frame is a copy
+// which stays valid after the camera is released
+func (pirtc *PiRTC) captureTimelapseFrame(opts TimelapseOptions) (image.Image, time.Time, error) {
+	pirtc.incrementStreamUsage()
+	defer pirtc.decrementStreamUsage()
+	if err := pirtc.enableStream(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	img, release, err := pirtc.captureFrame(opts.Width, opts.Height, 1)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer release()
+	takenAt := time.Now()
+
+	if opts.Timestamp && !pirtc.overlayStampsTime() {
+		return drawTimestamp(img, takenAt.Format(defaultTimestampLayout)), takenAt, nil
+	}
+	buffer := video.NewFrameBuffer(0)
+	buffer.StoreCopy(img)
+	return buffer.Load(), takenAt, nil
+}
+
+func (pirtc *PiRTC) newTimelapseSegment(path string, size image.Point, opts TimelapseOptions) (timelapseSegment, error) {
+	if opts.Format == TimelapseMJPEG {
+		segment, err := newMJPEGSegment(path, size, opts)
+		if err != nil {
+			return nil, err
+		}
+		return segment, nil
+	}
+	segment, err := newWebmSegment(path, size, opts, pirtc.params)
+	if err != nil {
+		return nil, err
+	}
+	return segment, nil
+}
+
+type mjpegSegment struct {
+	file    *os.File
+	writer  *aviWriter
+	quality int
+}
+
+func newMJPEGSegment(path string, size image.Point, opts TimelapseOptions) (*mjpegSegment, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := newAviWriter(file, size.X, size.Y, opts.PlaybackFPS)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &mjpegSegment{file: file, writer: writer, quality: opts.Quality}, nil
+}
+
+func (segment *mjpegSegment) WriteFrame(img image.Image) error {
+	var jpegOpts *jpeg.Options
+	if segment.quality > 0 {
+		jpegOpts = &jpeg.Options{Quality: segment.quality}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, jpegOpts); err != nil {
+		return err
+	}
+	return segment.writer.WriteFrame(buf.Bytes())
+}
+
+func (segment *mjpegSegment) Close() error {
+	err := segment.writer.Close()
+	if closeErr := segment.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type webmSegment struct {
+	encoder codec.ReadCloser
+	writer  webm.BlockWriteCloser
+	// next is the frame returned to the encoder by its reader
+	next   image.Image
+	frames int64
+	fps    int64
+}
+
+func newWebmSegment(path string, size image.Point, opts TimelapseOptions, params vpx.VP8Params) (*webmSegment, error) {
+	segment := &webmSegment{fps: int64(opts.PlaybackFPS)}
+
+	// a key frame every second of playback keeps the time-lapse seekable
+	params.KeyFrameInterval = opts.PlaybackFPS
+	reader := video.ReaderFunc(func() (image.Image, func(), error) {
+		return segment.next, func() {}, nil
+	})
+	encoder, err := params.BuildVideoEncoder(reader, prop.Media{
+		Video: prop.Video{
+			Width:       size.X,
+			Height:      size.Y,
+			FrameRate:   float32(opts.PlaybackFPS),
+			FrameFormat: frame.FormatI420,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		encoder.Close()
+		return nil, err
+	}
+	writers, err := webm.NewSimpleBlockWriter(file,
+		[]webm.TrackEntry{
+			{
+				Name:            "Video",
+				TrackNumber:     1,
+				TrackUID:        67890,
+				CodecID:         "V_VP8",
+				TrackType:       1,
+				DefaultDuration: uint64(time.Second) / uint64(opts.PlaybackFPS),
+				Video: &webm.Video{
+					PixelWidth:  uint64(size.X),
+					PixelHeight: uint64(size.Y),
+				},
+			},
+		})
+	if err != nil {
+		encoder.Close()
+		file.Close()
+		return nil, err
+	}
+	segment.encoder = encoder
+	segment.writer = writers[0]
+	return segment, nil
+}
+
+func (segment *webmSegment) WriteFrame(img image.Image) error {
+	segment.next = img
+	data, release, err := segment.encoder.Read()
+	segment.next = nil
+	if err != nil {
+		return err
+	}
+	defer release()
+	// the rate control may drop a frame
+	if len(data) == 0 {
+		return nil
+	}
+
+	keyFrame := data[0]&0x1 == 0
+	timestamp := segment.frames * 1000 / segment.fps
+	segment.frames++
+	_, err = segment.writer.Write(keyFrame, timestamp, data)
+	return err
+}
+
+func (segment *webmSegment) Close() error {
+	err := segment.writer.Close()
+	if closeErr := segment.encoder.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
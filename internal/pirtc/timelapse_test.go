@@ -0,0 +1,93 @@
+package pirtc
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimelapseRollover(t *testing.T) {
+	// the clock of the captures crosses midnight, then the camera resolution changes
+	start := time.Date(2026, 3, 14, 23, 58, 0, 0, time.Local)
+	captures := []struct {
+		offset time.Duration
+		size   image.Point
+		err    error
+	}{
+		{0, image.Pt(64, 48), nil},
+		{time.Minute, image.Pt(64, 48), nil},
+		{2 * time.Minute, image.Pt(64, 48), nil},
+		{3 * time.Minute, image.Pt(64, 48), errors.New("camera busy")},
+		{4 * time.Minute, image.Pt(64, 48), nil},
+		{5 * time.Minute, image.Pt(32, 24), nil},
+	}
+	expected := []struct {
+		name   string
+		frames uint32
+		width  uint32
+	}{
+		{"timelapse_2026-03-14_235800.avi", 2, 64},
+		{"timelapse_2026-03-15_000000.avi", 2, 64},
+		{"timelapse_2026-03-15_000300.avi", 1, 32},
+	}
+
+	dir := t.TempDir()
+	opts := TimelapseOptions{Dir: dir, Format: TimelapseMJPEG, PlaybackFPS: 10}
+	captured := 0
+	recorder := &timelapseRecorder{
+		opts: opts,
+		capture: func() (image.Image, time.Time, error) {
+			capture := captures[captured]
+			captured++
+			return image.NewGray(image.Rectangle{Max: capture.size}), start.Add(capture.offset), capture.err
+		},
+		newSegment: func(path string, size image.Point) (timelapseSegment, error) {
+			return newMJPEGSegment(path, size, opts)
+		},
+	}
+
+	ticks := make(chan time.Time)
+	stopCh := make(chan struct{})
+	segments := make(chan string, len(expected))
+	done := make(chan struct{})
+	go func() {
+		recorder.run(ticks, stopCh, segments)
+		close(done)
+	}()
+	// every tick is received once the previous capture is written
+	for range captures[1:] {
+		ticks <- time.Time{}
+	}
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the recorder to stop")
+	}
+
+	var paths []string
+	for path := range segments {
+		paths = append(paths, path)
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected %d files, got %v", len(expected), paths)
+	}
+	for i, segment := range expected {
+		if paths[i] != filepath.Join(dir, segment.name) {
+			t.Errorf("Expected %s, got %s", segment.name, paths[i])
+			continue
+		}
+		data, err := os.ReadFile(paths[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		avi := readAvi(t, data)
+		if avi.totalFrames != segment.frames || avi.width != segment.width {
+			t.Errorf("%s: expected %d frames %d pixels wide, got %d frames %d pixels wide",
+				segment.name, segment.frames, segment.width, avi.totalFrames, avi.width)
+		}
+	}
+}
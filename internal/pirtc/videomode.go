@@ -0,0 +1,220 @@
+package pirtc
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+var errInvalidVideoMode = errors.New("INVALID VIDEO MODE")
+
+const (
+	// bandwidthUpgradeMargin is the extra bandwidth a higher mode needs to be
+	// picked, the mode doesn't flap around the threshold
+	bandwidthUpgradeMargin = 1.25
+	// bandwidthUpgradeDelay is how long a mode is kept before a higher one
+	// is picked
+	bandwidthUpgradeDelay = 10 * time.Second
+)
+
+// VideoMode is the resolution and frame rate asked to the camera, the camera
+// opens the closest mode it supports
+type VideoMode struct {
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	FrameRate float32 `json:"fps,omitempty"`
+
+	// format is the compressed format of the camera passed through, the
+	// camera is decoded to I420 when it is empty. It isn't opened in
+	// passthrough then, the frames of this format aren't decoded.
+	format      frame.Format
+	passthrough frame.Format
+}
+
+var defaultVideoMode = VideoMode{Width: 1280, Height: 720}
+
+// bandwidthVideoModes are the modes picked by AdaptToBandwidth, from the
+// highest to the lowest, with the bandwidth in bit/s they need
+var bandwidthVideoModes = []struct {
+	minBitRate int
+	mode       VideoMode
+}{
+	{400_000, VideoMode{Width: 1280, Height: 720, FrameRate: 30}},
+	{200_000, VideoMode{Width: 640, Height: 480, FrameRate: 20}},
+	{0, VideoMode{Width: 320, Height: 240, FrameRate: 15}},
+}
+
+func (mode VideoMode) constraints(constraint *mediadevices.MediaTrackConstraints) {
+	if mode.format != "" {
+		constraint.FrameFormat = prop.FrameFormatExact(mode.format)
+	} else {
+		constraint.FrameFormat = decodedFrameFormat(mode.passthrough)
+	}
+	constraint.Width = prop.Int(mode.Width)
+	constraint.Height = prop.Int(mode.Height)
+	if mode.FrameRate > 0 {
+		constraint.FrameRate = prop.Float(mode.FrameRate)
+	}
+}
+
+// decodedFrameFormat prefers I420 and rejects the format passed through, which
+// would be read compressed
+type decodedFrameFormat frame.Format
+
+func (f decodedFrameFormat) Compare(a frame.Format) (float64, bool) {
+	if a == frame.FormatI420 {
+		return 0.0, true
+	}
+	return 1.0, f == "" || a != frame.Format(f)
+}
+
+func (f decodedFrameFormat) Value() (frame.Format, bool) { return frame.FormatI420, true }
+
+// VideoMode returns the mode asked to the camera
+func (pirtc *PiRTC) VideoMode() VideoMode {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	return pirtc.videoModeOrDefault()
+}
+
+// SetVideoMode changes the resolution and frame rate of the camera. An open
+// camera is reconfigured without dropping the viewers, they receive a key
+// frame at the new resolution.
+func (pirtc *PiRTC) SetVideoMode(mode VideoMode) error {
+	if mode.Width <= 0 || mode.Height <= 0 || mode.FrameRate < 0 {
+		return errInvalidVideoMode
+	}
+
+	pirtc.reconfigureMu.Lock()
+	defer pirtc.reconfigureMu.Unlock()
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	return pirtc.setVideoMode(mode)
+}
+
+// setVideoMode must be called with pirtc.reconfigureMu and pirtc.mu held, the
+// mode is kept only when the camera could be reconfigured
+func (pirtc *PiRTC) setVideoMode(mode VideoMode) error {
+	previous := pirtc.videoMode
+	pirtc.videoMode = &mode
+	if err := pirtc.reconfigureCamera(); err != nil {
+		pirtc.videoMode = previous
+		return err
+	}
+	return nil
+}
+
+// AdaptToBandwidth picks the video mode fitting the bandwidth available to the
+// viewer uuid, in bit/s. It is called by the bandwidth estimation. The mode
+// fits the slowest viewer: a lower mode is picked at once, a higher one once
+// the bandwidth exceeds its need by bandwidthUpgradeMargin and the current
+// mode was kept for bandwidthUpgradeDelay.
+func (pirtc *PiRTC) AdaptToBandwidth(uuid string, bitRate int) error {
+	pirtc.reconfigureMu.Lock()
+	defer pirtc.reconfigureMu.Unlock()
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+
+	pirtc.bandwidths[uuid] = bitRate
+	for _, viewerBitRate := range pirtc.bandwidths {
+		if viewerBitRate < bitRate {
+			bitRate = viewerBitRate
+		}
+	}
+
+	current := -1
+	for i, candidate := range bandwidthVideoModes {
+		if candidate.mode == pirtc.videoModeOrDefault() {
+			current = i
+		}
+	}
+	for i, candidate := range bandwidthVideoModes {
+		if bitRate < candidate.minBitRate {
+			continue
+		}
+		if i == current {
+			return nil
+		}
+		if current >= 0 && i < current {
+			if time.Since(pirtc.bandwidthModeSet) < bandwidthUpgradeDelay {
+				return nil
+			}
+			if float64(bitRate) < bandwidthUpgradeMargin*float64(candidate.minBitRate) {
+				continue
+			}
+		}
+		if err := pirtc.setVideoMode(candidate.mode); err != nil {
+			return err
+		}
+		pirtc.bandwidthModeSet = time.Now()
+		return nil
+	}
+	return nil
+}
+
+// forgetBandwidth drops the estimate of a viewer which left, the mode follows
+// the remaining viewers from their next estimate
+func (pirtc *PiRTC) forgetBandwidth(uuid string) {
+	pirtc.mu.Lock()
+	defer pirtc.mu.Unlock()
+	delete(pirtc.bandwidths, uuid)
+}
+
+// videoModeOrDefault must be called with pirtc.mu held
+func (pirtc *PiRTC) videoModeOrDefault() VideoMode {
+	if pirtc.videoMode == nil {
+		return defaultVideoMode
+	}
+	return *pirtc.videoMode
+}
+
+// cameraMode must be called with pirtc.mu held, it is the mode asked by the
+// operator with the frame rate of the scene profile, in the format of the open
+// camera
+func (pirtc *PiRTC) cameraMode() VideoMode {
+	mode := pirtc.videoModeOrDefault()
+	mode.format = pirtc.videoFormat
+	mode.passthrough = pirtc.videoPassthrough
+	if pirtc.sceneFrameRate > 0 {
+		mode.FrameRate = pirtc.sceneFrameRate
+	}
+	return mode
+}
+
+// reconfigureCamera must be called with pirtc.reconfigureMu and pirtc.mu held,
+// it reconfigures the open camera when its mode changed. pirtc.mu is released
+// while the driver is closed and reopened.
+func (pirtc *PiRTC) reconfigureCamera() error {
+	mode := pirtc.cameraMode()
+	stream := pirtc.stream
+	if stream == nil || mode == pirtc.openedMode {
+		// the mode will be used when the camera is opened
+		return nil
+	}
+
+	pirtc.mu.Unlock()
+	err := reconfigureTracks(stream, mode)
+	pirtc.mu.Lock()
+	if err != nil {
+		return err
+	}
+	// a stream opened meanwhile already has the mode
+	if pirtc.stream == stream {
+		pirtc.openedMode = mode
+	}
+	log.Printf("[Camera]: reconfigured to %dx%d@%v\n", mode.Width, mode.Height, mode.FrameRate)
+	return nil
+}
+
+func reconfigureTracks(stream mediadevices.MediaStream, mode VideoMode) error {
+	for _, track := range stream.GetVideoTracks() {
+		if err := track.(*mediadevices.VideoTrack).Reconfigure(mode.constraints); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package pirtc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptToBandwidth(t *testing.T) {
+	high, medium, low := bandwidthVideoModes[0].mode, bandwidthVideoModes[1].mode, bandwidthVideoModes[2].mode
+
+	testCases := map[string]struct {
+		mode VideoMode
+		// held is how long the mode was kept
+		held    time.Duration
+		viewers map[string]int
+		bitRate int
+		// expected is the mode after the estimate, changed tells if it was set
+		expected VideoMode
+		changed  bool
+	}{
+		"Downgrade": {
+			mode:     high,
+			bitRate:  150_000,
+			expected: low,
+			changed:  true,
+		},
+		"Kept": {
+			mode:     medium,
+			held:     time.Minute,
+			bitRate:  300_000,
+			expected: medium,
+		},
+		"UpgradeHeld": {
+			mode:     medium,
+			held:     bandwidthUpgradeDelay - time.Second,
+			bitRate:  1_000_000,
+			expected: medium,
+		},
+		"UpgradeUnderMargin": {
+			mode:     medium,
+			held:     time.Minute,
+			bitRate:  450_000,
+			expected: medium,
+		},
+		"Upgrade": {
+			mode:     medium,
+			held:     bandwidthUpgradeDelay,
+			bitRate:  500_000,
+			expected: high,
+			changed:  true,
+		},
+		"UpgradeToFittingMode": {
+			mode:     low,
+			held:     time.Minute,
+			bitRate:  450_000,
+			expected: medium,
+			changed:  true,
+		},
+		"SlowestViewer": {
+			mode:     high,
+			viewers:  map[string]int{"slow": 250_000},
+			bitRate:  1_000_000,
+			expected: medium,
+			changed:  true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			// without stream the mode is only kept for the next camera
+			mode := testCase.mode
+			modeSet := time.Now().Add(-testCase.held)
+			pirtc := &PiRTC{
+				videoMode:        &mode,
+				bandwidths:       map[string]int{},
+				bandwidthModeSet: modeSet,
+			}
+			for uuid, bitRate := range testCase.viewers {
+				pirtc.bandwidths[uuid] = bitRate
+			}
+
+			if err := pirtc.AdaptToBandwidth("viewer", testCase.bitRate); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if mode := pirtc.VideoMode(); mode != testCase.expected {
+				t.Errorf("Expected %+v, got %+v", testCase.expected, mode)
+			}
+			if changed := !pirtc.bandwidthModeSet.Equal(modeSet); changed != testCase.changed {
+				t.Errorf("Expected the mode to be set %v, got %v", testCase.changed, changed)
+			}
+		})
+	}
+}
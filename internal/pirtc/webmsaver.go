@@ -6,12 +6,21 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/at-wat/ebml-go/mkvcore"
 	"github.com/at-wat/ebml-go/webm"
 	"github.com/pion/rtp"
 	"github.com/pion/rtp/codecs"
 	"github.com/pion/webrtc/v4/pkg/media/samplebuilder"
 )
 
+// matroskaHeader is the header of the files of the codecs WebM doesn't
+// allow, H.264 and MJPEG. These files are saved as .mkv.
+var matroskaHeader = func() *webm.EBMLHeader {
+	header := *webm.DefaultEBMLHeader
+	header.DocType = "matroska"
+	return &header
+}()
+
 type webmSaver struct {
 	videoWriter    webm.BlockWriteCloser
 	videoBuilder   *samplebuilder.SampleBuilder
@@ -62,7 +71,105 @@ func (s *webmSaver) PushVP8(path string, rtpPacket *rtp.Packet) {
 	}
 }
 
+// PushH264 writes an access unit of H.264 in Annex B format lasting samples at
+// 90kHz. The file starts at the first key frame, its parameter sets describe
+// the video.
+func (s *webmSaver) PushH264(path string, accessUnit []byte, samples uint32, width, height int) {
+	nalus := splitAnnexB(accessUnit)
+	var sps, pps []byte
+	videoKeyframe := false
+	for _, nalu := range nalus {
+		switch nalu[0] & 0x1f {
+		case 5:
+			videoKeyframe = true
+		case 7:
+			sps = nalu
+		case 8:
+			pps = nalu
+		}
+	}
+	if s.videoWriter == nil {
+		if !videoKeyframe || len(sps) < 4 || pps == nil {
+			return
+		}
+		s.initWriter(path, "V_MPEG4/ISO/AVC", avcDecoderConfiguration(sps, pps), width, height, matroskaHeader)
+	}
+
+	// Matroska keeps the NAL units prefixed with their length
+	data := make([]byte, 0, len(accessUnit)+4*len(nalus))
+	for _, nalu := range nalus {
+		n := len(nalu)
+		data = append(data, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		data = append(data, nalu...)
+	}
+	s.videoTimestamp += time.Duration(samples) * time.Second / 90000
+	if _, err := s.videoWriter.Write(videoKeyframe, int64(s.videoTimestamp/time.Millisecond), data); err != nil {
+		panic(err)
+	}
+}
+
+// PushMJPEG writes a JPEG frame lasting samples at 90kHz, every frame is a key
+// frame.
+func (s *webmSaver) PushMJPEG(path string, jpeg []byte, samples uint32, width, height int) {
+	if s.videoWriter == nil {
+		s.initWriter(path, "V_MJPEG", nil, width, height, matroskaHeader)
+	}
+	s.videoTimestamp += time.Duration(samples) * time.Second / 90000
+	if _, err := s.videoWriter.Write(true, int64(s.videoTimestamp/time.Millisecond), jpeg); err != nil {
+		panic(err)
+	}
+}
+
+// splitAnnexB returns the NAL units of an access unit separated by start codes
+func splitAnnexB(accessUnit []byte) [][]byte {
+	var nalus [][]byte
+	start := -1
+	for i := 0; i+2 < len(accessUnit); i++ {
+		if accessUnit[i] != 0 || accessUnit[i+1] != 0 || accessUnit[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			nalus = appendNALU(nalus, accessUnit[start:i])
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 {
+		nalus = appendNALU(nalus, accessUnit[start:])
+	}
+	return nalus
+}
+
+// appendNALU appends nalu without the zero bytes of the next 4 bytes start code
+func appendNALU(nalus [][]byte, nalu []byte) [][]byte {
+	for len(nalu) > 0 && nalu[len(nalu)-1] == 0 {
+		nalu = nalu[:len(nalu)-1]
+	}
+	if len(nalu) == 0 {
+		return nalus
+	}
+	return append(nalus, nalu)
+}
+
+// avcDecoderConfiguration is the CodecPrivate of the H.264 tracks, an
+// AVCDecoderConfigurationRecord with a single SPS and PPS
+func avcDecoderConfiguration(sps, pps []byte) []byte {
+	record := []byte{1, sps[1], sps[2], sps[3], 0xff, 0xe1, byte(len(sps) >> 8), byte(len(sps))}
+	record = append(record, sps...)
+	record = append(record, 1, byte(len(pps)>>8), byte(len(pps)))
+	return append(record, pps...)
+}
+
+// InitWriter starts a VP8 file, in Matroska when path is a .mkv file
 func (s *webmSaver) InitWriter(path string, width, height int) {
+	header := webm.DefaultEBMLHeader
+	if filepath.Ext(path) == ".mkv" {
+		header = matroskaHeader
+	}
+	s.initWriter(path, "V_VP8", nil, width, height, header)
+}
+
+func (s *webmSaver) initWriter(path, codecID string, codecPrivate []byte, width, height int, header *webm.EBMLHeader) {
 	dir := filepath.Dir(path)
 
 	// Create directory if not exist
@@ -83,7 +190,8 @@ func (s *webmSaver) InitWriter(path string, width, height int) {
 				Name:            "Video",
 				TrackNumber:     1,
 				TrackUID:        67890,
-				CodecID:         "V_VP8",
+				CodecID:         codecID,
+				CodecPrivate:    codecPrivate,
 				TrackType:       1,
 				DefaultDuration: 33333333,
 				Video: &webm.Video{
@@ -91,7 +199,9 @@ func (s *webmSaver) InitWriter(path string, width, height int) {
 					PixelHeight: uint64(height),
 				},
 			},
-		})
+		},
+		mkvcore.WithEBMLHeader(header),
+	)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,489 @@
+package pirtc
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// Minimal lossless WebP (VP8L) encoder: subtract green and left predictor
+// transforms followed by Huffman coded literals and runs. There is no lossy WebP
+// encoder without libwebp, lossless is good enough for snapshots.
+
+const (
+	vp8lSignature      = 0x2f
+	vp8lMaxDimension   = 1 << 14
+	vp8lPredictorBits  = 9
+	vp8lPredictorLeft  = 1
+	vp8lMaxCodeLength  = 15
+	vp8lMaxCodeLenCode = 7
+	vp8lMinCopyLength  = 3
+	vp8lMaxCopyLength  = 4096
+	// distance code of the (1, 0) plane code, the pixel on the left
+	vp8lLeftDistanceCode = 2
+)
+
+const (
+	vp8lTransformPredictor     = 0
+	vp8lTransformSubtractGreen = 2
+)
+
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+var errWebpTooLarge = errors.New("webp: image is too large")
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	w.acc |= uint64(value) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
+
+// prefixCode is a canonical Huffman code, codes are stored bit reversed as
+// VP8L reads them starting from the most significant bit
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+func (c *prefixCode) writeSymbol(w *bitWriter, symbol int) {
+	if n := c.lengths[symbol]; n > 0 {
+		w.write(c.codes[symbol], uint(n))
+	}
+}
+
+// encodeWebp writes img as a lossless WebP file
+func encodeWebp(out io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > vp8lMaxDimension || height > vp8lMaxDimension || width == 0 || height == 0 {
+		return errWebpTooLarge
+	}
+
+	rgba, ok := img.(*image.NRGBA)
+	if !ok || rgba.Rect.Min != (image.Point{}) {
+		rgba = image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+	}
+
+	argb := make([]uint32, width*height)
+	opaque := true
+	for y := 0; y < height; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x := 0; x < width; x++ {
+			r, g, b, a := row[4*x], row[4*x+1], row[4*x+2], row[4*x+3]
+			if a != 0xff {
+				opaque = false
+			}
+			// subtract green
+			r -= g
+			b -= g
+			argb[y*width+x] = uint32(a)<<24 | uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+		}
+	}
+	residuals := predictLeft(argb, width, height)
+
+	w := &bitWriter{}
+	w.write(vp8lSignature, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	if opaque {
+		w.write(0, 1)
+	} else {
+		w.write(1, 1)
+	}
+	w.write(0, 3) // version
+
+	// transforms, the decoder undoes them in reverse order
+	w.write(1, 1)
+	w.write(vp8lTransformSubtractGreen, 2)
+	w.write(1, 1)
+	w.write(vp8lTransformPredictor, 2)
+	w.write(vp8lPredictorBits-2, 3)
+	blocksX := (width + 1<<vp8lPredictorBits - 1) >> vp8lPredictorBits
+	blocksY := (height + 1<<vp8lPredictorBits - 1) >> vp8lPredictorBits
+	modes := make([]uint32, blocksX*blocksY)
+	for i := range modes {
+		modes[i] = vp8lPredictorLeft << 8
+	}
+	writeEntropyImage(w, modes, false)
+	w.write(0, 1) // no more transforms
+
+	writeEntropyImage(w, residuals, true)
+
+	data := w.bytes()
+	chunkSize := len(data)
+	padding := chunkSize & 1
+
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+chunkSize+padding))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(chunkSize))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	if padding == 1 {
+		_, err := out.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// predictLeft applies the left predictor to every pixel, the first column is
+// predicted from the top pixel and the very first pixel from opaque black
+func predictLeft(argb []uint32, width, height int) []uint32 {
+	residuals := make([]uint32, len(argb))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			var predictor uint32
+			switch {
+			case x == 0 && y == 0:
+				predictor = 0xff000000
+			case x == 0:
+				predictor = argb[i-width]
+			default:
+				predictor = argb[i-1]
+			}
+			residuals[i] = subPixels(argb[i], predictor)
+		}
+	}
+	return residuals
+}
+
+func subPixels(a, b uint32) uint32 {
+	alphaGreen := 0x00ff00ff + (a & 0xff00ff00) - (b & 0xff00ff00)
+	redBlue := 0xff00ff00 + (a & 0x00ff00ff) - (b & 0x00ff00ff)
+	return (alphaGreen & 0xff00ff00) | (redBlue & 0x00ff00ff)
+}
+
+// writeEntropyImage writes pixels with one set of prefix codes and without
+// color cache. Runs of the previous pixel are coded as backward references.
+func writeEntropyImage(w *bitWriter, pixels []uint32, mainImage bool) {
+	w.write(0, 1) // no color cache
+	if mainImage {
+		w.write(0, 1) // no meta prefix codes
+	}
+
+	type symbol struct {
+		pixel  uint32
+		length int // backward reference to the left pixel when not zero
+	}
+	symbols := make([]symbol, 0, len(pixels))
+	for i := 0; i < len(pixels); {
+		run := 0
+		if i > 0 {
+			for i+run < len(pixels) && pixels[i+run] == pixels[i-1] && run < vp8lMaxCopyLength {
+				run++
+			}
+		}
+		if run >= vp8lMinCopyLength {
+			symbols = append(symbols, symbol{length: run})
+			i += run
+			continue
+		}
+		symbols = append(symbols, symbol{pixel: pixels[i]})
+		i++
+	}
+
+	// green + length prefixes, red, blue, alpha, distance prefixes
+	histograms := [5][]int{
+		make([]int, 256+24),
+		make([]int, 256),
+		make([]int, 256),
+		make([]int, 256),
+		make([]int, 40),
+	}
+	distancePrefix, _, _ := prefixEncode(vp8lLeftDistanceCode)
+	for _, s := range symbols {
+		if s.length > 0 {
+			prefix, _, _ := prefixEncode(s.length)
+			histograms[0][256+prefix]++
+			histograms[4][distancePrefix]++
+			continue
+		}
+		histograms[0][(s.pixel>>8)&0xff]++
+		histograms[1][(s.pixel>>16)&0xff]++
+		histograms[2][s.pixel&0xff]++
+		histograms[3][s.pixel>>24]++
+	}
+
+	var codes [5]*prefixCode
+	for i, histogram := range histograms {
+		codes[i] = writePrefixCode(w, histogram)
+	}
+
+	for _, s := range symbols {
+		if s.length > 0 {
+			prefix, extraBits, extra := prefixEncode(s.length)
+			codes[0].writeSymbol(w, 256+prefix)
+			w.write(extra, extraBits)
+			prefix, extraBits, extra = prefixEncode(vp8lLeftDistanceCode)
+			codes[4].writeSymbol(w, prefix)
+			w.write(extra, extraBits)
+			continue
+		}
+		codes[0].writeSymbol(w, int((s.pixel>>8)&0xff))
+		codes[1].writeSymbol(w, int((s.pixel>>16)&0xff))
+		codes[2].writeSymbol(w, int(s.pixel&0xff))
+		codes[3].writeSymbol(w, int(s.pixel>>24))
+	}
+}
+
+// prefixEncode splits a length or distance code into its prefix symbol and
+// extra bits
+func prefixEncode(value int) (prefix int, extraBits uint, extra uint32) {
+	if value <= 4 {
+		return value - 1, 0, 0
+	}
+	n := value - 1
+	highest := 0
+	for n>>(highest+1) != 0 {
+		highest++
+	}
+	second := (n >> (highest - 1)) & 1
+	extraBits = uint(highest - 1)
+	return 2*highest + second, extraBits, uint32(n) & (1<<extraBits - 1)
+}
+
+// writePrefixCode writes the Huffman code built for histogram and returns it
+func writePrefixCode(w *bitWriter, histogram []int) *prefixCode {
+	var used []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		// simple code
+		w.write(1, 1)
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		w.write(uint32(len(used)-1), 1)
+		w.write(1, 1) // 8 bits symbols
+		for _, symbol := range used {
+			w.write(uint32(symbol), 8)
+		}
+		code := &prefixCode{lengths: make([]uint8, len(histogram)), codes: make([]uint32, len(histogram))}
+		if len(used) == 2 {
+			code.lengths[used[0]], code.codes[used[0]] = 1, 0
+			code.lengths[used[1]], code.codes[used[1]] = 1, 1
+		}
+		return code
+	}
+
+	code := buildPrefixCode(histogram, vp8lMaxCodeLength)
+
+	// code lengths are run length encoded, only runs of zeros use repeat codes
+	var tokens, extraBits []int
+	for i := 0; i < len(code.lengths); {
+		length := int(code.lengths[i])
+		if length != 0 {
+			tokens = append(tokens, length)
+			extraBits = append(extraBits, 0)
+			i++
+			continue
+		}
+		run := 1
+		for i+run < len(code.lengths) && code.lengths[i+run] == 0 && run < 138 {
+			run++
+		}
+		switch {
+		case run >= 11:
+			tokens = append(tokens, 18)
+			extraBits = append(extraBits, run-11)
+		case run >= 3:
+			tokens = append(tokens, 17)
+			extraBits = append(extraBits, run-3)
+		default:
+			run = 1
+			tokens = append(tokens, 0)
+			extraBits = append(extraBits, 0)
+		}
+		i += run
+	}
+
+	lengthHistogram := make([]int, 19)
+	for _, token := range tokens {
+		lengthHistogram[token]++
+	}
+	// a complete code needs at least two symbols
+	if nonZero(lengthHistogram) < 2 {
+		if lengthHistogram[0] == 0 {
+			lengthHistogram[0] = 1
+		} else {
+			lengthHistogram[1] = 1
+		}
+	}
+	lengthCode := buildPrefixCode(lengthHistogram, vp8lMaxCodeLenCode)
+
+	numCodes := 19
+	for numCodes > 4 && lengthCode.lengths[vp8lCodeLengthOrder[numCodes-1]] == 0 {
+		numCodes--
+	}
+
+	w.write(0, 1) // normal code
+	w.write(uint32(numCodes-4), 4)
+	for i := 0; i < numCodes; i++ {
+		w.write(uint32(lengthCode.lengths[vp8lCodeLengthOrder[i]]), 3)
+	}
+	w.write(0, 1) // lengths of every symbol of the alphabet follow
+	for i, token := range tokens {
+		lengthCode.writeSymbol(w, token)
+		switch token {
+		case 17:
+			w.write(uint32(extraBits[i]), 3)
+		case 18:
+			w.write(uint32(extraBits[i]), 7)
+		}
+	}
+	return code
+}
+
+func nonZero(histogram []int) int {
+	n := 0
+	for _, count := range histogram {
+		if count > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// buildPrefixCode builds a canonical Huffman code whose lengths do not
+// exceed maxLength, histogram must have at least two used symbols
+func buildPrefixCode(histogram []int, maxLength int) *prefixCode {
+	counts := make([]int, len(histogram))
+	copy(counts, histogram)
+
+	var lengths []uint8
+	for {
+		lengths = huffmanLengths(counts)
+		longest := uint8(0)
+		for _, length := range lengths {
+			if length > longest {
+				longest = length
+			}
+		}
+		if int(longest) <= maxLength {
+			break
+		}
+		// flatten the distribution until the tree is shallow enough
+		for i, count := range counts {
+			if count > 0 {
+				counts[i] = (count + 1) / 2
+			}
+		}
+	}
+
+	code := &prefixCode{lengths: lengths, codes: make([]uint32, len(lengths))}
+	var lengthCount [vp8lMaxCodeLength + 1]uint32
+	for _, length := range lengths {
+		lengthCount[length]++
+	}
+	lengthCount[0] = 0
+	var nextCode [vp8lMaxCodeLength + 2]uint32
+	for length := 1; length <= vp8lMaxCodeLength; length++ {
+		nextCode[length+1] = (nextCode[length] + lengthCount[length]) << 1
+	}
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		c := nextCode[length]
+		nextCode[length]++
+		code.codes[symbol] = reverseBits(c, uint(length))
+	}
+	return code
+}
+
+// huffmanLengths returns the code length of every symbol of a Huffman tree
+func huffmanLengths(counts []int) []uint8 {
+	type node struct {
+		count       int
+		symbol      int
+		left, right *node
+	}
+
+	var nodes []*node
+	for symbol, count := range counts {
+		if count > 0 {
+			nodes = append(nodes, &node{count: count, symbol: symbol})
+		}
+	}
+	// ties are broken by symbol to keep the output deterministic
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].count != nodes[j].count {
+			return nodes[i].count < nodes[j].count
+		}
+		return nodes[i].symbol < nodes[j].symbol
+	})
+
+	// two queues Huffman construction: leaves and merged nodes are both sorted
+	var merged []*node
+	pop := func() *node {
+		if len(merged) == 0 || (len(nodes) > 0 && nodes[0].count <= merged[0].count) {
+			n := nodes[0]
+			nodes = nodes[1:]
+			return n
+		}
+		n := merged[0]
+		merged = merged[1:]
+		return n
+	}
+	for len(nodes)+len(merged) > 1 {
+		a := pop()
+		b := pop()
+		merged = append(merged, &node{count: a.count + b.count, symbol: -1, left: a, right: b})
+	}
+
+	lengths := make([]uint8, len(counts))
+	var walk func(n *node, depth uint8)
+	walk = func(n *node, depth uint8) {
+		if n.left == nil {
+			lengths[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	if len(merged) == 1 {
+		walk(merged[0], 0)
+	}
+	return lengths
+}
+
+func reverseBits(code uint32, n uint) uint32 {
+	var reversed uint32
+	for i := uint(0); i < n; i++ {
+		reversed = reversed<<1 | code&1
+		code >>= 1
+	}
+	return reversed
+}
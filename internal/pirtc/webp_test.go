@@ -0,0 +1,98 @@
+package pirtc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func randomNRGBA(width, height int, alpha bool) *image.NRGBA {
+	rng := rand.New(rand.NewSource(int64(width*height + 1)))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	rng.Read(img.Pix)
+	if !alpha {
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 0xff
+		}
+	}
+	return img
+}
+
+func flatNRGBA(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func gradientYCbCr(width, height int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = uint8(i * 7)
+	}
+	for i := range img.Cb {
+		img.Cb[i] = uint8(i * 3)
+		img.Cr[i] = uint8(255 - i*5)
+	}
+	return img
+}
+
+func TestEncodeWebp(t *testing.T) {
+	testCases := map[string]image.Image{
+		"Pixel":             randomNRGBA(1, 1, true),
+		"OddOpaque":         randomNRGBA(7, 3, false),
+		"OddAlpha":          randomNRGBA(33, 17, true),
+		"PredictorBlocks":   randomNRGBA(600, 2, true),
+		"Flat":              flatNRGBA(13, 11, color.NRGBA{R: 10, G: 200, B: 30, A: 0xff}),
+		"FlatTransparent":   flatNRGBA(5, 9, color.NRGBA{R: 1, G: 2, B: 3, A: 0x80}),
+		"YCbCr":             gradientYCbCr(31, 15),
+		"SubImage":          randomNRGBA(40, 40, true).SubImage(image.Rect(3, 5, 20, 26)),
+		"SubImageTopOrigin": randomNRGBA(9, 9, false).SubImage(image.Rect(0, 0, 9, 4)),
+	}
+
+	for name, img := range testCases {
+		img := img
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeWebp(&buf, img); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			bounds := img.Bounds()
+			if decoded.Bounds() != image.Rect(0, 0, bounds.Dx(), bounds.Dy()) {
+				t.Fatalf("Expected %v, got %v", bounds.Size(), decoded.Bounds())
+			}
+			expected := image.NewNRGBA(decoded.Bounds())
+			draw.Draw(expected, expected.Rect, img, bounds.Min, draw.Src)
+			for y := 0; y < bounds.Dy(); y++ {
+				for x := 0; x < bounds.Dx(); x++ {
+					want := expected.NRGBAAt(x, y)
+					got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					if want != got {
+						t.Fatalf("(%d, %d): expected %v, got %v", x, y, want, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeWebpTooLarge(t *testing.T) {
+	testCases := map[string]image.Rectangle{
+		"Empty": image.Rect(0, 0, 0, 10),
+		"Wide":  image.Rect(0, 0, vp8lMaxDimension+1, 1),
+	}
+	for name, rect := range testCases {
+		if err := encodeWebp(&bytes.Buffer{}, image.NewGray(rect)); err != errWebpTooLarge {
+			t.Errorf("%s: expected %v, got %v", name, errWebpTooLarge, err)
+		}
+	}
+}
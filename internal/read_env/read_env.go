@@ -18,6 +18,62 @@ type Env struct {
 	VideoPath string
 	ImagePath string
 	UnixPath string
+	// Overlay burns the timestamp, the name and the location in the video
+	// unless it is "off"
+	Overlay         string
+	TimestampFormat string
+	Timezone        string
+	LogoPath        string
+	// PrivacyMaskPath is the file keeping the privacy masks of the camera
+	PrivacyMaskPath string
+	// Rotation (0, 90, 180, 270), Flip (horizontal, vertical, both), Crop
+	// ("x,y,width,height") and Zoom correct the mounting of the camera
+	Rotation string
+	Flip     string
+	Crop     string
+	Zoom     string
+	// PtzPresetPath is the file keeping the PTZ presets of the camera
+	PtzPresetPath string
+	// DayNight switches the day and night profiles from the brightness of the
+	// scene when "on". The thresholds are average luma values from 0 to 255,
+	// DayNightHold is in seconds and the profiles are written as
+	// "fps=15,bitrate=250000,grayscale,denoise"
+	DayNight       string
+	NightThreshold string
+	DayThreshold   string
+	DayNightHold   string
+	DayProfile     string
+	NightProfile   string
+	// CameraControlsPath is the file keeping the controls of the camera set by
+	// the operator
+	CameraControlsPath string
+	// RecordingPreview uploads a poster frame and an animated GIF preview with
+	// every recording unless it is "off", PreviewWidth and PreviewFrames size
+	// the preview
+	RecordingPreview string
+	PreviewWidth     string
+	PreviewFrames    string
+	// SoundDetection starts a recording on loud sounds when "on". The
+	// thresholds are RMS and peak levels in dBFS, SoundMinDuration is in
+	// milliseconds and SoundHold in seconds.
+	SoundDetection     string
+	SoundThreshold     string
+	SoundPeakThreshold string
+	SoundMinDuration   string
+	SoundHold          string
+	// AudioHighPass is the cutoff of the high-pass filter of the microphone in
+	// Hz, AudioNoiseSuppression the attenuation of the noise in dB and
+	// AudioNoiseGate the level muting the sound in dBFS. AudioGain is "on" for
+	// the automatic gain, towards AudioGainTarget in dBFS.
+	AudioHighPass         string
+	AudioNoiseSuppression string
+	AudioNoiseGate        string
+	AudioGain             string
+	AudioGainTarget       string
+	// VideoPassthrough records the H.264 or the MJPEG of the camera without
+	// encoding it again when "h264" or "mjpeg", the H.264 is sent as it is
+	// too. "off" encodes the decoded frames.
+	VideoPassthrough string
 }
 
 func ReadEnv() (*Env, error) {
@@ -38,6 +94,64 @@ func ReadEnv() (*Env, error) {
 	videoPath := os.Getenv("VIDEO_PATH")
 	imagePath := os.Getenv("IMAGE_PATH")
 	unixPath := os.Getenv("UNIX_PATH")
+	overlay := os.Getenv("OVERLAY")
+	timestampFormat := os.Getenv("TIMESTAMP_FORMAT")
+	timezone := os.Getenv("TIMEZONE")
+	logoPath := os.Getenv("LOGO_PATH")
+	privacyMaskPath := os.Getenv("PRIVACY_MASK_PATH")
+	if privacyMaskPath == "" {
+		privacyMaskPath = "./privacy_masks.json"
+	}
+	rotation := os.Getenv("ROTATION")
+	flip := os.Getenv("FLIP")
+	crop := os.Getenv("CROP")
+	zoom := os.Getenv("ZOOM")
+	ptzPresetPath := os.Getenv("PTZ_PRESET_PATH")
+	if ptzPresetPath == "" {
+		ptzPresetPath = "./ptz_presets.json"
+	}
+	dayNight := os.Getenv("DAY_NIGHT")
+	if dayNight == "" {
+		dayNight = "off"
+	}
+	nightThreshold := os.Getenv("NIGHT_THRESHOLD")
+	dayThreshold := os.Getenv("DAY_THRESHOLD")
+	dayNightHold := os.Getenv("DAY_NIGHT_HOLD")
+	dayProfile := os.Getenv("DAY_PROFILE")
+	nightProfile, ok := os.LookupEnv("NIGHT_PROFILE")
+	if !ok {
+		nightProfile = "fps=15,bitrate=250000,grayscale,denoise"
+	}
+	cameraControlsPath := os.Getenv("CAMERA_CONTROLS_PATH")
+	if cameraControlsPath == "" {
+		cameraControlsPath = "./camera_controls.json"
+	}
+	recordingPreview := os.Getenv("RECORDING_PREVIEW")
+	if recordingPreview == "" {
+		recordingPreview = "on"
+	}
+	previewWidth := os.Getenv("PREVIEW_WIDTH")
+	previewFrames := os.Getenv("PREVIEW_FRAMES")
+	soundDetection := os.Getenv("SOUND_DETECTION")
+	if soundDetection == "" {
+		soundDetection = "off"
+	}
+	soundThreshold := os.Getenv("SOUND_THRESHOLD")
+	soundPeakThreshold := os.Getenv("SOUND_PEAK_THRESHOLD")
+	soundMinDuration := os.Getenv("SOUND_MIN_DURATION")
+	soundHold := os.Getenv("SOUND_HOLD")
+	audioHighPass := os.Getenv("AUDIO_HIGH_PASS")
+	audioNoiseSuppression := os.Getenv("AUDIO_NOISE_SUPPRESSION")
+	audioNoiseGate := os.Getenv("AUDIO_NOISE_GATE")
+	audioGain := os.Getenv("AUDIO_GAIN")
+	if audioGain == "" {
+		audioGain = "off"
+	}
+	audioGainTarget := os.Getenv("AUDIO_GAIN_TARGET")
+	videoPassthrough := os.Getenv("VIDEO_PASSTHROUGH")
+	if videoPassthrough == "" {
+		videoPassthrough = "off"
+	}
 	// check if api key exist in .env file
 	isApiKeyExist, err := checkKeyExist("API_KEY")
 	if err != nil {
@@ -72,6 +186,37 @@ func ReadEnv() (*Env, error) {
 		ApiKey:    apiKey,
 		ImagePath: imagePath,
 		UnixPath: unixPath,
+		Overlay:         overlay,
+		TimestampFormat: timestampFormat,
+		Timezone:        timezone,
+		LogoPath:        logoPath,
+		PrivacyMaskPath: privacyMaskPath,
+		Rotation:        rotation,
+		Flip:            flip,
+		Crop:            crop,
+		Zoom:            zoom,
+		PtzPresetPath:   ptzPresetPath,
+		DayNight:        dayNight,
+		NightThreshold:  nightThreshold,
+		DayThreshold:    dayThreshold,
+		DayNightHold:    dayNightHold,
+		DayProfile:      dayProfile,
+		NightProfile:    nightProfile,
+		CameraControlsPath: cameraControlsPath,
+		RecordingPreview:   recordingPreview,
+		PreviewWidth:       previewWidth,
+		PreviewFrames:      previewFrames,
+		SoundDetection:     soundDetection,
+		SoundThreshold:     soundThreshold,
+		SoundPeakThreshold: soundPeakThreshold,
+		SoundMinDuration:   soundMinDuration,
+		SoundHold:          soundHold,
+		AudioHighPass:         audioHighPass,
+		AudioNoiseSuppression: audioNoiseSuppression,
+		AudioNoiseGate:        audioNoiseGate,
+		AudioGain:             audioGain,
+		AudioGainTarget:       audioGainTarget,
+		VideoPassthrough:      videoPassthrough,
 	}
 	err = env.Save()
 	if err != nil {
@@ -91,6 +236,37 @@ func (env Env) toMap() map[string]string {
 	envMap["VIDEO_PATH"] = env.VideoPath
 	envMap["IMAGE_PATH"] = env.ImagePath
 	envMap["UNIX_PATH"] = env.UnixPath
+	envMap["OVERLAY"] = env.Overlay
+	envMap["TIMESTAMP_FORMAT"] = env.TimestampFormat
+	envMap["TIMEZONE"] = env.Timezone
+	envMap["LOGO_PATH"] = env.LogoPath
+	envMap["PRIVACY_MASK_PATH"] = env.PrivacyMaskPath
+	envMap["ROTATION"] = env.Rotation
+	envMap["FLIP"] = env.Flip
+	envMap["CROP"] = env.Crop
+	envMap["ZOOM"] = env.Zoom
+	envMap["PTZ_PRESET_PATH"] = env.PtzPresetPath
+	envMap["DAY_NIGHT"] = env.DayNight
+	envMap["NIGHT_THRESHOLD"] = env.NightThreshold
+	envMap["DAY_THRESHOLD"] = env.DayThreshold
+	envMap["DAY_NIGHT_HOLD"] = env.DayNightHold
+	envMap["DAY_PROFILE"] = env.DayProfile
+	envMap["NIGHT_PROFILE"] = env.NightProfile
+	envMap["CAMERA_CONTROLS_PATH"] = env.CameraControlsPath
+	envMap["RECORDING_PREVIEW"] = env.RecordingPreview
+	envMap["PREVIEW_WIDTH"] = env.PreviewWidth
+	envMap["PREVIEW_FRAMES"] = env.PreviewFrames
+	envMap["SOUND_DETECTION"] = env.SoundDetection
+	envMap["SOUND_THRESHOLD"] = env.SoundThreshold
+	envMap["SOUND_PEAK_THRESHOLD"] = env.SoundPeakThreshold
+	envMap["SOUND_MIN_DURATION"] = env.SoundMinDuration
+	envMap["SOUND_HOLD"] = env.SoundHold
+	envMap["AUDIO_HIGH_PASS"] = env.AudioHighPass
+	envMap["AUDIO_NOISE_SUPPRESSION"] = env.AudioNoiseSuppression
+	envMap["AUDIO_NOISE_GATE"] = env.AudioNoiseGate
+	envMap["AUDIO_GAIN"] = env.AudioGain
+	envMap["AUDIO_GAIN_TARGET"] = env.AudioGainTarget
+	envMap["VIDEO_PASSTHROUGH"] = env.VideoPassthrough
 	return envMap
 }
 
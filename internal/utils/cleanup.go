@@ -8,8 +8,9 @@ import (
 	"time"
 )
 
-
-func deleteFiles(folderPath string) error {
+// deleteFiles removes the files of folderPath with remove, a file already
+// removed with another one is skipped
+func deleteFiles(folderPath string, remove func(path string) error) error {
 	dirEntries, err := os.ReadDir(folderPath)
 	if err != nil {
 		return err
@@ -23,7 +24,7 @@ func deleteFiles(folderPath string) error {
 	for _, entry := range dirEntries {
 		if !entry.IsDir() {
 			filePath := filepath.Join(folderPath, entry.Name())
-			if err := os.Remove(filePath); err != nil {
+			if err := remove(filePath); err != nil && !os.IsNotExist(err) {
 				return err
 			}
 			fmt.Println("Deleted:", filePath)
@@ -33,7 +34,12 @@ func deleteFiles(folderPath string) error {
 
 }
 
-func RunPeriodicFileCleanup(folderPaths []string,hour int, stopChan <-chan struct{}){
+// RunPeriodicFileCleanup removes the files of folderPaths every hour hours
+// with remove, nil removes them with os.Remove
+func RunPeriodicFileCleanup(folderPaths []string,hour int, remove func(path string) error, stopChan <-chan struct{}){
+	if remove == nil {
+		remove = os.Remove
+	}
 	interval := time.Duration(hour) * time.Hour
 	
 	ticker := time.NewTicker(interval)
@@ -41,7 +47,7 @@ func RunPeriodicFileCleanup(folderPaths []string,hour int, stopChan <-chan struc
 		select {
 		case <-ticker.C:
 			for _, folderPath := range folderPaths {
-				if err := deleteFiles(folderPath); err != nil {
+				if err := deleteFiles(folderPath, remove); err != nil {
 					fmt.Printf("Error deleting files in %s: %v\n", folderPath, err)
 				}
 			}
@@ -3,7 +3,11 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
@@ -34,7 +38,25 @@ func GetCurrentTimeStr() string {
 	return timeString
 }
 
+// LoadImage decodes a PNG or JPEG file
+func LoadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
 func UploadImage(uri string, path string, apiKey string) error {
+	return UploadImageWithFields(uri, path, apiKey, nil)
+}
+
+// UploadImageWithFields uploads an image with metadata fields, like the video a
+// preview belongs to
+func UploadImageWithFields(uri string, path string, apiKey string, fields map[string]string) error {
 	file, err := os.Open(path)
 	if err != nil {
 		return err
@@ -44,6 +66,12 @@ func UploadImage(uri string, path string, apiKey string) error {
 	requestBody := &bytes.Buffer{}
 	writer := multipart.NewWriter(requestBody)
 
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+
 	part, err := createFormFileImage(writer, "file", filepath.Base(path))
 	if err != nil {
 		return err
@@ -128,14 +156,22 @@ func UploadVideo(uri string, path string, camUuid string, apiKey string) error {
 func createFormFileImage(w *multipart.Writer, fieldname string, filename string) (io.Writer, error) {
 	h := make(textproto.MIMEHeader)
 	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s";  filename="%s"`, escapeQuotes(fieldname), escapeQuotes(filename)))
-	h.Set("Content-Type", "image/jpeg")
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "image/jpeg"
+	}
+	h.Set("Content-Type", contentType)
 	return w.CreatePart(h)
 }
 
 func createFormFileVideo(w *multipart.Writer, fieldname string, filename string) (io.Writer, error) {
 	h := make(textproto.MIMEHeader)
 	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s";  filename="%s"`, escapeQuotes(fieldname), escapeQuotes(filename)))
-	h.Set("Content-Type", "video/webm")
+	contentType := "video/webm"
+	if filepath.Ext(filename) == ".avi" {
+		contentType = "video/x-msvideo"
+	}
+	h.Set("Content-Type", contentType)
 	return w.CreatePart(h)
 }
 